@@ -0,0 +1,116 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"go/token"
+	"io"
+	"io/ioutil"
+	"os"
+
+	"github.com/BenLubar/coolc/internal/ast"
+)
+
+// documentation runs `coolc doc`, which writes documentation for the basic
+// classes and the classes in the given files to out, or to the file given
+// by -o.
+func documentation(args []string, out, errors io.Writer) int {
+	var opt ast.Options
+
+	opt.Errors = errors
+	opt.Library = true
+
+	// warnings are for people compiling the code, not reading about it.
+	opt.Warnings = make(map[string]bool)
+	for _, w := range ast.Warnings {
+		opt.Warnings[w.Name] = false
+	}
+
+	flagSet := flag.NewFlagSet("coolc doc", flag.ContinueOnError)
+	flagSet.SetOutput(errors)
+	flagSet.Usage = func() {
+		fmt.Fprintln(errors, "Usage: coolc doc [ -format html|markdown ] [ -o fileout ] [ file1.cool file2.cool ... filen.cool ]")
+		flagSet.PrintDefaults()
+	}
+	flagFormat := flagSet.String("format", "html", "format of the documentation: html or markdown")
+	flagOutput := flagSet.String("o", "", "output filename (default standard output)")
+	flagSet.BoolVar(&opt.Coroutine, "coroutine", false, "include the classes from coroutine.cool")
+	flagSet.StringVar(&opt.Diagnostics, "diagnostics", "text", "format of error messages: text, pretty (with source code), or json")
+
+	if err := flagSet.Parse(args[1:]); err != nil {
+		return 1
+	}
+
+	switch opt.Diagnostics {
+	case "text", "pretty", "json":
+	default:
+		fmt.Fprintf(errors, "invalid value %q for flag -diagnostics\n", opt.Diagnostics)
+		flagSet.Usage()
+		return 1
+	}
+
+	switch *flagFormat {
+	case "html", "markdown":
+	default:
+		fmt.Fprintf(errors, "invalid value %q for flag -format\n", *flagFormat)
+		flagSet.Usage()
+		return 1
+	}
+
+	fset := token.NewFileSet()
+
+	var haveErrors bool
+	var prog ast.Program
+
+	{
+		f := fset.AddFile("basic.cool", -1, len(basicCool))
+		f.SetLinesForContent(basicCool)
+		prog.MarkBuiltin(f)
+
+		haveErrors = prog.Parse(f, opt, bytes.NewReader(basicCool))
+	}
+
+	if opt.Coroutine {
+		f := fset.AddFile("coroutine.cool", -1, len(coroutineCool))
+		f.SetLinesForContent(coroutineCool)
+		prog.MarkBuiltin(f)
+
+		haveErrors = prog.Parse(f, opt, bytes.NewReader(coroutineCool)) || haveErrors
+	}
+
+	for _, name := range flagSet.Args() {
+		b, err := ioutil.ReadFile(name)
+		if err != nil {
+			ast.ReportError(opt, "read", fmt.Sprintf("%s: %v", name, err))
+			return 2
+		}
+
+		f := fset.AddFile(name, -1, len(b))
+		f.SetLinesForContent(b)
+
+		haveErrors = prog.Parse(f, opt, bytes.NewReader(b)) || haveErrors
+	}
+
+	if prog.Semant(opt, fset) || haveErrors {
+		return 2
+	}
+
+	if *flagOutput != "" {
+		f, err := os.Create(*flagOutput)
+		if err != nil {
+			ast.ReportError(opt, "write", fmt.Sprintf("%s: %v", *flagOutput, err))
+			return 2
+		}
+		defer f.Close()
+
+		out = f
+	}
+
+	if err := prog.WriteDoc(out, *flagFormat); err != nil {
+		ast.ReportError(opt, "write", fmt.Sprintf("error writing documentation: %v", err))
+		return 2
+	}
+
+	return 0
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const docTestSource = `/** A shape. */
+class Shape() {
+	/** The area of the shape. */
+	def area() : Int = 0;
+}
+
+class Square(var side : Int) extends Shape() {
+	/**
+	 * The number of sides.
+	 */
+	var sides : Int = 4;
+
+	override def area() : Int = side * side;
+}
+
+class Cube(var s : Int) extends Square(s) {
+	/** Cubes have volume, not area. */
+	override def area() : Int = 6 * s * s;
+}
+`
+
+func runDoc(t *testing.T, args ...string) string {
+	dir, err := ioutil.TempDir("", "coolc-doc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	name := filepath.Join(dir, "shapes.cool")
+	if err = ioutil.WriteFile(name, []byte(docTestSource), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var out, errors bytes.Buffer
+	if exit := documentation(append(append([]string{"doc"}, args...), name), &out, &errors); exit != 0 {
+		t.Errorf("exit status was unexpected: %v", exit)
+	}
+	if errors.Len() != 0 {
+		t.Errorf("unexpected output on standard error:\n%s", errors.Bytes())
+	}
+
+	return out.String()
+}
+
+func TestDocMarkdown(t *testing.T) {
+	out := runDoc(t, "-format", "markdown")
+
+	for _, expected := range []string{
+		"- [Any](#class-any)\n  - [IO](#class-io)\n",
+		"  - [Shape](#class-shape)\n    - [Square](#class-square)\n      - [Cube](#class-cube)\n",
+		"## class Shape\n\n```cool\nclass Shape extends Any\n```\n",
+		"A shape.\n",
+		"Direct subclasses: [Square](#class-square).\n",
+		"```cool\nclass Square(var side : Int) extends Shape\n```\n",
+		"Inherits from [Any](#class-any) > [Shape](#class-shape).\n",
+		"```cool\nvar sides : Int\n```\n\nThe number of sides.\n",
+		"```cool\ndef area() : Int\n```\n\nThe area of the shape.\n\nOverridden in [Square](#class-square), [Cube](#class-cube).\n",
+		// Square.area has no doc comment, so it uses the one from Shape.
+		"```cool\noverride def area() : Int\n```\n\nThe area of the shape.\n\nOverrides `area` in [Shape](#class-shape).\n",
+		"Cubes have volume, not area.\n\nOverrides `area` in [Square](#class-square).\n",
+		"- From [Any](#class-any): `toString`, `equals`\n",
+		// the basic classes are documented too.
+		"## class IO\n",
+		"Print the argument (without quotes) to stdout and return itself\n",
+	} {
+		if !strings.Contains(out, expected) {
+			t.Errorf("expected %q in output:\n%s", expected, out)
+		}
+	}
+}
+
+func TestDocHTML(t *testing.T) {
+	out := runDoc(t)
+
+	for _, expected := range []string{
+		"<!DOCTYPE html>",
+		`<li><a href="#class-shape">Shape</a><ul>` + "\n" + `<li><a href="#class-square">Square</a><ul>`,
+		`<h2 id="class-square">Square</h2>`,
+		`<pre>class Square(var side : Int) extends Shape</pre>`,
+		`<dt id="class-cube.area"><code>override def area() : Int</code></dt>`,
+		`Overrides <a href="#class-square.area"><code>area</code></a> in <a href="#class-square">Square</a>.`,
+		`<p>From <a href="#class-any">Any</a>: <a href="#class-any.toString"><code>toString</code></a>, <a href="#class-any.equals"><code>equals</code></a></p>`,
+	} {
+		if !strings.Contains(out, expected) {
+			t.Errorf("expected %q in output:\n%s", expected, out)
+		}
+	}
+}
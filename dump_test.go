@@ -0,0 +1,66 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+type dumpTestNode map[string]interface{}
+
+// find returns every node in the tree with the given kind.
+func (n dumpTestNode) find(kind string) []dumpTestNode {
+	var found []dumpTestNode
+	var walk func(interface{})
+	walk = func(v interface{}) {
+		switch v := v.(type) {
+		case map[string]interface{}:
+			if v["kind"] == kind {
+				found = append(found, v)
+			}
+			for _, child := range v {
+				walk(child)
+			}
+		case []interface{}:
+			for _, child := range v {
+				walk(child)
+			}
+		}
+	}
+	walk(map[string]interface{}(n))
+	return found
+}
+
+func TestDumpASTOptimized(t *testing.T) {
+	out, exit := runFile(t, "testdata/dump0000.cool", "-dump-ast=optimized")
+	if exit != 0 {
+		t.Fatalf("exit status was unexpected: %v\n%s", exit, out)
+	}
+
+	var dump dumpTestNode
+	if err := json.Unmarshal([]byte(out), &dump); err != nil {
+		t.Fatal(err)
+	}
+	if dump["stage"] != "optimized" {
+		t.Errorf("expected stage optimized, not %v", dump["stage"])
+	}
+
+	var main dumpTestNode
+	for _, c := range dump.find("class") {
+		if c["name"] == "Main" {
+			main = c
+		}
+	}
+	if main == nil {
+		t.Fatal("no Main class in dump")
+	}
+
+	inlined := make(map[interface{}]int)
+	for _, v := range main.find("var") {
+		if m, ok := v["inlined"]; ok {
+			inlined[m]++
+		}
+	}
+	if inlined["Counter.next"] != 2 || inlined["Main.describe"] != 2 || inlined["Counter.Counter"] != 1 {
+		t.Errorf("expected Counter.next and Main.describe to be inlined twice and the constructor once, not %v", inlined)
+	}
+}
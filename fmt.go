@@ -0,0 +1,117 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"go/token"
+	"io"
+	"io/ioutil"
+	"os"
+
+	"github.com/BenLubar/coolc/internal/ast"
+)
+
+// format runs `coolc fmt`, which rewrites Cool source files in the canonical
+// layout. Without -w or -l, the formatted code is written to out. With no
+// files, the source code is read from in.
+func format(args []string, in io.Reader, out, errors io.Writer) int {
+	var opt ast.Options
+
+	opt.Errors = errors
+
+	flagSet := flag.NewFlagSet("coolc fmt", flag.ContinueOnError)
+	flagSet.SetOutput(errors)
+	flagSet.Usage = func() {
+		fmt.Fprintln(errors, "Usage: coolc fmt [ -w ] [ -l ] [ file1.cool file2.cool ... filen.cool ]")
+		flagSet.PrintDefaults()
+	}
+	flagWrite := flagSet.Bool("w", false, "write the result to the file instead of standard output")
+	flagList := flagSet.Bool("l", false, "list files whose formatting differs from the canonical layout")
+	flagSet.StringVar(&opt.Diagnostics, "diagnostics", "text", "format of error messages: text, pretty (with source code), or json")
+
+	if err := flagSet.Parse(args[1:]); err != nil {
+		return 1
+	}
+
+	switch opt.Diagnostics {
+	case "text", "pretty", "json":
+	default:
+		fmt.Fprintf(errors, "invalid value %q for flag -diagnostics\n", opt.Diagnostics)
+		flagSet.Usage()
+		return 1
+	}
+
+	fset := token.NewFileSet()
+
+	if flagSet.NArg() == 0 {
+		if *flagWrite {
+			fmt.Fprintln(errors, "cannot use -w with standard input")
+			return 1
+		}
+
+		src, err := ioutil.ReadAll(in)
+		if err != nil {
+			ast.ReportError(opt, "read", fmt.Sprintf("<stdin>: %v", err))
+			return 2
+		}
+
+		formatted, haveErrors := ast.Format(opt, fset, "<stdin>", src)
+		if haveErrors {
+			return 2
+		}
+
+		if *flagList {
+			if !bytes.Equal(src, formatted) {
+				fmt.Fprintln(out, "<stdin>")
+			}
+		} else {
+			_, _ = out.Write(formatted)
+		}
+
+		return 0
+	}
+
+	status := 0
+
+	for _, name := range flagSet.Args() {
+		src, err := ioutil.ReadFile(name)
+		if err != nil {
+			ast.ReportError(opt, "read", fmt.Sprintf("%s: %v", name, err))
+			status = 2
+			continue
+		}
+
+		formatted, haveErrors := ast.Format(opt, fset, name, src)
+		if haveErrors {
+			status = 2
+			continue
+		}
+
+		changed := !bytes.Equal(src, formatted)
+
+		if *flagList && changed {
+			fmt.Fprintln(out, name)
+		}
+
+		if *flagWrite {
+			if changed {
+				fi, err := os.Stat(name)
+				if err != nil {
+					ast.ReportError(opt, "write", fmt.Sprintf("%s: %v", name, err))
+					status = 2
+					continue
+				}
+
+				if err := ioutil.WriteFile(name, formatted, fi.Mode().Perm()); err != nil {
+					ast.ReportError(opt, "write", fmt.Sprintf("%s: %v", name, err))
+					status = 2
+				}
+			}
+		} else if !*flagList {
+			_, _ = out.Write(formatted)
+		}
+	}
+
+	return status
+}
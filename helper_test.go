@@ -2,11 +2,13 @@ package main
 
 import (
 	"bytes"
+	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"regexp"
 	"strconv"
 	"strings"
 	"testing"
@@ -33,6 +35,68 @@ func runCompiler(args []string) (out []byte, exit int) {
 	return
 }
 
+// runSource runs the compiler on source with the given arguments. It returns
+// what was written to standard output, including by a program started with
+// -run, followed by the compiler's diagnostics, and the exit status.
+func runSource(t testing.TB, source string, args ...string) (string, int) {
+	dir, err := ioutil.TempDir("", "coolc-source")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	name := filepath.Join(dir, "main.cool")
+	if err = ioutil.WriteFile(name, []byte(source), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	return runFile(t, name, args...)
+}
+
+// compileSource compiles source with the given arguments and returns the
+// assembly.
+func compileSource(t testing.TB, source string, args ...string) string {
+	dir, err := ioutil.TempDir("", "coolc-asm")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	output := filepath.Join(dir, "main.s")
+	out, exit := runSource(t, source, append([]string{"-o", output}, args...)...)
+	if exit != 0 {
+		t.Fatalf("exit status was unexpected: %v\n%s", exit, out)
+	}
+
+	b, err := ioutil.ReadFile(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+// runFile runs the compiler on the source file name like runSource.
+func runFile(t testing.TB, name string, args ...string) (string, int) {
+	// the program inherits the compiler's standard output.
+	stdout, err := ioutil.TempFile("", "coolc-stdout")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(stdout.Name())
+	defer stdout.Close()
+
+	realStdout := os.Stdout
+	os.Stdout = stdout
+	out, exit := runCompiler(append(append([]string{"coolc"}, args...), name))
+	os.Stdout = realStdout
+
+	b, err := ioutil.ReadFile(stdout.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b) + string(out), exit
+}
+
 func testBad(t testing.TB, prefix string, args ...string) {
 	prefix = filepath.Join("testdata", prefix)
 	expected := prefix + ".expected"
@@ -53,6 +117,176 @@ func testBad(t testing.TB, prefix string, args ...string) {
 	}
 }
 
+// testBadJSON checks that the JSON diagnostics for a bad program contain the
+// same information as the text diagnostics in the .expected file.
+func testBadJSON(t testing.TB, prefix string, args ...string) {
+	prefix = filepath.Join("testdata", prefix)
+	expected := prefix + ".expected"
+	source := prefix + ".cool"
+
+	expect, err := ioutil.ReadFile(expected)
+	if err != nil {
+		t.Fatalf("error reading %q: %v", expected, err)
+	}
+
+	out, exit := runCompiler(append(append([]string{"coolc", "-o", os.DevNull, "-diagnostics=json"}, args...), source))
+	if exit != 2 {
+		t.Errorf("exit status for %q was unexpected: %v", source, exit)
+	}
+
+	type position struct {
+		File   string
+		Offset int
+		Line   int
+		Column int
+	}
+	type diagnostic struct {
+		Severity string
+		Code     string
+		Pos      *position
+		End      *position
+		Message  string
+		Notes    []*diagnostic
+	}
+
+	format := func(buf *bytes.Buffer, d *diagnostic, pattern string) {
+		if d.Pos == nil {
+			buf.WriteString("-")
+		} else {
+			fmt.Fprintf(buf, "%s:%d:%d", d.Pos.File, d.Pos.Line, d.Pos.Column)
+		}
+		fmt.Fprintf(buf, pattern, d.Message)
+
+		if d.End != nil && (d.Pos == nil || d.End.Offset <= d.Pos.Offset) {
+			t.Errorf("for %q: end position %+v is not after start position %+v", source, d.End, d.Pos)
+		}
+	}
+
+	var text bytes.Buffer
+	dec := json.NewDecoder(bytes.NewReader(out))
+	for dec.More() {
+		var d diagnostic
+		if err := dec.Decode(&d); err != nil {
+			t.Fatalf("for %q: error decoding diagnostics: %v\n%s", source, err, out)
+		}
+
+		if d.Severity != "error" || d.Code == "" {
+			t.Errorf("for %q: unexpected severity %q or code %q", source, d.Severity, d.Code)
+		}
+
+		format(&text, &d, ": %s\n")
+		for _, n := range d.Notes {
+			if n.Severity != "note" {
+				t.Errorf("for %q: unexpected note severity %q", source, n.Severity)
+			}
+			format(&text, n, ": (%s)\n")
+		}
+	}
+
+	if !bytes.Equal(expect, text.Bytes()) {
+		t.Errorf("for %q:\nExpected output:\n%s\nActual output:\n%s", source, expect, out)
+	}
+}
+
+// testBadPretty compares the output of -diagnostics=pretty for a bad program
+// with the .pretty file.
+func testBadPretty(t testing.TB, prefix string, args ...string) {
+	prefix = filepath.Join("testdata", prefix)
+	expected := prefix + ".pretty"
+	source := prefix + ".cool"
+
+	expect, err := ioutil.ReadFile(expected)
+	if err != nil {
+		t.Fatalf("error reading %q: %v", expected, err)
+	}
+
+	out, exit := runCompiler(append(append([]string{"coolc", "-o", os.DevNull, "-diagnostics=pretty"}, args...), source))
+	if exit != 2 {
+		t.Errorf("exit status for %q was unexpected: %v", source, exit)
+	}
+
+	if !bytes.Equal(expect, out) {
+		t.Errorf("for %q:\nExpected output:\n%s\nActual output:\n%s", source, expect, out)
+	}
+}
+
+// testWarn checks that a program with warnings compiles and that the warnings
+// match the .expected file.
+func testWarn(t testing.TB, prefix string, args ...string) {
+	prefix = filepath.Join("testdata", prefix)
+	expected := prefix + ".expected"
+	source := prefix + ".cool"
+
+	expect, err := ioutil.ReadFile(expected)
+	if err != nil {
+		t.Fatalf("error reading %q: %v", expected, err)
+	}
+
+	out, exit := runCompiler(append(append([]string{"coolc", "-o", os.DevNull}, args...), source))
+	if exit != 0 {
+		t.Errorf("exit status for %q was unexpected: %v", source, exit)
+	}
+
+	if !bytes.Equal(expect, out) {
+		t.Errorf("for %q:\nExpected output:\n%s\nActual output:\n%s", source, expect, out)
+	}
+}
+
+// testWarnError checks that -Werror turns the warnings in the .expected file
+// into errors.
+func testWarnError(t testing.TB, prefix string, args ...string) {
+	prefix = filepath.Join("testdata", prefix)
+	expected := prefix + ".expected"
+	source := prefix + ".cool"
+
+	expect, err := ioutil.ReadFile(expected)
+	if err != nil {
+		t.Fatalf("error reading %q: %v", expected, err)
+	}
+	expect = bytes.Replace(expect, []byte(": warning: "), []byte(": "), -1)
+	expect = bytes.Replace(expect, []byte(" [-W"), []byte(" [-Werror="), -1)
+
+	out, exit := runCompiler(append(append([]string{"coolc", "-o", os.DevNull, "-Werror"}, args...), source))
+	if exit != 2 {
+		t.Errorf("exit status for %q was unexpected: %v", source, exit)
+	}
+
+	if !bytes.Equal(expect, out) {
+		t.Errorf("for %q:\nExpected output:\n%s\nActual output:\n%s", source, expect, out)
+	}
+}
+
+func testFmt(t testing.TB, prefix string) {
+	prefix = filepath.Join("testdata", prefix)
+	expected := prefix + ".expected"
+	source := prefix + ".cool"
+
+	expect, err := ioutil.ReadFile(expected)
+	if err != nil {
+		t.Fatalf("error reading %q: %v", expected, err)
+	}
+
+	var out, errors bytes.Buffer
+	if exit := format([]string{"fmt", source}, nil, &out, &errors); exit != 0 {
+		t.Errorf("exit status for %q was unexpected: %v\n%s", source, exit, errors.Bytes())
+	}
+
+	if !bytes.Equal(expect, out.Bytes()) {
+		t.Errorf("for %q:\nExpected output:\n%s\nActual output:\n%s", source, expect, out.Bytes())
+	}
+
+	// formatting the formatted code must not change it.
+	out.Reset()
+	errors.Reset()
+	if exit := format([]string{"fmt"}, bytes.NewReader(expect), &out, &errors); exit != 0 {
+		t.Errorf("exit status for %q was unexpected: %v\n%s", expected, exit, errors.Bytes())
+	}
+
+	if !bytes.Equal(expect, out.Bytes()) {
+		t.Errorf("formatting %q again changed it:\n%s", expected, out.Bytes())
+	}
+}
+
 func testGood(t testing.TB, prefix, lib string, args ...string) {
 	prefix = filepath.Join("testdata", prefix)
 	expected := prefix + ".expected"
@@ -119,3 +353,243 @@ func benchmarkGood(b *testing.B, prefix, lib string, args ...string) {
 		b.Errorf("error running %q: %v", prefix, err)
 	}
 }
+
+func testExe(t testing.TB, prefix string, args ...string) {
+	prefix = filepath.Join("testdata", prefix)
+	expected := prefix + ".expected"
+	source := prefix + ".cool"
+
+	expect, err := ioutil.ReadFile(expected)
+	if err != nil {
+		t.Fatalf("error reading %q: %v", expected, err)
+	}
+
+	dir, err := ioutil.TempDir("", "coolc-exe")
+	if err != nil {
+		t.Fatalf("error creating output directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	exe := filepath.Join(dir, "main")
+
+	if out, exit := runCompiler(append(append([]string{"coolc", "-exe", "-o", exe}, args...), source)); exit != 0 {
+		t.Fatalf("unexpected compiler exit status for %q: %v\n%s", source, exit, out)
+	} else if len(out) != 0 {
+		t.Errorf("unexpected compiler ouput for %q:\n%s", source, out)
+	}
+
+	out, err := exec.Command(exe).CombinedOutput()
+	if err != nil {
+		t.Errorf("error running %q: %v", prefix, err)
+	}
+
+	if !bytes.Equal(expect, out) {
+		t.Errorf("for %q:\nExpected output:\n%s\nActual output:\n%s", source, expect, out)
+	}
+}
+
+func testRun(t testing.TB, prefix string, args ...string) {
+	prefix = filepath.Join("testdata", prefix)
+	expected := prefix + ".expected"
+	source := prefix + ".cool"
+
+	expect, err := ioutil.ReadFile(expected)
+	if err != nil {
+		t.Fatalf("error reading %q: %v", expected, err)
+	}
+
+	// the program inherits the compiler's standard output.
+	stdout, err := ioutil.TempFile("", "coolc-run")
+	if err != nil {
+		t.Fatalf("error creating output file: %v", err)
+	}
+	defer os.Remove(stdout.Name())
+	defer stdout.Close()
+
+	realStdout := os.Stdout
+	os.Stdout = stdout
+	out, exit := runCompiler(append(append([]string{"coolc"}, args...), source))
+	os.Stdout = realStdout
+
+	if exit != 0 {
+		t.Errorf("unexpected exit status for %q: %v\n%s", source, exit, out)
+	} else if len(out) != 0 {
+		t.Errorf("unexpected compiler ouput for %q:\n%s", source, out)
+	}
+
+	out, err = ioutil.ReadFile(stdout.Name())
+	if err != nil {
+		t.Fatalf("error reading output of %q: %v", source, err)
+	}
+
+	if !bytes.Equal(expect, out) {
+		t.Errorf("for %q:\nExpected output:\n%s\nActual output:\n%s", source, expect, out)
+	}
+}
+
+// optArgs are the ways testOpt runs each program: with each backend, and
+// with each optimization turned off in turn.
+var optArgs = [][]string{
+	{"-interp"},
+	{"-run"},
+	{"-run", "-coroutine"},
+	{"-run", "-opt-dispatch=false"},
+	{"-run", "-opt-fold=false"},
+	{"-run", "-opt-inline=false"},
+	{"-run", "-opt-licm=false"},
+	{"-run", "-opt-escape=false"},
+	{"-run", "-opt-int=false"},
+	{"-run", "-opt-dead=false"},
+	{"-run", "-opt-jump=false"},
+	{"-run", "-opt-unused=false"},
+	{"-run", "-opt-regs=false"},
+	{"-run", "-opt-peephole=false"},
+}
+
+// testOpt checks that a program written to exercise the optimizer gives the
+// output in the .expected file, followed by any runtime error, with each of
+// optArgs. The exit status must be the same each time.
+func testOpt(t testing.TB, prefix string) {
+	prefix = filepath.Join("testdata", prefix)
+	expected := prefix + ".expected"
+	source := prefix + ".cool"
+
+	expect, err := ioutil.ReadFile(expected)
+	if err != nil {
+		t.Fatalf("error reading %q: %v", expected, err)
+	}
+
+	first := -1
+	for _, args := range optArgs {
+		out, exit := runFile(t, source, args...)
+		if first == -1 {
+			first = exit
+		} else if exit != first {
+			t.Errorf("%v: exit status for %q was %v, not %v", args, source, exit, first)
+		}
+
+		if out != string(expect) {
+			t.Errorf("%v: for %q:\nExpected output:\n%s\nActual output:\n%s", args, source, expect, out)
+		}
+	}
+}
+
+// testOptReport compares the output of -opt-report for a program with the
+// .report file.
+func testOptReport(t testing.TB, prefix string) {
+	prefix = filepath.Join("testdata", prefix)
+	expected := prefix + ".report"
+	source := prefix + ".cool"
+
+	expect, err := ioutil.ReadFile(expected)
+	if err != nil {
+		t.Fatalf("error reading %q: %v", expected, err)
+	}
+
+	out, exit := runCompiler([]string{"coolc", "-opt-report", "-o", os.DevNull, source})
+	if exit != 0 {
+		t.Errorf("exit status for %q was unexpected: %v", source, exit)
+	}
+
+	if !bytes.Equal(expect, out) {
+		t.Errorf("for %q:\nExpected output:\n%s\nActual output:\n%s", source, expect, out)
+	}
+}
+
+// testOptPrint compares the output of -print=optimized for a program with the
+// .print file, and checks that the output compiles.
+func testOptPrint(t testing.TB, prefix string) {
+	prefix = filepath.Join("testdata", prefix)
+	expected := prefix + ".print"
+	source := prefix + ".cool"
+
+	expect, err := ioutil.ReadFile(expected)
+	if err != nil {
+		t.Fatalf("error reading %q: %v", expected, err)
+	}
+
+	out, exit := runFile(t, source, "-print=optimized")
+	if exit != 0 {
+		t.Errorf("exit status for %q was unexpected: %v", source, exit)
+	}
+
+	if out != string(expect) {
+		t.Errorf("for %q:\nExpected output:\n%s\nActual output:\n%s", source, expect, out)
+	}
+
+	if diag, exit := runSource(t, out, "-o", os.DevNull); exit != 0 {
+		t.Errorf("printed %q does not compile: %v\n%s", source, exit, diag)
+	}
+}
+
+// testDump checks the -dump-ast output for the given stage against the
+// .parsed.json or .checked.json file. Only the classes declared in the test's
+// source file are compared, so changes to basic.cool don't affect the test.
+func testDump(t testing.TB, prefix, stage string) {
+	prefix = filepath.Join("testdata", prefix)
+	expected := prefix + "." + stage + ".json"
+	source := prefix + ".cool"
+
+	expect, err := ioutil.ReadFile(expected)
+	if err != nil {
+		t.Fatalf("error reading %q: %v", expected, err)
+	}
+
+	out, exit := runFile(t, source, "-dump-ast="+stage)
+	if exit != 0 {
+		t.Fatalf("exit status for %q was unexpected: %v\n%s", source, exit, out)
+	}
+
+	actual, err := dumpClasses([]byte(out), source)
+	if err != nil {
+		t.Fatalf("for %q: %v", source, err)
+	}
+
+	if !bytes.Equal(expect, actual) {
+		t.Errorf("for %q:\nExpected output:\n%s\nActual output:\n%s", source, expect, actual)
+	}
+}
+
+// dumpClasses returns the classes in a -dump-ast dump that are declared in
+// the named source file, indented and one after another. Variable IDs are
+// renumbered in the order they appear, so they don't depend on the number of
+// variables in basic.cool.
+func dumpClasses(dump []byte, source string) ([]byte, error) {
+	var program struct {
+		Classes []json.RawMessage `json:"classes"`
+	}
+	if err := json.Unmarshal(dump, &program); err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	for _, c := range program.Classes {
+		var class struct {
+			Pos string `json:"pos"`
+		}
+		if err := json.Unmarshal(c, &class); err != nil {
+			return nil, err
+		}
+		if !strings.HasPrefix(class.Pos, source+":") {
+			continue
+		}
+
+		if err := json.Indent(&buf, c, "", "\t"); err != nil {
+			return nil, err
+		}
+		buf.WriteByte('\n')
+	}
+
+	ids := make(map[string]string)
+	return dumpIDPattern.ReplaceAllFunc(buf.Bytes(), func(b []byte) []byte {
+		m := dumpIDPattern.FindSubmatch(b)
+		id, ok := ids[string(m[2])]
+		if !ok {
+			id = strconv.Itoa(len(ids) + 1)
+			ids[string(m[2])] = id
+		}
+		return append(m[1], id...)
+	}), nil
+}
+
+var dumpIDPattern = regexp.MustCompile(`("(?:id|object|attribute)": )([0-9]+)`)
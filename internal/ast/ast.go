@@ -2,7 +2,6 @@ package ast
 
 import (
 	"go/token"
-	"strconv"
 )
 
 // Program is a set of classes with a generated main method that is called by
@@ -20,6 +19,35 @@ type Program struct {
 	Main Expr
 
 	classMap map[string]*Class
+
+	// sources is the source code of each file given to Parse by file
+	// name. It is used to show the code an error message is about.
+	sources map[string][]byte
+	// builtin is the set of files that are part of the standard library.
+	builtin map[*token.File]bool
+	// haveSyntaxErrors is true if Parse reported any errors.
+	haveSyntaxErrors bool
+	// idents is every identifier read by the lexer in each file, in the
+	// order they appear. It is used to find identifiers by position.
+	idents map[*token.File][]*Ident
+	// receivers is the static type of the receiver of each method call
+	// by the identifier of the method name.
+	receivers map[*Ident]*Class
+	// exprs is the source code covered by each expression in each file,
+	// in the order the parser built them. It is used to find expressions
+	// by position.
+	exprs map[*token.File][]exprSpan
+	// types is the static type of each expression that Semant checked.
+	types map[Expr]*Class
+	// live is the set of classes the optimized program can instantiate,
+	// and reached is the set of methods it can call. Both are nil if
+	// -opt-dead is off.
+	live    map[*Class]bool
+	reached map[*Method]bool
+	// borrowed is, for each method, whether it only reads its receiver
+	// and each of its arguments without keeping them. It is nil if
+	// -opt-escape is off.
+	borrowed map[*Method][]bool
 }
 
 // Class is a Cool class as defined in CoolAid section 3.
@@ -32,6 +60,9 @@ type Class struct {
 	// Extends is the extends declaration, as defined in section 3.2 of
 	// CoolAid, or a generated one for classes implicitly extending Any.
 	Extends *Extends
+	// Doc is the text of the /** */ comment before the class declaration,
+	// or an empty string if there is none.
+	Doc string
 	// Features is the set of features, as defined in section 3.1 of
 	// CoolAid, in the order they appear in source code. Features also
 	// contains generated features for the formals of the class and a
@@ -81,20 +112,6 @@ type Formal struct {
 	Name *Ident
 	// Type is the declared type of the argument.
 	Type *Ident
-
-	// Offset is the offset from the base pointer of the temporary used for
-	// this variable.
-	Offset int
-}
-
-// Base implements Object.
-func (a *Formal) Base(this int) string {
-	return "%ebp"
-}
-
-// Offs implements Object.
-func (a *Formal) Offs() string {
-	return strconv.Itoa(a.Offset)
 }
 
 // Stack implements Object.
@@ -120,23 +137,12 @@ func (a *Formal) CanAssign() bool {
 // AttributeObject is a type used in optimized ASTs that allows access to
 // another object's attributes.
 type AttributeObject struct {
-	// Object is the base of this attribute. Object.Base must return a
-	// register, not an offset from a register.
+	// Object is the base of this attribute.
 	Object Object
 	// Attribute is the attribute to access.
 	Attribute *Attribute
 }
 
-// Base implements Object.
-func (a *AttributeObject) Base(this int) string {
-	return a.Object.Offs() + "(" + a.Object.Base(this) + ")"
-}
-
-// Offs implements Object.
-func (a *AttributeObject) Offs() string {
-	return "offset_of_" + a.Attribute.Parent.Type.Name + "." + a.Attribute.Name.Name
-}
-
 // Stack implements Object.
 func (a *AttributeObject) Stack() bool {
 	return false
@@ -178,21 +184,14 @@ type Attribute struct {
 	Type *Ident
 	// Init is the initializer for this attribute.
 	Init Expr
+	// Doc is the text of the /** */ comment before the attribute, or an
+	// empty string if there is none.
+	Doc string
 
 	// Parent is the class that this attribute is declared within.
 	Parent *Class
 }
 
-// Base implements Object.
-func (a *Attribute) Base(this int) string {
-	return strconv.Itoa(this) + "(%ebp)"
-}
-
-// Offs implements Object.
-func (a *Attribute) Offs() string {
-	return "offset_of_" + a.Parent.Type.Name + "." + a.Name.Name
-}
-
 // Stack implements Object.
 func (a *Attribute) Stack() bool {
 	return false
@@ -226,6 +225,9 @@ type Method struct {
 	Type *Ident
 	// Body is the expression given for this method.
 	Body Expr
+	// Doc is the text of the /** */ comment before the method, or an
+	// empty string if there is none.
+	Doc string
 
 	// Parent is the class that this method is declared within.
 	Parent *Class
@@ -239,33 +241,20 @@ type Expr interface {
 	semantTypes(*semCtx, *Class)
 	semantIdentifiers(*semCtx, semantIdentifiers) *Class
 	semantGuaranteedNonNull(*semCtx) bool
+	semantCost(*semCtx) int
+	semantAssigned(*semCtx, *semLoop)
 	semantOpt(*semCtx) Expr
 	semantReplaceObject(*semCtx, Object, Object) Expr
 
-	genCollectLiterals(*genCtx)
-	genCountStack(*genCtx) int
-	genCountVars(*genCtx) int
-	genCode(*genCtx)
-}
+	rta(*rtaCtx)
+	escape(*escCtx, Object)
 
-// ArithmeticExpr is an expression that can return an unboxed integer.
-type ArithmeticExpr interface {
-	Expr
-
-	genCodeRawInt(*genCtx)
-}
+	print(*printCtx, int)
 
-// JumpExpr is an expression that can jump instead of returning a boolean.
-type JumpExpr interface {
-	Expr
-
-	genCodeJump(*genCtx, string, string)
-}
-
-type UnusedExpr interface {
-	Expr
+	genCollectLiterals(*genCtx)
+	lower(*irCtx) irOperand
 
-	genCodeUnused(*genCtx)
+	interp(*interpCtx, *interpFrame) *interpObject
 }
 
 // NotExpr is an expression of the form `!x`.
@@ -356,19 +345,6 @@ type MatchExpr struct {
 	Left Expr
 	// Cases are the cases given, in source code order.
 	Cases []*Case
-
-	// Offset is the stack offset of the temporary for the value of Left.
-	Offset int
-}
-
-// Base implements Object.
-func (e *MatchExpr) Base(this int) string {
-	return "%ebp"
-}
-
-// Offs implements Object.
-func (e *MatchExpr) Offs() string {
-	return strconv.Itoa(e.Offset)
 }
 
 // Stack implements Object.
@@ -459,18 +435,22 @@ type VarExpr struct {
 	// Body is `z` in the expression `var x : X = y; z`.
 	Body Expr
 
-	// Offset is the stack offset of this variable.
-	Offset int
-}
-
-// Base implements Object.
-func (e *VarExpr) Base(this int) string {
-	return "%ebp"
-}
-
-// Offs implements Object.
-func (e *VarExpr) Offs() string {
-	return strconv.Itoa(e.Offset)
+	// Inlined is the method whose body replaced a call to it, if this
+	// is the variable for `this` generated by inlining the call.
+	Inlined *Method
+	// Args are the arguments of the inlined call. The body starts with
+	// a variable for each argument, but the optimizer removes the
+	// variables of arguments it propagated, so the arguments are kept
+	// here to print the call.
+	Args []Expr
+	// NullCheck is true if the program must stop with a null pointer
+	// error when Init is null, because this is the `this` of an inlined
+	// call whose receiver might be null.
+	NullCheck bool
+	// Downcast is true if the type of Init is a superclass of Type. This
+	// only happens when rapid type analysis found that Init can only be
+	// an instance of Type, so the value is not checked.
+	Downcast bool
 }
 
 // Stack implements Object.
@@ -555,6 +535,13 @@ type NativeExpr struct {
 	Pos token.Pos
 }
 
+// BadExpr is a placeholder for an expression that could not be parsed. It has
+// type Nothing so that it does not cause further errors.
+type BadExpr struct {
+	// Pos is the position where the parser recovered from the error.
+	Pos token.Pos
+}
+
 // Case is a case of the form `case x : Y => z` or `case null => z`.
 type Case struct {
 	// Name is `x` in `case x : Y => z` or `null` in `case null => z`.
@@ -567,11 +554,6 @@ type Case struct {
 
 // Object is a stored value.
 type Object interface {
-	// Base is the base location for this object. The argument is the
-	// offset of `this` from %ebp in the current method.
-	Base(int) string
-	// Offs is the offset of this object from Base.
-	Offs() string
 	// Stack returns true if this is stored on the stack. In this
 	// implementation, the stack is reference-counted while the heap is
 	// garbage-collected.
@@ -594,6 +576,9 @@ type Ident struct {
 	Name string
 	// Pos is the position of the first byte of the name.
 	Pos token.Pos
+	// End is the position immediately after the name, or token.NoPos if
+	// the identifier was generated by the compiler.
+	End token.Pos
 
 	// Class is the class this type identifier refers to.
 	Class *Class
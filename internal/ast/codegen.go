@@ -4,7 +4,9 @@ import (
 	"fmt"
 	"go/token"
 	"io"
+	"sort"
 	"strconv"
+	"strings"
 )
 
 type genCtx struct {
@@ -14,14 +16,27 @@ type genCtx struct {
 	strings       []string
 	stringLengths []int
 
-	this int
-
-	label    int
-	vars     int
-	varsUsed int
+	label int
 
 	fset *token.FileSet
 
+	// live and reached are Program.live and Program.reached.
+	live    map[*Class]bool
+	reached map[*Method]bool
+	// borrowed is Program.borrowed.
+	borrowed map[*Method][]bool
+	// regs is where the temporaries of the current method are kept.
+	regs *genRegs
+	// pos is the position of the instruction being generated, and
+	// pushed is the number of words pushed on the stack since the start
+	// of the method, the most of which is maxPushed.
+	pos       int
+	pushed    int
+	maxPushed int
+	// code is the code for the current method, which is written once
+	// the whole method has been generated.
+	code []genInstr
+
 	opt Options
 }
 
@@ -32,6 +47,57 @@ func (ctx *genCtx) Printf(format string, args ...interface{}) {
 	}
 }
 
+// genInstr is one line of the code for a method: an instruction or
+// directive with its operands, or a label if Label is set.
+type genInstr struct {
+	Label string
+	Op    string
+	Args  []string
+}
+
+func (in genInstr) String() string {
+	if in.Label != "" {
+		return in.Label + ":"
+	}
+	if len(in.Args) == 0 {
+		return "\t" + in.Op
+	}
+	return "\t" + in.Op + " " + strings.Join(in.Args, ", ")
+}
+
+// Op adds an instruction to the code for the current method.
+func (ctx *genCtx) Op(op string, args ...string) {
+	ctx.code = append(ctx.code, genInstr{Op: op, Args: args})
+}
+
+// Mark adds a label to the code for the current method.
+func (ctx *genCtx) Mark(label string) {
+	ctx.code = append(ctx.code, genInstr{Label: label})
+}
+
+// Push adds an instruction that pushes arg on the stack.
+func (ctx *genCtx) Push(arg string) {
+	if arg[0] == '%' {
+		ctx.Op("push", arg)
+	} else {
+		ctx.Op("pushl", arg)
+	}
+	ctx.pushed++
+	if ctx.pushed > ctx.maxPushed {
+		ctx.maxPushed = ctx.pushed
+	}
+}
+
+// Pop adds an instruction that pops the top of the stack into arg.
+func (ctx *genCtx) Pop(arg string) {
+	if arg[0] == '%' {
+		ctx.Op("pop", arg)
+	} else {
+		ctx.Op("popl", arg)
+	}
+	ctx.pushed--
+}
+
 func (ctx *genCtx) AddInt(x int32) int {
 	for i, y := range ctx.ints {
 		if x == y {
@@ -53,22 +119,49 @@ func (ctx *genCtx) AddString(x string) int {
 	return len(ctx.strings) - 1
 }
 
+// Live returns true if c can be instantiated, so it needs a method table.
+func (ctx *genCtx) Live(c *Class) bool {
+	return ctx.live == nil || ctx.live[c]
+}
+
+// Reached returns true if m can be called, so it needs code.
+func (ctx *genCtx) Reached(m *Method) bool {
+	return ctx.reached == nil || ctx.reached[m]
+}
+
 func (ctx *genCtx) Label() string {
 	ctx.label++
 	return strconv.Itoa(ctx.label)
 }
 
-func (ctx *genCtx) Slot() (int, func()) {
-	if ctx.vars == ctx.varsUsed {
-		panic("INTERNAL ERROR: too many vars")
+// Scratch calls f with a register that can be changed freely until f
+// returns. If every register is holding a value, %ebx is saved on the stack
+// while f runs.
+func (ctx *genCtx) Scratch(f func(reg string)) {
+	for _, reg := range genRegisters {
+		if !ctx.regs.Busy(reg, ctx.pos) {
+			f(reg)
+			return
+		}
+	}
+	ctx.Push("%ebx")
+	f("%ebx")
+	ctx.Pop("%ebx")
+}
+
+// SaveRegs stores the registers that are used after a call in their home
+// slots. The returned function loads them again after the call.
+func (ctx *genCtx) SaveRegs() func() {
+	live := ctx.regs.Call(ctx.pos)
+	for _, iv := range live {
+		if !iv.readOnly {
+			ctx.Op("movl", iv.reg, strconv.Itoa(iv.home)+"(%ebp)")
+		}
 	}
-	ctx.varsUsed++
-	n := ctx.varsUsed
-	return -n * 4, func() {
-		if ctx.varsUsed != n {
-			panic("INTERNAL ERROR: missed var release")
+	return func() {
+		for _, iv := range live {
+			ctx.Op("movl", strconv.Itoa(iv.home)+"(%ebp)", iv.reg)
 		}
-		ctx.varsUsed--
 	}
 }
 
@@ -83,6 +176,10 @@ func (p *Program) CodeGen(opt Options, fset *token.FileSet, w io.Writer) (err er
 		w:    w,
 		fset: fset,
 		opt:  opt,
+
+		live:     p.live,
+		reached:  p.reached,
+		borrowed: p.borrowed,
 	}
 	ctx.AddInt(0) // int_lit_0 must be 0
 	nullClassID := ctx.AddString("Null")
@@ -130,10 +227,20 @@ func (p *Program) CodeGen(opt Options, fset *token.FileSet, w io.Writer) (err er
 	ctx.Printf("\n")
 
 	for _, c := range p.Ordered {
-		ctx.Printf(".align 2\n")
-		ctx.Printf("methods_of_%s:\n", c.Type.Name)
+		if ctx.Live(c) {
+			ctx.Printf(".align 2\n")
+			ctx.Printf("methods_of_%s:\n", c.Type.Name)
+			for _, m := range c.Methods {
+				if ctx.Reached(m) {
+					ctx.Printf("\t.long %s.%s\n", m.Parent.Type.Name, m.Name.Name)
+				} else {
+					ctx.Printf("\t.long runtime.removed_method_panic\n")
+				}
+			}
+		}
+		// the runtime uses method_offset_Runnable.run even if no
+		// Runnable is ever created.
 		for _, m := range c.Methods {
-			ctx.Printf("\t.long %s.%s\n", m.Parent.Type.Name, m.Name.Name)
 			if m.Parent == c {
 				ctx.Printf("\t.globl method_offset_%s.%s\n", c.Type.Name, m.Name.Name)
 				ctx.Printf("\t.set method_offset_%s.%s, %d\n", c.Type.Name, m.Name.Name, m.Order*4)
@@ -156,7 +263,11 @@ func (p *Program) CodeGen(opt Options, fset *token.FileSet, w io.Writer) (err er
 	ctx.Printf("method_tables:\n")
 	ctx.Printf("\t.long 0\n")
 	for _, c := range p.Ordered {
-		ctx.Printf("\t.long methods_of_%s\n", c.Type.Name)
+		if ctx.Live(c) {
+			ctx.Printf("\t.long methods_of_%s\n", c.Type.Name)
+		} else {
+			ctx.Printf("\t.long 0\n")
+		}
 	}
 	ctx.Printf("\n")
 
@@ -166,7 +277,7 @@ func (p *Program) CodeGen(opt Options, fset *token.FileSet, w io.Writer) (err er
 	ctx.Printf("\n")
 	ctx.Printf(".text\n")
 
-	genMethod(ctx, "main", -1, p.Main)
+	genMethod(ctx, "main", -1, nil, p.Main)
 
 	for _, c := range p.Ordered {
 		c.genCode(ctx)
@@ -186,7 +297,7 @@ func (c *Class) genCollectLiterals(ctx *genCtx) {
 	c.NameID = ctx.AddString(c.Type.Name)
 
 	for _, f := range c.Features {
-		if m, ok := f.(*Method); ok {
+		if m, ok := f.(*Method); ok && ctx.Reached(m) {
 			m.Body.genCollectLiterals(ctx)
 		}
 	}
@@ -209,303 +320,694 @@ func (c *Class) genConstants(ctx *genCtx) {
 	ctx.Printf(".set size_of_%s, %d\n", c.Type.Name, c.Size)
 }
 
-func genMethod(ctx *genCtx, name string, args int, body Expr) {
+func (c *Class) genCode(ctx *genCtx) {
+	for _, f := range c.Features {
+		if m, ok := f.(*Method); ok {
+			if _, ok := m.Body.(*NativeExpr); ok || !ctx.Reached(m) {
+				continue
+			}
+
+			var name string
+			if file := ctx.fset.File(m.Name.Pos); file != nil {
+				name = file.Name()
+			}
+
+			ctx.Printf("\n")
+			ctx.Printf(".file %q\n", name)
+
+			genMethod(ctx, c.Type.Name+"."+m.Name.Name, len(m.Args), m.Args, m.Body)
+		}
+	}
+}
+
+func genMethod(ctx *genCtx, name string, args int, formals []*Formal, body Expr) {
 	ctx.Printf("\n")
 	ctx.Printf(".globl %s\n", name)
 	ctx.Printf(".type %s, @function\n", name)
-	ctx.Printf("%s:\n", name)
-	ctx.Printf("\t.cfi_startproc\n")
 
-	ctx.this = args*4 + 8
-	ctx.vars = body.genCountVars(ctx)
-	if ctx.opt.Coroutine {
-		ctx.Printf("\tmovl $%d, %%eax\n", (2+ctx.vars+body.genCountStack(ctx))*4)
-		ctx.Printf("\tmovl $%d, %%ebx\n", args+1)
-		ctx.Printf("\tcall runtime.morestack\n")
+	fn := irLower(ctx, name, args, formals, body)
+	if ctx.opt.OptInt {
+		// runtime.morestack can't grow the stack of a coroutine, so
+		// coroutine programs don't build Ints in the stack frame.
+		irOptInt(fn, !ctx.opt.Coroutine)
+	}
+	irCancelRefs(fn)
+	if ctx.opt.OptUnused {
+		irOptUnused(fn)
+	}
+	if ctx.opt.OptJump {
+		irOptJump(fn)
 	}
-	ctx.Printf("\tpush %%ebp\n")
-	ctx.Printf("\t.cfi_def_cfa_offset 8\n")
-	ctx.Printf("\t.cfi_offset ebp, -8\n")
-	ctx.Printf("\tmovl %%esp, %%ebp\n")
-	ctx.Printf("\t.cfi_def_cfa_register ebp\n")
-	ctx.Printf("\tsubl $%d, %%esp\n", ctx.vars*4)
-
-	//ctx.Printf("\tmovl $0, %%eax\n")
-	//ctx.Printf("\tcall gc_check\n")
 
 	ctx.label = 0
-	ctx.varsUsed = 0
-	body.genCode(ctx)
-	for i := 0; i <= args; i++ {
-		ctx.Printf("\tmovl %d(%%ebp), %%ebx\n", i*4+8)
-		genGC(ctx, "%ebx")
+	for i, b := range fn.Blocks {
+		b.label = ctx.Label()
+		b.index = i
+	}
+	genAccumulate(fn)
+	genIntervals(ctx, fn)
+
+	// the body is generated first to find out how much stack it needs.
+	ctx.code = nil
+	ctx.pos = 0
+	ctx.pushed, ctx.maxPushed = 0, 0
+	for _, b := range fn.Blocks {
+		ctx.Mark(b.label)
+		for _, in := range b.Instrs {
+			in.gen(ctx)
+			ctx.pos += 2
+		}
+		b.Term.genTerm(ctx, fn, b)
+		ctx.pos += 2
 	}
+	text := ctx.code
 
-	//ctx.Printf("\tcall gc_check\n")
+	ctx.code = nil
+	ctx.Mark(name)
+	ctx.Op(".cfi_startproc")
+	if ctx.opt.Coroutine {
+		// one more word for the push in Scratch.
+		ctx.Op("movl", "$"+strconv.Itoa((3+ctx.regs.slots+ctx.maxPushed)*4), "%eax")
+		ctx.Op("movl", "$"+strconv.Itoa(args+1), "%ebx")
+		ctx.Op("call", "runtime.morestack")
+	}
+	ctx.Op("push", "%ebp")
+	ctx.Op(".cfi_def_cfa_offset", "8")
+	ctx.Op(".cfi_offset", "ebp", "-8")
+	ctx.Op("movl", "%esp", "%ebp")
+	ctx.Op(".cfi_def_cfa_register", "ebp")
+	ctx.Op("subl", "$"+strconv.Itoa(ctx.regs.slots*4), "%esp")
+	for _, t := range fn.Params {
+		if t.iv != nil && t.iv.reg != "" {
+			ctx.Op("movl", strconv.Itoa(t.iv.home)+"(%ebp)", t.iv.reg)
+		}
+	}
 
-	ctx.Printf("\tleave\n")
-	ctx.Printf("\t.cfi_def_cfa esp, 4\n")
-	ctx.Printf("\tret $%d\n", args*4+4)
-	ctx.Printf("\t.cfi_endproc\n")
-	ctx.Printf("\t.size %s, .-%s\n", name, name)
-}
+	//ctx.Printf("\tmovl $0, %%eax\n")
+	//ctx.Printf("\tcall gc_check\n")
 
-func genRef(ctx *genCtx, reg string) {
-	label_done := ctx.Label()
+	ctx.code = append(ctx.code, text...)
+	ctx.Op(".cfi_endproc")
+	ctx.Op(".size", name, ".-"+name)
 
-	ctx.Printf("\ttest %s, %s\n", reg, reg)
-	ctx.Printf("\tjz %sf\n", label_done)
-	ctx.Printf("\tcmpl $0, gc_offset(%s)\n", reg)
-	ctx.Printf("\tjl %sf\n", label_done)
-	ctx.Printf("\tincl gc_offset(%s)\n", reg)
-	ctx.Printf("%s:\n", label_done)
+	code := ctx.code
+	ctx.code = nil
+	if ctx.opt.OptPeephole {
+		code = genPeephole(code)
+	}
+	for _, in := range code {
+		ctx.Printf("%s\n", in)
+	}
 }
 
-func genGC(ctx *genCtx, reg string) {
-	label_done := ctx.Label()
+// genAccumulate finds the temporaries that can stay in %eax, where each
+// instruction leaves its result, until they are used: the ones that are used
+// by the instruction after the one that sets them, or by the first one in
+// the block after a jump if each block before it sets them last. The
+// reference count changes and null checks in between don't change %eax.
+func genAccumulate(fn *irFunc) {
+	refs := irScan(fn)
+	preds := make(map[*irBlock]int)
+	for _, b := range fn.Blocks {
+		for _, s := range b.Term.Successors() {
+			preds[s]++
+		}
+	}
 
-	ctx.Printf("\ttest %s, %s\n", reg, reg)
-	ctx.Printf("\tjz %sf\n", label_done)
-	ctx.Printf("\tcmpl $0, gc_offset(%s)\n", reg)
-	ctx.Printf("\tjle %sf\n", label_done)
-	ctx.Printf("\tdecl gc_offset(%s)\n", reg)
-	ctx.Printf("%s:\n", label_done)
-}
+	for _, t := range fn.Temps {
+		t.acc = false
+		defs := refs.defs[t]
+		if t.Param != 0 || t.Words != 1 || len(defs) == 0 {
+			continue
+		}
 
-func genCodeRawInt(ctx *genCtx, e Expr) {
-	if raw, ok := e.(ArithmeticExpr); ok && ctx.opt.OptInt {
-		raw.genCodeRawInt(ctx)
-	} else {
-		e.genCode(ctx)
-		genGC(ctx, "%eax")
-		ctx.Printf("\tmovl offset_of_Int.value(%%eax), %%eax\n")
-	}
-}
+		b, start := refs.block[defs[0]], refs.index[defs[0]]+1
+		if len(defs) > 1 {
+			var join *irBlock
+			for _, in := range defs {
+				p := refs.block[in]
+				if refs.index[in] != len(p.Instrs)-1 || p.Term.Op != irJump || (join != nil && p.Term.Targets[0] != join) {
+					join = nil
+					break
+				}
+				join = p.Term.Targets[0]
+			}
+			if join == nil || preds[join] != len(defs) {
+				continue
+			}
+			b, start = join, 0
+		}
 
-func genCodeJump(ctx *genCtx, e Expr, l0, l1 string) {
-	if raw, ok := e.(JumpExpr); ok && ctx.opt.OptJump {
-		raw.genCodeJump(ctx, l0, l1)
-	} else {
-		e.genCode(ctx)
-		ctx.Printf("\tcmpl $boolean_false, %%eax\n")
-		ctx.Printf("\tje %s\n", l0)
-		ctx.Printf("\tjmp %s\n", l1)
+		t.acc = genAccumulated(b, start, t, len(refs.uses[t]))
 	}
 }
 
-func genCodeUnused(ctx *genCtx, e Expr) {
-	if raw, ok := e.(UnusedExpr); ok && ctx.opt.OptUnused {
-		raw.genCodeUnused(ctx)
-	} else {
-		e.genCode(ctx)
-		genGC(ctx, "%eax")
+// genAccumulated returns true if the n uses of t are all in b starting at
+// instruction start, and %eax holds t until the last one.
+func genAccumulated(b *irBlock, start int, t *irTemp, n int) bool {
+	seen := 0
+	for i := start; i <= len(b.Instrs); i++ {
+		in := b.Term
+		if i < len(b.Instrs) {
+			in = b.Instrs[i]
+		}
+
+		uses := 0
+		for _, u := range in.Uses() {
+			if u == t {
+				uses++
+			}
+		}
+		counts := in.Op == irRef || in.Op == irUnref || in.Op == irNullCheck
+		switch {
+		case uses == 0 && counts:
+		case uses == 0:
+			return false
+		case counts:
+			seen++
+			if seen == n {
+				return true
+			}
+		default:
+			if uses != 1 || seen+1 != n || in.Op == irBox {
+				return false
+			}
+			// the divisor can't be in %eax.
+			return in.Op != irDiv || in.Args[0] == irOperand(t)
+		}
 	}
+	return false
 }
 
-func (c *Class) genCode(ctx *genCtx) {
-	for _, f := range c.Features {
-		if m, ok := f.(*Method); ok {
-			if _, ok := m.Body.(*NativeExpr); ok {
-				continue
-			}
+// genIntervals finds when each temporary that isn't kept in %eax is used and
+// chooses where to keep it.
+func genIntervals(ctx *genCtx, fn *irFunc) {
+	r := &genRegs{}
+	ctx.regs = r
 
-			var name string
-			if file := ctx.fset.File(m.Name.Pos); file != nil {
-				name = file.Name()
+	for _, t := range fn.Temps {
+		t.iv = nil
+	}
+	for _, t := range fn.Params {
+		t.iv = &genInterval{home: t.Param, words: 1, start: -1, end: -1, readOnly: true}
+		r.intervals = append(r.intervals, t.iv)
+	}
+	use := func(t *irTemp, pos, depth int) {
+		if t.acc {
+			return
+		}
+		if t.iv == nil {
+			t.iv = &genInterval{words: t.Words, start: pos, end: pos}
+			r.intervals = append(r.intervals, t.iv)
+		}
+		t.iv.Use(pos, depth)
+	}
+
+	type genLoop struct {
+		start, end int
+	}
+	var loops []genLoop
+	start := make(map[*irBlock]int)
+	pos := 0
+	for _, b := range fn.Blocks {
+		start[b] = pos
+		pos += 2 * (len(b.Instrs) + 1)
+	}
+
+	pos = 0
+	for _, b := range fn.Blocks {
+		for _, in := range append(b.Instrs[:len(b.Instrs):len(b.Instrs)], b.Term) {
+			for _, t := range in.Uses() {
+				if in.Op == irBox {
+					// the integer is stored in the Int after
+					// it is allocated.
+					use(t, pos+1, b.Depth)
+				} else {
+					use(t, pos, b.Depth)
+				}
 			}
+			if in.Op == irDiv {
+				if t := irTempOf(in.Args[1]); t != nil && t.iv != nil {
+					t.iv.avoid = "%edx"
+				}
+			}
+			if in.Dst != nil {
+				use(in.Dst, pos+1, b.Depth)
+			}
+			if in.Calls() {
+				r.calls = append(r.calls, genCall{pos: pos, weight: genFactor(b.Depth)})
+			}
+			for _, s := range in.Successors() {
+				if start[s] <= start[b] {
+					loops = append(loops, genLoop{start: start[s], end: pos + 1})
+				}
+			}
+			pos += 2
+		}
+	}
 
-			ctx.Printf("\n")
-			ctx.Printf(".file %q\n", name)
-
-			for i, a := range m.Args {
-				a.Offset = (len(m.Args)-i)*4 + 4
+	sort.Slice(loops, func(i, j int) bool {
+		return loops[i].end < loops[j].end
+	})
+	for changed := true; changed; {
+		changed = false
+		for _, l := range loops {
+			if r.Loop(l.start, l.end) {
+				changed = true
 			}
-			genMethod(ctx, c.Type.Name+"."+m.Name.Name, len(m.Args), m.Body)
 		}
 	}
-}
 
-func (e *NotExpr) genCollectLiterals(ctx *genCtx) {
-	e.Expr.genCollectLiterals(ctx)
+	if ctx.opt.OptRegs {
+		r.Allocate()
+	}
+	r.Homes()
 }
 
-func (e *NotExpr) genCountStack(ctx *genCtx) int {
-	return e.Expr.genCountStack(ctx)
+// genOperand returns the assembly operand for a.
+func genOperand(a irOperand) string {
+	t := irTempOf(a)
+	switch {
+	case t == nil:
+		return a.String()
+	case t.acc:
+		return "%eax"
+	case t.Words != 1:
+		panic("INTERNAL ERROR: " + t.String() + " is not one word")
+	}
+	return t.iv.Operand()
 }
 
-func (e *NotExpr) genCountVars(ctx *genCtx) int {
-	return e.Expr.genCountVars(ctx)
+// genInReg returns true if a is kept in a register, including %eax.
+func genInReg(a irOperand) bool {
+	t := irTempOf(a)
+	return t != nil && (t.acc || t.iv.reg != "")
 }
 
-func (e *NotExpr) genCode(ctx *genCtx) {
-	label_true := ctx.Label()
-	label_false := ctx.Label()
-	label_done := ctx.Label()
-
-	e.genCodeJump(ctx, label_false+"f", label_true+"f")
-
-	ctx.Printf("%s:\n", label_true)
-	ctx.Printf("\tlea boolean_true, %%eax\n")
-	ctx.Printf("\tjmp %sf\n", label_done)
-	ctx.Printf("%s:\n", label_false)
-	ctx.Printf("\tlea boolean_false, %%eax\n")
-	ctx.Printf("%s:\n", label_done)
+// genInMemory returns true if a is kept in its home slot.
+func genInMemory(a irOperand) bool {
+	t := irTempOf(a)
+	return t != nil && !t.acc && t.iv.reg == ""
 }
 
-func (e *NotExpr) genCodeJump(ctx *genCtx, l0, l1 string) {
-	genCodeJump(ctx, e.Expr, l1, l0)
+// genLoad puts a in %eax. An Int built in the stack frame is loaded as its
+// address.
+func genLoad(ctx *genCtx, a irOperand) {
+	t := irTempOf(a)
+	switch {
+	case t != nil && t.acc:
+	case t != nil && t.Words != 1:
+		ctx.Op("leal", strconv.Itoa(t.iv.home)+"(%ebp)", "%eax")
+	default:
+		ctx.Op("movl", genOperand(a), "%eax")
+	}
 }
 
-func (e *NegativeExpr) genCollectLiterals(ctx *genCtx) {
-	e.Expr.genCollectLiterals(ctx)
+// genStore stores %eax in t.
+func genStore(ctx *genCtx, t *irTemp) {
+	if !t.acc {
+		ctx.Op("movl", "%eax", genOperand(t))
+	}
 }
 
-func (e *NegativeExpr) genCountStack(ctx *genCtx) int {
-	return e.Expr.genCountStack(ctx)
+// genReg returns a register that holds a, loading it into %eax if it isn't
+// in one.
+func genReg(ctx *genCtx, a irOperand) string {
+	if genInReg(a) {
+		return genOperand(a)
+	}
+	genLoad(ctx, a)
+	return "%eax"
 }
 
-func (e *NegativeExpr) genCountVars(ctx *genCtx) int {
-	vars := e.Expr.genCountVars(ctx)
-	if v := 1; vars < v {
-		vars = v
+// genCount calls count with a register that holds a, which is changed by
+// genRef or genGC. Constants are never counted.
+func genCount(ctx *genCtx, a irOperand, count func(ctx *genCtx, reg string)) {
+	if irTempOf(a) == nil {
+		return
 	}
-	return vars
+	if genInReg(a) {
+		count(ctx, genOperand(a))
+		return
+	}
+	ctx.Scratch(func(reg string) {
+		ctx.Op("movl", genOperand(a), reg)
+		count(ctx, reg)
+	})
 }
 
-func (e *NegativeExpr) genCode(ctx *genCtx) {
-	offset, unreserve := ctx.Slot()
-	ctx.Printf("\tmovl $(size_of_Int + 4), %%eax\n")
-	ctx.Printf("\tmovl $tag_of_Int, %%ebx\n")
-	ctx.Printf("\tcall gc_alloc\n")
-	ctx.Printf("\tmovl %%eax, %d(%%ebp)\n", offset)
+func genRef(ctx *genCtx, reg string) {
+	label_done := ctx.Label()
 
-	genCodeRawInt(ctx, e.Expr)
-	ctx.Printf("\tmovl %%eax, %%ebx\n")
+	ctx.Op("test", reg, reg)
+	ctx.Op("jz", label_done+"f")
+	ctx.Op("cmpl", "$0", "gc_offset("+reg+")")
+	ctx.Op("jl", label_done+"f")
+	ctx.Op("incl", "gc_offset("+reg+")")
+	ctx.Mark(label_done)
+}
 
-	ctx.Printf("\tmovl %d(%%ebp), %%eax\n", offset)
-	unreserve()
+func genGC(ctx *genCtx, reg string) {
+	label_done := ctx.Label()
 
-	ctx.Printf("\tnegl %%ebx\n")
-	ctx.Printf("\tmovl %%ebx, offset_of_Int.value(%%eax)\n")
-}
+	ctx.Op("test", reg, reg)
+	ctx.Op("jz", label_done+"f")
+	ctx.Op("cmpl", "$0", "gc_offset("+reg+")")
+	ctx.Op("jle", label_done+"f")
+	ctx.Op("decl", "gc_offset("+reg+")")
+	ctx.Mark(label_done)
+}
+
+// genAlloc allocates an object with the given size and tag. The object is
+// in %eax.
+func genAlloc(ctx *genCtx, size, tag string) {
+	reload := ctx.SaveRegs()
+	ctx.Op("movl", "$"+size, "%eax")
+	ctx.Op("movl", "$"+tag, "%ebx")
+	ctx.Op("call", "gc_alloc")
+	reload()
+}
+
+// genAttribute returns the offset of a in its object.
+func genAttribute(a *Attribute) string {
+	return "offset_of_" + a.Parent.Type.Name + "." + a.Name.Name
+}
+
+// genDivide divides a by b, leaving the result in %eax. idiv divides
+// %edx:%eax, so b is never kept in %edx, and %edx is saved if it holds a
+// value.
+func genDivide(ctx *genCtx, a, b irOperand) {
+	genLoad(ctx, a)
+	busy := ctx.regs.Busy("%edx", ctx.pos)
+	if busy {
+		ctx.Push("%edx")
+	}
+	divisor := genOperand(b)
+	if irTempOf(b) == nil {
+		ctx.Push(divisor)
+		divisor = "(%esp)"
+	}
+	ctx.Op("cdq")
+	ctx.Op("idivl", divisor)
+	if irTempOf(b) == nil {
+		ctx.Op("addl", "$4", "%esp")
+		ctx.pushed--
+	}
+	if busy {
+		ctx.Pop("%edx")
+	}
+}
+
+// genSwapped is the jump for the same comparison with the operands swapped.
+var genSwapped = map[string]string{
+	"jl":  "jg",
+	"jle": "jge",
+}
+
+// genCompare compares the integers a and b and returns the jump that is
+// taken if a < b for irLess or irBranchLess, or a <= b for irLessEq or
+// irBranchLessEq.
+func genCompare(ctx *genCtx, op irOp, a, b irOperand) string {
+	jump := "jl"
+	if op == irLessEq || op == irBranchLessEq {
+		jump = "jle"
+	}
+	_, aConst := a.(irConst)
+	_, bConst := b.(irConst)
+	switch {
+	case genInReg(a) && !genInMemory(b):
+		ctx.Op("cmpl", genOperand(b), genOperand(a))
+	case aConst && !bConst:
+		ctx.Op("cmpl", genOperand(a), genOperand(b))
+		return genSwapped[jump]
+	case genInMemory(a) && !genInMemory(b):
+		ctx.Op("cmpl", genOperand(b), genOperand(a))
+	default:
+		ctx.Op("cmpl", genOperand(b), genReg(ctx, a))
+	}
+	return jump
+}
+
+// genBoolean stores the Boolean for whether jump is taken in t.
+func genBoolean(ctx *genCtx, jump string, t *irTemp) {
+	label_true := ctx.Label()
+	label_done := ctx.Label()
 
-func (e *NegativeExpr) genCodeRawInt(ctx *genCtx) {
-	genCodeRawInt(ctx, e.Expr)
-	ctx.Printf("\tnegl %%eax\n")
-}
+	ctx.Op(jump, label_true+"f")
+	ctx.Op("movl", "$boolean_false", "%eax")
+	ctx.Op("jmp", label_done+"f")
+	ctx.Mark(label_true)
+	ctx.Op("movl", "$boolean_true", "%eax")
+	ctx.Mark(label_done)
+	genStore(ctx, t)
+}
+
+// gen generates the code for an instruction.
+func (in *irInstr) gen(ctx *genCtx) {
+	switch in.Op {
+	case irMove:
+		a, d := in.Args[0], in.Dst
+		switch {
+		case d.acc:
+			genLoad(ctx, a)
+		case genInMemory(a) && genInMemory(d):
+			genLoad(ctx, a)
+			genStore(ctx, d)
+		default:
+			ctx.Op("movl", genOperand(a), genOperand(d))
+		}
 
-func (e *IfExpr) genCollectLiterals(ctx *genCtx) {
-	e.Cond.genCollectLiterals(ctx)
-	e.Then.genCollectLiterals(ctx)
-	e.Else.genCollectLiterals(ctx)
-}
+	case irAdd, irMul:
+		op := "addl"
+		if in.Op == irMul {
+			op = "imull"
+		}
+		a, b := in.Args[0], in.Args[1]
+		if irTempOf(b) != nil && irTempOf(b).acc {
+			a, b = b, a
+		}
+		genLoad(ctx, a)
+		ctx.Op(op, genOperand(b), "%eax")
+		genStore(ctx, in.Dst)
+
+	case irSub:
+		a, b := in.Args[0], in.Args[1]
+		if irTempOf(b) != nil && irTempOf(b).acc {
+			ctx.Op("negl", "%eax")
+			ctx.Op("addl", genOperand(a), "%eax")
+		} else {
+			genLoad(ctx, a)
+			ctx.Op("subl", genOperand(b), "%eax")
+		}
+		genStore(ctx, in.Dst)
+
+	case irDiv:
+		genDivide(ctx, in.Args[0], in.Args[1])
+		genStore(ctx, in.Dst)
+
+	case irNeg:
+		genLoad(ctx, in.Args[0])
+		ctx.Op("negl", "%eax")
+		genStore(ctx, in.Dst)
+
+	case irBox:
+		genAlloc(ctx, "(size_of_Int + 4)", "tag_of_Int")
+		if v := genOperand(in.Args[0]); genInMemory(in.Args[0]) {
+			ctx.Push(v)
+			ctx.Pop("offset_of_Int.value(%eax)")
+		} else {
+			ctx.Op("movl", v, "offset_of_Int.value(%eax)")
+		}
+		genStore(ctx, in.Dst)
+
+	case irStackBox:
+		base := strconv.Itoa(in.Dst.iv.home)
+		ctx.Op("movl", "$tag_of_Int", base+"+tag_offset(%ebp)")
+		ctx.Op("movl", "$(size_of_Int + 4)", base+"+size_offset(%ebp)")
+		ctx.Op("movl", "$gc_tag_root", base+"+gc_offset(%ebp)")
+		value := genOperand(in.Args[0])
+		if genInMemory(in.Args[0]) {
+			genLoad(ctx, in.Args[0])
+			value = "%eax"
+		}
+		ctx.Op("movl", value, base+"+offset_of_Int.value(%ebp)")
 
-func (e *IfExpr) genCountStack(ctx *genCtx) int {
-	stack := e.Cond.genCountStack(ctx)
-	if s := e.Then.genCountStack(ctx); s > stack {
-		stack = s
-	}
-	if s := e.Else.genCountStack(ctx); s > stack {
-		stack = s
-	}
-	return stack
-}
+	case irUnbox, irLoad:
+		offset := "offset_of_Int.value"
+		if in.Op == irLoad {
+			offset = genAttribute(in.Attr)
+		}
+		base := genReg(ctx, in.Args[0])
+		if d := in.Dst; !d.acc && d.iv.reg != "" {
+			ctx.Op("movl", offset+"("+base+")", d.iv.reg)
+		} else {
+			ctx.Op("movl", offset+"("+base+")", "%eax")
+			genStore(ctx, d)
+		}
 
-func (e *IfExpr) genCountVars(ctx *genCtx) int {
-	vars := e.Cond.genCountVars(ctx)
-	if v := e.Then.genCountVars(ctx); v > vars {
-		vars = v
-	}
-	if v := e.Else.genCountVars(ctx); v > vars {
-		vars = v
-	}
-	return vars
-}
+	case irStore:
+		offset := genAttribute(in.Attr)
+		a, b := in.Args[0], in.Args[1]
+		switch {
+		case genInReg(a) && genInMemory(b):
+			ctx.Scratch(func(reg string) {
+				ctx.Op("movl", genOperand(b), reg)
+				ctx.Op("movl", reg, offset+"("+genOperand(a)+")")
+			})
+		case genInReg(a):
+			ctx.Op("movl", genOperand(b), offset+"("+genOperand(a)+")")
+		default:
+			value := genOperand(b)
+			if genInMemory(b) {
+				genLoad(ctx, b)
+				value = "%eax"
+			}
+			ctx.Scratch(func(reg string) {
+				ctx.Op("movl", genOperand(a), reg)
+				ctx.Op("movl", value, offset+"("+reg+")")
+			})
+		}
 
-func (e *IfExpr) genCodeShared(ctx *genCtx, t, f func()) {
-	label_true := ctx.Label()
-	label_false := ctx.Label()
-	label_done := ctx.Label()
+	case irRef:
+		genCount(ctx, in.Args[0], genRef)
+
+	case irUnref:
+		genCount(ctx, in.Args[0], genGC)
+
+	case irNullCheck:
+		a := in.Args[0]
+		switch {
+		case a == irOperand(irNull):
+			ctx.Op("jmp", "runtime.null_panic")
+		case irTempOf(a) == nil:
+		case genInReg(a):
+			ctx.Op("test", genOperand(a), genOperand(a))
+			ctx.Op("jz", "runtime.null_panic")
+		default:
+			ctx.Op("cmpl", "$0", genOperand(a))
+			ctx.Op("je", "runtime.null_panic")
+		}
 
-	genCodeJump(ctx, e.Cond, label_false+"f", label_true+"f")
+	case irTag:
+		label_null := ctx.Label()
 
-	ctx.Printf("%s:\n", label_true)
-	t()
-	ctx.Printf("\tjmp %sf\n", label_done)
-	ctx.Printf("%s:\n", label_false)
-	f()
-	ctx.Printf("%s:\n", label_done)
-}
+		genLoad(ctx, in.Args[0])
+		ctx.Op("test", "%eax", "%eax")
+		ctx.Op("jz", label_null+"f")
+		ctx.Op("movl", "tag_offset(%eax)", "%eax")
+		ctx.Mark(label_null)
+		genStore(ctx, in.Dst)
 
-func (e *IfExpr) genCode(ctx *genCtx) {
-	e.genCodeShared(ctx, func() {
-		e.Then.genCode(ctx)
-	}, func() {
-		e.Else.genCode(ctx)
-	})
-}
+	case irAlloc:
+		genAlloc(ctx, "size_of_"+in.Class.Type.Name, "tag_of_"+in.Class.Type.Name)
+		genStore(ctx, in.Dst)
 
-func (e *IfExpr) genCodeRawInt(ctx *genCtx) {
-	e.genCodeShared(ctx, func() {
-		genCodeRawInt(ctx, e.Then)
-	}, func() {
-		genCodeRawInt(ctx, e.Else)
-	})
-}
+	case irLess, irLessEq:
+		genBoolean(ctx, genCompare(ctx, in.Op, in.Args[0], in.Args[1]), in.Dst)
 
-func (e *IfExpr) genCodeJump(ctx *genCtx, l0, l1 string) {
-	e.genCodeShared(ctx, func() {
-		genCodeJump(ctx, e.Then, l0, l1)
-	}, func() {
-		genCodeJump(ctx, e.Else, l0, l1)
-	})
-}
+	case irNot:
+		ctx.Op("cmpl", "$boolean_false", genReg(ctx, in.Args[0]))
+		genBoolean(ctx, "je", in.Dst)
 
-func (e *IfExpr) genCodeUnused(ctx *genCtx) {
-	e.genCodeShared(ctx, func() {
-		genCodeUnused(ctx, e.Then)
-	}, func() {
-		genCodeUnused(ctx, e.Else)
-	})
-}
+	case irPush:
+		if t := irTempOf(in.Args[0]); t != nil && t.Words != 1 {
+			genLoad(ctx, t)
+			ctx.Push("%eax")
+		} else {
+			ctx.Push(genOperand(in.Args[0]))
+		}
 
-func (e *WhileExpr) genCollectLiterals(ctx *genCtx) {
-	e.Cond.genCollectLiterals(ctx)
-	e.Body.genCollectLiterals(ctx)
-}
+	case irLookup:
+		ctx.Op("movl", strconv.Itoa(in.N*4)+"(%esp)", "%eax")
+		ctx.Op("movl", "tag_offset(%eax)", "%eax")
+		ctx.Op("shll", "$2", "%eax")
+		ctx.Op("movl", "method_tables(%eax)", "%eax")
+		ctx.Op("movl", "method_offset_"+in.Method.Parent.Type.Name+"."+in.Method.Name.Name+"(%eax)", "%eax")
+		genStore(ctx, in.Dst)
+
+	case irCall:
+		reload := ctx.SaveRegs()
+		if len(in.Args) != 0 {
+			ctx.Op("call", "*"+genOperand(in.Args[0]))
+		} else {
+			ctx.Op("call", in.Method.Parent.Type.Name+"."+in.Method.Name.Name)
+		}
+		reload()
+		ctx.pushed -= in.N + 1
+		genStore(ctx, in.Dst)
 
-func (e *WhileExpr) genCountStack(ctx *genCtx) int {
-	stack := e.Cond.genCountStack(ctx)
-	if s := e.Body.genCountStack(ctx); s > stack {
-		stack = s
+	default:
+		panic("INTERNAL ERROR: unexpected " + in.Op.String())
 	}
-	return stack
 }
 
-func (e *WhileExpr) genCountVars(ctx *genCtx) int {
-	vars := e.Cond.genCountVars(ctx)
-	if v := e.Body.genCountVars(ctx); v > vars {
-		vars = v
+// genTerm generates the code for the terminator of b.
+func (in *irInstr) genTerm(ctx *genCtx, fn *irFunc, b *irBlock) {
+	target := func(t *irBlock) string {
+		if t.index > b.index {
+			return t.label + "f"
+		}
+		return t.label + "b"
+	}
+
+	switch in.Op {
+	case irJump:
+		ctx.Op("jmp", target(in.Targets[0]))
+
+	case irBranch:
+		ctx.Op("cmpl", "$boolean_false", genReg(ctx, in.Args[0]))
+		ctx.Op("je", target(in.Targets[0]))
+		ctx.Op("jmp", target(in.Targets[1]))
+
+	case irBranchLess, irBranchLessEq:
+		ctx.Op(genCompare(ctx, in.Op, in.Args[0], in.Args[1]), target(in.Targets[1]))
+		ctx.Op("jmp", target(in.Targets[0]))
+
+	case irSwitch:
+		tag := genReg(ctx, in.Args[0])
+		for _, c := range in.Cases {
+			if c.Order == c.MaxOrder {
+				ctx.Op("cmpl", "$"+strconv.Itoa(c.Order), tag)
+				ctx.Op("je", target(c.Target))
+			} else {
+				label_skip := ctx.Label()
+				ctx.Op("cmpl", "$"+strconv.Itoa(c.Order), tag)
+				ctx.Op("jl", label_skip+"f")
+				ctx.Op("cmpl", "$"+strconv.Itoa(c.MaxOrder), tag)
+				ctx.Op("jle", target(c.Target))
+				ctx.Mark(label_skip)
+			}
+		}
+		ctx.Op("jmp", "runtime.case_panic")
+
+	case irReturn:
+		genLoad(ctx, in.Args[0])
+		ctx.Op("leave")
+		ctx.Op(".cfi_def_cfa", "esp", "4")
+		ctx.Op("ret", "$"+strconv.Itoa(fn.Args*4+4))
+
+	default:
+		panic("INTERNAL ERROR: unexpected " + in.Op.String())
 	}
-	return vars
 }
 
-func (e *WhileExpr) genCode(ctx *genCtx) {
-	e.genCodeUnused(ctx)
-	ctx.Printf("\tlea unit_lit, %%eax\n")
+func (e *NotExpr) genCollectLiterals(ctx *genCtx) {
+	e.Expr.genCollectLiterals(ctx)
+}
+
+func (e *NegativeExpr) genCollectLiterals(ctx *genCtx) {
+	e.Expr.genCollectLiterals(ctx)
 }
 
-func (e *WhileExpr) genCodeUnused(ctx *genCtx) {
-	label_cond := ctx.Label()
-	label_body := ctx.Label()
-	label_done := ctx.Label()
+func (e *IfExpr) genCollectLiterals(ctx *genCtx) {
+	e.Cond.genCollectLiterals(ctx)
+	e.Then.genCollectLiterals(ctx)
+	e.Else.genCollectLiterals(ctx)
+}
 
-	ctx.Printf("%s:\n", label_cond)
-	genCodeJump(ctx, e.Cond, label_done+"f", label_body+"f")
-	ctx.Printf("%s:\n", label_body)
-	genCodeUnused(ctx, e.Body)
-	ctx.Printf("\tjmp %sb\n", label_cond)
-	ctx.Printf("%s:\n", label_done)
+func (e *WhileExpr) genCollectLiterals(ctx *genCtx) {
+	e.Cond.genCollectLiterals(ctx)
+	e.Body.genCollectLiterals(ctx)
 }
 
 func (e *LessOrEqualExpr) genCollectLiterals(ctx *genCtx) {
@@ -513,987 +1015,101 @@ func (e *LessOrEqualExpr) genCollectLiterals(ctx *genCtx) {
 	e.Right.genCollectLiterals(ctx)
 }
 
-func (e *LessOrEqualExpr) genCountStack(ctx *genCtx) int {
-	stack := e.Left.genCountStack(ctx)
-	if s := e.Right.genCountStack(ctx); s > stack {
-		stack = s
-	}
-	return stack
+func (e *LessThanExpr) genCollectLiterals(ctx *genCtx) {
+	e.Left.genCollectLiterals(ctx)
+	e.Right.genCollectLiterals(ctx)
 }
 
-func (e *LessOrEqualExpr) genCountVars(ctx *genCtx) int {
-	vars := e.Left.genCountVars(ctx)
-	if v := 1 + e.Right.genCountVars(ctx); v > vars {
-		vars = v
-	}
-	return vars
+func (e *MultiplyExpr) genCollectLiterals(ctx *genCtx) {
+	e.Left.genCollectLiterals(ctx)
+	e.Right.genCollectLiterals(ctx)
 }
 
-func (e *LessOrEqualExpr) genCode(ctx *genCtx) {
-	label_true := ctx.Label()
-	label_false := ctx.Label()
-	label_done := ctx.Label()
-
-	e.genCodeJump(ctx, label_false+"f", label_true+"f")
-	ctx.Printf("%s:\n", label_false)
-	ctx.Printf("\tlea boolean_false, %%eax\n")
-	ctx.Printf("\tjmp %sf\n", label_done)
-	ctx.Printf("%s:\n", label_true)
-	ctx.Printf("\tlea boolean_true, %%eax\n")
-	ctx.Printf("%s:\n", label_done)
+func (e *DivideExpr) genCollectLiterals(ctx *genCtx) {
+	e.Left.genCollectLiterals(ctx)
+	e.Right.genCollectLiterals(ctx)
 }
 
-func (e *LessOrEqualExpr) genCodeJump(ctx *genCtx, l0, l1 string) {
-	genCodeRawInt(ctx, e.Left)
-	offset, unreserve := ctx.Slot()
-	ctx.Printf("\tmovl %%eax, %d(%%ebp)\n", offset)
-	genCodeRawInt(ctx, e.Right)
-	ctx.Printf("\tmovl %d(%%ebp), %%ebx\n", offset)
-	unreserve()
-	ctx.Printf("\tcmpl %%eax, %%ebx\n")
-	ctx.Printf("\tjle %s\n", l1)
-	ctx.Printf("\tjmp %s\n", l0)
+func (e *AddExpr) genCollectLiterals(ctx *genCtx) {
+	e.Left.genCollectLiterals(ctx)
+	e.Right.genCollectLiterals(ctx)
 }
 
-func (e *LessThanExpr) genCollectLiterals(ctx *genCtx) {
+func (e *SubtractExpr) genCollectLiterals(ctx *genCtx) {
 	e.Left.genCollectLiterals(ctx)
 	e.Right.genCollectLiterals(ctx)
 }
 
-func (e *LessThanExpr) genCountStack(ctx *genCtx) int {
-	stack := e.Left.genCountStack(ctx)
-	if s := e.Right.genCountStack(ctx); s > stack {
-		stack = s
+func (e *MatchExpr) genCollectLiterals(ctx *genCtx) {
+	e.Left.genCollectLiterals(ctx)
+	for _, c := range e.Cases {
+		c.Body.genCollectLiterals(ctx)
 	}
-	return stack
 }
 
-func (e *LessThanExpr) genCountVars(ctx *genCtx) int {
-	vars := e.Left.genCountVars(ctx)
-	if v := 1 + e.Right.genCountVars(ctx); v > vars {
-		vars = v
+func (e *DynamicCallExpr) genCollectLiterals(ctx *genCtx) {
+	e.Recv.genCollectLiterals(ctx)
+	for _, a := range e.Args {
+		a.genCollectLiterals(ctx)
 	}
-	return vars
 }
 
-func (e *LessThanExpr) genCode(ctx *genCtx) {
-	label_true := ctx.Label()
-	label_false := ctx.Label()
-	label_done := ctx.Label()
-
-	e.genCodeJump(ctx, label_false+"f", label_true+"f")
-	ctx.Printf("%s:\n", label_false)
-	ctx.Printf("\tlea boolean_false, %%eax\n")
-	ctx.Printf("\tjmp %sf\n", label_done)
-	ctx.Printf("%s:\n", label_true)
-	ctx.Printf("\tlea boolean_true, %%eax\n")
-	ctx.Printf("%s:\n", label_done)
-}
-
-func (e *LessThanExpr) genCodeJump(ctx *genCtx, l0, l1 string) {
-	genCodeRawInt(ctx, e.Left)
-	offset, unreserve := ctx.Slot()
-	ctx.Printf("\tmovl %%eax, %d(%%ebp)\n", offset)
-	genCodeRawInt(ctx, e.Right)
-	ctx.Printf("\tmovl %d(%%ebp), %%ebx\n", offset)
-	unreserve()
-	ctx.Printf("\tcmpl %%eax, %%ebx\n")
-	ctx.Printf("\tjl %s\n", l1)
-	ctx.Printf("\tjmp %s\n", l0)
-}
-
-func genArithmetic(ctx *genCtx, left, right Expr, compute func(), box bool) {
-	genCodeRawInt(ctx, left)
-	offset, unreserve := ctx.Slot()
-	ctx.Printf("\tmovl %%eax, %d(%%ebp)\n", offset)
-	genCodeRawInt(ctx, right)
-	ctx.Printf("\tmovl %%eax, %%ecx\n")
-	ctx.Printf("\tmovl %d(%%ebp), %%ebx\n", offset)
-	compute()
-	if box {
-		ctx.Printf("\tmovl %%eax, %d(%%ebp)\n", offset)
-		ctx.Printf("\tmovl $(size_of_Int + 4), %%eax\n")
-		ctx.Printf("\tmovl $tag_of_Int, %%ebx\n")
-		ctx.Printf("\tcall gc_alloc\n")
-		ctx.Printf("\tmovl %d(%%ebp), %%ecx\n", offset)
-		ctx.Printf("\tmovl %%ecx, offset_of_Int.value(%%eax)\n")
+func (e *SuperCallExpr) genCollectLiterals(ctx *genCtx) {
+	for _, a := range e.Args {
+		a.genCollectLiterals(ctx)
 	}
-	unreserve()
 }
 
-func (e *MultiplyExpr) genCollectLiterals(ctx *genCtx) {
-	e.Left.genCollectLiterals(ctx)
-	e.Right.genCollectLiterals(ctx)
+func (e *StaticCallExpr) genCollectLiterals(ctx *genCtx) {
+	e.Recv.genCollectLiterals(ctx)
+	for _, a := range e.Args {
+		a.genCollectLiterals(ctx)
+	}
 }
 
-func (e *MultiplyExpr) genCountStack(ctx *genCtx) int {
-	stack := e.Left.genCountStack(ctx)
-	if s := e.Right.genCountStack(ctx); s > stack {
-		stack = s
-	}
-	return stack
-}
-
-func (e *MultiplyExpr) genCountVars(ctx *genCtx) int {
-	vars := e.Left.genCountVars(ctx)
-	if v := 1 + e.Right.genCountVars(ctx); v > vars {
-		vars = v
-	}
-	return vars
-}
-
-func (e *MultiplyExpr) genCode(ctx *genCtx) {
-	genArithmetic(ctx, e.Left, e.Right, func() {
-		ctx.Printf("\timul %%ebx, %%ecx\n")
-		ctx.Printf("\tmovl %%ecx, %%eax\n")
-	}, true)
-}
-
-func (e *MultiplyExpr) genCodeRawInt(ctx *genCtx) {
-	genArithmetic(ctx, e.Left, e.Right, func() {
-		ctx.Printf("\timul %%ebx, %%ecx\n")
-		ctx.Printf("\tmovl %%ecx, %%eax\n")
-	}, false)
-}
-
-func (e *DivideExpr) genCollectLiterals(ctx *genCtx) {
-	e.Left.genCollectLiterals(ctx)
-	e.Right.genCollectLiterals(ctx)
-}
-
-func (e *DivideExpr) genCountStack(ctx *genCtx) int {
-	stack := e.Left.genCountStack(ctx)
-	if s := e.Right.genCountStack(ctx); s > stack {
-		stack = s
-	}
-	return stack
-}
-
-func (e *DivideExpr) genCountVars(ctx *genCtx) int {
-	vars := e.Left.genCountVars(ctx)
-	if v := 1 + e.Right.genCountVars(ctx); v > vars {
-		vars = v
-	}
-	return vars
-}
-
-func (e *DivideExpr) genCode(ctx *genCtx) {
-	genArithmetic(ctx, e.Left, e.Right, func() {
-		ctx.Printf("\tmovl %%ebx, %%eax\n")
-		ctx.Printf("\tcdq\n")
-		ctx.Printf("\tidiv %%ecx\n")
-	}, true)
-}
-
-func (e *DivideExpr) genCodeRawInt(ctx *genCtx) {
-	genArithmetic(ctx, e.Left, e.Right, func() {
-		ctx.Printf("\tmovl %%ebx, %%eax\n")
-		ctx.Printf("\tcdq\n")
-		ctx.Printf("\tidiv %%ecx\n")
-	}, false)
-}
-
-func (e *AddExpr) genCollectLiterals(ctx *genCtx) {
-	e.Left.genCollectLiterals(ctx)
-	e.Right.genCollectLiterals(ctx)
-}
-
-func (e *AddExpr) genCountStack(ctx *genCtx) int {
-	stack := e.Left.genCountStack(ctx)
-	if s := e.Right.genCountStack(ctx); s > stack {
-		stack = s
-	}
-	return stack
-}
-
-func (e *AddExpr) genCountVars(ctx *genCtx) int {
-	vars := e.Left.genCountVars(ctx)
-	if v := 1 + e.Right.genCountVars(ctx); v > vars {
-		vars = v
-	}
-	return vars
-}
-
-func (e *AddExpr) genCode(ctx *genCtx) {
-	genArithmetic(ctx, e.Left, e.Right, func() {
-		ctx.Printf("\taddl %%ebx, %%ecx\n")
-		ctx.Printf("\tmovl %%ecx, %%eax\n")
-	}, true)
-}
-
-func (e *AddExpr) genCodeRawInt(ctx *genCtx) {
-	genArithmetic(ctx, e.Left, e.Right, func() {
-		ctx.Printf("\taddl %%ebx, %%ecx\n")
-		ctx.Printf("\tmovl %%ecx, %%eax\n")
-	}, false)
-}
-
-func (e *SubtractExpr) genCollectLiterals(ctx *genCtx) {
-	e.Left.genCollectLiterals(ctx)
-	e.Right.genCollectLiterals(ctx)
-}
-
-func (e *SubtractExpr) genCountStack(ctx *genCtx) int {
-	stack := e.Left.genCountStack(ctx)
-	if s := e.Right.genCountStack(ctx); s > stack {
-		stack = s
-	}
-	return stack
-}
-
-func (e *SubtractExpr) genCountVars(ctx *genCtx) int {
-	vars := e.Left.genCountVars(ctx)
-	if v := 1 + e.Right.genCountVars(ctx); v > vars {
-		vars = v
-	}
-	return vars
-}
-
-func (e *SubtractExpr) genCode(ctx *genCtx) {
-	genArithmetic(ctx, e.Left, e.Right, func() {
-		ctx.Printf("\tsubl %%ecx, %%ebx\n")
-		ctx.Printf("\tmovl %%ebx, %%eax\n")
-	}, true)
-}
-
-func (e *SubtractExpr) genCodeRawInt(ctx *genCtx) {
-	genArithmetic(ctx, e.Left, e.Right, func() {
-		ctx.Printf("\tsubl %%ecx, %%ebx\n")
-		ctx.Printf("\tmovl %%ebx, %%eax\n")
-	}, false)
-}
-
-func (e *MatchExpr) genCollectLiterals(ctx *genCtx) {
-	e.Left.genCollectLiterals(ctx)
-	for _, c := range e.Cases {
-		c.Body.genCollectLiterals(ctx)
-	}
-}
-
-func (e *MatchExpr) genCountStack(ctx *genCtx) int {
-	stack := e.Left.genCountStack(ctx)
-	for _, c := range e.Cases {
-		if s := c.Body.genCountStack(ctx); s > stack {
-			stack = s
-		}
-	}
-	return stack
-}
-
-func (e *MatchExpr) genCountVars(ctx *genCtx) int {
-	vars := e.Left.genCountVars(ctx)
-	for _, c := range e.Cases {
-		if v := c.Body.genCountVars(ctx); v > vars {
-			vars = v
-		}
-	}
-	return vars + 1
-}
-
-func (e *MatchExpr) genCode(ctx *genCtx) {
-	label_null := ctx.Label()
-	label_done := ctx.Label()
-
-	e.Left.genCode(ctx)
-	offset, unreserve := ctx.Slot()
-	e.Offset = offset
-	ctx.Printf("\tmovl %%eax, %d(%%ebp)\n", offset)
-	ctx.Printf("\ttest %%eax, %%eax\n")
-	ctx.Printf("\tjz %sf\n", label_null)
-	ctx.Printf("\tmovl tag_offset(%%eax), %%eax\n")
-	ctx.Printf("%s:\n", label_null)
-
-	labels := make([]string, len(e.Cases))
-	for i := range labels {
-		labels[i] = ctx.Label()
-	}
-
-	for i, c := range e.Cases {
-		if c.Type.Class.Order == c.Type.Class.MaxOrder {
-			ctx.Printf("\tcmpl $%d, %%eax\n", c.Type.Class.Order)
-			ctx.Printf("\tje %sf\n", labels[i])
-		} else {
-			label_skip := ctx.Label()
-			ctx.Printf("\tcmpl $%d, %%eax\n", c.Type.Class.Order)
-			ctx.Printf("\tjl %sf\n", label_skip)
-			ctx.Printf("\tcmpl $%d, %%eax\n", c.Type.Class.MaxOrder)
-			ctx.Printf("\tjle %sf\n", labels[i])
-			ctx.Printf("%s:\n", label_skip)
-		}
-	}
-	ctx.Printf("\tjmp runtime.case_panic\n")
-
-	for i, c := range e.Cases {
-		ctx.Printf("%s:\n", labels[i])
-		c.genCode(ctx)
-		ctx.Printf("\tjmp %sf\n", label_done)
-	}
-
-	ctx.Printf("%s:\n", label_done)
-	ctx.Printf("\tmovl %d(%%ebp), %%ebx\n", offset)
-	genGC(ctx, "%ebx")
-	unreserve()
-}
-
-func (e *MatchExpr) genCodeRawInt(ctx *genCtx) {
-	label_null := ctx.Label()
-	label_done := ctx.Label()
-
-	e.Left.genCode(ctx)
-	offset, unreserve := ctx.Slot()
-	e.Offset = offset
-	ctx.Printf("\tmovl %%eax, %d(%%ebp)\n", offset)
-	ctx.Printf("\ttest %%eax, %%eax\n")
-	ctx.Printf("\tjz %sf\n", label_null)
-	ctx.Printf("\tmovl tag_offset(%%eax), %%eax\n")
-	ctx.Printf("%s:\n", label_null)
-
-	labels := make([]string, len(e.Cases))
-	for i := range labels {
-		labels[i] = ctx.Label()
-	}
-
-	for i, c := range e.Cases {
-		if c.Type.Class.Order == c.Type.Class.MaxOrder {
-			ctx.Printf("\tcmpl $%d, %%eax\n", c.Type.Class.Order)
-			ctx.Printf("\tje %sf\n", labels[i])
-		} else {
-			label_skip := ctx.Label()
-			ctx.Printf("\tcmpl $%d, %%eax\n", c.Type.Class.Order)
-			ctx.Printf("\tjl %sf\n", label_skip)
-			ctx.Printf("\tcmpl $%d, %%eax\n", c.Type.Class.MaxOrder)
-			ctx.Printf("\tjle %sf\n", labels[i])
-			ctx.Printf("%s:\n", label_skip)
-		}
-	}
-	ctx.Printf("\tjmp runtime.case_panic\n")
-
-	for i, c := range e.Cases {
-		ctx.Printf("%s:\n", labels[i])
-		c.genCodeRawInt(ctx)
-		ctx.Printf("\tjmp %sf\n", label_done)
-	}
-
-	ctx.Printf("%s:\n", label_done)
-	ctx.Printf("\tmovl %d(%%ebp), %%ebx\n", offset)
-	genGC(ctx, "%ebx")
-	unreserve()
-}
-
-func (e *MatchExpr) genCodeJump(ctx *genCtx, l0, l1 string) {
-	label_null := ctx.Label()
-	label_true := ctx.Label()
-	label_false := ctx.Label()
-
-	e.Left.genCode(ctx)
-	offset, unreserve := ctx.Slot()
-	e.Offset = offset
-	ctx.Printf("\tmovl %%eax, %d(%%ebp)\n", offset)
-	ctx.Printf("\ttest %%eax, %%eax\n")
-	ctx.Printf("\tjz %sf\n", label_null)
-	ctx.Printf("\tmovl tag_offset(%%eax), %%eax\n")
-	ctx.Printf("%s:\n", label_null)
-
-	labels := make([]string, len(e.Cases))
-	for i := range labels {
-		labels[i] = ctx.Label()
-	}
-
-	for i, c := range e.Cases {
-		if c.Type.Class.Order == c.Type.Class.MaxOrder {
-			ctx.Printf("\tcmpl $%d, %%eax\n", c.Type.Class.Order)
-			ctx.Printf("\tje %sf\n", labels[i])
-		} else {
-			label_skip := ctx.Label()
-			ctx.Printf("\tcmpl $%d, %%eax\n", c.Type.Class.Order)
-			ctx.Printf("\tjl %sf\n", label_skip)
-			ctx.Printf("\tcmpl $%d, %%eax\n", c.Type.Class.MaxOrder)
-			ctx.Printf("\tjle %sf\n", labels[i])
-			ctx.Printf("%s:\n", label_skip)
-		}
-	}
-	ctx.Printf("\tjmp runtime.case_panic\n")
-
-	for i, c := range e.Cases {
-		ctx.Printf("%s:\n", labels[i])
-		c.genCodeJump(ctx, label_false+"f", label_true+"f")
-	}
-
-	ctx.Printf("%s:\n", label_false)
-	ctx.Printf("\tmovl %d(%%ebp), %%ebx\n", offset)
-	genGC(ctx, "%ebx")
-	ctx.Printf("\tjmp %s\n", l0)
-
-	ctx.Printf("%s:\n", label_true)
-	ctx.Printf("\tmovl %d(%%ebp), %%ebx\n", offset)
-	genGC(ctx, "%ebx")
-	ctx.Printf("\tjmp %s\n", l1)
-
-	unreserve()
-}
-
-func (e *MatchExpr) genCodeUnused(ctx *genCtx) {
-	label_null := ctx.Label()
-	label_done := ctx.Label()
-
-	e.Left.genCode(ctx)
-	offset, unreserve := ctx.Slot()
-	e.Offset = offset
-	ctx.Printf("\tmovl %%eax, %d(%%ebp)\n", offset)
-	ctx.Printf("\ttest %%eax, %%eax\n")
-	ctx.Printf("\tjz %sf\n", label_null)
-	ctx.Printf("\tmovl tag_offset(%%eax), %%eax\n")
-	ctx.Printf("%s:\n", label_null)
-
-	labels := make([]string, len(e.Cases))
-	for i := range labels {
-		labels[i] = ctx.Label()
-	}
-
-	for i, c := range e.Cases {
-		if c.Type.Class.Order == c.Type.Class.MaxOrder {
-			ctx.Printf("\tcmpl $%d, %%eax\n", c.Type.Class.Order)
-			ctx.Printf("\tje %sf\n", labels[i])
-		} else {
-			label_skip := ctx.Label()
-			ctx.Printf("\tcmpl $%d, %%eax\n", c.Type.Class.Order)
-			ctx.Printf("\tjl %sf\n", label_skip)
-			ctx.Printf("\tcmpl $%d, %%eax\n", c.Type.Class.MaxOrder)
-			ctx.Printf("\tjle %sf\n", labels[i])
-			ctx.Printf("%s:\n", label_skip)
-		}
-	}
-	ctx.Printf("\tjmp runtime.case_panic\n")
-
-	for i, c := range e.Cases {
-		ctx.Printf("%s:\n", labels[i])
-		c.genCodeUnused(ctx)
-		ctx.Printf("\tjmp %sf\n", label_done)
-	}
-
-	ctx.Printf("%s:\n", label_done)
-	ctx.Printf("\tmovl %d(%%ebp), %%ebx\n", offset)
-	genGC(ctx, "%ebx")
-	unreserve()
-}
-
-func (e *DynamicCallExpr) genCollectLiterals(ctx *genCtx) {
-	e.Recv.genCollectLiterals(ctx)
-	for _, a := range e.Args {
-		a.genCollectLiterals(ctx)
-	}
-}
-
-func (e *DynamicCallExpr) genCountStack(ctx *genCtx) int {
-	stack := e.Recv.genCountStack(ctx)
-	for i, a := range e.Args {
-		if s := 1 + i + a.genCountStack(ctx); s > stack {
-			stack = s
-		}
-	}
-	if s := 1 + len(e.Args) + 1; s > stack {
-		stack = s
-	}
-	return stack
-}
-
-func (e *DynamicCallExpr) genCountVars(ctx *genCtx) int {
-	vars := e.Recv.genCountVars(ctx)
-	for _, a := range e.Args {
-		if v := a.genCountVars(ctx); v > vars {
-			vars = v
-		}
-	}
-	return vars
-}
-
-func (e *DynamicCallExpr) genCode(ctx *genCtx) {
-	e.Recv.genCode(ctx)
-	if !e.RecvNotNull {
-		ctx.Printf("\ttest %%eax, %%eax\n")
-		ctx.Printf("\tjz runtime.null_panic\n")
-	}
-	ctx.Printf("\tpush %%eax\n")
-	for _, a := range e.Args {
-		a.genCode(ctx)
-		ctx.Printf("\tpush %%eax\n")
-	}
-	if e.HasOverride || !ctx.opt.OptDispatch {
-		ctx.Printf("\tmovl %d(%%esp), %%eax\n", len(e.Args)*4)
-		ctx.Printf("\tmovl tag_offset(%%eax), %%eax\n")
-		ctx.Printf("\tshll $2, %%eax\n")
-		ctx.Printf("\tmovl method_tables(%%eax), %%eax\n")
-		ctx.Printf("\tmovl method_offset_%s.%s(%%eax), %%eax\n", e.Name.Method.Parent.Type.Name, e.Name.Method.Name.Name)
-		ctx.Printf("\tcall *%%eax\n")
-	} else {
-		ctx.Printf("\tcall %s.%s\n", e.Name.Method.Parent.Type.Name, e.Name.Method.Name.Name)
-	}
-}
-
-func (e *SuperCallExpr) genCollectLiterals(ctx *genCtx) {
-	for _, a := range e.Args {
-		a.genCollectLiterals(ctx)
-	}
-}
-
-func (e *SuperCallExpr) genCountStack(ctx *genCtx) int {
-	stack := 1
-	for i, a := range e.Args {
-		if s := 1 + i + a.genCountStack(ctx); s > stack {
-			stack = s
-		}
-	}
-	if s := 1 + len(e.Args) + 1; s > stack {
-		stack = s
-	}
-	return stack
-}
-
-func (e *SuperCallExpr) genCountVars(ctx *genCtx) int {
-	vars := 0
-	for _, a := range e.Args {
-		if v := a.genCountVars(ctx); v > vars {
-			vars = v
-		}
-	}
-	return vars
-}
-
-func (e *SuperCallExpr) genCode(ctx *genCtx) {
-	ctx.Printf("\tmovl %d(%%ebp), %%eax\n", ctx.this)
-	genRef(ctx, "%eax")
-	ctx.Printf("\tpush %%eax\n")
-	for _, a := range e.Args {
-		a.genCode(ctx)
-		ctx.Printf("\tpush %%eax\n")
-	}
-	ctx.Printf("\tcall %s.%s\n", e.Name.Method.Parent.Type.Name, e.Name.Method.Name.Name)
-}
-
-func (e *StaticCallExpr) genCollectLiterals(ctx *genCtx) {
-	e.Recv.genCollectLiterals(ctx)
-	for _, a := range e.Args {
-		a.genCollectLiterals(ctx)
-	}
-}
-
-func (e *StaticCallExpr) genCountStack(ctx *genCtx) int {
-	stack := e.Recv.genCountStack(ctx)
-	for i, a := range e.Args {
-		if s := 1 + i + a.genCountStack(ctx); s > stack {
-			stack = s
-		}
-	}
-	if s := 1 + len(e.Args) + 1; s > stack {
-		stack = s
-	}
-	return stack
-}
-
-func (e *StaticCallExpr) genCountVars(ctx *genCtx) int {
-	vars := e.Recv.genCountVars(ctx)
-	for _, a := range e.Args {
-		if v := a.genCountVars(ctx); v > vars {
-			vars = v
-		}
-	}
-	return vars
-}
-
-func (e *StaticCallExpr) genCode(ctx *genCtx) {
-	e.Recv.genCode(ctx)
-	ctx.Printf("\tpush %%eax\n")
-	for _, a := range e.Args {
-		a.genCode(ctx)
-		ctx.Printf("\tpush %%eax\n")
-	}
-	ctx.Printf("\tcall %s.%s\n", e.Name.Method.Parent.Type.Name, e.Name.Method.Name.Name)
-}
-
-func (e *AllocExpr) genCollectLiterals(ctx *genCtx) {
-}
-
-func (e *AllocExpr) genCountStack(ctx *genCtx) int {
-	return 0
-}
-
-func (e *AllocExpr) genCountVars(ctx *genCtx) int {
-	return 0
-}
-
-func (e *AllocExpr) genCode(ctx *genCtx) {
-	ctx.Printf("\tmovl $size_of_%s, %%eax\n", e.Type.Name)
-	ctx.Printf("\tmovl $tag_of_%s, %%ebx\n", e.Type.Name)
-	ctx.Printf("\tcall gc_alloc\n")
-	var gen func(c *Class)
-	gen = func(c *Class) {
-		if c == nativeClass {
-			return
-		}
-		gen(c.Extends.Type.Class)
-		for _, f := range c.Features {
-			if a, ok := f.(*Attribute); ok {
-				var sym string
-				switch a.Type.Name {
-				case "Int":
-					sym = "int_lit_0"
-				case "Boolean":
-					sym = "boolean_false"
-				case "Unit":
-					sym = "unit_lit"
-				default:
-					continue
-				}
-				ctx.Printf("\tmovl $%s, offset_of_%s.%s(%%eax)\n", sym, c.Type.Name, a.Name.Name)
-			}
-		}
-	}
-	gen(e.Type.Class)
+func (e *AllocExpr) genCollectLiterals(ctx *genCtx) {
 }
 
 func (e *AssignExpr) genCollectLiterals(ctx *genCtx) {
 	e.Expr.genCollectLiterals(ctx)
 }
 
-func (e *AssignExpr) genCountStack(ctx *genCtx) int {
-	return e.Expr.genCountVars(ctx)
-}
-
-func (e *AssignExpr) genCountVars(ctx *genCtx) int {
-	return e.Expr.genCountVars(ctx)
-}
-
-func (e *AssignExpr) genCode(ctx *genCtx) {
-	e.genCodeUnused(ctx)
-	ctx.Printf("\tleal unit_lit, %%eax\n")
-}
-
-func (e *AssignExpr) genCodeUnused(ctx *genCtx) {
-	var rawInt bool
-	if e.Name.Object.RawInt() && ctx.opt.OptInt {
-		if raw, ok := e.Expr.(ArithmeticExpr); ok {
-			rawInt = true
-			raw.genCodeRawInt(ctx)
-		}
-	}
-	if !rawInt {
-		e.Expr.genCode(ctx)
-	}
-	ctx.Printf("\tmovl %s, %%edx\n", e.Name.Object.Base(ctx.this))
-	if e.Name.Object.Stack() {
-		if !e.Name.Object.RawInt() || !ctx.opt.OptInt {
-			ctx.Printf("\tmovl %s(%%edx), %%ebx\n", e.Name.Object.Offs())
-			genGC(ctx, "%ebx")
-		}
-	} else if !rawInt {
-		genGC(ctx, "%eax")
-	}
-	if e.Name.Object.RawInt() && !rawInt && ctx.opt.OptInt {
-		if e.Name.Object.Stack() {
-			genGC(ctx, "%eax")
-		}
-		ctx.Printf("\tmovl offset_of_Int.value(%%eax), %%eax\n")
-	}
-	ctx.Printf("\tmovl %%eax, %s(%%edx)\n", e.Name.Object.Offs())
-}
-
 func (e *VarExpr) genCollectLiterals(ctx *genCtx) {
 	e.Init.genCollectLiterals(ctx)
 	e.Body.genCollectLiterals(ctx)
 }
 
-func (e *VarExpr) genCountStack(ctx *genCtx) int {
-	stack := e.Init.genCountStack(ctx)
-	if s := e.Body.genCountStack(ctx); s > stack {
-		stack = s
-	}
-	return stack
-}
-
-func (e *VarExpr) genCountVars(ctx *genCtx) int {
-	vars := e.Init.genCountVars(ctx)
-	if v := e.Body.genCountVars(ctx); v > vars {
-		vars = v
-	}
-	return vars + 1
-}
-
-func (e *VarExpr) genCodeShared(ctx *genCtx, body func()) {
-	var rawInt bool
-	if e.RawInt() && ctx.opt.OptInt {
-		if raw, ok := e.Init.(ArithmeticExpr); ok {
-			rawInt = true
-			raw.genCodeRawInt(ctx)
-		}
-	}
-	if !rawInt {
-		e.Init.genCode(ctx)
-		if e.RawInt() && ctx.opt.OptInt {
-			genGC(ctx, "%eax")
-			ctx.Printf("\tmovl offset_of_Int.value(%%eax), %%eax\n")
-		}
-	}
-	offset, unreserve := ctx.Slot()
-	e.Offset = offset
-	ctx.Printf("\tmovl %%eax, %d(%%ebp)\n", offset)
-	body()
-	ctx.Printf("\tmovl %d(%%ebp), %%ebx\n", offset)
-	if !e.RawInt() || !ctx.opt.OptInt {
-		genGC(ctx, "%ebx")
-	}
-	unreserve()
-}
-
-func (e *VarExpr) genCode(ctx *genCtx) {
-	e.genCodeShared(ctx, func() {
-		e.Body.genCode(ctx)
-	})
-}
-
-func (e *VarExpr) genCodeRawInt(ctx *genCtx) {
-	e.genCodeShared(ctx, func() {
-		genCodeRawInt(ctx, e.Body)
-	})
-}
-
-func (e *VarExpr) genCodeUnused(ctx *genCtx) {
-	e.genCodeShared(ctx, func() {
-		genCodeUnused(ctx, e.Body)
-	})
-}
-
 func (e *ChainExpr) genCollectLiterals(ctx *genCtx) {
 	e.Pre.genCollectLiterals(ctx)
 	e.Expr.genCollectLiterals(ctx)
 }
 
-func (e *ChainExpr) genCountStack(ctx *genCtx) int {
-	stack := e.Pre.genCountStack(ctx)
-	if s := e.Expr.genCountStack(ctx); s > stack {
-		stack = s
-	}
-	return stack
-}
-
-func (e *ChainExpr) genCountVars(ctx *genCtx) int {
-	vars := e.Pre.genCountVars(ctx)
-	if v := e.Expr.genCountVars(ctx); v > vars {
-		vars = v
-	}
-	return vars
-}
-
-func (e *ChainExpr) genCode(ctx *genCtx) {
-	genCodeUnused(ctx, e.Pre)
-	e.Expr.genCode(ctx)
-}
-
-func (e *ChainExpr) genCodeRawInt(ctx *genCtx) {
-	genCodeUnused(ctx, e.Pre)
-	genCodeRawInt(ctx, e.Expr)
-}
-
-func (e *ChainExpr) genCodeJump(ctx *genCtx, l0, l1 string) {
-	genCodeUnused(ctx, e.Pre)
-	genCodeJump(ctx, e.Expr, l0, l1)
-}
-
-func (e *ChainExpr) genCodeUnused(ctx *genCtx) {
-	genCodeUnused(ctx, e.Pre)
-	genCodeUnused(ctx, e.Expr)
-}
-
 func (e *ThisExpr) genCollectLiterals(ctx *genCtx) {
 }
 
-func (e *ThisExpr) genCountStack(ctx *genCtx) int {
-	return 0
-}
-
-func (e *ThisExpr) genCountVars(ctx *genCtx) int {
-	return 0
-}
-
-func (e *ThisExpr) genCode(ctx *genCtx) {
-	ctx.Printf("\tmovl %d(%%ebp), %%eax\n", ctx.this)
-	genRef(ctx, "%eax")
-}
-
-func (e *ThisExpr) genCodeUnused(ctx *genCtx) {
-}
-
 func (e *NullExpr) genCollectLiterals(ctx *genCtx) {
 }
 
-func (e *NullExpr) genCountStack(ctx *genCtx) int {
-	return 0
-}
-
-func (e *NullExpr) genCountVars(ctx *genCtx) int {
-	return 0
-}
-
-func (e *NullExpr) genCode(ctx *genCtx) {
-	ctx.Printf("\tmovl $0, %%eax\n")
-}
-
-func (e *NullExpr) genCodeUnused(ctx *genCtx) {
-}
-
 func (e *UnitExpr) genCollectLiterals(ctx *genCtx) {
 }
 
-func (e *UnitExpr) genCountStack(ctx *genCtx) int {
-	return 0
-}
-
-func (e *UnitExpr) genCountVars(ctx *genCtx) int {
-	return 0
-}
-
-func (e *UnitExpr) genCode(ctx *genCtx) {
-	ctx.Printf("\tleal unit_lit, %%eax\n")
-}
-
-func (e *UnitExpr) genCodeUnused(ctx *genCtx) {
-}
-
 func (e *NameExpr) genCollectLiterals(ctx *genCtx) {
 }
 
-func (e *NameExpr) genCountStack(ctx *genCtx) int {
-	return 0
-}
-
-func (e *NameExpr) genCountVars(ctx *genCtx) int {
-	return 0
-}
-
-func (e *NameExpr) genCode(ctx *genCtx) {
-	if e.Name.Object.RawInt() && ctx.opt.OptInt {
-		ctx.Printf("\tmovl $(size_of_Int + 4), %%eax\n")
-		ctx.Printf("\tmovl $tag_of_Int, %%ebx\n")
-		ctx.Printf("\tcall gc_alloc\n")
-		ctx.Printf("\tmovl %s, %%edx\n", e.Name.Object.Base(ctx.this))
-		ctx.Printf("\tmovl %s(%%edx), %%ebx\n", e.Name.Object.Offs())
-		ctx.Printf("\tmovl %%ebx, offset_of_Int.value(%%eax)\n")
-	} else {
-		ctx.Printf("\tmovl %s, %%edx\n", e.Name.Object.Base(ctx.this))
-		ctx.Printf("\tmovl %s(%%edx), %%eax\n", e.Name.Object.Offs())
-		genRef(ctx, "%eax")
-	}
-}
-
-func (e *NameExpr) genCodeRawInt(ctx *genCtx) {
-	ctx.Printf("\tmovl %s, %%edx\n", e.Name.Object.Base(ctx.this))
-	ctx.Printf("\tmovl %s(%%edx), %%eax\n", e.Name.Object.Offs())
-	if !e.Name.Object.RawInt() || !ctx.opt.OptInt {
-		ctx.Printf("\tmovl offset_of_Int.value(%%eax), %%eax\n")
-	}
-}
-
-func (e *NameExpr) genCodeUnused(ctx *genCtx) {
-}
-
 func (e *StringExpr) genCollectLiterals(ctx *genCtx) {
 	e.Lit.LitID = ctx.AddString(e.Lit.Str)
 }
 
-func (e *StringExpr) genCountStack(ctx *genCtx) int {
-	return 0
-}
-
-func (e *StringExpr) genCountVars(ctx *genCtx) int {
-	return 0
-}
-
-func (e *StringExpr) genCode(ctx *genCtx) {
-	ctx.Printf("\tleal string_lit_%d, %%eax\n", e.Lit.LitID)
-}
-
-func (e *StringExpr) genCodeUnused(ctx *genCtx) {
-}
-
 func (e *BoolExpr) genCollectLiterals(ctx *genCtx) {
 }
 
-func (e *BoolExpr) genCountStack(ctx *genCtx) int {
-	return 0
-}
-
-func (e *BoolExpr) genCountVars(ctx *genCtx) int {
-	return 0
-}
-
-func (e *BoolExpr) genCode(ctx *genCtx) {
-	if e.Lit.Bool {
-		ctx.Printf("\tleal boolean_true, %%eax\n")
-	} else {
-		ctx.Printf("\tleal boolean_false, %%eax\n")
-	}
-}
-
-func (e *BoolExpr) genCodeJump(ctx *genCtx, l0, l1 string) {
-	if e.Lit.Bool {
-		ctx.Printf("\tjmp %s\n", l1)
-	} else {
-		ctx.Printf("\tjmp %s\n", l0)
-	}
-}
-
-func (e *BoolExpr) genCodeUnused(ctx *genCtx) {
-}
-
 func (e *IntExpr) genCollectLiterals(ctx *genCtx) {
 	e.Lit.LitID = ctx.AddInt(e.Lit.Int)
 }
 
-func (e *IntExpr) genCountStack(ctx *genCtx) int {
-	return 0
-}
-
-func (e *IntExpr) genCountVars(ctx *genCtx) int {
-	return 0
-}
-
-func (e *IntExpr) genCode(ctx *genCtx) {
-	ctx.Printf("\tleal int_lit_%d, %%eax\n", e.Lit.LitID)
-}
-
-func (e *IntExpr) genCodeRawInt(ctx *genCtx) {
-	ctx.Printf("\tmovl $%d, %%eax\n", e.Lit.Int)
-}
-
-func (e *IntExpr) genCodeUnused(ctx *genCtx) {
-}
-
 func (e *NativeExpr) genCollectLiterals(ctx *genCtx) {
 }
 
-func (e *NativeExpr) genCountStack(ctx *genCtx) int {
-	panic("NativeExpr.genCountStack should never be called")
-}
-
-func (e *NativeExpr) genCountVars(ctx *genCtx) int {
-	panic("NativeExpr.genCountVars should never be called")
-}
-
-func (e *NativeExpr) genCode(ctx *genCtx) {
-	panic("NativeExpr.genCode should never be called")
-}
-
-func (c *Case) genCode(ctx *genCtx) {
-	c.Body.genCode(ctx)
-}
-
-func (c *Case) genCodeRawInt(ctx *genCtx) {
-	genCodeRawInt(ctx, c.Body)
-}
-
-func (c *Case) genCodeJump(ctx *genCtx, l0, l1 string) {
-	genCodeJump(ctx, c.Body, l0, l1)
-}
-
-func (c *Case) genCodeUnused(ctx *genCtx) {
-	genCodeUnused(ctx, c.Body)
+func (e *BadExpr) genCollectLiterals(ctx *genCtx) {
+	panic("BadExpr.genCollectLiterals should never be called")
 }
@@ -0,0 +1,281 @@
+package ast
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"go/token"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Severity is the kind of a Diagnostic.
+type Severity string
+
+const (
+	// SeverityError is a problem that prevents the program from being
+	// compiled.
+	SeverityError Severity = "error"
+	// SeverityWarning is a likely mistake that does not prevent the
+	// program from being compiled.
+	SeverityWarning Severity = "warning"
+	// SeverityNote is extra information about another Diagnostic.
+	SeverityNote Severity = "note"
+	// SeverityRemark is an explanation of what the optimizer did, reported
+	// when Options.OptReport is set.
+	SeverityRemark Severity = "remark"
+)
+
+// Diagnostic is a message from the compiler about a range of source code.
+type Diagnostic struct {
+	// Severity is the kind of message this is.
+	Severity Severity
+	// Code is a short name for the kind of problem that does not change
+	// between versions of the compiler. It is empty for notes.
+	Code string
+	// Pos is the position of the first byte the message is about. It may
+	// be token.NoPos for problems that are not tied to any source code.
+	Pos token.Pos
+	// End is the position immediately after the last byte the message is
+	// about, or token.NoPos if it is not known.
+	End token.Pos
+	// Message is the human-readable description of the problem.
+	Message string
+	// Notes are related messages, such as the location of a previous
+	// declaration.
+	Notes []*Diagnostic
+}
+
+// Note adds a related message to d.
+func (d *Diagnostic) Note(pos, end token.Pos, message string) *Diagnostic {
+	n := &Diagnostic{
+		Severity: SeverityNote,
+		Pos:      pos,
+		End:      end,
+		Message:  message,
+	}
+	d.Notes = append(d.Notes, n)
+	return n
+}
+
+// NoteIdent adds a related message about id to d.
+func (d *Diagnostic) NoteIdent(id *Ident, message string) *Diagnostic {
+	return d.Note(id.Pos, id.End, message)
+}
+
+// ReportError writes an error that is not about any source code, such as
+// a file that could not be read, in the format requested by opt.
+func ReportError(opt Options, code, message string) {
+	d := &Diagnostic{
+		Severity: SeverityError,
+		Code:     code,
+		Message:  message,
+	}
+
+	switch {
+	case opt.Report != nil, opt.Diagnostics == "json", opt.Diagnostics == "pretty":
+		writeDiagnostics(opt, nil, nil, []*Diagnostic{d})
+	default:
+		fmt.Fprintln(opt.Errors, message)
+	}
+}
+
+type jsonPosition struct {
+	Filename string `json:"file"`
+	Offset   int    `json:"offset"`
+	Line     int    `json:"line"`
+	Column   int    `json:"column"`
+}
+
+type jsonDiagnostic struct {
+	Severity Severity          `json:"severity"`
+	Code     string            `json:"code,omitempty"`
+	Pos      *jsonPosition     `json:"pos,omitempty"`
+	End      *jsonPosition     `json:"end,omitempty"`
+	Message  string            `json:"message"`
+	Notes    []*jsonDiagnostic `json:"notes,omitempty"`
+}
+
+func toJSONPosition(position func(token.Pos) token.Position, pos token.Pos) *jsonPosition {
+	if !pos.IsValid() || position == nil {
+		return nil
+	}
+
+	p := position(pos)
+
+	return &jsonPosition{
+		Filename: p.Filename,
+		Offset:   p.Offset,
+		Line:     p.Line,
+		Column:   p.Column,
+	}
+}
+
+func toJSONDiagnostic(position func(token.Pos) token.Position, d *Diagnostic) *jsonDiagnostic {
+	j := &jsonDiagnostic{
+		Severity: d.Severity,
+		Code:     d.Code,
+		Pos:      toJSONPosition(position, d.Pos),
+		End:      toJSONPosition(position, d.End),
+		Message:  d.Message,
+	}
+
+	for _, n := range d.Notes {
+		j.Notes = append(j.Notes, toJSONDiagnostic(position, n))
+	}
+
+	return j
+}
+
+// writeDiagnostics writes ds to opt.Errors. In the text format, each
+// diagnostic and each note is one line. In the pretty format, each one is
+// followed by the line of source code it is about. In the JSON format, each
+// diagnostic is one JSON object per line with its notes nested inside it.
+func writeDiagnostics(opt Options, position func(token.Pos) token.Position, sources map[string][]byte, ds []*Diagnostic) {
+	if opt.Report != nil {
+		for _, d := range ds {
+			opt.Report(d)
+		}
+		return
+	}
+
+	switch opt.Diagnostics {
+	case "json":
+		enc := json.NewEncoder(opt.Errors)
+		for _, d := range ds {
+			if err := enc.Encode(toJSONDiagnostic(position, d)); err != nil {
+				panic(err)
+			}
+		}
+
+	case "pretty":
+		w := &prettyWriter{
+			w:        opt.Errors,
+			color:    opt.Color,
+			position: position,
+			sources:  sources,
+		}
+		for _, d := range ds {
+			w.Write(d)
+			for _, n := range d.Notes {
+				w.Write(n)
+			}
+		}
+
+	default:
+		for _, d := range ds {
+			if d.Severity == SeverityWarning || d.Severity == SeverityRemark {
+				fmt.Fprintf(opt.Errors, "%v: %s: %s%s\n", position(d.Pos), d.Severity, d.Message, flagHint(d))
+			} else {
+				fmt.Fprintf(opt.Errors, "%v: %s%s\n", position(d.Pos), d.Message, flagHint(d))
+			}
+			for _, n := range d.Notes {
+				fmt.Fprintf(opt.Errors, "%v: (%s)\n", position(n.Pos), n.Message)
+			}
+		}
+	}
+}
+
+// flagHint returns the flag that controls d if d is a warning, for the end of
+// the message.
+func flagHint(d *Diagnostic) string {
+	if lookupWarning(d.Code) == nil {
+		return ""
+	}
+	if d.Severity == SeverityError {
+		return " [-Werror=" + d.Code + "]"
+	}
+	return " [-W" + d.Code + "]"
+}
+
+const (
+	colorReset   = "\x1b[0m"
+	colorBold    = "\x1b[1m"
+	colorRed     = "\x1b[1;31m"
+	colorMagenta = "\x1b[1;35m"
+	colorCyan    = "\x1b[1;36m"
+	colorGreen   = "\x1b[1;32m"
+	colorBlue    = "\x1b[1;34m"
+)
+
+type prettyWriter struct {
+	w        io.Writer
+	color    bool
+	position func(token.Pos) token.Position
+	sources  map[string][]byte
+}
+
+func (w *prettyWriter) Color(color, s string) string {
+	if !w.color {
+		return s
+	}
+	return color + s + colorReset
+}
+
+// Write writes a diagnostic in the form
+//
+//	file.cool:1:22: error: message
+//	    1 | class Main() { { abort(); } };
+//	      |                      ^^^^^
+func (w *prettyWriter) Write(d *Diagnostic) {
+	severity := w.Color(colorRed, string(d.Severity)+":")
+	switch d.Severity {
+	case SeverityWarning:
+		severity = w.Color(colorMagenta, string(d.Severity)+":")
+	case SeverityNote:
+		severity = w.Color(colorCyan, string(d.Severity)+":")
+	case SeverityRemark:
+		severity = w.Color(colorGreen, string(d.Severity)+":")
+	}
+	message := w.Color(colorBold, d.Message) + flagHint(d)
+
+	if !d.Pos.IsValid() || w.position == nil {
+		fmt.Fprintf(w.w, "%s %s\n", severity, message)
+		return
+	}
+
+	pos := w.position(d.Pos)
+	fmt.Fprintf(w.w, "%s %s %s\n", w.Color(colorBold, pos.String()+":"), severity, message)
+
+	src, ok := w.sources[pos.Filename]
+	if !ok || pos.Offset > len(src) {
+		return
+	}
+
+	start := bytes.LastIndexByte(src[:pos.Offset], '\n') + 1
+	line := src[start:]
+	if i := bytes.IndexByte(line, '\n'); i != -1 {
+		line = line[:i]
+	}
+	line = bytes.TrimRight(line, "\r")
+
+	// underline the whole range if it is on one line.
+	width := 1
+	if d.End.IsValid() {
+		if end := w.position(d.End); end.Filename == pos.Filename && end.Offset > pos.Offset {
+			width = end.Offset - pos.Offset
+		}
+	}
+	if rest := len(line) - (pos.Offset - start); width > rest {
+		width = rest
+	}
+	if width < 1 {
+		width = 1
+	}
+
+	// keep tabs in the indentation so that the caret lines up with the
+	// code no matter how wide the terminal displays tabs.
+	indent := append([]byte(nil), line[:pos.Offset-start]...)
+	for i, c := range indent {
+		if c != '\t' {
+			indent[i] = ' '
+		}
+	}
+
+	number := strconv.Itoa(pos.Line)
+	gutter := strings.Repeat(" ", len(number))
+
+	fmt.Fprintf(w.w, " %s %s %s\n", w.Color(colorBlue, number), w.Color(colorBlue, "|"), line)
+	fmt.Fprintf(w.w, " %s %s %s%s\n", gutter, w.Color(colorBlue, "|"), indent, w.Color(colorGreen, strings.Repeat("^", width)))
+}
@@ -0,0 +1,352 @@
+package ast
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"io"
+	"strings"
+)
+
+// docClass is the documentation for one class, collected from the program
+// after Semant.
+type docClass struct {
+	class *Class
+	// ancestors is the chain of parent classes, starting with Any.
+	ancestors []*Class
+	children  []*Class
+
+	attributes []*Attribute
+	methods    []*docMethod
+	// inherited is the methods that are declared in an ancestor and not
+	// overridden, grouped by the class they are declared in, starting
+	// with the parent class.
+	inherited []*docInherited
+}
+
+type docMethod struct {
+	method *Method
+	// doc is the method's doc comment, or the doc comment of the method
+	// it overrides if it doesn't have one.
+	doc string
+	// overrides is the method in the parent class that this method
+	// overrides, if any.
+	overrides *Method
+	// overriddenIn is the set of subclasses that override this method.
+	overriddenIn []*Class
+}
+
+type docInherited struct {
+	from    *Class
+	methods []*Method
+}
+
+// WriteDoc writes documentation for every class in the program to w as a
+// single page in "html" or "markdown" format. It includes the class
+// hierarchy, the signature and doc comment of each class, attribute, and
+// method, and the methods each class inherits or overrides. WriteDoc must
+// be called after Semant.
+func (p *Program) WriteDoc(w io.Writer, format string) error {
+	children := make(map[*Class][]*Class)
+	for _, c := range p.Ordered {
+		if parent := c.Extends.Type.Class; parent != nativeClass {
+			children[parent] = append(children[parent], c)
+		}
+	}
+
+	var classes []*docClass
+	for _, c := range p.Ordered {
+		classes = append(classes, p.docClass(c, children[c]))
+	}
+
+	var buf bytes.Buffer
+	switch format {
+	case "html":
+		writeDocHTML(&buf, classes)
+	case "markdown":
+		writeDocMarkdown(&buf, classes)
+	default:
+		return fmt.Errorf("unknown documentation format %q", format)
+	}
+
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+func (p *Program) docClass(c *Class, children []*Class) *docClass {
+	d := &docClass{
+		class:    c,
+		children: children,
+	}
+
+	for a := c; a != nativeClass; a = a.Extends.Type.Class {
+		d.ancestors = append([]*Class{a}, d.ancestors...)
+	}
+	d.ancestors = d.ancestors[:len(d.ancestors)-1]
+
+	// the first features are the attributes generated for the formals,
+	// which are documented as part of the class declaration.
+	for _, f := range c.Features[len(c.Formals):] {
+		if a, ok := f.(*Attribute); ok && a.Type.Name != "native" {
+			d.attributes = append(d.attributes, a)
+		}
+	}
+
+	inherited := make(map[*Class][]*Method)
+	for i, m := range c.Methods {
+		if m.Parent != c {
+			inherited[m.Parent] = append(inherited[m.Parent], m)
+			continue
+		}
+
+		dm := &docMethod{
+			method: m,
+			doc:    m.Doc,
+		}
+		if parent := c.Extends.Type.Class; i < len(parent.Methods) {
+			dm.overrides = parent.Methods[i]
+		}
+		for o := dm.overrides; dm.doc == "" && o != nil; {
+			dm.doc = o.Doc
+			if parent := o.Parent.Extends.Type.Class; i < len(parent.Methods) {
+				o = parent.Methods[i]
+			} else {
+				o = nil
+			}
+		}
+		if c.HasOverride[i] {
+			for _, sub := range p.Ordered[c.Order:c.MaxOrder] {
+				if sub.Methods[i].Parent == sub {
+					dm.overriddenIn = append(dm.overriddenIn, sub)
+				}
+			}
+		}
+		d.methods = append(d.methods, dm)
+	}
+
+	for i := len(d.ancestors) - 1; i >= 0; i-- {
+		if methods := inherited[d.ancestors[i]]; len(methods) != 0 {
+			d.inherited = append(d.inherited, &docInherited{
+				from:    d.ancestors[i],
+				methods: methods,
+			})
+		}
+	}
+
+	return d
+}
+
+// methodSignature is like describeMethod, but without the class name.
+func methodSignature(m *Method) string {
+	args := make([]string, len(m.Args))
+	for i, a := range m.Args {
+		args[i] = a.Name.Name + " : " + a.Type.Name
+	}
+
+	s := "def "
+	if m.Override {
+		s = "override def "
+	}
+	return s + m.Name.Name + "(" + strings.Join(args, ", ") + ") : " + m.Type.Name
+}
+
+func attributeSignature(a *Attribute) string {
+	return "var " + a.Name.Name + " : " + a.Type.Name
+}
+
+// docAnchor is the id of the heading for a class in the HTML output, and
+// the anchor GitHub and most other Markdown renderers generate for the
+// heading of a class in the Markdown output.
+func docAnchor(c *Class) string {
+	return "class-" + strings.ToLower(c.Type.Name)
+}
+
+const docHTMLHeader = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Classes</title>
+<style>
+body { font-family: sans-serif; max-width: 50em; margin: 0 auto; padding: 1em; }
+pre, code { font-family: monospace; }
+pre { background: #f4f4f4; padding: 0.5em; }
+dt { margin-top: 1em; }
+.note { color: #666; }
+</style>
+</head>
+<body>
+`
+
+func writeDocHTML(w *bytes.Buffer, classes []*docClass) {
+	link := func(c *Class) string {
+		return `<a href="#` + docAnchor(c) + `">` + html.EscapeString(c.Type.Name) + `</a>`
+	}
+	links := func(cs []*Class) string {
+		s := make([]string, len(cs))
+		for i, c := range cs {
+			s[i] = link(c)
+		}
+		return strings.Join(s, ", ")
+	}
+	methodLink := func(m *Method) string {
+		return `<a href="#` + docAnchor(m.Parent) + `.` + html.EscapeString(m.Name.Name) + `"><code>` + html.EscapeString(m.Name.Name) + `</code></a>`
+	}
+
+	w.WriteString(docHTMLHeader)
+
+	w.WriteString("<h1>Classes</h1>\n")
+	// the classes are in preorder, so each one is either a child of the
+	// previous class or a sibling of it or one of its ancestors.
+	depth := 0
+	for _, d := range classes {
+		if d.class.Depth > depth {
+			w.WriteString("<ul>\n")
+			depth++
+		} else {
+			w.WriteString("</li>\n")
+			for ; depth > d.class.Depth; depth-- {
+				w.WriteString("</ul>\n</li>\n")
+			}
+		}
+		fmt.Fprintf(w, "<li>%s", link(d.class))
+	}
+	if depth != 0 {
+		w.WriteString("</li>\n")
+		for ; depth > 1; depth-- {
+			w.WriteString("</ul>\n</li>\n")
+		}
+		w.WriteString("</ul>\n")
+	}
+
+	for _, d := range classes {
+		fmt.Fprintf(w, "\n<h2 id=\"%s\">%s</h2>\n", docAnchor(d.class), html.EscapeString(d.class.Type.Name))
+		fmt.Fprintf(w, "<pre>%s</pre>\n", html.EscapeString(describeClass(d.class)))
+		if len(d.ancestors) != 0 {
+			fmt.Fprintf(w, "<p class=\"note\">Inherits from %s.</p>\n", strings.Replace(links(d.ancestors), ", ", " &gt; ", -1))
+		}
+		if len(d.children) != 0 {
+			fmt.Fprintf(w, "<p class=\"note\">Direct subclasses: %s.</p>\n", links(d.children))
+		}
+		if d.class.Doc != "" {
+			// like Javadoc, doc comments are HTML.
+			fmt.Fprintf(w, "<div class=\"doc\">%s</div>\n", d.class.Doc)
+		}
+
+		if len(d.attributes) != 0 {
+			w.WriteString("<h3>Attributes</h3>\n<dl>\n")
+			for _, a := range d.attributes {
+				fmt.Fprintf(w, "<dt><code>%s</code></dt>\n", html.EscapeString(attributeSignature(a)))
+				if a.Doc != "" {
+					fmt.Fprintf(w, "<dd><div class=\"doc\">%s</div></dd>\n", a.Doc)
+				}
+			}
+			w.WriteString("</dl>\n")
+		}
+
+		if len(d.methods) != 0 {
+			w.WriteString("<h3>Methods</h3>\n<dl>\n")
+			for _, m := range d.methods {
+				fmt.Fprintf(w, "<dt id=\"%s.%s\"><code>%s</code></dt>\n", docAnchor(d.class), html.EscapeString(m.method.Name.Name), html.EscapeString(methodSignature(m.method)))
+				w.WriteString("<dd>")
+				if m.doc != "" {
+					fmt.Fprintf(w, "<div class=\"doc\">%s</div>", m.doc)
+				}
+				if m.overrides != nil {
+					fmt.Fprintf(w, "<p class=\"note\">Overrides %s in %s.</p>", methodLink(m.overrides), link(m.overrides.Parent))
+				}
+				if len(m.overriddenIn) != 0 {
+					fmt.Fprintf(w, "<p class=\"note\">Overridden in %s.</p>", links(m.overriddenIn))
+				}
+				w.WriteString("</dd>\n")
+			}
+			w.WriteString("</dl>\n")
+		}
+
+		if len(d.inherited) != 0 {
+			w.WriteString("<h3>Inherited methods</h3>\n")
+			for _, in := range d.inherited {
+				names := make([]string, len(in.methods))
+				for i, m := range in.methods {
+					names[i] = methodLink(m)
+				}
+				fmt.Fprintf(w, "<p>From %s: %s</p>\n", link(in.from), strings.Join(names, ", "))
+			}
+		}
+	}
+
+	w.WriteString("</body>\n</html>\n")
+}
+
+func writeDocMarkdown(w *bytes.Buffer, classes []*docClass) {
+	link := func(c *Class) string {
+		return "[" + c.Type.Name + "](#" + docAnchor(c) + ")"
+	}
+	links := func(cs []*Class) string {
+		s := make([]string, len(cs))
+		for i, c := range cs {
+			s[i] = link(c)
+		}
+		return strings.Join(s, ", ")
+	}
+	code := func(s string) {
+		fmt.Fprintf(w, "```cool\n%s\n```\n\n", s)
+	}
+
+	w.WriteString("# Classes\n\n")
+	for _, d := range classes {
+		fmt.Fprintf(w, "%s- %s\n", strings.Repeat("  ", d.class.Depth-1), link(d.class))
+	}
+	w.WriteString("\n")
+
+	for _, d := range classes {
+		fmt.Fprintf(w, "## class %s\n\n", d.class.Type.Name)
+		code(describeClass(d.class))
+		if len(d.ancestors) != 0 {
+			fmt.Fprintf(w, "Inherits from %s.\n\n", strings.Replace(links(d.ancestors), ", ", " > ", -1))
+		}
+		if len(d.children) != 0 {
+			fmt.Fprintf(w, "Direct subclasses: %s.\n\n", links(d.children))
+		}
+		if d.class.Doc != "" {
+			fmt.Fprintf(w, "%s\n\n", d.class.Doc)
+		}
+
+		if len(d.attributes) != 0 {
+			w.WriteString("### Attributes\n\n")
+			for _, a := range d.attributes {
+				code(attributeSignature(a))
+				if a.Doc != "" {
+					fmt.Fprintf(w, "%s\n\n", a.Doc)
+				}
+			}
+		}
+
+		if len(d.methods) != 0 {
+			w.WriteString("### Methods\n\n")
+			for _, m := range d.methods {
+				code(methodSignature(m.method))
+				if m.doc != "" {
+					fmt.Fprintf(w, "%s\n\n", m.doc)
+				}
+				if m.overrides != nil {
+					fmt.Fprintf(w, "Overrides `%s` in %s.\n\n", m.overrides.Name.Name, link(m.overrides.Parent))
+				}
+				if len(m.overriddenIn) != 0 {
+					fmt.Fprintf(w, "Overridden in %s.\n\n", links(m.overriddenIn))
+				}
+			}
+		}
+
+		if len(d.inherited) != 0 {
+			w.WriteString("### Inherited methods\n\n")
+			for _, in := range d.inherited {
+				names := make([]string, len(in.methods))
+				for i, m := range in.methods {
+					names[i] = "`" + m.Name.Name + "`"
+				}
+				fmt.Fprintf(w, "- From %s: %s\n", link(in.from), strings.Join(names, ", "))
+			}
+			w.WriteString("\n")
+		}
+	}
+}
@@ -0,0 +1,493 @@
+package ast
+
+import (
+	"bytes"
+	"encoding/json"
+	"go/token"
+	"io"
+)
+
+// DumpAST writes the program to w as JSON. stage is recorded in the output
+// and should say which point in compilation the program was dumped at:
+// "parsed", "checked", or "optimized".
+//
+// The output is an object with these fields:
+//
+//	"stage"    the stage given to DumpAST
+//	"classes"  every class in the order it was read, as a class node
+//	"order"    the names of the classes in topological order, after checking
+//	"main"     the expression that starts the program, after checking
+//
+// Every node is an object with a "kind" field. Fields that would be false,
+// zero, or empty are left out, and so are fields that are not known yet at
+// the stage being dumped. Positions are written as "file:line:column", and
+// "pos" and "end" are the position of a node's first byte and the position
+// after its last byte. Generated nodes have no position.
+//
+// Declarations are written in full in only one place, so the output is a tree
+// even though the program is not. Classes are referred to by name, such as
+// "Int", and methods by their class and name, such as "IO.out". Every
+// variable has an "id" that is unique within the dump, and a node that reads
+// or writes a variable has an "object" field with its id. An attribute of an
+// object other than this is written as {"object": id, "attribute": id}.
+//
+// A class node has these fields:
+//
+//	"kind"      "class"
+//	"name", "pos", "end"
+//	"doc"       the text of its doc comment
+//	"extends"   the name of its parent class
+//	"superArgs" the arguments to the parent's constructor, as expressions
+//	"formals"   its parameters, as formal nodes
+//	"features"  its features, including generated ones, as feature nodes
+//	"order", "maxOrder", "depth"
+//	            its position in the topological order, the highest order of
+//	            any of its descendants, and its depth, where Any is 1
+//	"methods"   its method table, as method names
+//	"overridden"
+//	            the names of the methods in its method table that a
+//	            subclass overrides
+//
+// A formal node has "kind" "formal", "id", "name", "pos", "end", and "type".
+//
+// Feature nodes have a "kind" of "attribute", "method", or "init":
+//
+//	attribute  "id", "name", "pos", "end", "type", "doc", "init"
+//	method     "name", "pos", "end", "override", "args" (formal nodes),
+//	           "type", "doc", "body", and "order", its offset in the
+//	           method table
+//	init       "body"
+//
+// Expression nodes have "pos" and "end" if they came from the parser, and
+// "staticType", the name of the type the checker found for them, if they were
+// checked. Their other fields depend on their "kind":
+//
+//	not, negative           "expr"
+//	if                      "cond", "then", "else"
+//	while                   "cond", "body"
+//	lessOrEqual, lessThan, multiply, divide, add, subtract
+//	                        "left", "right"
+//	match                   "id", "left", "cases"; each case has "name",
+//	                        "type", and "body", and its name refers to the
+//	                        match's id
+//	call                    "recv", "name", "method", "args", and the
+//	                        annotations "dynamic" (the method is chosen at
+//	                        runtime) and "recvNotNull" (the receiver is
+//	                        never null, so it is not checked)
+//	superCall               "name", "method", "args", "class"
+//	staticCall              "recv", "name", "method", "args"
+//	alloc                   "class"
+//	assign                  "name", "object", "expr"
+//	var                     "id", "name", "type", "init", "body", and the
+//	                        annotations "inlined" (the method whose body
+//	                        this is), "nullCheck", and "downcast"
+//	chain                   "pre", "expr"
+//	this                    "class"
+//	name                    "name", "object"
+//	int, string, boolean    "value"
+//	null, unit, native, bad no other fields
+//
+// "method" is the method a call is bound to, which is only known after
+// checking. A call without "dynamic" is compiled as a static call to it.
+func (p *Program) DumpAST(w io.Writer, fset *token.FileSet, stage string) error {
+	d := &dumper{
+		fset:  fset,
+		ids:   make(map[Object]int),
+		spans: make(map[Expr]exprSpan),
+		types: p.types,
+	}
+	for _, spans := range p.exprs {
+		for _, span := range spans {
+			// an expression in parentheses is recorded again with
+			// the parentheses. the first span is the expression's own.
+			if _, ok := d.spans[span.Expr]; !ok {
+				d.spans[span.Expr] = span
+			}
+		}
+	}
+
+	classes := make([]interface{}, len(p.Classes))
+	for i, c := range p.Classes {
+		classes[i] = d.class(c)
+	}
+
+	obj := dumpObject{
+		{"stage", stage},
+		{"classes", classes},
+	}
+	if len(p.Ordered) != 0 {
+		order := make([]string, len(p.Ordered))
+		for i, c := range p.Ordered {
+			order[i] = c.Type.Name
+		}
+		obj.add("order", order)
+	}
+	if p.Main != nil {
+		obj.add("main", d.expr(p.Main))
+	}
+
+	b, err := json.MarshalIndent(obj, "", "\t")
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(append(b, '\n'))
+	return err
+}
+
+// dumpObject is a JSON object that keeps its fields in order.
+type dumpObject []dumpField
+
+type dumpField struct {
+	name  string
+	value interface{}
+}
+
+func (o *dumpObject) add(name string, value interface{}) {
+	*o = append(*o, dumpField{name, value})
+}
+
+// MarshalJSON implements json.Marshaler.
+func (o dumpObject) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+
+	buf.WriteByte('{')
+	for i, f := range o {
+		if i != 0 {
+			buf.WriteByte(',')
+		}
+		b, err := json.Marshal(f.name)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(b)
+		buf.WriteByte(':')
+		b, err = json.Marshal(f.value)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(b)
+	}
+	buf.WriteByte('}')
+
+	return buf.Bytes(), nil
+}
+
+type dumper struct {
+	fset  *token.FileSet
+	ids   map[Object]int
+	spans map[Expr]exprSpan
+	types map[Expr]*Class
+}
+
+// id returns the ID of a variable, assigning it one if it doesn't have one.
+func (d *dumper) id(o Object) int {
+	if id, ok := d.ids[o]; ok {
+		return id
+	}
+	id := len(d.ids) + 1
+	d.ids[o] = id
+	return id
+}
+
+func (d *dumper) ref(o Object) interface{} {
+	if a, ok := o.(*AttributeObject); ok {
+		return dumpObject{
+			{"object", d.ref(a.Object)},
+			{"attribute", d.id(a.Attribute)},
+		}
+	}
+	return d.id(o)
+}
+
+// pos adds "pos" and "end" to obj if they are known.
+func (d *dumper) pos(obj *dumpObject, pos, end token.Pos) {
+	if pos.IsValid() {
+		obj.add("pos", d.fset.Position(pos).String())
+	}
+	if end.IsValid() {
+		obj.add("end", d.fset.Position(end).String())
+	}
+}
+
+// name adds "name", "pos", and "end" to obj for a declaration.
+func (d *dumper) name(obj *dumpObject, id *Ident) {
+	obj.add("name", id.Name)
+	d.pos(obj, id.Pos, id.End)
+}
+
+func dumpClassName(c *Class) string {
+	if c == nativeClass {
+		return "native"
+	}
+	return c.Type.Name
+}
+
+func dumpMethodName(m *Method) string {
+	if m.Parent == nil {
+		return m.Name.Name
+	}
+	return m.Parent.Type.Name + "." + m.Name.Name
+}
+
+func (d *dumper) class(c *Class) interface{} {
+	obj := dumpObject{{"kind", "class"}}
+	d.name(&obj, c.Type)
+	if c.Doc != "" {
+		obj.add("doc", c.Doc)
+	}
+	if c.Extends != nil {
+		obj.add("extends", c.Extends.Type.Name)
+		if len(c.Extends.Args) != 0 {
+			obj.add("superArgs", d.exprs(c.Extends.Args))
+		}
+	}
+	if len(c.Formals) != 0 {
+		obj.add("formals", d.formals(c.Formals))
+	}
+
+	features := make([]interface{}, len(c.Features))
+	for i, f := range c.Features {
+		features[i] = d.feature(f)
+	}
+	obj.add("features", features)
+
+	if c.Depth != 0 {
+		obj.add("order", c.Order)
+		obj.add("maxOrder", c.MaxOrder)
+		obj.add("depth", c.Depth)
+	}
+	if len(c.Methods) != 0 {
+		methods := make([]string, len(c.Methods))
+		var overridden []string
+		for i, m := range c.Methods {
+			methods[i] = dumpMethodName(m)
+			if i < len(c.HasOverride) && c.HasOverride[i] {
+				overridden = append(overridden, methods[i])
+			}
+		}
+		obj.add("methods", methods)
+		if len(overridden) != 0 {
+			obj.add("overridden", overridden)
+		}
+	}
+
+	return obj
+}
+
+func (d *dumper) formals(formals []*Formal) []interface{} {
+	values := make([]interface{}, len(formals))
+	for i, f := range formals {
+		obj := dumpObject{{"kind", "formal"}, {"id", d.id(f)}}
+		d.name(&obj, f.Name)
+		obj.add("type", f.Type.Name)
+		values[i] = obj
+	}
+	return values
+}
+
+func (d *dumper) feature(f Feature) interface{} {
+	switch f := f.(type) {
+	case *Attribute:
+		obj := dumpObject{{"kind", "attribute"}, {"id", d.id(f)}}
+		d.name(&obj, f.Name)
+		obj.add("type", f.Type.Name)
+		if f.Doc != "" {
+			obj.add("doc", f.Doc)
+		}
+		if f.Init != nil {
+			obj.add("init", d.expr(f.Init))
+		}
+		return obj
+
+	case *Method:
+		obj := dumpObject{{"kind", "method"}}
+		d.name(&obj, f.Name)
+		if f.Override {
+			obj.add("override", true)
+		}
+		if len(f.Args) != 0 {
+			obj.add("args", d.formals(f.Args))
+		}
+		obj.add("type", f.Type.Name)
+		if f.Doc != "" {
+			obj.add("doc", f.Doc)
+		}
+		obj.add("body", d.expr(f.Body))
+		if f.Parent != nil {
+			obj.add("order", f.Order)
+		}
+		return obj
+
+	case *Init:
+		return dumpObject{{"kind", "init"}, {"body", d.expr(f.Expr)}}
+	}
+
+	panic("ast: unexpected feature type")
+}
+
+func (d *dumper) exprs(exprs []Expr) []interface{} {
+	values := make([]interface{}, len(exprs))
+	for i, e := range exprs {
+		values[i] = d.expr(e)
+	}
+	return values
+}
+
+// call adds the fields shared by every kind of method call to obj.
+func (d *dumper) call(obj *dumpObject, name *Ident, args []Expr) {
+	obj.add("name", name.Name)
+	if name.Method != nil {
+		obj.add("method", dumpMethodName(name.Method))
+	}
+	obj.add("args", d.exprs(args))
+}
+
+// binary adds the operands of a binary operator to obj.
+func (d *dumper) binary(obj *dumpObject, e *BinaryOperator) {
+	obj.add("left", d.expr(e.Left))
+	obj.add("right", d.expr(e.Right))
+}
+
+func (d *dumper) expr(e Expr) interface{} {
+	obj := dumpObject{{"kind", ""}}
+	if span, ok := d.spans[e]; ok {
+		d.pos(&obj, span.Pos, span.End)
+	}
+	if t := d.types[e]; t != nil && t != errorClass {
+		obj.add("staticType", dumpClassName(t))
+	}
+	var kind string
+
+	switch e := e.(type) {
+	case *NotExpr:
+		kind = "not"
+		obj.add("expr", d.expr(e.Expr))
+	case *NegativeExpr:
+		kind = "negative"
+		obj.add("expr", d.expr(e.Expr))
+	case *IfExpr:
+		kind = "if"
+		obj.add("cond", d.expr(e.Cond))
+		obj.add("then", d.expr(e.Then))
+		obj.add("else", d.expr(e.Else))
+	case *WhileExpr:
+		kind = "while"
+		obj.add("cond", d.expr(e.Cond))
+		obj.add("body", d.expr(e.Body))
+	case *LessOrEqualExpr:
+		kind = "lessOrEqual"
+		d.binary(&obj, (*BinaryOperator)(e))
+	case *LessThanExpr:
+		kind = "lessThan"
+		d.binary(&obj, (*BinaryOperator)(e))
+	case *MultiplyExpr:
+		kind = "multiply"
+		d.binary(&obj, (*BinaryOperator)(e))
+	case *DivideExpr:
+		kind = "divide"
+		d.binary(&obj, (*BinaryOperator)(e))
+	case *AddExpr:
+		kind = "add"
+		d.binary(&obj, (*BinaryOperator)(e))
+	case *SubtractExpr:
+		kind = "subtract"
+		d.binary(&obj, (*BinaryOperator)(e))
+	case *MatchExpr:
+		kind = "match"
+		obj.add("id", d.id(e))
+		obj.add("left", d.expr(e.Left))
+		cases := make([]interface{}, len(e.Cases))
+		for i, c := range e.Cases {
+			cases[i] = dumpObject{
+				{"name", c.Name.Name},
+				{"type", c.Type.Name},
+				{"body", d.expr(c.Body)},
+			}
+		}
+		obj.add("cases", cases)
+	case *DynamicCallExpr:
+		kind = "call"
+		obj.add("recv", d.expr(e.Recv))
+		d.call(&obj, e.Name, e.Args)
+		if e.HasOverride {
+			obj.add("dynamic", true)
+		}
+		if e.RecvNotNull {
+			obj.add("recvNotNull", true)
+		}
+	case *SuperCallExpr:
+		kind = "superCall"
+		d.call(&obj, e.Name, e.Args)
+		if e.Class != nil {
+			obj.add("class", dumpClassName(e.Class))
+		}
+	case *StaticCallExpr:
+		kind = "staticCall"
+		obj.add("recv", d.expr(e.Recv))
+		d.call(&obj, e.Name, e.Args)
+	case *AllocExpr:
+		kind = "alloc"
+		obj.add("class", e.Type.Name)
+	case *AssignExpr:
+		kind = "assign"
+		obj.add("name", e.Name.Name)
+		if e.Name.Object != nil {
+			obj.add("object", d.ref(e.Name.Object))
+		}
+		obj.add("expr", d.expr(e.Expr))
+	case *VarExpr:
+		kind = "var"
+		obj.add("id", d.id(e))
+		obj.add("name", e.Name.Name)
+		obj.add("type", e.Type.Name)
+		obj.add("init", d.expr(e.Init))
+		obj.add("body", d.expr(e.Body))
+		if e.Inlined != nil {
+			obj.add("inlined", dumpMethodName(e.Inlined))
+		}
+		if e.NullCheck {
+			obj.add("nullCheck", true)
+		}
+		if e.Downcast {
+			obj.add("downcast", true)
+		}
+	case *ChainExpr:
+		kind = "chain"
+		obj.add("pre", d.expr(e.Pre))
+		obj.add("expr", d.expr(e.Expr))
+	case *ThisExpr:
+		kind = "this"
+		if e.Class != nil {
+			obj.add("class", dumpClassName(e.Class))
+		}
+	case *NullExpr:
+		kind = "null"
+	case *UnitExpr:
+		kind = "unit"
+	case *NameExpr:
+		kind = "name"
+		obj.add("name", e.Name.Name)
+		if e.Name.Object != nil {
+			obj.add("object", d.ref(e.Name.Object))
+		}
+	case *StringExpr:
+		kind = "string"
+		obj.add("value", e.Lit.Str)
+	case *BoolExpr:
+		kind = "boolean"
+		obj.add("value", e.Lit.Bool)
+	case *IntExpr:
+		kind = "int"
+		obj.add("value", e.Lit.Int)
+	case *NativeExpr:
+		kind = "native"
+	case *BadExpr:
+		kind = "bad"
+	default:
+		panic("ast: unexpected expression type")
+	}
+
+	obj[0].value = kind
+	return obj
+}
@@ -0,0 +1,499 @@
+package ast
+
+import "strconv"
+
+// escCtx is the state of escape analysis, which follows the objects a method
+// body creates and receives to find the ones that never outlive it. The
+// variables that can hold the same object are joined into a group, and each
+// group records whether its objects can escape, whether they might be some
+// other object, and which allocations they come from.
+type escCtx struct {
+	// borrowed is, for each method, whether it only reads its receiver
+	// (index 0) and each of its arguments without keeping them.
+	borrowed map[*Method][]bool
+
+	// parent links each variable to another in its group. A variable with
+	// no parent is the root of its group.
+	parent map[Object]Object
+	// groups is the state of each group by its root.
+	groups map[Object]*escGroup
+	// allocs is the number of times each allocation appears in the body,
+	// and order is the allocations in the order they appear.
+	allocs map[*AllocExpr]int
+	order  []*AllocExpr
+	// boxed is the arguments of calls that can be built in the stack
+	// frame, for remarks.
+	boxed []escBoxed
+}
+
+// escGroup is the state of a set of variables that can hold the same object.
+type escGroup struct {
+	// escapes is true if the object can be stored somewhere the analysis
+	// can't follow.
+	escapes bool
+	// mixed is true if the variables can hold an object that wasn't
+	// allocated by this body, or if the object itself is read by a match
+	// or a method, so it needs to exist.
+	mixed bool
+	// allocs is the allocations stored in the variables.
+	allocs []*AllocExpr
+}
+
+// escBoxed is an Int argument of a call that is built in the stack frame.
+type escBoxed struct {
+	call *DynamicCallExpr
+	arg  Expr
+}
+
+// escScalar is an object that never leaves the method that creates it, so
+// its attributes are kept in temporaries instead of on the heap.
+type escScalar struct {
+	// attributes is every attribute of the object, starting with the
+	// ones declared by its most distant ancestor.
+	attributes []*Attribute
+	// temps is the temporary for each attribute, set by irLower.
+	temps map[*Attribute]*irTemp
+}
+
+var (
+	// escHeap is the destination of values that escape analysis loses
+	// track of, such as arguments to methods that might keep them.
+	escHeap Object = &Attribute{}
+	// escBorrow is the destination of values passed to a method that
+	// only reads them.
+	escBorrow Object = &Attribute{}
+)
+
+// escapeAnalysis finds the receivers and arguments each method only reads
+// and reports the objects that can be kept in the stack frame. Arguments are
+// assumed to be borrowed until a method is found to keep them, and the
+// methods are checked again until nothing changes.
+func (ctx *semCtx) escapeAnalysis() map[*Method][]bool {
+	esc := &escCtx{
+		borrowed: make(map[*Method][]bool),
+	}
+
+	var methods []*Method
+	for _, c := range ctx.program.Classes {
+		for _, f := range c.Features {
+			m, ok := f.(*Method)
+			if !ok {
+				continue
+			}
+			if _, ok := m.Body.(*NativeExpr); ok {
+				if b := escNative(m); b != nil {
+					esc.borrowed[m] = b
+				}
+				continue
+			}
+			if ctx.program.reached != nil && !ctx.program.reached[m] {
+				continue
+			}
+			b := make([]bool, 1+len(m.Args))
+			for i := range m.Args {
+				b[1+i] = true
+			}
+			esc.borrowed[m] = b
+			methods = append(methods, m)
+		}
+	}
+
+	for changed := true; changed; {
+		changed = false
+		for _, m := range methods {
+			esc.Body(m.Body)
+			for i, a := range m.Args {
+				if esc.borrowed[m][1+i] && esc.Escapes(a) {
+					esc.borrowed[m][1+i] = false
+					changed = true
+				}
+			}
+		}
+	}
+
+	if ctx.opt.OptReport {
+		reported := make(map[string]bool)
+		remark := func(code string, id *Ident, message string) {
+			key := code + "\x00" + strconv.Itoa(int(id.Pos)) + "\x00" + message
+			if !reported[key] {
+				reported[key] = true
+				ctx.Remark(code, id.Pos, id.End, message)
+			}
+		}
+		for _, body := range append([]Expr{ctx.program.Main}, escBodies(methods)...) {
+			scalars := esc.Body(body)
+			for _, alloc := range esc.order {
+				if scalars[alloc] == nil {
+					continue
+				}
+				remark("scalar-replaced", alloc.Type, "kept the attributes of new "+alloc.Type.Name+" in stack slots: the object never leaves the method")
+			}
+			if !ctx.opt.OptInt || ctx.opt.Coroutine {
+				continue
+			}
+			for _, b := range esc.boxed {
+				remark("stack-allocated", b.call.Name, "built the Int "+remarkExpr(b.arg)+" in the stack frame: "+remarkMethod(b.call.Name)+" doesn't keep it")
+			}
+		}
+	}
+
+	return esc.borrowed
+}
+
+func escBodies(methods []*Method) []Expr {
+	bodies := make([]Expr, len(methods))
+	for i, m := range methods {
+		bodies[i] = m.Body
+	}
+	return bodies
+}
+
+// escNative returns whether the native method m only reads its receiver and
+// each of its arguments, or nil if it keeps all of them.
+func escNative(m *Method) []bool {
+	switch m.Parent.Type.Name + "." + m.Name.Name {
+	case "Any.equals", "Int.equals", "String.equals":
+		return []bool{true, true}
+	case "Any.toString", "Int.toString":
+		return []bool{true}
+	case "String.charAt", "ArrayAny.get":
+		return []bool{true, true}
+	case "String.substring":
+		return []bool{true, true, true}
+	case "ArrayAny.set":
+		// the array keeps obj.
+		return []bool{true, true, false}
+	}
+	return nil
+}
+
+// escAttributes returns the attributes of c, or false if c has native
+// attributes, which can't be kept in stack slots.
+func escAttributes(c *Class) ([]*Attribute, bool) {
+	if c == nativeClass {
+		return nil, true
+	}
+	attributes, ok := escAttributes(c.Extends.Type.Class)
+	if !ok {
+		return nil, false
+	}
+	for _, f := range c.Features {
+		if a, ok := f.(*Attribute); ok {
+			if _, ok := a.Init.(*NativeExpr); ok {
+				return nil, false
+			}
+			attributes = append(attributes, a)
+		}
+	}
+	return attributes, true
+}
+
+// escBoxes returns true if the code for e allocates an Int on the heap that
+// could be built in the stack frame instead.
+func escBoxes(e Expr) bool {
+	switch e := e.(type) {
+	case *AddExpr, *SubtractExpr, *MultiplyExpr, *DivideExpr, *NegativeExpr:
+		return true
+	case *NameExpr:
+		v, ok := e.Name.Object.(*VarExpr)
+		return ok && v.RawInt()
+	}
+	return false
+}
+
+// Body analyzes body and returns the allocations in it whose attributes can
+// be kept in stack slots, along with the variables that hold each of them.
+func (ctx *escCtx) Body(body Expr) map[*AllocExpr][]*VarExpr {
+	ctx.parent = make(map[Object]Object)
+	ctx.groups = make(map[Object]*escGroup)
+	ctx.allocs = make(map[*AllocExpr]int)
+	ctx.order = nil
+	ctx.boxed = nil
+
+	body.escape(ctx, escHeap)
+
+	scalars := make(map[*AllocExpr][]*VarExpr)
+	for v := range ctx.parent {
+		if v, ok := v.(*VarExpr); ok {
+			if alloc := ctx.Scalar(v); alloc != nil {
+				scalars[alloc] = append(scalars[alloc], v)
+			}
+		}
+	}
+	return scalars
+}
+
+// Scalar returns the allocation held by v if the object never leaves the
+// body and v can't hold anything else.
+func (ctx *escCtx) Scalar(v Object) *AllocExpr {
+	g := ctx.Group(v)
+	if g.escapes || g.mixed || len(g.allocs) != 1 {
+		return nil
+	}
+	// an allocation that appears more than once belongs to code that
+	// was inlined more than once, and both copies could be alive at the
+	// same time.
+	alloc := g.allocs[0]
+	if ctx.allocs[alloc] != 1 {
+		return nil
+	}
+	if _, ok := escAttributes(alloc.Type.Class); !ok {
+		return nil
+	}
+	return alloc
+}
+
+// Find returns the root of the group v is in.
+func (ctx *escCtx) Find(v Object) Object {
+	p, ok := ctx.parent[v]
+	if !ok {
+		ctx.parent[v] = v
+		return v
+	}
+	if p == v {
+		return v
+	}
+	root := ctx.Find(p)
+	ctx.parent[v] = root
+	return root
+}
+
+// Group returns the state of the group v is in. Formals and match
+// variables hold objects from outside the body.
+func (ctx *escCtx) Group(v Object) *escGroup {
+	root := ctx.Find(v)
+	g, ok := ctx.groups[root]
+	if !ok {
+		_, local := root.(*VarExpr)
+		g = &escGroup{mixed: !local}
+		ctx.groups[root] = g
+	}
+	return g
+}
+
+// Escapes returns true if the object held by v can escape.
+func (ctx *escCtx) Escapes(v Object) bool {
+	if _, ok := ctx.parent[v]; !ok {
+		return false
+	}
+	return ctx.Group(v).escapes
+}
+
+// escTracked returns true if v is a variable escape analysis follows.
+func escTracked(v Object) bool {
+	switch v.(type) {
+	case *VarExpr, *Formal, *MatchExpr:
+		return true
+	}
+	return false
+}
+
+// Flow records that the value of the variable from is stored in to.
+func (ctx *escCtx) Flow(from, to Object) {
+	switch {
+	case to == nil:
+	case escTracked(to):
+		a, b := ctx.Group(from), ctx.Group(to)
+		ra, rb := ctx.Find(from), ctx.Find(to)
+		if ra == rb {
+			return
+		}
+		ctx.parent[ra] = rb
+		delete(ctx.groups, ra)
+		b.escapes = b.escapes || a.escapes
+		b.mixed = b.mixed || a.mixed
+		b.allocs = append(b.allocs, a.allocs...)
+	case to == escBorrow:
+		ctx.Group(from).mixed = true
+	default:
+		ctx.Group(from).escapes = true
+	}
+}
+
+// Value records that a value that isn't followed is stored in to.
+func (ctx *escCtx) Value(to Object) {
+	if to != nil && escTracked(to) {
+		ctx.Group(to).mixed = true
+	}
+}
+
+// Alloc records that the object allocated by e is stored in to.
+func (ctx *escCtx) Alloc(e *AllocExpr, to Object) {
+	if ctx.allocs[e] == 0 {
+		ctx.order = append(ctx.order, e)
+	}
+	ctx.allocs[e]++
+	if to != nil && escTracked(to) {
+		g := ctx.Group(to)
+		g.allocs = append(g.allocs, e)
+	}
+}
+
+// Arg returns the destination of argument i of a call to m, where the
+// receiver is argument 0.
+func (ctx *escCtx) Arg(m *Method, hasOverride bool, i int) Object {
+	if !hasOverride && ctx.borrowed[m] != nil && ctx.borrowed[m][i] {
+		return escBorrow
+	}
+	return escHeap
+}
+
+func (e *NotExpr) escape(ctx *escCtx, to Object) {
+	e.Expr.escape(ctx, nil)
+	ctx.Value(to)
+}
+
+func (e *NegativeExpr) escape(ctx *escCtx, to Object) {
+	e.Expr.escape(ctx, nil)
+	ctx.Value(to)
+}
+
+func (e *IfExpr) escape(ctx *escCtx, to Object) {
+	e.Cond.escape(ctx, nil)
+	e.Then.escape(ctx, to)
+	e.Else.escape(ctx, to)
+}
+
+func (e *WhileExpr) escape(ctx *escCtx, to Object) {
+	e.Cond.escape(ctx, nil)
+	e.Body.escape(ctx, nil)
+	ctx.Value(to)
+}
+
+func (e *LessOrEqualExpr) escape(ctx *escCtx, to Object) {
+	e.Left.escape(ctx, nil)
+	e.Right.escape(ctx, nil)
+	ctx.Value(to)
+}
+
+func (e *LessThanExpr) escape(ctx *escCtx, to Object) {
+	e.Left.escape(ctx, nil)
+	e.Right.escape(ctx, nil)
+	ctx.Value(to)
+}
+
+func (e *MultiplyExpr) escape(ctx *escCtx, to Object) {
+	e.Left.escape(ctx, nil)
+	e.Right.escape(ctx, nil)
+	ctx.Value(to)
+}
+
+func (e *DivideExpr) escape(ctx *escCtx, to Object) {
+	e.Left.escape(ctx, nil)
+	e.Right.escape(ctx, nil)
+	ctx.Value(to)
+}
+
+func (e *AddExpr) escape(ctx *escCtx, to Object) {
+	e.Left.escape(ctx, nil)
+	e.Right.escape(ctx, nil)
+	ctx.Value(to)
+}
+
+func (e *SubtractExpr) escape(ctx *escCtx, to Object) {
+	e.Left.escape(ctx, nil)
+	e.Right.escape(ctx, nil)
+	ctx.Value(to)
+}
+
+func (e *MatchExpr) escape(ctx *escCtx, to Object) {
+	// the match reads the tag of the object, and each case variable is
+	// the match itself.
+	e.Left.escape(ctx, e)
+	for _, c := range e.Cases {
+		c.Body.escape(ctx, to)
+	}
+}
+
+func (e *DynamicCallExpr) escape(ctx *escCtx, to Object) {
+	m := e.Name.Method
+	e.Recv.escape(ctx, ctx.Arg(m, e.HasOverride, 0))
+	if ctx.Arg(m, e.HasOverride, 0) == escBorrow && escBoxes(e.Recv) {
+		ctx.boxed = append(ctx.boxed, escBoxed{e, e.Recv})
+	}
+	for i, a := range e.Args {
+		a.escape(ctx, ctx.Arg(m, e.HasOverride, 1+i))
+		if ctx.Arg(m, e.HasOverride, 1+i) == escBorrow && escBoxes(a) {
+			ctx.boxed = append(ctx.boxed, escBoxed{e, a})
+		}
+	}
+	ctx.Value(to)
+}
+
+func (e *SuperCallExpr) escape(ctx *escCtx, to Object) {
+	for i, a := range e.Args {
+		a.escape(ctx, ctx.Arg(e.Name.Method, false, 1+i))
+	}
+	ctx.Value(to)
+}
+
+func (e *StaticCallExpr) escape(ctx *escCtx, to Object) {
+	e.Recv.escape(ctx, escHeap)
+	for i, a := range e.Args {
+		a.escape(ctx, ctx.Arg(e.Name.Method, false, 1+i))
+	}
+	ctx.Value(to)
+}
+
+func (e *AllocExpr) escape(ctx *escCtx, to Object) {
+	ctx.Alloc(e, to)
+}
+
+func (e *AssignExpr) escape(ctx *escCtx, to Object) {
+	if v, ok := e.Name.Object.(*VarExpr); ok {
+		e.Expr.escape(ctx, v)
+	} else {
+		e.Expr.escape(ctx, escHeap)
+	}
+	ctx.Value(to)
+}
+
+func (e *VarExpr) escape(ctx *escCtx, to Object) {
+	ctx.Group(e)
+	e.Init.escape(ctx, e)
+	e.Body.escape(ctx, to)
+}
+
+func (e *ChainExpr) escape(ctx *escCtx, to Object) {
+	e.Pre.escape(ctx, nil)
+	e.Expr.escape(ctx, to)
+}
+
+func (e *ThisExpr) escape(ctx *escCtx, to Object) {
+	ctx.Value(to)
+}
+
+func (e *NullExpr) escape(ctx *escCtx, to Object) {
+	ctx.Value(to)
+}
+
+func (e *UnitExpr) escape(ctx *escCtx, to Object) {
+	ctx.Value(to)
+}
+
+func (e *NameExpr) escape(ctx *escCtx, to Object) {
+	// reading an attribute of an object doesn't let the object escape.
+	if escTracked(e.Name.Object) {
+		ctx.Flow(e.Name.Object, to)
+	} else {
+		ctx.Value(to)
+	}
+}
+
+func (e *StringExpr) escape(ctx *escCtx, to Object) {
+	ctx.Value(to)
+}
+
+func (e *BoolExpr) escape(ctx *escCtx, to Object) {
+	ctx.Value(to)
+}
+
+func (e *IntExpr) escape(ctx *escCtx, to Object) {
+	ctx.Value(to)
+}
+
+func (e *NativeExpr) escape(ctx *escCtx, to Object) {
+}
+
+func (e *BadExpr) escape(ctx *escCtx, to Object) {
+}
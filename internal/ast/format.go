@@ -0,0 +1,849 @@
+package ast
+
+import (
+	"bytes"
+	"fmt"
+	"go/token"
+	"reflect"
+	"strings"
+)
+
+// Format rewrites the Cool source code src into the canonical layout. Syntax
+// errors are reported to opt and make Format return haveErrors. The result is
+// parsed again and compared with src so that formatting can never change what
+// the program means.
+//
+// The formatter works on tokens rather than the AST so that parentheses,
+// braces, and comments are kept exactly where they were. Indentation, spacing,
+// and the placement of braces are decided by the formatter. Line breaks inside
+// expressions are kept where the original code had them, but are otherwise
+// not added or removed.
+func Format(opt Options, fset *token.FileSet, name string, src []byte) (out []byte, haveErrors bool) {
+	var before Program
+	f := fset.AddFile(name, -1, len(src))
+	f.SetLinesForContent(src)
+	if before.Parse(f, opt, bytes.NewReader(src)) {
+		return nil, true
+	}
+
+	out, err := formatTokens(f, src)
+	if err != nil {
+		ReportError(opt, "fmt", fmt.Sprintf("%s: %v", name, err))
+		return nil, true
+	}
+
+	// make sure the formatted code means the same thing.
+	var after Program
+	f2 := token.NewFileSet().AddFile(name, -1, len(out))
+	f2.SetLinesForContent(out)
+	quiet := opt
+	quiet.Report = func(*Diagnostic) {}
+	if after.Parse(f2, quiet, bytes.NewReader(out)) || !sameSyntax(reflect.ValueOf(before.Classes), reflect.ValueOf(after.Classes)) {
+		ReportError(opt, "fmt", fmt.Sprintf("%s: formatting would change the meaning of the program", name))
+		return nil, true
+	}
+
+	return out, false
+}
+
+// sameSyntax compares two parsed programs, ignoring positions.
+func sameSyntax(a, b reflect.Value) bool {
+	if a.Type() != b.Type() {
+		return false
+	}
+
+	switch a.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if a.IsNil() || b.IsNil() {
+			return a.IsNil() == b.IsNil()
+		}
+		return sameSyntax(a.Elem(), b.Elem())
+	case reflect.Struct:
+		for i := 0; i < a.NumField(); i++ {
+			if a.Type().Field(i).Type == reflect.TypeOf(token.NoPos) {
+				continue
+			}
+			if !sameSyntax(a.Field(i), b.Field(i)) {
+				return false
+			}
+		}
+		return true
+	case reflect.Slice:
+		if a.Len() != b.Len() {
+			return false
+		}
+		for i := 0; i < a.Len(); i++ {
+			if !sameSyntax(a.Index(i), b.Index(i)) {
+				return false
+			}
+		}
+		return true
+	case reflect.String:
+		return a.String() == b.String()
+	case reflect.Bool:
+		return a.Bool() == b.Bool()
+	case reflect.Int, reflect.Int32:
+		return a.Int() == b.Int()
+	}
+	panic("ast: cannot compare " + a.Type().String())
+}
+
+type fmtToken struct {
+	tok    int
+	offset int
+	text   string
+	// trivia is the comments before the token.
+	trivia []trivia
+	// newlines is the number of line breaks between the last comment (or
+	// the previous token) and this token.
+	newlines int
+}
+
+// fmtError stops the formatter if the tokens are not what it expects. The
+// source code has already been parsed, so this is a bug in the formatter.
+type fmtError struct {
+	offset int
+	msg    string
+}
+
+// whitespace that the formatter has been asked to write before the next
+// token.
+const (
+	fmtNone = iota
+	fmtSpace
+	fmtNewline
+	fmtBlank
+)
+
+type formatter struct {
+	file *token.File
+	toks []fmtToken
+	i    int
+
+	out     bytes.Buffer
+	indent  int
+	pending int
+	// last is the token most recently written, or -1 at the start of the
+	// file.
+	last int
+	// afterLineComment is true if the last thing written was a // comment,
+	// so the next token must be on a new line.
+	afterLineComment bool
+}
+
+func formatTokens(f *token.File, src []byte) (out []byte, err error) {
+	l := &lex{
+		file:    f,
+		r:       bytes.NewReader(src),
+		program: &Program{idents: make(map[*token.File][]*Ident)},
+	}
+
+	p := &formatter{
+		file: f,
+		last: -1,
+	}
+
+	var lvalue yySymType
+	for {
+		tok := l.Lex(&lvalue)
+		t := fmtToken{
+			tok:      tok,
+			trivia:   l.trivia,
+			newlines: l.newlines,
+		}
+		if tok != 0 {
+			t.offset = l.offset
+			t.text = string(src[l.offset:l.End()])
+		}
+		p.toks = append(p.toks, t)
+		if tok == 0 {
+			break
+		}
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			e, ok := r.(fmtError)
+			if !ok {
+				panic(r)
+			}
+			err = fmt.Errorf("%v: %s", f.Position(f.Pos(e.offset)), e.msg)
+		}
+	}()
+
+	p.program()
+
+	return p.out.Bytes(), nil
+}
+
+func (p *formatter) peek() int {
+	return p.toks[p.i].tok
+}
+
+// broken returns true if there was a line break or a comment before the next
+// token in the original source code.
+func (p *formatter) broken() bool {
+	t := p.toks[p.i]
+	return t.newlines != 0 || len(t.trivia) != 0
+}
+
+func (p *formatter) fail(msg string) {
+	panic(fmtError{
+		offset: p.toks[p.i].offset,
+		msg:    msg,
+	})
+}
+
+func (p *formatter) space() {
+	if p.pending < fmtSpace {
+		p.pending = fmtSpace
+	}
+}
+
+func (p *formatter) newline() {
+	if p.pending < fmtNewline {
+		p.pending = fmtNewline
+	}
+}
+
+func (p *formatter) blank() {
+	p.pending = fmtBlank
+}
+
+// blankAllowed returns true if a blank line from the original source code
+// can be kept before the next token.
+func (p *formatter) blankAllowed() bool {
+	if p.last == -1 {
+		// only after comments at the start of the file.
+		return p.out.Len() != 0
+	}
+	switch p.toks[p.last].tok {
+	case '{', '(', ARROW:
+		return false
+	}
+	return p.peek() != '}'
+}
+
+func (p *formatter) writeNewline(blank bool) {
+	if blank {
+		p.out.WriteByte('\n')
+	}
+	p.out.WriteByte('\n')
+	for i := 0; i < p.indent; i++ {
+		p.out.WriteByte('\t')
+	}
+	p.afterLineComment = false
+}
+
+// comments writes the comments before the next token. Comments that were on
+// the same line as the previous token stay there, and comments that were on
+// their own line get their own line at the current indentation.
+func (p *formatter) comments() {
+	t := &p.toks[p.i]
+	for i, c := range t.trivia {
+		if c.newlines == 0 && p.last != -1 && !p.afterLineComment {
+			p.out.WriteByte(' ')
+		} else if p.last != -1 || p.out.Len() != 0 {
+			p.writeNewline(p.pending == fmtBlank || (c.newlines > 1 && p.blankAllowed()))
+			if p.pending == fmtBlank {
+				p.pending = fmtNewline
+			}
+		}
+		p.out.WriteString(c.text)
+
+		next := t.offset
+		if i+1 < len(t.trivia) {
+			next = t.trivia[i+1].offset
+		}
+		if strings.HasPrefix(c.text, "//") {
+			p.afterLineComment = true
+			p.newline()
+		} else if p.pending < fmtSpace && next > c.offset+len(c.text) {
+			p.pending = fmtSpace
+		}
+	}
+	if len(t.trivia) != 0 && t.newlines != 0 {
+		p.newline()
+	}
+	t.trivia = nil
+}
+
+// emit writes the next token after any comments and pending whitespace.
+func (p *formatter) emit() {
+	p.comments()
+
+	t := p.toks[p.i]
+	switch {
+	case p.afterLineComment || p.pending >= fmtNewline:
+		blank := p.pending == fmtBlank || (t.newlines > 1 && p.blankAllowed())
+		if p.last != -1 || p.out.Len() != 0 {
+			p.writeNewline(blank)
+		}
+	case p.pending == fmtSpace:
+		p.out.WriteByte(' ')
+	}
+
+	p.out.WriteString(t.text)
+	p.pending = fmtNone
+	p.afterLineComment = false
+	p.last = p.i
+	p.i++
+}
+
+func (p *formatter) expect(tok int) {
+	if p.peek() != tok {
+		p.fail(fmt.Sprintf("formatter expected %s, found %q", fmtTokenName(tok), p.toks[p.i].text))
+	}
+	p.emit()
+}
+
+// fmtTokenName describes a token returned by Lex.
+func fmtTokenName(tok int) string {
+	if tok >= yyPrivate && tok < yyPrivate+len(yyTok2) {
+		return lexTokenName(yyTokname(int(yyTok2[tok-yyPrivate])))
+	}
+	return fmt.Sprintf("'%c'", tok)
+}
+
+func (p *formatter) program() {
+	for p.peek() != 0 {
+		if p.last != -1 {
+			p.blank()
+		}
+		p.class()
+	}
+
+	// comments at the end of the file.
+	p.comments()
+	if p.out.Len() != 0 {
+		p.out.WriteByte('\n')
+	}
+}
+
+func (p *formatter) class() {
+	p.expect(CLASS)
+	p.space()
+	p.expect(TYPEID)
+	p.expect('(')
+	for p.peek() != ')' {
+		p.expect(VAR)
+		p.space()
+		p.formal()
+		if p.peek() == ',' {
+			p.expect(',')
+			p.space()
+		}
+	}
+	p.expect(')')
+
+	if p.peek() == EXTENDS {
+		p.space()
+		p.expect(EXTENDS)
+		p.space()
+		if p.peek() == NATIVE {
+			p.expect(NATIVE)
+		} else {
+			p.expect(TYPEID)
+			p.actuals()
+		}
+	}
+
+	p.space()
+	p.expect('{')
+	if p.peek() == '}' && !p.broken() {
+		p.expect('}')
+		return
+	}
+	p.indent++
+	for p.peek() != '}' {
+		p.newline()
+		p.feature()
+		p.expect(';')
+	}
+	p.newline()
+	p.comments()
+	p.indent--
+	p.newline()
+	p.expect('}')
+}
+
+func (p *formatter) formal() {
+	p.expect(OBJECTID)
+	p.space()
+	p.expect(':')
+	p.space()
+	p.expect(TYPEID)
+}
+
+func (p *formatter) feature() {
+	switch p.peek() {
+	case '{':
+		p.block()
+
+	case VAR:
+		p.expect(VAR)
+		p.space()
+		p.expect(OBJECTID)
+		p.space()
+		if p.peek() == ':' {
+			p.expect(':')
+			p.space()
+			p.expect(TYPEID)
+			p.space()
+			p.expect('=')
+			p.body()
+		} else {
+			p.expect('=')
+			p.space()
+			p.expect(NATIVE)
+		}
+
+	default:
+		if p.peek() == OVERRIDE {
+			p.expect(OVERRIDE)
+			p.space()
+		}
+		p.expect(DEF)
+		p.space()
+		p.expect(OBJECTID)
+		p.expect('(')
+		for p.peek() != ')' {
+			p.formal()
+			if p.peek() == ',' {
+				p.expect(',')
+				p.space()
+			}
+		}
+		p.expect(')')
+		p.space()
+		p.expect(':')
+		p.space()
+		p.expect(TYPEID)
+		p.space()
+		p.expect('=')
+		if p.peek() == NATIVE {
+			p.space()
+			p.expect(NATIVE)
+		} else {
+			p.body()
+		}
+	}
+}
+
+// body writes the expression after the '=' of a feature. It goes on the next
+// line if it did in the original code, unless it is a block.
+func (p *formatter) body() {
+	if p.peek() != '{' && p.broken() {
+		p.indent++
+		p.newline()
+		p.expr(precAssign)
+		p.indent--
+		return
+	}
+	p.space()
+	p.expr(precAssign)
+}
+
+// block writes a block in braces. Blocks with more than one statement, or
+// that were already split across lines, have one statement per line.
+func (p *formatter) block() {
+	p.expect('{')
+	if p.peek() == '}' && !p.broken() {
+		p.expect('}')
+		return
+	}
+
+	if !p.broken() && p.simpleBlock() {
+		p.space()
+		p.statement()
+		p.space()
+		p.expect('}')
+		return
+	}
+
+	p.indent++
+	p.statements('}')
+	p.newline()
+	p.comments()
+	p.indent--
+	p.newline()
+	p.expect('}')
+}
+
+// simpleBlock returns true if the block starting at the next token has one
+// expression and no comments.
+func (p *formatter) simpleBlock() bool {
+	depth := 0
+	for i := p.i; i < len(p.toks); i++ {
+		t := p.toks[i]
+		if i != p.i && len(t.trivia) != 0 {
+			return false
+		}
+		switch t.tok {
+		case '{', '(':
+			depth++
+		case '}', ')':
+			if depth == 0 {
+				return true
+			}
+			depth--
+		case ';', VAR:
+			if depth == 0 {
+				return false
+			}
+		case 0:
+			return false
+		}
+	}
+	return false
+}
+
+// statements writes the statements of a block, each on its own line, up to
+// the token end (which is not written). In a match case, the block also ends
+// at the next case.
+func (p *formatter) statements(end int) {
+	for p.peek() != end && !(end == CASE && p.peek() == '}') {
+		p.newline()
+		p.statement()
+		if p.peek() != ';' {
+			break
+		}
+		p.expect(';')
+	}
+}
+
+func (p *formatter) statement() {
+	if p.peek() != VAR {
+		p.expr(precAssign)
+		return
+	}
+
+	p.expect(VAR)
+	p.space()
+	p.formal()
+	p.space()
+	p.expect('=')
+	p.body()
+}
+
+// operator precedence, from lowest to highest, as in syntax.y.
+const (
+	precAssign = iota
+	precIf
+	precMatch
+	precCompare
+	precEqual
+	precAdd
+	precMultiply
+	precUnary
+	precDot
+)
+
+func binaryPrec(tok int) int {
+	switch tok {
+	case LE, '<':
+		return precCompare
+	case EQ:
+		return precEqual
+	case '+', '-':
+		return precAdd
+	case '*', '/':
+		return precMultiply
+	}
+	return -1
+}
+
+// expr writes an expression whose operators all have at least the given
+// precedence. A line break in the original code before or after an operator
+// is kept, and the rest of the expression is indented one more level.
+func (p *formatter) expr(prec int) {
+	indent := p.indent
+	defer func() {
+		p.indent = indent
+	}()
+	continued := false
+	cont := func() {
+		if !continued {
+			continued = true
+			p.indent++
+		}
+		p.newline()
+	}
+
+	p.unary()
+
+	for {
+		tok := p.peek()
+		switch {
+		case tok == '.':
+			if p.broken() {
+				cont()
+			}
+			p.expect('.')
+			p.expect(OBJECTID)
+			p.actuals()
+
+		case tok == MATCH && prec <= precMatch:
+			p.space()
+			p.expect(MATCH)
+			p.match()
+
+		case binaryPrec(tok) >= prec:
+			p.space()
+			broken := p.broken()
+			p.emit()
+			if broken || p.broken() {
+				cont()
+			} else {
+				p.space()
+			}
+			p.expr(binaryPrec(tok) + 1)
+
+		default:
+			return
+		}
+	}
+}
+
+// unary writes a prefix expression or a primary expression.
+func (p *formatter) unary() {
+	switch p.peek() {
+	case '!', '-':
+		p.emit()
+		p.expr(precUnary)
+
+	case IF:
+		p.expect(IF)
+		p.space()
+		p.expect('(')
+		p.group(func() {
+			p.expr(precAssign)
+		})
+		// a branch goes on its own line if it was on its own line in
+		// the original code, except for blocks and else-if.
+		broken := p.peek() != '{' && p.broken()
+		p.branch(broken)
+		if p.toks[p.last].tok != '}' && (broken || p.broken()) {
+			p.newline()
+		} else {
+			p.space()
+		}
+		p.expect(ELSE)
+		p.branch(p.peek() != '{' && p.peek() != IF && p.broken())
+
+	case WHILE:
+		p.expect(WHILE)
+		p.space()
+		p.expect('(')
+		p.group(func() {
+			p.expr(precAssign)
+		})
+		p.branch(p.peek() != '{' && p.broken())
+
+	case OBJECTID:
+		p.expect(OBJECTID)
+		switch p.peek() {
+		case '(':
+			p.actuals()
+		case '=':
+			p.space()
+			p.expect('=')
+			p.body()
+		}
+
+	case SUPER:
+		p.expect(SUPER)
+		p.expect('.')
+		p.expect(OBJECTID)
+		p.actuals()
+
+	case NEW:
+		p.expect(NEW)
+		p.space()
+		p.expect(TYPEID)
+		p.actuals()
+
+	case '{':
+		p.block()
+
+	case '(':
+		p.expect('(')
+		if p.peek() == ')' {
+			p.expect(')')
+			return
+		}
+		p.group(func() {
+			p.expr(precAssign)
+		})
+
+	case NULL, INTEGER, STRING, TRUE, FALSE, THIS:
+		p.emit()
+
+	default:
+		p.fail(fmt.Sprintf("formatter expected an expression, found %q", p.toks[p.i].text))
+	}
+}
+
+// branch writes the body of an if, else, or while.
+func (p *formatter) branch(broken bool) {
+	if broken {
+		p.indent++
+		p.newline()
+		p.expr(precIf)
+		p.indent--
+		return
+	}
+	p.space()
+	p.expr(precIf)
+}
+
+// actuals writes the arguments of a method call.
+func (p *formatter) actuals() {
+	p.expect('(')
+	if p.peek() == ')' {
+		p.expect(')')
+		return
+	}
+	p.group(func() {
+		for {
+			p.expr(precAssign)
+			if p.peek() != ',' {
+				break
+			}
+			p.expect(',')
+			if p.broken() {
+				p.newline()
+			} else {
+				p.space()
+			}
+		}
+	})
+}
+
+// group writes the contents of parentheses, which have already been opened.
+// If there was a line break after the '(' in the original code, the contents
+// are indented on their own lines.
+func (p *formatter) group(contents func()) {
+	if !p.broken() {
+		contents()
+		p.expect(')')
+		return
+	}
+
+	p.indent++
+	p.newline()
+	contents()
+	p.newline()
+	p.comments()
+	p.indent--
+	p.newline()
+	p.expect(')')
+}
+
+// match writes the cases of a match expression, one per line, with the
+// arrows lined up.
+func (p *formatter) match() {
+	p.space()
+	p.expect('{')
+	p.indent++
+
+	// find the width of the widest case before the arrow.
+	width := 0
+	depth := 0
+	for i := p.i; i < len(p.toks) && depth >= 0; i++ {
+		switch p.toks[i].tok {
+		case '{':
+			depth++
+		case '}':
+			depth--
+		case CASE:
+			if depth == 0 {
+				if w := caseWidth(p.toks[i:]); w > width {
+					width = w
+				}
+			}
+		}
+	}
+
+	for p.peek() == CASE {
+		p.newline()
+		w := caseWidth(p.toks[p.i:])
+		p.expect(CASE)
+		p.space()
+		if p.peek() == NULL {
+			p.expect(NULL)
+		} else {
+			p.formal()
+		}
+		// comments in the middle of a case make the width unknown.
+		if len(p.toks[p.i].trivia) == 0 {
+			p.out.WriteString(strings.Repeat(" ", width-w))
+		}
+		p.space()
+		p.expect(ARROW)
+
+		if p.peek() == CASE || p.peek() == '}' {
+			// empty block
+			continue
+		}
+		if !p.broken() && p.simpleCase() {
+			p.space()
+			p.statement()
+			continue
+		}
+		p.indent++
+		p.statements(CASE)
+		p.indent--
+	}
+
+	p.newline()
+	p.comments()
+	p.indent--
+	p.newline()
+	p.expect('}')
+}
+
+// caseWidth returns the width of `case x : T` or `case null`.
+func caseWidth(toks []fmtToken) int {
+	if len(toks) > 1 && toks[1].tok == NULL {
+		return len("case null")
+	}
+	if len(toks) > 3 {
+		return len("case ") + len(toks[1].text) + len(" : ") + len(toks[3].text)
+	}
+	return 0
+}
+
+// simpleCase returns true if the body of the case starting at the next token
+// is one expression with no comments.
+func (p *formatter) simpleCase() bool {
+	depth := 0
+	for i := p.i; i < len(p.toks); i++ {
+		t := p.toks[i]
+		if i != p.i && len(t.trivia) != 0 {
+			return false
+		}
+		switch t.tok {
+		case '{', '(':
+			depth++
+		case ')':
+			depth--
+		case '}':
+			if depth == 0 {
+				return true
+			}
+			depth--
+		case CASE:
+			if depth == 0 {
+				return true
+			}
+		case ';', VAR:
+			if depth == 0 {
+				return false
+			}
+		case 0:
+			return false
+		}
+	}
+	return false
+}
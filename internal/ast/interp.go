@@ -0,0 +1,717 @@
+package ast
+
+import (
+	"bufio"
+	"io"
+	"strconv"
+	"sync"
+)
+
+// interpObject is a Cool object at runtime. The null reference is represented
+// as a nil *interpObject.
+type interpObject struct {
+	class  *Class
+	fields map[*Attribute]*interpObject
+
+	// Int.value
+	int int32
+	// String.str_field
+	str string
+	// ArrayAny.array_field
+	array []*interpObject
+	// Coroutine.coroutine_field
+	coroutine *interpCoroutine
+	// Channel.channel_field
+	channel *interpChannel
+}
+
+// interpFrame is the state of a single method call.
+type interpFrame struct {
+	this *interpObject
+	vars map[Object]*interpObject
+}
+
+func (f *interpFrame) Load(o Object) *interpObject {
+	switch v := o.(type) {
+	case *Attribute:
+		return f.this.fields[v]
+	case *AttributeObject:
+		return f.Load(v.Object).fields[v.Attribute]
+	default:
+		return f.vars[o]
+	}
+}
+
+func (f *interpFrame) Store(o Object, value *interpObject) {
+	switch v := o.(type) {
+	case *Attribute:
+		f.this.fields[v] = value
+	case *AttributeObject:
+		f.Load(v.Object).fields[v.Attribute] = value
+	default:
+		f.vars[o] = value
+	}
+}
+
+// interpCoroutine is a Coroutine. Every coroutine runs in its own goroutine,
+// but only the goroutine of ctx.current is allowed to do anything.
+type interpCoroutine struct {
+	prev, next *interpCoroutine
+
+	runnable *interpObject
+	wake     chan struct{}
+	main     chan struct{}
+}
+
+// interpChannel is the state of a Channel. It holds at most one value.
+type interpChannel struct {
+	full  bool
+	value *interpObject
+}
+
+// interpExit is used with panic to stop the program.
+type interpExit struct {
+	status int
+}
+
+// interpAbandoned is used with panic to stop the goroutine of a coroutine that
+// will never be scheduled again.
+var interpAbandoned = new(byte)
+
+type interpCtx struct {
+	program *Program
+
+	in  *bufio.Reader
+	out *bufio.Writer
+
+	unitClass    *Class
+	intClass     *Class
+	booleanClass *Class
+	stringClass  *Class
+	symbolClass  *Class
+
+	stringLength *Attribute
+	arrayLength  *Attribute
+	symbolName   *Attribute
+	symbolHash   *Attribute
+	runnable     *Attribute
+
+	unit     *interpObject
+	true     *interpObject
+	false    *interpObject
+	symbols  map[string]*interpObject
+	nsymbols int32
+
+	current  *interpCoroutine
+	deadlock *interpCoroutine
+	done     chan struct{}
+	stop     sync.Once
+	failure  interface{}
+
+	opt Options
+}
+
+// Interp runs the program by walking its syntax tree instead of generating
+// code. It may only be used on a program that passed Semant. Runtime errors
+// are written to stdout with the same messages as libcool. The return value
+// is the exit status of the program.
+func (p *Program) Interp(opt Options, stdin io.Reader, stdout io.Writer) (exit int) {
+	ctx := &interpCtx{
+		program: p,
+
+		in:  bufio.NewReader(stdin),
+		out: bufio.NewWriter(stdout),
+
+		unitClass:    p.classMap["Unit"],
+		intClass:     p.classMap["Int"],
+		booleanClass: p.classMap["Boolean"],
+		stringClass:  p.classMap["String"],
+		symbolClass:  p.classMap["Symbol"],
+
+		symbols: make(map[string]*interpObject),
+
+		done: make(chan struct{}),
+
+		opt: opt,
+	}
+
+	ctx.stringLength = ctx.Attribute("String", "length")
+	ctx.arrayLength = ctx.Attribute("ArrayAny", "length")
+	ctx.symbolName = ctx.Attribute("Symbol", "name")
+	ctx.symbolHash = ctx.Attribute("Symbol", "hash")
+	if opt.Coroutine {
+		ctx.runnable = ctx.Attribute("Coroutine", "runnable")
+	}
+
+	ctx.unit = ctx.Alloc(ctx.unitClass)
+	ctx.true = ctx.Alloc(ctx.booleanClass)
+	ctx.false = ctx.Alloc(ctx.booleanClass)
+
+	defer func() {
+		// release the goroutines of any coroutines that are still
+		// waiting to be scheduled.
+		ctx.stop.Do(func() {
+			close(ctx.done)
+		})
+
+		if err := ctx.out.Flush(); err != nil && exit == 0 {
+			exit = 1
+		}
+	}()
+
+	defer func() {
+		if r := recover(); r != nil {
+			if e, ok := r.(interpExit); ok {
+				exit = e.status
+				return
+			}
+			panic(r)
+		}
+	}()
+
+	p.Main.interp(ctx, &interpFrame{
+		vars: make(map[Object]*interpObject),
+	})
+
+	return 0
+}
+
+func (ctx *interpCtx) Attribute(class, name string) *Attribute {
+	for _, f := range ctx.program.classMap[class].Features {
+		if a, ok := f.(*Attribute); ok && a.Name.Name == name {
+			return a
+		}
+	}
+	panic("INTERNAL COMPILER ERROR: missing attribute " + class + "." + name)
+}
+
+func (ctx *interpCtx) Method(class, name string) *Method {
+	for _, m := range ctx.program.classMap[class].Methods {
+		if m.Name.Name == name {
+			return m
+		}
+	}
+	panic("INTERNAL COMPILER ERROR: missing method " + class + "." + name)
+}
+
+func (ctx *interpCtx) Alloc(c *Class) *interpObject {
+	o := &interpObject{
+		class:  c,
+		fields: make(map[*Attribute]*interpObject),
+	}
+	for p := c; p != nativeClass; p = p.Extends.Type.Class {
+		for _, f := range p.Features {
+			if a, ok := f.(*Attribute); ok {
+				switch a.Type.Name {
+				case "Int":
+					o.fields[a] = ctx.Int(0)
+				case "Boolean":
+					o.fields[a] = ctx.false
+				case "Unit":
+					o.fields[a] = ctx.unit
+				}
+			}
+		}
+	}
+	return o
+}
+
+func (ctx *interpCtx) Int(i int32) *interpObject {
+	return &interpObject{
+		class: ctx.intClass,
+		int:   i,
+	}
+}
+
+func (ctx *interpCtx) Bool(b bool) *interpObject {
+	if b {
+		return ctx.true
+	}
+	return ctx.false
+}
+
+func (ctx *interpCtx) String(s string) *interpObject {
+	return &interpObject{
+		class: ctx.stringClass,
+		fields: map[*Attribute]*interpObject{
+			ctx.stringLength: ctx.Int(int32(len(s))),
+		},
+		str: s,
+	}
+}
+
+func (ctx *interpCtx) Exit(status int) {
+	panic(interpExit{status})
+}
+
+func (ctx *interpCtx) Panic(message string) {
+	ctx.out.WriteString(message)
+	ctx.Exit(1)
+}
+
+func (ctx *interpCtx) NullPanic() {
+	ctx.Panic("Null pointer dereference\n")
+}
+
+func (ctx *interpCtx) BoundsPanic() {
+	ctx.Panic("Index out of bounds\n")
+}
+
+func (ctx *interpCtx) CasePanic(c *Class) {
+	ctx.Panic("Unhandled type in match expression: " + c.Type.Name + "\n")
+}
+
+func (ctx *interpCtx) DivideByZero() {
+	// the generated code lets the processor raise SIGFPE, so use the
+	// same exit status a shell would report for that.
+	ctx.Exit(128 + 8)
+}
+
+func (ctx *interpCtx) Call(m *Method, this *interpObject, args []*interpObject) *interpObject {
+	if _, ok := m.Body.(*NativeExpr); ok {
+		return ctx.Native(m, this, args)
+	}
+
+	frame := &interpFrame{
+		this: this,
+		vars: make(map[Object]*interpObject, len(args)),
+	}
+	for i, a := range m.Args {
+		frame.vars[a] = args[i]
+	}
+
+	return m.Body.interp(ctx, frame)
+}
+
+func (ctx *interpCtx) Native(m *Method, this *interpObject, args []*interpObject) *interpObject {
+	switch m.Parent.Type.Name + "." + m.Name.Name {
+	case "Any.toString":
+		return ctx.String(this.class.Type.Name)
+
+	case "Any.equals":
+		return ctx.Bool(this == args[0])
+
+	case "IO.abort":
+		if args[0] == nil {
+			ctx.NullPanic()
+		}
+		ctx.out.WriteString(args[0].str)
+		ctx.Exit(1)
+
+	case "IO.out":
+		if args[0] == nil {
+			ctx.NullPanic()
+		}
+		ctx.out.WriteString(args[0].str)
+		return this
+
+	case "IO.in":
+		// the runtime has a fixed-size input buffer, so longer lines
+		// are split.
+		const max = 0x400
+
+		// make sure the prompt is visible before we block.
+		ctx.out.Flush()
+
+		var buf []byte
+		for len(buf) < max {
+			b, err := ctx.in.ReadByte()
+			if err != nil {
+				if len(buf) == 0 {
+					return nil
+				}
+				break
+			}
+			if b == '\n' {
+				break
+			}
+			buf = append(buf, b)
+		}
+		return ctx.String(string(buf))
+
+	case "IO.symbol":
+		if args[0] == nil {
+			ctx.NullPanic()
+		}
+		if sym, ok := ctx.symbols[args[0].str]; ok {
+			return sym
+		}
+		ctx.nsymbols++
+		sym := ctx.Alloc(ctx.symbolClass)
+		sym.fields[ctx.symbolName] = args[0]
+		sym.fields[ctx.symbolHash] = ctx.Int(ctx.nsymbols)
+		ctx.symbols[args[0].str] = sym
+		return sym
+
+	case "IO.symbol_name":
+		if args[0] == nil {
+			ctx.NullPanic()
+		}
+		return args[0].fields[ctx.symbolName]
+
+	case "Int.toString":
+		return ctx.String(strconv.Itoa(int(this.int)))
+
+	case "Int.equals":
+		return ctx.Bool(args[0] != nil && args[0].class == ctx.intClass && args[0].int == this.int)
+
+	case "String.equals":
+		return ctx.Bool(args[0] != nil && args[0].class == ctx.stringClass && args[0].str == this.str)
+
+	case "String.concat":
+		if args[0] == nil {
+			ctx.NullPanic()
+		}
+		return ctx.String(this.str + args[0].str)
+
+	case "String.substring":
+		start, end := uint32(args[0].int), uint32(args[1].int)
+		if start >= uint32(len(this.str)) || end < start || end > uint32(len(this.str)) {
+			ctx.BoundsPanic()
+		}
+		return ctx.String(this.str[start:end])
+
+	case "String.charAt":
+		index := uint32(args[0].int)
+		if index >= uint32(len(this.str)) {
+			ctx.BoundsPanic()
+		}
+		return ctx.Int(int32(this.str[index]))
+
+	case "ArrayAny.get":
+		index := uint32(args[0].int)
+		if index >= uint32(len(this.array)) {
+			ctx.BoundsPanic()
+		}
+		return this.array[index]
+
+	case "ArrayAny.set":
+		index := uint32(args[0].int)
+		if index >= uint32(len(this.array)) {
+			ctx.BoundsPanic()
+		}
+		old := this.array[index]
+		this.array[index] = args[1]
+		return old
+
+	case "ArrayAny.ArrayAny":
+		if args[0].int&^0x1fffffff != 0 {
+			ctx.BoundsPanic()
+		}
+		this.fields[ctx.arrayLength] = args[0]
+		this.array = make([]*interpObject, args[0].int)
+		return this
+
+	case "Coroutine.Coroutine":
+		if args[0] == nil {
+			ctx.NullPanic()
+		}
+		this.fields[ctx.runnable] = args[0]
+		this.coroutine = ctx.Go(args[0])
+		return this
+
+	case "Channel.Channel":
+		this.channel = &interpChannel{}
+		return this
+
+	case "Channel.send":
+		ch := this.channel
+		for ch.full {
+			ctx.Sched()
+		}
+		ctx.deadlock = nil
+		ch.full = true
+		ch.value = args[0]
+		for ch.full && ch.value == args[0] {
+			ctx.Sched()
+		}
+		ctx.deadlock = nil
+		return ctx.unit
+
+	case "Channel.recv":
+		ch := this.channel
+		for !ch.full {
+			ctx.Sched()
+		}
+		ctx.deadlock = nil
+		ch.full = false
+		return ch.value
+	}
+
+	panic("INTERNAL COMPILER ERROR: missing native method " + m.Parent.Type.Name + "." + m.Name.Name)
+}
+
+// Go starts a coroutine. The first coroutine is Main, and Go does not return
+// until it has terminated. Later coroutines are added to the schedule after
+// the current one.
+func (ctx *interpCtx) Go(runnable *interpObject) *interpCoroutine {
+	co := &interpCoroutine{
+		runnable: runnable,
+		wake:     make(chan struct{}, 1),
+	}
+
+	isMain := ctx.current == nil
+	if isMain {
+		co.prev, co.next = co, co
+		co.main = make(chan struct{})
+	} else {
+		co.prev, co.next = ctx.current, ctx.current.next
+		co.prev.next, co.next.prev = co, co
+	}
+
+	// call Runnable.run the same way the runtime does.
+	run := ctx.Method("Runnable", "run")
+	ctx.runCoroutine(co, runnable.class.Methods[run.Order])
+
+	if isMain {
+		ctx.current = co
+		co.wake <- struct{}{}
+
+		select {
+		case <-co.main:
+		case <-ctx.done:
+			ctx.Rethrow()
+		}
+
+		// any other coroutines are abandoned.
+		ctx.current = nil
+	}
+
+	return co
+}
+
+func (ctx *interpCtx) runCoroutine(co *interpCoroutine, run *Method) {
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				if r == interpAbandoned {
+					return
+				}
+				// the program is over. let the goroutine that
+				// called Interp report it.
+				ctx.stop.Do(func() {
+					ctx.failure = r
+					close(ctx.done)
+				})
+			}
+		}()
+
+		ctx.Wait(co)
+
+		ctx.Call(run, co.runnable, nil)
+
+		// remove ourself from the schedule.
+		co.prev.next, co.next.prev = co.next, co.prev
+		ctx.current = co.next
+
+		if co.main != nil {
+			close(co.main)
+			return
+		}
+
+		ctx.current.wake <- struct{}{}
+	}()
+}
+
+// Sched lets the next coroutine run. It is only called by coroutines that are
+// waiting for a Channel, so it reports a deadlock if every coroutine has
+// called Sched since the last Channel operation completed.
+func (ctx *interpCtx) Sched() {
+	co := ctx.current
+	if ctx.deadlock == nil {
+		ctx.deadlock = co
+	} else if ctx.deadlock == co {
+		ctx.Panic("Deadlock\n")
+	}
+
+	ctx.current = co.next
+	ctx.current.wake <- struct{}{}
+
+	ctx.Wait(co)
+}
+
+func (ctx *interpCtx) Wait(co *interpCoroutine) {
+	select {
+	case <-co.wake:
+	case <-ctx.done:
+		panic(interpAbandoned)
+	}
+}
+
+// Rethrow continues a panic from a coroutine in the goroutine that started
+// Main.
+func (ctx *interpCtx) Rethrow() {
+	if ctx.failure == nil {
+		panic(interpAbandoned)
+	}
+	panic(ctx.failure)
+}
+
+func (e *NotExpr) interp(ctx *interpCtx, f *interpFrame) *interpObject {
+	return ctx.Bool(e.Expr.interp(ctx, f) == ctx.false)
+}
+
+func (e *NegativeExpr) interp(ctx *interpCtx, f *interpFrame) *interpObject {
+	return ctx.Int(-e.Expr.interp(ctx, f).int)
+}
+
+func (e *IfExpr) interp(ctx *interpCtx, f *interpFrame) *interpObject {
+	if e.Cond.interp(ctx, f) != ctx.false {
+		return e.Then.interp(ctx, f)
+	}
+	return e.Else.interp(ctx, f)
+}
+
+func (e *WhileExpr) interp(ctx *interpCtx, f *interpFrame) *interpObject {
+	for e.Cond.interp(ctx, f) != ctx.false {
+		e.Body.interp(ctx, f)
+	}
+	return ctx.unit
+}
+
+func interpArithmetic(ctx *interpCtx, f *interpFrame, left, right Expr) (int32, int32) {
+	l := left.interp(ctx, f).int
+	r := right.interp(ctx, f).int
+	return l, r
+}
+
+func (e *LessOrEqualExpr) interp(ctx *interpCtx, f *interpFrame) *interpObject {
+	l, r := interpArithmetic(ctx, f, e.Left, e.Right)
+	return ctx.Bool(l <= r)
+}
+
+func (e *LessThanExpr) interp(ctx *interpCtx, f *interpFrame) *interpObject {
+	l, r := interpArithmetic(ctx, f, e.Left, e.Right)
+	return ctx.Bool(l < r)
+}
+
+func (e *MultiplyExpr) interp(ctx *interpCtx, f *interpFrame) *interpObject {
+	l, r := interpArithmetic(ctx, f, e.Left, e.Right)
+	return ctx.Int(l * r)
+}
+
+func (e *DivideExpr) interp(ctx *interpCtx, f *interpFrame) *interpObject {
+	l, r := interpArithmetic(ctx, f, e.Left, e.Right)
+	if r == 0 || (r == -1 && l == -1<<31) {
+		ctx.DivideByZero()
+	}
+	return ctx.Int(l / r)
+}
+
+func (e *AddExpr) interp(ctx *interpCtx, f *interpFrame) *interpObject {
+	l, r := interpArithmetic(ctx, f, e.Left, e.Right)
+	return ctx.Int(l + r)
+}
+
+func (e *SubtractExpr) interp(ctx *interpCtx, f *interpFrame) *interpObject {
+	l, r := interpArithmetic(ctx, f, e.Left, e.Right)
+	return ctx.Int(l - r)
+}
+
+func (e *MatchExpr) interp(ctx *interpCtx, f *interpFrame) *interpObject {
+	left := e.Left.interp(ctx, f)
+	f.vars[e] = left
+
+	if left == nil {
+		for _, c := range e.Cases {
+			if c.Type.Class == nullClass {
+				return c.Body.interp(ctx, f)
+			}
+		}
+		ctx.CasePanic(nullClass)
+	}
+
+	for _, c := range e.Cases {
+		if c.Type.Class.Order <= left.class.Order && left.class.Order <= c.Type.Class.MaxOrder {
+			return c.Body.interp(ctx, f)
+		}
+	}
+	ctx.CasePanic(left.class)
+	return nil
+}
+
+func interpArgs(ctx *interpCtx, f *interpFrame, args []Expr) []*interpObject {
+	values := make([]*interpObject, len(args))
+	for i, a := range args {
+		values[i] = a.interp(ctx, f)
+	}
+	return values
+}
+
+func (e *DynamicCallExpr) interp(ctx *interpCtx, f *interpFrame) *interpObject {
+	recv := e.Recv.interp(ctx, f)
+	if recv == nil {
+		ctx.NullPanic()
+	}
+	args := interpArgs(ctx, f, e.Args)
+	return ctx.Call(recv.class.Methods[e.Name.Method.Order], recv, args)
+}
+
+func (e *SuperCallExpr) interp(ctx *interpCtx, f *interpFrame) *interpObject {
+	args := interpArgs(ctx, f, e.Args)
+	return ctx.Call(e.Name.Method, f.this, args)
+}
+
+func (e *StaticCallExpr) interp(ctx *interpCtx, f *interpFrame) *interpObject {
+	recv := e.Recv.interp(ctx, f)
+	args := interpArgs(ctx, f, e.Args)
+	return ctx.Call(e.Name.Method, recv, args)
+}
+
+func (e *AllocExpr) interp(ctx *interpCtx, f *interpFrame) *interpObject {
+	return ctx.Alloc(e.Type.Class)
+}
+
+func (e *AssignExpr) interp(ctx *interpCtx, f *interpFrame) *interpObject {
+	f.Store(e.Name.Object, e.Expr.interp(ctx, f))
+	return ctx.unit
+}
+
+func (e *VarExpr) interp(ctx *interpCtx, f *interpFrame) *interpObject {
+	f.vars[e] = e.Init.interp(ctx, f)
+	if e.NullCheck && f.vars[e] == nil {
+		ctx.NullPanic()
+	}
+	return e.Body.interp(ctx, f)
+}
+
+func (e *ChainExpr) interp(ctx *interpCtx, f *interpFrame) *interpObject {
+	e.Pre.interp(ctx, f)
+	return e.Expr.interp(ctx, f)
+}
+
+func (e *ThisExpr) interp(ctx *interpCtx, f *interpFrame) *interpObject {
+	return f.this
+}
+
+func (e *NullExpr) interp(ctx *interpCtx, f *interpFrame) *interpObject {
+	return nil
+}
+
+func (e *UnitExpr) interp(ctx *interpCtx, f *interpFrame) *interpObject {
+	return ctx.unit
+}
+
+func (e *NameExpr) interp(ctx *interpCtx, f *interpFrame) *interpObject {
+	return f.Load(e.Name.Object)
+}
+
+func (e *StringExpr) interp(ctx *interpCtx, f *interpFrame) *interpObject {
+	return ctx.String(e.Lit.Str)
+}
+
+func (e *BoolExpr) interp(ctx *interpCtx, f *interpFrame) *interpObject {
+	return ctx.Bool(e.Lit.Bool)
+}
+
+func (e *IntExpr) interp(ctx *interpCtx, f *interpFrame) *interpObject {
+	return ctx.Int(e.Lit.Int)
+}
+
+func (e *NativeExpr) interp(ctx *interpCtx, f *interpFrame) *interpObject {
+	panic("NativeExpr.interp should never be called")
+}
+
+func (e *BadExpr) interp(ctx *interpCtx, f *interpFrame) *interpObject {
+	panic("BadExpr.interp should never be called")
+}
@@ -0,0 +1,877 @@
+package ast
+
+import (
+	"strconv"
+	"strings"
+)
+
+// irFunc is the intermediate representation of a method body, between the
+// checked AST and the x86 code generated for it. The body is split into
+// basic blocks of instructions on temporaries, with every allocation,
+// reference count change, null check, and method lookup written out.
+type irFunc struct {
+	// Name is the symbol of the method.
+	Name string
+	// Args is the number of arguments, not counting the receiver, or -1
+	// for main, which has no receiver.
+	Args int
+	// Params is the receiver followed by the arguments.
+	Params []*irTemp
+	// Blocks is every block in the order the code is laid out. The first
+	// one is the entry and the last one returns.
+	Blocks []*irBlock
+	// Temps is every temporary, in the order they were created.
+	Temps []*irTemp
+}
+
+// irBlock is a basic block: instructions that run in order, followed by a
+// terminator that decides which block runs next.
+type irBlock struct {
+	Instrs []*irInstr
+	Term   *irInstr
+	// Depth is the number of loops the block is inside.
+	Depth int
+
+	// label is the local label of the block in the generated code, and
+	// index is its position in the method.
+	label string
+	index int
+}
+
+// irTemp is a temporary: a local variable, an argument, the value of a match
+// expression, or an intermediate value. A temporary that holds an object
+// holds a counted reference to it unless the object is a literal.
+type irTemp struct {
+	ID int
+	// Raw is true if the temporary holds an unboxed integer.
+	Raw bool
+	// Int is true if the temporary is an Int variable that irOptInt can
+	// keep unboxed.
+	Int bool
+	// Param is the offset of an argument from %ebp, or 0 for any other
+	// temporary.
+	Param int
+	// Words is the number of words the temporary takes in the stack
+	// frame, which is 4 for an Int built in the stack frame.
+	Words int
+
+	// iv is where the temporary is kept, and acc is true if it is only
+	// ever in %eax instead.
+	iv  *genInterval
+	acc bool
+}
+
+func (t *irTemp) String() string {
+	return "t" + strconv.Itoa(t.ID)
+}
+
+// irOperand is an *irTemp or an irConst.
+type irOperand interface {
+	String() string
+}
+
+// irConst is an operand that never changes: an integer, or the address of a
+// literal. The null pointer is the integer 0.
+type irConst struct {
+	// Sym is the symbol of the literal, or "" for an integer.
+	Sym string
+	// Int is the integer, or the value of an Int literal.
+	Int int32
+}
+
+func (c irConst) String() string {
+	if c.Sym != "" {
+		return "$" + c.Sym
+	}
+	return "$" + strconv.Itoa(int(c.Int))
+}
+
+// IsInt returns true if c is the address of an Int literal.
+func (c irConst) IsInt() bool {
+	return strings.HasPrefix(c.Sym, "int_lit_")
+}
+
+var (
+	irNull    = irConst{}
+	irUnit    = irConst{Sym: "unit_lit"}
+	irTrue    = irConst{Sym: "boolean_true"}
+	irFalse   = irConst{Sym: "boolean_false"}
+	irIntZero = irConst{Sym: "int_lit_0"}
+)
+
+// irOp is the operation of an instruction. Dst is the temporary an
+// instruction stores to, and A and B are Args[0] and Args[1].
+type irOp int
+
+const (
+	// irMove copies A to Dst. A reference held by A is moved, not
+	// counted again.
+	irMove irOp = iota
+	// irAdd, irSub, irMul, and irDiv compute A op B for unboxed integers.
+	irAdd
+	irSub
+	irMul
+	irDiv
+	// irNeg computes -A for an unboxed integer.
+	irNeg
+	// irBox allocates an Int holding the unboxed integer A.
+	irBox
+	// irStackBox builds an Int holding A in the stack slots of Dst, which
+	// the garbage collector and reference counting ignore. It stays
+	// valid until the calls in Keep return.
+	irStackBox
+	// irUnbox reads the unboxed integer in the Int A.
+	irUnbox
+	// irLoad reads Attr of the object A without counting a reference.
+	irLoad
+	// irStore sets Attr of the object A to B. The heap is traced by the
+	// garbage collector, so B must not hold a counted reference.
+	irStore
+	// irRef and irUnref add and remove a counted reference to A.
+	irRef
+	irUnref
+	// irNullCheck stops the program if A is null.
+	irNullCheck
+	// irTag reads the class tag of A, which is 0 for null.
+	irTag
+	// irAlloc allocates an instance of Class with no attributes set.
+	irAlloc
+	// irLess and irLessEq compare unboxed integers, returning a Boolean.
+	irLess
+	irLessEq
+	// irNot returns the Boolean that is not A.
+	irNot
+	// irPush pushes A as an argument of Call. Borrowed is true if the
+	// method only reads it.
+	irPush
+	// irLookup finds Method in the method table of the receiver that was
+	// pushed before the last N arguments.
+	irLookup
+	// irCall calls Method, or the method found by irLookup in A if there
+	// is one, with the receiver and N arguments pushed. The callee
+	// removes a reference from each of them. Dst holds the result.
+	irCall
+
+	// irJump goes to Targets[0].
+	irJump
+	// irBranch goes to Targets[0] if the Boolean A is false, and to
+	// Targets[1] otherwise.
+	irBranch
+	// irBranchLess and irBranchLessEq go to Targets[1] if A < B or A <= B
+	// for unboxed integers, and to Targets[0] otherwise.
+	irBranchLess
+	irBranchLessEq
+	// irSwitch goes to the target of the first case whose range includes
+	// the tag A. If there is none, the program stops.
+	irSwitch
+	// irReturn returns A from the method.
+	irReturn
+)
+
+var irOpNames = [...]string{
+	irMove:         "move",
+	irAdd:          "add",
+	irSub:          "sub",
+	irMul:          "mul",
+	irDiv:          "div",
+	irNeg:          "neg",
+	irBox:          "box",
+	irStackBox:     "stackbox",
+	irUnbox:        "unbox",
+	irLoad:         "load",
+	irStore:        "store",
+	irRef:          "ref",
+	irUnref:        "unref",
+	irNullCheck:    "nullcheck",
+	irTag:          "tag",
+	irAlloc:        "alloc",
+	irLess:         "less",
+	irLessEq:       "lesseq",
+	irNot:          "not",
+	irPush:         "push",
+	irLookup:       "lookup",
+	irCall:         "call",
+	irJump:         "jump",
+	irBranch:       "branch",
+	irBranchLess:   "branchless",
+	irBranchLessEq: "branchlesseq",
+	irSwitch:       "switch",
+	irReturn:       "return",
+}
+
+func (op irOp) String() string {
+	return irOpNames[op]
+}
+
+// irInstr is an instruction or a terminator.
+type irInstr struct {
+	Op   irOp
+	Dst  *irTemp
+	Args []irOperand
+
+	Attr     *Attribute
+	Class    *Class
+	Method   *Method
+	N        int
+	Borrowed bool
+	// Call is the call an irPush is an argument of.
+	Call *irInstr
+	// Keep is the Ints built in the stack frame for the arguments of an
+	// irCall.
+	Keep []*irTemp
+
+	Targets []*irBlock
+	Cases   []irCase
+}
+
+// irCase is a range of class tags that an irSwitch goes to Target for.
+type irCase struct {
+	Order, MaxOrder int
+	Target          *irBlock
+}
+
+func (in *irInstr) String() string {
+	var buf strings.Builder
+	if in.Dst != nil {
+		buf.WriteString(in.Dst.String())
+		buf.WriteString(" = ")
+	}
+	buf.WriteString(in.Op.String())
+	for i, a := range in.Args {
+		if i != 0 {
+			buf.WriteString(",")
+		}
+		buf.WriteString(" ")
+		buf.WriteString(a.String())
+	}
+	if in.Attr != nil {
+		buf.WriteString(" ." + in.Attr.Name.Name)
+	}
+	if in.Class != nil {
+		buf.WriteString(" " + in.Class.Type.Name)
+	}
+	if in.Method != nil {
+		buf.WriteString(" " + in.Method.Parent.Type.Name + "." + in.Method.Name.Name)
+	}
+	for _, t := range in.Targets {
+		buf.WriteString(" " + t.label)
+	}
+	for _, c := range in.Cases {
+		buf.WriteString(" " + strconv.Itoa(c.Order) + "-" + strconv.Itoa(c.MaxOrder) + ":" + c.Target.label)
+	}
+	return buf.String()
+}
+
+// irTempOf returns the temporary that is operand a, or nil if a is a constant.
+func irTempOf(a irOperand) *irTemp {
+	t, _ := a.(*irTemp)
+	return t
+}
+
+// Uses returns the temporaries in in that are read by it, including the
+// Ints kept for a call.
+func (in *irInstr) Uses() []*irTemp {
+	var uses []*irTemp
+	for _, a := range in.Args {
+		if t := irTempOf(a); t != nil {
+			uses = append(uses, t)
+		}
+	}
+	return append(uses, in.Keep...)
+}
+
+// Pure returns true if removing the instruction only changes the value of
+// Dst.
+func (in *irInstr) Pure() bool {
+	switch in.Op {
+	case irMove, irAdd, irSub, irMul, irNeg, irBox, irUnbox, irLoad, irTag, irAlloc, irLess, irLessEq, irNot:
+		return true
+	}
+	return false
+}
+
+// Owned returns true if Dst holds a new counted reference after the
+// instruction, instead of whatever reference its operand held.
+func (in *irInstr) Owned() bool {
+	switch in.Op {
+	case irBox, irAlloc, irCall:
+		return true
+	}
+	return false
+}
+
+// Calls returns true if the instruction calls a method or the allocator,
+// which don't preserve any registers.
+func (in *irInstr) Calls() bool {
+	switch in.Op {
+	case irBox, irAlloc, irCall:
+		return true
+	}
+	return false
+}
+
+// Successors returns the blocks a terminator can go to.
+func (in *irInstr) Successors() []*irBlock {
+	succ := append([]*irBlock(nil), in.Targets...)
+	for _, c := range in.Cases {
+		succ = append(succ, c.Target)
+	}
+	return succ
+}
+
+func (fn *irFunc) String() string {
+	var buf strings.Builder
+	buf.WriteString(fn.Name + ":\n")
+	for _, b := range fn.Blocks {
+		buf.WriteString(b.label + ":\n")
+		for _, in := range b.Instrs {
+			buf.WriteString("\t" + in.String() + "\n")
+		}
+		buf.WriteString("\t" + b.Term.String() + "\n")
+	}
+	return buf.String()
+}
+
+// irCtx is the state of lowering a method body to its intermediate
+// representation.
+type irCtx struct {
+	fn    *irFunc
+	block *irBlock
+	depth int
+
+	// this is the receiver, and vars is the temporary for each argument,
+	// variable, and match expression in scope.
+	this *irTemp
+	vars map[Object]*irTemp
+	// scalars is the objects whose attributes are kept in temporaries,
+	// by their allocation and by the variables that hold them.
+	scalars    map[*AllocExpr]*escScalar
+	scalarVars map[*VarExpr]*escScalar
+	// pushed is the arguments pushed for calls that haven't been made.
+	pushed []*irInstr
+
+	// borrowed is Program.borrowed, and dispatch is Options.OptDispatch.
+	borrowed map[*Method][]bool
+	dispatch bool
+}
+
+// irLower builds the intermediate representation of a method. args is the
+// number of arguments, or -1 for main.
+func irLower(ctx *genCtx, name string, args int, formals []*Formal, body Expr) *irFunc {
+	ir := &irCtx{
+		fn: &irFunc{
+			Name: name,
+			Args: args,
+		},
+		vars:     make(map[Object]*irTemp),
+		borrowed: ctx.borrowed,
+		dispatch: ctx.opt.OptDispatch,
+	}
+	ir.block = ir.Block()
+
+	if args >= 0 {
+		ir.this = ir.Temp()
+		ir.this.Param = args*4 + 8
+		ir.fn.Params = append(ir.fn.Params, ir.this)
+		for i, a := range formals {
+			t := ir.Temp()
+			t.Param = (args-i)*4 + 4
+			ir.vars[a] = t
+			ir.fn.Params = append(ir.fn.Params, t)
+		}
+	}
+
+	// the attributes of objects kept in temporaries last for the whole
+	// method, so they start out null.
+	scalars := ir.Scalars(body)
+	for _, s := range scalars {
+		for _, a := range s.attributes {
+			t := ir.Temp()
+			t.Int = a.Type.Name == "Int"
+			s.temps[a] = t
+			ir.Emit(&irInstr{Op: irMove, Dst: t, Args: []irOperand{irNull}})
+		}
+	}
+
+	result := body.lower(ir)
+
+	for _, t := range ir.fn.Params {
+		ir.Emit(&irInstr{Op: irUnref, Args: []irOperand{t}})
+	}
+	for _, s := range scalars {
+		for _, a := range s.attributes {
+			ir.Emit(&irInstr{Op: irUnref, Args: []irOperand{s.temps[a]}})
+		}
+	}
+	ir.End(&irInstr{Op: irReturn, Args: []irOperand{result}})
+
+	return ir.fn
+}
+
+// Scalars finds the objects in body whose attributes can be kept in
+// temporaries, in the order they are allocated.
+func (ctx *irCtx) Scalars(body Expr) []*escScalar {
+	ctx.scalars = make(map[*AllocExpr]*escScalar)
+	ctx.scalarVars = make(map[*VarExpr]*escScalar)
+	if ctx.borrowed == nil {
+		return nil
+	}
+
+	esc := &escCtx{borrowed: ctx.borrowed}
+	held := esc.Body(body)
+
+	var scalars []*escScalar
+	for _, alloc := range esc.order {
+		if held[alloc] == nil {
+			continue
+		}
+		attributes, _ := escAttributes(alloc.Type.Class)
+		s := &escScalar{
+			attributes: attributes,
+			temps:      make(map[*Attribute]*irTemp),
+		}
+		ctx.scalars[alloc] = s
+		for _, v := range held[alloc] {
+			ctx.scalarVars[v] = s
+		}
+		scalars = append(scalars, s)
+	}
+	return scalars
+}
+
+// Temp creates a temporary.
+func (ctx *irCtx) Temp() *irTemp {
+	t := &irTemp{ID: len(ctx.fn.Temps), Words: 1}
+	ctx.fn.Temps = append(ctx.fn.Temps, t)
+	return t
+}
+
+// Block creates a block at the current loop depth. It is laid out after
+// every block created before it.
+func (ctx *irCtx) Block() *irBlock {
+	b := &irBlock{Depth: ctx.depth}
+	ctx.fn.Blocks = append(ctx.fn.Blocks, b)
+	return b
+}
+
+// Emit adds an instruction to the current block.
+func (ctx *irCtx) Emit(in *irInstr) {
+	ctx.block.Instrs = append(ctx.block.Instrs, in)
+}
+
+// Op adds an instruction that stores to a new temporary and returns it.
+func (ctx *irCtx) Op(op irOp, args ...irOperand) *irTemp {
+	t := ctx.Temp()
+	switch op {
+	case irAdd, irSub, irMul, irDiv, irNeg, irUnbox, irTag:
+		t.Raw = true
+	}
+	ctx.Emit(&irInstr{Op: op, Dst: t, Args: args})
+	return t
+}
+
+// End ends the current block with a terminator.
+func (ctx *irCtx) End(term *irInstr) {
+	ctx.block.Term = term
+}
+
+// Jump ends the current block with a jump to b and continues in b.
+func (ctx *irCtx) Jump(b *irBlock) {
+	ctx.End(&irInstr{Op: irJump, Targets: []*irBlock{b}})
+	ctx.block = b
+}
+
+// Ref returns a counted reference to the object in t.
+func (ctx *irCtx) Ref(t *irTemp) irOperand {
+	c := ctx.Op(irMove, t)
+	ctx.Emit(&irInstr{Op: irRef, Args: []irOperand{c}})
+	return c
+}
+
+// Unref removes the reference held by a, if it holds one.
+func (ctx *irCtx) Unref(a irOperand) {
+	if irTempOf(a) != nil {
+		ctx.Emit(&irInstr{Op: irUnref, Args: []irOperand{a}})
+	}
+}
+
+// Unbox returns the unboxed integer of the Int in a, removing the reference
+// held by a.
+func (ctx *irCtx) Unbox(a irOperand) irOperand {
+	ctx.Unref(a)
+	return ctx.Op(irUnbox, a)
+}
+
+// Assign moves the reference in a to t, removing the reference t held
+// before.
+func (ctx *irCtx) Assign(t *irTemp, a irOperand) {
+	ctx.Unref(t)
+	ctx.Emit(&irInstr{Op: irMove, Dst: t, Args: []irOperand{a}})
+}
+
+// Push pushes a as an argument of the next call. borrowed is true if the
+// method only reads it.
+func (ctx *irCtx) Push(a irOperand, borrowed bool) {
+	in := &irInstr{Op: irPush, Args: []irOperand{a}, Borrowed: borrowed}
+	ctx.Emit(in)
+	ctx.pushed = append(ctx.pushed, in)
+}
+
+// Borrowed returns true if argument i of a call to m, where the receiver is
+// argument 0, is only read by the method.
+func (ctx *irCtx) Borrowed(m *Method, hasOverride bool, i int) bool {
+	b := ctx.borrowed[m]
+	return b != nil && !hasOverride && b[i]
+}
+
+// Call calls m with the receiver and n arguments that were pushed last,
+// and returns the result. If dynamic is true, the method is found in the
+// method table of the receiver.
+func (ctx *irCtx) Call(m *Method, n int, dynamic bool) irOperand {
+	call := &irInstr{Op: irCall, Method: m, N: n}
+	if dynamic {
+		f := ctx.Temp()
+		f.Raw = true
+		ctx.Emit(&irInstr{Op: irLookup, Dst: f, Method: m, N: n})
+		call.Args = []irOperand{f}
+	}
+	call.Dst = ctx.Temp()
+	for _, in := range ctx.pushed[len(ctx.pushed)-n-1:] {
+		in.Call = call
+	}
+	ctx.pushed = ctx.pushed[:len(ctx.pushed)-n-1]
+	ctx.Emit(call)
+	return call.Dst
+}
+
+// Read returns a counted reference to the value of o.
+func (ctx *irCtx) Read(o Object) irOperand {
+	if t := ctx.Field(o); t != nil {
+		return ctx.Ref(t)
+	}
+	if o.Stack() {
+		return ctx.Ref(ctx.vars[o])
+	}
+	t := ctx.Temp()
+	ctx.Emit(&irInstr{Op: irLoad, Dst: t, Args: []irOperand{ctx.Base(o)}, Attr: ctx.Attribute(o)})
+	ctx.Emit(&irInstr{Op: irRef, Args: []irOperand{t}})
+	return t
+}
+
+// Field returns the temporary that holds o if it is an attribute of an
+// object whose attributes are kept in temporaries.
+func (ctx *irCtx) Field(o Object) *irTemp {
+	if a, ok := o.(*AttributeObject); ok {
+		if v, ok := a.Object.(*VarExpr); ok {
+			if s := ctx.scalarVars[v]; s != nil {
+				return s.temps[a.Attribute]
+			}
+		}
+	}
+	return nil
+}
+
+// Base returns the temporary that holds the object o is an attribute of.
+func (ctx *irCtx) Base(o Object) *irTemp {
+	if a, ok := o.(*AttributeObject); ok {
+		return ctx.vars[a.Object]
+	}
+	return ctx.this
+}
+
+// Attribute returns the attribute o refers to.
+func (ctx *irCtx) Attribute(o Object) *Attribute {
+	if a, ok := o.(*AttributeObject); ok {
+		return a.Attribute
+	}
+	return o.(*Attribute)
+}
+
+func (e *NotExpr) lower(ctx *irCtx) irOperand {
+	return ctx.Op(irNot, e.Expr.lower(ctx))
+}
+
+func (e *NegativeExpr) lower(ctx *irCtx) irOperand {
+	return ctx.Op(irBox, ctx.Op(irNeg, ctx.Unbox(e.Expr.lower(ctx))))
+}
+
+func (e *IfExpr) lower(ctx *irCtx) irOperand {
+	cond := e.Cond.lower(ctx)
+	branch := &irInstr{Op: irBranch, Args: []irOperand{cond}, Targets: []*irBlock{nil, ctx.Block()}}
+	ctx.End(branch)
+
+	j := ctx.Temp()
+	ctx.block = branch.Targets[1]
+	ctx.Emit(&irInstr{Op: irMove, Dst: j, Args: []irOperand{e.Then.lower(ctx)}})
+	then := ctx.block
+
+	branch.Targets[0] = ctx.Block()
+	ctx.block = branch.Targets[0]
+	ctx.Emit(&irInstr{Op: irMove, Dst: j, Args: []irOperand{e.Else.lower(ctx)}})
+
+	done := ctx.Block()
+	then.Term = &irInstr{Op: irJump, Targets: []*irBlock{done}}
+	ctx.Jump(done)
+	return j
+}
+
+func (e *WhileExpr) lower(ctx *irCtx) irOperand {
+	ctx.depth++
+	cond := ctx.Block()
+	ctx.Jump(cond)
+	branch := &irInstr{Op: irBranch, Args: []irOperand{e.Cond.lower(ctx)}, Targets: []*irBlock{nil, ctx.Block()}}
+	ctx.End(branch)
+	ctx.block = branch.Targets[1]
+	ctx.Unref(e.Body.lower(ctx))
+	ctx.End(&irInstr{Op: irJump, Targets: []*irBlock{cond}})
+	ctx.depth--
+
+	branch.Targets[0] = ctx.Block()
+	ctx.block = branch.Targets[0]
+	return irUnit
+}
+
+// lowerCompare lowers a comparison of two Ints.
+func lowerCompare(ctx *irCtx, op irOp, left, right Expr) irOperand {
+	l := ctx.Unbox(left.lower(ctx))
+	r := ctx.Unbox(right.lower(ctx))
+	return ctx.Op(op, l, r)
+}
+
+func (e *LessOrEqualExpr) lower(ctx *irCtx) irOperand {
+	return lowerCompare(ctx, irLessEq, e.Left, e.Right)
+}
+
+func (e *LessThanExpr) lower(ctx *irCtx) irOperand {
+	return lowerCompare(ctx, irLess, e.Left, e.Right)
+}
+
+// lowerArithmetic lowers an arithmetic operation on two Ints.
+func lowerArithmetic(ctx *irCtx, op irOp, left, right Expr) irOperand {
+	l := ctx.Unbox(left.lower(ctx))
+	r := ctx.Unbox(right.lower(ctx))
+	return ctx.Op(irBox, ctx.Op(op, l, r))
+}
+
+func (e *MultiplyExpr) lower(ctx *irCtx) irOperand {
+	return lowerArithmetic(ctx, irMul, e.Left, e.Right)
+}
+
+func (e *DivideExpr) lower(ctx *irCtx) irOperand {
+	return lowerArithmetic(ctx, irDiv, e.Left, e.Right)
+}
+
+func (e *AddExpr) lower(ctx *irCtx) irOperand {
+	return lowerArithmetic(ctx, irAdd, e.Left, e.Right)
+}
+
+func (e *SubtractExpr) lower(ctx *irCtx) irOperand {
+	return lowerArithmetic(ctx, irSub, e.Left, e.Right)
+}
+
+func (e *MatchExpr) lower(ctx *irCtx) irOperand {
+	left := ctx.Temp()
+	ctx.Emit(&irInstr{Op: irMove, Dst: left, Args: []irOperand{e.Left.lower(ctx)}})
+	ctx.vars[e] = left
+
+	sw := &irInstr{Op: irSwitch, Args: []irOperand{ctx.Op(irTag, left)}}
+	ctx.End(sw)
+
+	j := ctx.Temp()
+	var ends []*irBlock
+	for _, c := range e.Cases {
+		ctx.block = ctx.Block()
+		sw.Cases = append(sw.Cases, irCase{
+			Order:    c.Type.Class.Order,
+			MaxOrder: c.Type.Class.MaxOrder,
+			Target:   ctx.block,
+		})
+		v := c.Body.lower(ctx)
+		ctx.Emit(&irInstr{Op: irMove, Dst: j, Args: []irOperand{v}})
+		ends = append(ends, ctx.block)
+	}
+
+	done := ctx.Block()
+	for _, b := range ends {
+		b.Term = &irInstr{Op: irJump, Targets: []*irBlock{done}}
+	}
+	ctx.block = done
+	ctx.Unref(left)
+	return j
+}
+
+func (e *DynamicCallExpr) lower(ctx *irCtx) irOperand {
+	m := e.Name.Method
+	recv := e.Recv.lower(ctx)
+	if !e.RecvNotNull {
+		ctx.Emit(&irInstr{Op: irNullCheck, Args: []irOperand{recv}})
+	}
+	ctx.Push(recv, ctx.Borrowed(m, e.HasOverride, 0))
+	for i, a := range e.Args {
+		ctx.Push(a.lower(ctx), ctx.Borrowed(m, e.HasOverride, 1+i))
+	}
+	return ctx.Call(m, len(e.Args), e.HasOverride || !ctx.dispatch)
+}
+
+func (e *SuperCallExpr) lower(ctx *irCtx) irOperand {
+	ctx.Push(ctx.Ref(ctx.this), false)
+	for _, a := range e.Args {
+		ctx.Push(a.lower(ctx), false)
+	}
+	return ctx.Call(e.Name.Method, len(e.Args), false)
+}
+
+func (e *StaticCallExpr) lower(ctx *irCtx) irOperand {
+	ctx.Push(e.Recv.lower(ctx), false)
+	for _, a := range e.Args {
+		ctx.Push(a.lower(ctx), false)
+	}
+	return ctx.Call(e.Name.Method, len(e.Args), false)
+}
+
+// irDefault returns the value an attribute of type t starts with, or nil if
+// it starts out null.
+func irDefault(t *Ident) irOperand {
+	switch t.Name {
+	case "Int":
+		return irIntZero
+	case "Boolean":
+		return irFalse
+	case "Unit":
+		return irUnit
+	}
+	return nil
+}
+
+func (e *AllocExpr) lower(ctx *irCtx) irOperand {
+	if s := ctx.scalars[e]; s != nil {
+		// the variables that hold the object are never dereferenced,
+		// so they hold null.
+		for _, a := range s.attributes {
+			v := irDefault(a.Type)
+			if v == nil {
+				v = irNull
+			}
+			ctx.Assign(s.temps[a], v)
+		}
+		return irNull
+	}
+
+	t := ctx.Temp()
+	ctx.Emit(&irInstr{Op: irAlloc, Dst: t, Class: e.Type.Class})
+	var init func(c *Class)
+	init = func(c *Class) {
+		if c == nativeClass {
+			return
+		}
+		init(c.Extends.Type.Class)
+		for _, f := range c.Features {
+			if a, ok := f.(*Attribute); ok {
+				if v := irDefault(a.Type); v != nil {
+					ctx.Emit(&irInstr{Op: irStore, Args: []irOperand{t, v}, Attr: a})
+				}
+			}
+		}
+	}
+	init(e.Type.Class)
+	return t
+}
+
+func (e *AssignExpr) lower(ctx *irCtx) irOperand {
+	obj := e.Name.Object
+	v := e.Expr.lower(ctx)
+	if t := ctx.Field(obj); t != nil {
+		ctx.Assign(t, v)
+	} else if obj.Stack() {
+		ctx.Assign(ctx.vars[obj], v)
+	} else {
+		ctx.Unref(v)
+		ctx.Emit(&irInstr{Op: irStore, Args: []irOperand{ctx.Base(obj), v}, Attr: ctx.Attribute(obj)})
+	}
+	return irUnit
+}
+
+// lowerAlias returns the temporary that holds the value of e if e is the
+// `this` of an inlined call on `this` or on the `this` of another inlined
+// call. Those can't be assigned to, so e can share their temporary instead
+// of having one of its own.
+func (e *VarExpr) lowerAlias(ctx *irCtx) *irTemp {
+	if e.Inlined == nil || e.NullCheck || e.RawInt() {
+		return nil
+	}
+
+	switch init := e.Init.(type) {
+	case *ThisExpr:
+		return ctx.this
+	case *NameExpr:
+		if v, ok := init.Name.Object.(*VarExpr); ok && v.Inlined != nil {
+			return ctx.vars[v]
+		}
+	}
+
+	return nil
+}
+
+func (e *VarExpr) lower(ctx *irCtx) irOperand {
+	if t := e.lowerAlias(ctx); t != nil {
+		ctx.vars[e] = t
+		return e.Body.lower(ctx)
+	}
+
+	v := e.Init.lower(ctx)
+	if e.NullCheck && ctx.scalarVars[e] == nil {
+		ctx.Emit(&irInstr{Op: irNullCheck, Args: []irOperand{v}})
+	}
+	t := ctx.Temp()
+	t.Int = e.RawInt()
+	ctx.Emit(&irInstr{Op: irMove, Dst: t, Args: []irOperand{v}})
+	ctx.vars[e] = t
+
+	result := e.Body.lower(ctx)
+	ctx.Unref(t)
+	return result
+}
+
+func (e *ChainExpr) lower(ctx *irCtx) irOperand {
+	ctx.Unref(e.Pre.lower(ctx))
+	return e.Expr.lower(ctx)
+}
+
+func (e *ThisExpr) lower(ctx *irCtx) irOperand {
+	return ctx.Ref(ctx.this)
+}
+
+func (e *NullExpr) lower(ctx *irCtx) irOperand {
+	return irNull
+}
+
+func (e *UnitExpr) lower(ctx *irCtx) irOperand {
+	return irUnit
+}
+
+func (e *NameExpr) lower(ctx *irCtx) irOperand {
+	return ctx.Read(e.Name.Object)
+}
+
+func (e *StringExpr) lower(ctx *irCtx) irOperand {
+	return irConst{Sym: "string_lit_" + strconv.Itoa(e.Lit.LitID)}
+}
+
+func (e *BoolExpr) lower(ctx *irCtx) irOperand {
+	if e.Lit.Bool {
+		return irTrue
+	}
+	return irFalse
+}
+
+func (e *IntExpr) lower(ctx *irCtx) irOperand {
+	return irConst{Sym: "int_lit_" + strconv.Itoa(e.Lit.LitID), Int: e.Lit.Int}
+}
+
+func (e *NativeExpr) lower(ctx *irCtx) irOperand {
+	panic("NativeExpr.lower should never be called")
+}
+
+func (e *BadExpr) lower(ctx *irCtx) irOperand {
+	panic("BadExpr.lower should never be called")
+}
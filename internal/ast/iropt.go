@@ -0,0 +1,579 @@
+package ast
+
+// irRefs is where each temporary of a function is stored to and read.
+type irRefs struct {
+	defs map[*irTemp][]*irInstr
+	uses map[*irTemp][]*irInstr
+	// block and index are where each instruction is. A terminator's
+	// index is the number of instructions in its block.
+	block map[*irInstr]*irBlock
+	index map[*irInstr]int
+}
+
+func irScan(fn *irFunc) *irRefs {
+	refs := &irRefs{
+		defs:  make(map[*irTemp][]*irInstr),
+		uses:  make(map[*irTemp][]*irInstr),
+		block: make(map[*irInstr]*irBlock),
+		index: make(map[*irInstr]int),
+	}
+	add := func(b *irBlock, i int, in *irInstr) {
+		refs.block[in] = b
+		refs.index[in] = i
+		if in.Dst != nil {
+			refs.defs[in.Dst] = append(refs.defs[in.Dst], in)
+		}
+		for _, t := range in.Uses() {
+			refs.uses[t] = append(refs.uses[t], in)
+		}
+	}
+	for _, b := range fn.Blocks {
+		for i, in := range b.Instrs {
+			add(b, i, in)
+		}
+		add(b, len(b.Instrs), b.Term)
+	}
+	return refs
+}
+
+// irRewrite replaces each instruction in fn with the instructions f returns
+// for it.
+func irRewrite(fn *irFunc, f func(in *irInstr) []*irInstr) {
+	for _, b := range fn.Blocks {
+		instrs := make([]*irInstr, 0, len(b.Instrs))
+		for _, in := range b.Instrs {
+			instrs = append(instrs, f(in)...)
+		}
+		b.Instrs = instrs
+	}
+}
+
+// irRemove removes the instructions in remove from fn.
+func irRemove(fn *irFunc, remove map[*irInstr]bool) {
+	irRewrite(fn, func(in *irInstr) []*irInstr {
+		if remove[in] {
+			return nil
+		}
+		return []*irInstr{in}
+	})
+}
+
+// irOptInt keeps the values of Int variables unboxed and removes boxing that
+// is immediately undone. If stack is true, it also builds the Ints passed to
+// methods that only read them in the stack frame.
+func irOptInt(fn *irFunc, stack bool) {
+	irUnboxVars(fn)
+	for irFoldBoxes(fn) || irCopies(fn) {
+	}
+	if stack {
+		irStackInts(fn)
+	}
+}
+
+// irUnboxVars makes the Int variables that are only read and assigned hold
+// unboxed integers. Reading one boxes its value again.
+func irUnboxVars(fn *irFunc) {
+	refs := irScan(fn)
+	unboxed := make(map[*irTemp]bool)
+	for _, t := range fn.Temps {
+		if !t.Int || t.Param != 0 {
+			continue
+		}
+		ok := true
+		for _, in := range refs.uses[t] {
+			if in.Op != irMove && in.Op != irRef && in.Op != irUnref {
+				ok = false
+			}
+		}
+		for _, in := range refs.defs[t] {
+			if in.Op != irMove {
+				ok = false
+			}
+		}
+		if ok {
+			unboxed[t] = true
+			t.Raw = true
+		}
+	}
+
+	// a read of the variable is boxed, which counts the reference.
+	boxed := make(map[*irTemp]bool)
+	irRewrite(fn, func(in *irInstr) []*irInstr {
+		switch {
+		case (in.Op == irRef || in.Op == irUnref) && unboxed[irTempOf(in.Args[0])]:
+			return nil
+		case in.Op == irRef && boxed[irTempOf(in.Args[0])]:
+			return nil
+		case in.Op != irMove || unboxed[in.Dst] == unboxed[irTempOf(in.Args[0])]:
+		case unboxed[irTempOf(in.Args[0])]:
+			in.Op = irBox
+			boxed[in.Dst] = true
+		default:
+			if c, ok := in.Args[0].(irConst); ok {
+				in.Args = []irOperand{irConst{Int: c.Int}}
+				break
+			}
+			unref := &irInstr{Op: irUnref, Args: in.Args}
+			in.Op = irUnbox
+			return []*irInstr{unref, in}
+		}
+		return []*irInstr{in}
+	})
+}
+
+// irFoldBoxes removes an Int that is only unboxed again before the integer
+// in it can change, unboxes Int literals, and replaces the temporaries that
+// are only set to an integer with the integer. It returns true if it
+// changed anything.
+func irFoldBoxes(fn *irFunc) bool {
+	refs := irScan(fn)
+	changed := false
+	remove := make(map[*irInstr]bool)
+
+	for _, b := range fn.Blocks {
+		for i, box := range b.Instrs {
+			if box.Op != irBox {
+				continue
+			}
+			uses := refs.uses[box.Dst]
+			last := i
+			ok := len(uses) != 0
+			for _, in := range uses {
+				if (in.Op != irUnbox && in.Op != irUnref) || refs.block[in] != b {
+					ok = false
+					break
+				}
+				if j := refs.index[in]; j > last {
+					last = j
+				}
+			}
+			if r := irTempOf(box.Args[0]); ok && r != nil {
+				for _, in := range b.Instrs[i+1 : last] {
+					if in.Dst == r {
+						ok = false
+					}
+				}
+			}
+			if !ok {
+				continue
+			}
+			for _, in := range uses {
+				if in.Op == irUnbox {
+					in.Op = irMove
+					in.Args = box.Args
+				} else {
+					remove[in] = true
+				}
+			}
+			remove[box] = true
+			changed = true
+		}
+	}
+	irRemove(fn, remove)
+
+	for _, b := range fn.Blocks {
+		for _, in := range b.Instrs {
+			if c, ok := in.Args0().(irConst); ok && in.Op == irUnbox && c.IsInt() {
+				in.Op = irMove
+				in.Args = []irOperand{irConst{Int: c.Int}}
+				changed = true
+			}
+		}
+	}
+
+	consts := make(map[*irTemp]irConst)
+	for _, t := range fn.Temps {
+		if defs := refs.defs[t]; t.Raw && len(defs) == 1 && defs[0].Op == irMove && !remove[defs[0]] {
+			if c, ok := defs[0].Args[0].(irConst); ok {
+				consts[t] = c
+				remove[defs[0]] = true
+			}
+		}
+	}
+	if len(consts) == 0 {
+		return changed
+	}
+	irRemove(fn, remove)
+	for _, b := range fn.Blocks {
+		for _, in := range b.Instrs {
+			irReplaceConsts(in, consts)
+		}
+		irReplaceConsts(b.Term, consts)
+	}
+	return true
+}
+
+// irCopies replaces the unboxed integers that are copies of another
+// temporary with that temporary, if they are only used in the same block
+// before the other temporary changes. It returns true if it changed
+// anything.
+func irCopies(fn *irFunc) bool {
+	refs := irScan(fn)
+	remove := make(map[*irInstr]bool)
+	changed := make(map[*irTemp]bool)
+
+	for _, b := range fn.Blocks {
+		for i, mv := range b.Instrs {
+			t, src := mv.Dst, irTempOf(mv.Args0())
+			if mv.Op != irMove || src == nil || !t.Raw || len(refs.defs[t]) != 1 || changed[t] || changed[src] {
+				continue
+			}
+			uses := refs.uses[t]
+			last := i
+			ok := len(uses) != 0
+			for _, in := range uses {
+				if refs.block[in] != b || refs.index[in] < i {
+					ok = false
+					break
+				}
+				if j := refs.index[in]; j > last {
+					last = j
+				}
+			}
+			if !ok {
+				continue
+			}
+			for _, in := range b.Instrs[i+1 : last] {
+				if in.Dst == src {
+					ok = false
+				}
+			}
+			if !ok {
+				continue
+			}
+			for _, in := range uses {
+				for j, a := range in.Args {
+					if a == irOperand(t) {
+						in.Args[j] = src
+					}
+				}
+			}
+			remove[mv] = true
+			changed[t], changed[src] = true, true
+		}
+	}
+	irRemove(fn, remove)
+	return len(remove) != 0
+}
+
+func irReplaceConsts(in *irInstr, consts map[*irTemp]irConst) {
+	for i, a := range in.Args {
+		if c, ok := consts[irTempOf(a)]; ok {
+			in.Args[i] = c
+		}
+	}
+}
+
+// Args0 returns the first operand of in, or nil if it has none.
+func (in *irInstr) Args0() irOperand {
+	if len(in.Args) == 0 {
+		return nil
+	}
+	return in.Args[0]
+}
+
+// irStackInts builds each Int that is only passed to a method that reads it
+// in the stack frame of the caller instead of allocating it.
+func irStackInts(fn *irFunc) {
+	refs := irScan(fn)
+	remove := make(map[*irInstr]bool)
+	for _, b := range fn.Blocks {
+		for _, push := range b.Instrs {
+			t := irTempOf(push.Args0())
+			if push.Op != irPush || !push.Borrowed || t == nil {
+				continue
+			}
+			defs := refs.defs[t]
+			if len(defs) != 1 || defs[0].Op != irBox {
+				continue
+			}
+			ok := true
+			for _, in := range refs.uses[t] {
+				if in != push && in.Op != irNullCheck {
+					ok = false
+				}
+			}
+			if !ok {
+				continue
+			}
+
+			// an Int is never null.
+			for _, in := range refs.uses[t] {
+				if in != push {
+					remove[in] = true
+				}
+			}
+			defs[0].Op = irStackBox
+			t.Words = 4
+			push.Call.Keep = append(push.Call.Keep, t)
+		}
+	}
+	irRemove(fn, remove)
+}
+
+// irOptJump branches on comparisons and negations directly instead of on the
+// Boolean they return, and makes the blocks that only branch on a Boolean set
+// by the blocks before them branch where it leads instead.
+func irOptJump(fn *irFunc) {
+	for changed := true; changed; {
+		changed = false
+		refs := irScan(fn)
+		for _, b := range fn.Blocks {
+			if irFoldBranch(b, refs) {
+				changed = true
+				refs = irScan(fn)
+			}
+		}
+		for _, b := range fn.Blocks[1:] {
+			if irThread(fn, b, refs) {
+				changed = true
+				refs = irScan(fn)
+			}
+		}
+		if irRemoveUnreachable(fn) {
+			changed = true
+		}
+	}
+}
+
+// irFoldBranch changes a branch on a Boolean that is a constant, the result
+// of a comparison, or the negation of another Boolean to not need the
+// Boolean. It returns true if it changed anything.
+func irFoldBranch(b *irBlock, refs *irRefs) bool {
+	term := b.Term
+	if term.Op != irBranch {
+		return false
+	}
+	switch term.Args[0] {
+	case irFalse:
+		b.Term = &irInstr{Op: irJump, Targets: term.Targets[:1]}
+		return true
+	case irTrue:
+		b.Term = &irInstr{Op: irJump, Targets: term.Targets[1:]}
+		return true
+	}
+
+	t := irTempOf(term.Args[0])
+	if t == nil || len(b.Instrs) == 0 || len(refs.uses[t]) != 1 || len(refs.defs[t]) != 1 {
+		return false
+	}
+	def := b.Instrs[len(b.Instrs)-1]
+	if def.Dst != t {
+		return false
+	}
+	switch def.Op {
+	case irLess:
+		b.Term = &irInstr{Op: irBranchLess, Args: def.Args, Targets: term.Targets}
+	case irLessEq:
+		b.Term = &irInstr{Op: irBranchLessEq, Args: def.Args, Targets: term.Targets}
+	case irNot:
+		b.Term = &irInstr{Op: irBranch, Args: def.Args, Targets: []*irBlock{term.Targets[1], term.Targets[0]}}
+	default:
+		return false
+	}
+	b.Instrs = b.Instrs[:len(b.Instrs)-1]
+	return true
+}
+
+// irThread changes the blocks that jump to b to do what b does instead, if b
+// only branches on a Boolean they set or only jumps somewhere else. It
+// returns true if it changed anything.
+func irThread(fn *irFunc, b *irBlock, refs *irRefs) bool {
+	var preds []*irBlock
+	for _, p := range fn.Blocks {
+		for _, s := range p.Term.Successors() {
+			if s == b {
+				if p.Term.Op != irJump {
+					return false
+				}
+				preds = append(preds, p)
+			}
+		}
+	}
+	if len(preds) == 0 {
+		return false
+	}
+
+	if b.Term.Op == irJump && len(b.Instrs) == 0 && b.Term.Targets[0] != b {
+		for _, p := range preds {
+			term := *b.Term
+			p.Term = &term
+		}
+		return true
+	}
+
+	t := irTempOf(b.Term.Args0())
+	if b.Term.Op != irBranch || t == nil || len(refs.uses[t]) != 1 {
+		return false
+	}
+	// the instructions in b are copied to each block before it, so
+	// only do it for the reference count changes at the end of a match.
+	for _, in := range b.Instrs {
+		if (in.Op != irRef && in.Op != irUnref) || in.Args[0] == t {
+			return false
+		}
+	}
+	defs := refs.defs[t]
+	if len(defs) != len(preds) {
+		return false
+	}
+	for i, p := range preds {
+		if len(p.Instrs) == 0 || p.Instrs[len(p.Instrs)-1] != defs[i] || defs[i].Op != irMove {
+			return false
+		}
+	}
+
+	for _, p := range preds {
+		def := p.Instrs[len(p.Instrs)-1]
+		p.Instrs = p.Instrs[:len(p.Instrs)-1]
+		for _, in := range b.Instrs {
+			copied := *in
+			p.Instrs = append(p.Instrs, &copied)
+		}
+		p.Term = &irInstr{Op: irBranch, Args: def.Args, Targets: b.Term.Targets}
+	}
+	return true
+}
+
+// irRemoveUnreachable removes the blocks that can't be reached from the
+// entry. It returns true if it removed any.
+func irRemoveUnreachable(fn *irFunc) bool {
+	reached := map[*irBlock]bool{fn.Blocks[0]: true}
+	queue := []*irBlock{fn.Blocks[0]}
+	for len(queue) != 0 {
+		b := queue[0]
+		queue = queue[1:]
+		for _, s := range b.Term.Successors() {
+			if !reached[s] {
+				reached[s] = true
+				queue = append(queue, s)
+			}
+		}
+	}
+
+	blocks := fn.Blocks[:0]
+	for _, b := range fn.Blocks {
+		if reached[b] {
+			blocks = append(blocks, b)
+		}
+	}
+	removed := len(blocks) != len(fn.Blocks)
+	fn.Blocks = blocks
+	return removed
+}
+
+// irCancelRefs removes each reference that is counted and then removed
+// again before anything could free the object, which is what reading a
+// variable only to unbox it or compare it does.
+func irCancelRefs(fn *irFunc) {
+	remove := make(map[*irInstr]bool)
+	for _, b := range fn.Blocks {
+		for i, in := range b.Instrs {
+			if in.Op != irUnref {
+				continue
+			}
+			t := irTempOf(in.Args[0])
+			for j := i - 1; j >= 0; j-- {
+				prev := b.Instrs[j]
+				if prev.Op == irRef && irTempOf(prev.Args[0]) == t && !remove[prev] {
+					remove[prev], remove[in] = true, true
+					break
+				}
+				if (prev.Op == irUnref && !remove[prev]) || prev.Calls() || prev.Dst == t {
+					break
+				}
+			}
+		}
+	}
+	irRemove(fn, remove)
+}
+
+// irOptUnused removes the values that are never used, along with the
+// reference count changes for them.
+func irOptUnused(fn *irFunc) {
+	for changed := true; changed; {
+		changed = false
+		refs := irScan(fn)
+		remove := make(map[*irInstr]bool)
+		replace := make(map[*irInstr]*irInstr)
+
+		for _, t := range fn.Temps {
+			defs, uses := refs.defs[t], refs.uses[t]
+			if t.Param != 0 || len(defs) == 0 {
+				continue
+			}
+			counted, unused := 0, true
+			for _, in := range uses {
+				switch {
+				case in.Op == irRef:
+					counted++
+				case in.Op == irUnref:
+					counted--
+				default:
+					unused = false
+				}
+			}
+			if !unused || remove[defs[0]] {
+				continue
+			}
+
+			if len(defs) == 1 && defs[0].Pure() && (counted == 0 || (counted == -1 && defs[0].Owned())) {
+				// the value is only counted and uncounted.
+				remove[defs[0]] = true
+				for _, in := range uses {
+					remove[in] = true
+				}
+				changed = true
+				continue
+			}
+
+			if t.Raw {
+				pure := true
+				for _, in := range defs {
+					pure = pure && in.Pure()
+				}
+				if pure {
+					for _, in := range defs {
+						remove[in] = true
+					}
+					changed = true
+				}
+				continue
+			}
+
+			// a variable that is never read only needs to remove the
+			// references to the values stored in it.
+			moves := true
+			for _, in := range uses {
+				moves = moves && in.Op == irUnref
+			}
+			for _, in := range defs {
+				moves = moves && in.Op == irMove
+			}
+			if !moves {
+				continue
+			}
+			for _, in := range uses {
+				remove[in] = true
+			}
+			for _, in := range defs {
+				if a := irTempOf(in.Args[0]); a != nil && !a.Raw {
+					replace[in] = &irInstr{Op: irUnref, Args: in.Args}
+				} else {
+					remove[in] = true
+				}
+			}
+			changed = true
+		}
+
+		irRewrite(fn, func(in *irInstr) []*irInstr {
+			if r := replace[in]; r != nil {
+				return []*irInstr{r}
+			}
+			if remove[in] {
+				return nil
+			}
+			return []*irInstr{in}
+		})
+	}
+}
@@ -2,11 +2,11 @@ package ast
 
 import (
 	"bytes"
-	"fmt"
 	"go/token"
 	"io"
 	"os"
 	"strconv"
+	"strings"
 	"unicode"
 )
 
@@ -20,8 +20,24 @@ func (p *Program) Parse(f *token.File, opt Options, r *bytes.Reader) (haveErrors
 		opt: opt,
 	}
 
+	if p.sources == nil {
+		p.sources = make(map[string][]byte)
+	}
+	if p.idents == nil {
+		p.idents = make(map[*token.File][]*Ident)
+	}
+	if p.exprs == nil {
+		p.exprs = make(map[*token.File][]exprSpan)
+	}
+	src := make([]byte, r.Size())
+	n, _ := r.ReadAt(src, 0)
+	p.sources[f.Name()] = src[:n]
+
 	yyParse(l)
 
+	writeDiagnostics(opt, f.Position, p.sources, l.diagnostics)
+	p.haveSyntaxErrors = p.haveSyntaxErrors || l.haveError
+
 	return l.haveError
 }
 
@@ -78,26 +94,87 @@ type lex struct {
 	r      *bytes.Reader
 	offset int
 
-	program   *Program
-	haveError bool
+	// tok is the token most recently returned by Lex. If it is ILLEGAL,
+	// illegal is the reason, or "" if the lexer already reported an
+	// error.
+	tok     int
+	illegal string
+	// recent is the three tokens before tok, most recent first, and
+	// brackets is the brackets they leave open. Error uses them to
+	// explain syntax errors the parser has no message for.
+	recent   [3]int
+	brackets []lexBracket
+
+	program     *Program
+	haveError   bool
+	diagnostics []*Diagnostic
+
+	// spans is the source code covered by each expression the parser
+	// has built so far.
+	spans map[Expr]exprSpan
+
+	// trivia is the comments before the token most recently returned by
+	// Lex, and newlines is the number of line breaks between the last
+	// comment (or the previous token) and the token.
+	trivia   []trivia
+	newlines int
+	// docs is the doc comment before each class, var, def, or override
+	// keyword that has one, by the position of the keyword.
+	docs map[token.Pos]string
 
 	opt Options
 }
 
+// lexBracket is an open bracket.
+type lexBracket struct {
+	// kind is '{' for a block, 'c' for the body of a class, 'm' for the
+	// cases of a match, or '(' for parentheses.
+	kind byte
+	// ifs is the number of if expressions since the last ';' that don't
+	// have an else branch yet.
+	ifs int
+	// body is true in the body of a class if the feature since the last
+	// ';' can end at the next token.
+	body bool
+}
+
+// trivia is a comment. The parser ignores comments, but the formatter and
+// the documentation generator need them.
+type trivia struct {
+	// newlines is the number of line breaks between the previous token
+	// or comment and this one. More than one means there is a blank line.
+	newlines int
+	// offset is the offset of the first byte of the comment.
+	offset int
+	// text is the comment, including the // or /* */.
+	text string
+}
+
 func (l *lex) Lex(lvalue *yySymType) (tok int) {
+	l.illegal = ""
+	l.trivia = nil
+	l.newlines = 0
+
 	defer func() {
 		if r := recover(); r != nil {
-			if r == errorSentinel {
+			switch r {
+			case errorSentinel:
 				lvalue.pos = l.Pos()
 				tok = ILLEGAL
-				return
-			}
-			if r == eofSentinel {
+			case eofSentinel:
 				tok = 0
-				return
+			default:
+				panic(r)
 			}
-			panic(r)
 		}
+
+		if tok != 0 {
+			lvalue.end = l.file.Pos(l.End())
+		}
+
+		l.track(l.tok)
+		l.recent[0], l.recent[1], l.recent[2] = l.tok, l.recent[0], l.recent[1]
+		l.tok = tok
 	}()
 
 	unexpected := false
@@ -106,12 +183,12 @@ func (l *lex) Lex(lvalue *yySymType) (tok int) {
 		if err != nil {
 			if err == io.EOF {
 				if unexpected {
-					l.Error("unexpected EOF")
+					l.Report("lexical", "unexpected end of file")
 					panic(errorSentinel)
 				}
 				panic(eofSentinel)
 			}
-			l.Error(err.Error())
+			l.Report("lexical", err.Error())
 			panic(errorSentinel)
 		}
 	}
@@ -133,6 +210,9 @@ func (l *lex) Lex(lvalue *yySymType) (tok int) {
 		l.offset = int(offset)
 
 		if unicode.IsSpace(rune(r)) {
+			if r == '\n' {
+				l.newlines++
+			}
 			r, err = l.r.ReadByte()
 			check(err)
 			continue
@@ -140,28 +220,45 @@ func (l *lex) Lex(lvalue *yySymType) (tok int) {
 
 		if r == '/' {
 			r, err = l.r.ReadByte()
+			if err == io.EOF {
+				// the file ends with '/', and there is nothing to
+				// unread.
+				r = '/'
+				break
+			}
+			check(err)
 			switch r {
 			case '/':
+				comment := []byte("//")
 				for {
 					r, err = l.r.ReadByte()
+					if err == io.EOF {
+						l.comment(int(offset), comment)
+					}
 					check(err)
 					if r == '\n' {
+						l.comment(int(offset), bytes.TrimRight(comment, "\r"))
+						l.newlines = 1
 						r, err = l.r.ReadByte()
 						check(err)
 						break
 					}
+					comment = append(comment, r)
 				}
 				continue
 			case '*':
 				unexpected = true
+				comment := []byte("/*")
 				for {
 					r, err = l.r.ReadByte()
 					check(err)
+					comment = append(comment, r)
 					if r == '*' {
 						r, err = l.r.ReadByte()
 						check(err)
 						if r == '/' {
 							unexpected = false
+							l.comment(int(offset), append(comment, '/'))
 							r, err = l.r.ReadByte()
 							check(err)
 							break
@@ -197,8 +294,10 @@ func (l *lex) Lex(lvalue *yySymType) (tok int) {
 				check(l.r.UnreadByte())
 				lvalue.id = &Ident{
 					Pos:  l.file.Pos(int(offset)),
+					End:  l.file.Pos(int(offset) + len(buf)),
 					Name: string(buf),
 				}
+				l.program.idents[l.file] = append(l.program.idents[l.file], lvalue.id)
 				return TYPEID
 			}
 		}
@@ -214,13 +313,22 @@ func (l *lex) Lex(lvalue *yySymType) (tok int) {
 				check(l.r.UnreadByte())
 				s := string(buf)
 				if tok, ok := idTokens[s]; ok {
+					if tok == ILLEGAL {
+						l.illegal = "'" + s + "' is a reserved word and cannot be used as an identifier"
+					}
 					lvalue.pos = l.file.Pos(int(offset))
+					switch tok {
+					case CLASS, VAR, DEF, OVERRIDE:
+						l.recordDoc(lvalue.pos)
+					}
 					return tok
 				}
 				lvalue.id = &Ident{
 					Pos:  l.file.Pos(int(offset)),
+					End:  l.file.Pos(int(offset) + len(buf)),
 					Name: s,
 				}
+				l.program.idents[l.file] = append(l.program.idents[l.file], lvalue.id)
 				return OBJECTID
 			}
 		}
@@ -231,6 +339,7 @@ func (l *lex) Lex(lvalue *yySymType) (tok int) {
 		check(l.r.UnreadByte())
 
 		if r >= '0' && r <= '9' {
+			l.illegal = "integer literals cannot start with 0"
 			return ILLEGAL
 		}
 
@@ -250,9 +359,11 @@ func (l *lex) Lex(lvalue *yySymType) (tok int) {
 				buf = append(buf, r)
 			} else {
 				check(l.r.UnreadByte())
-				var n int64
-				n, err = strconv.ParseInt(string(buf), 10, 32)
-				check(err)
+				n, err := strconv.ParseInt(string(buf), 10, 32)
+				if err != nil {
+					l.Report("lexical", "integer literal "+string(buf)+" is too large")
+					panic(errorSentinel)
+				}
 				lvalue.int = &IntLit{
 					Pos: l.file.Pos(int(offset)),
 					Int: int32(n),
@@ -293,6 +404,7 @@ func (l *lex) Lex(lvalue *yySymType) (tok int) {
 		for {
 			switch r {
 			case '\n':
+				l.illegal = "string literal is missing its closing '\"'"
 				lvalue.pos = l.file.Pos(int(offset))
 				return ILLEGAL
 
@@ -324,6 +436,7 @@ func (l *lex) Lex(lvalue *yySymType) (tok int) {
 				case '\\':
 					buf = append(buf, '\\')
 				default:
+					l.illegal = "unknown escape sequence in string literal: \\" + string(r)
 					lvalue.pos = l.file.Pos(int(offset))
 					return ILLEGAL
 				}
@@ -367,11 +480,293 @@ func (l *lex) Lex(lvalue *yySymType) (tok int) {
 	return int(r)
 }
 
+// span records that e covers the source code from pos to end, including any
+// parentheses or braces around it. Expressions that contain a syntax error
+// have no span.
+func (l *lex) span(e Expr, pos, end token.Pos) {
+	if !pos.IsValid() || !end.IsValid() {
+		return
+	}
+	if l.spans == nil {
+		l.spans = make(map[Expr]exprSpan)
+	}
+	span := exprSpan{Expr: e, Pos: pos, End: end}
+	l.spans[e] = span
+	if l.program.exprs != nil {
+		l.program.exprs[l.file] = append(l.program.exprs[l.file], span)
+	}
+}
+
+// exprPos returns the position of the first byte of e, or token.NoPos if e
+// has no span.
+func (l *lex) exprPos(e Expr) token.Pos {
+	return l.spans[e].Pos
+}
+
+// exprEnd returns the position immediately after e, or token.NoPos if e has
+// no span.
+func (l *lex) exprEnd(e Expr) token.Pos {
+	return l.spans[e].End
+}
+
+// comment records a comment that starts at offset.
+func (l *lex) comment(offset int, text []byte) {
+	l.trivia = append(l.trivia, trivia{
+		newlines: l.newlines,
+		offset:   offset,
+		text:     string(text),
+	})
+	l.newlines = 0
+}
+
+// recordDoc remembers the doc comment for the declaration starting at pos.
+// A doc comment is a comment starting with /** that is the last comment
+// before the declaration.
+func (l *lex) recordDoc(pos token.Pos) {
+	if len(l.trivia) == 0 {
+		return
+	}
+
+	text := l.trivia[len(l.trivia)-1].text
+	if !strings.HasPrefix(text, "/**") || text == "/**/" {
+		return
+	}
+
+	if l.docs == nil {
+		l.docs = make(map[token.Pos]string)
+	}
+	l.docs[pos] = docText(text)
+}
+
+// doc returns the doc comment for the declaration starting at pos.
+func (l *lex) doc(pos token.Pos) string {
+	return l.docs[pos]
+}
+
+// docText removes the comment markers from a doc comment, as well as the
+// column of asterisks at the start of each line, if any.
+func docText(comment string) string {
+	comment = strings.TrimSuffix(strings.TrimPrefix(comment, "/**"), "*/")
+
+	lines := strings.Split(comment, "\n")
+	for i, line := range lines {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "*") {
+			line = strings.TrimSpace(line[1:])
+		}
+		lines[i] = line
+	}
+
+	return strings.TrimSpace(strings.Join(lines, "\n"))
+}
+
+// tokenNames describes the tokens that yacc refers to by their name in the
+// grammar. Keywords and literal characters are described by lexTokenName.
+var tokenNames = map[string]string{
+	"$end":     "end of file",
+	"$unk":     "unknown character",
+	"OBJECTID": "identifier",
+	"TYPEID":   "type name",
+	"INTEGER":  "integer",
+	"STRING":   "string",
+	"ARROW":    "'=>'",
+	"EQ":       "'=='",
+	"LE":       "'<='",
+}
+
+func lexTokenName(name string) string {
+	if s, ok := tokenNames[name]; ok {
+		return s
+	}
+	if strings.HasPrefix(name, "'") {
+		return name
+	}
+	return "'" + strings.ToLower(name) + "'"
+}
+
+// found returns the source code of the most recent token for use in error
+// messages.
+func (l *lex) found() string {
+	if l.tok == 0 {
+		return "end of file"
+	}
+
+	b := make([]byte, l.End()-l.offset)
+	n, _ := l.r.ReadAt(b, int64(l.offset))
+	b = b[:n]
+	if i := bytes.IndexByte(b, '\n'); i != -1 {
+		b = b[:i]
+	}
+	if len(b) > 20 {
+		b = append(b[:17:17], "..."...)
+	}
+
+	return "'" + string(b) + "'"
+}
+
 func (l *lex) Error(s string) {
-	fmt.Fprintf(l.opt.Errors, "%v: %s\n", l.file.Position(l.Pos()), s)
+	if l.tok == ILLEGAL {
+		// don't report the same token twice.
+		if l.illegal != "" {
+			l.Report("lexical", l.illegal)
+		}
+		return
+	}
+
+	s = strings.TrimPrefix(s, "syntax error: ")
+
+	if strings.HasPrefix(s, "unexpected ") {
+		// the parser doesn't have a message for this state, so it
+		// gave us "unexpected TOKEN, expecting TOKEN or TOKEN", or
+		// just "unexpected TOKEN" if it expected more than four.
+		if msg := l.explain(); msg != "" {
+			s = strings.Replace(msg, "%s", l.found(), -1)
+		} else {
+			msg = "unexpected " + l.found()
+			if i := strings.Index(s, ", expecting "); i != -1 {
+				expected := strings.Split(s[i+len(", expecting "):], " or ")
+				for i := range expected {
+					expected[i] = lexTokenName(expected[i])
+				}
+				msg += ", expected " + strings.Join(expected, " or ")
+			}
+			s = msg
+		}
+	} else {
+		// messages from %error in syntax.y
+		s = strings.Replace(s, "%s", l.found(), -1)
+	}
+
+	l.Report("syntax", "syntax error: "+s)
+}
+
+// track updates brackets for tok, which is followed by the token that was
+// just read.
+func (l *lex) track(tok int) {
+	var top *lexBracket
+	if len(l.brackets) != 0 {
+		top = &l.brackets[len(l.brackets)-1]
+	}
+
+	switch {
+	case tok == '{' && top == nil:
+		l.brackets = append(l.brackets, lexBracket{kind: 'c'})
+	case tok == '{' && l.recent[0] == MATCH:
+		l.brackets = append(l.brackets, lexBracket{kind: 'm'})
+	case tok == '{' || tok == '(':
+		l.brackets = append(l.brackets, lexBracket{kind: byte(tok)})
+	case (tok == '}' || tok == ')') && top != nil:
+		l.brackets = l.brackets[:len(l.brackets)-1]
+		if len(l.brackets) != 0 && top.kind == '{' {
+			// a block in the body of a class is a feature.
+			if parent := &l.brackets[len(l.brackets)-1]; parent.kind == 'c' {
+				parent.body = true
+			}
+		}
+	case top == nil:
+	case tok == ';' || tok == CASE:
+		top.ifs = 0
+		top.body = false
+	case tok == IF:
+		top.ifs++
+	case tok == ELSE && top.ifs != 0:
+		top.ifs--
+	case tok == '=' && top.kind == 'c':
+		top.body = true
+	}
+}
+
+// explain returns a message for a syntax error at the current token that
+// explains what is missing, or "" if it can't tell. The parser's list of
+// expected tokens can't be used for this, because the parser has often
+// already reduced the expression before the error, so it no longer accepts
+// a ';' or an else branch.
+func (l *lex) explain() string {
+	if len(l.brackets) == 0 {
+		return ""
+	}
+	top := l.brackets[len(l.brackets)-1]
+
+	switch prev := l.recent[0]; {
+	case top.ifs != 0 && lexEndsExpr(prev):
+		return "missing 'else' branch of 'if' expression, found %s"
+	case top.kind == '{' && lexEndsExpr(prev) && lexStartsExpr(l.tok):
+		return "missing ';' between expressions in block, found %s"
+	case top.kind == 'c' && top.body && lexEndsExpr(prev) && lexStartsFeature(l.tok):
+		return "missing ';' after feature declaration, found %s"
+	case top.kind == 'c' && l.recent == [3]int{'=', OBJECTID, VAR}:
+		return "missing type in attribute declaration, found %s"
+	case l.tok == VAR && lexBeforeExpr(prev):
+		return "'var' is not allowed inside an expression here"
+	}
+	return ""
+}
+
+// lexEndsExpr returns true if an expression can end with tok.
+func lexEndsExpr(tok int) bool {
+	switch tok {
+	case OBJECTID, INTEGER, STRING, TRUE, FALSE, NULL, THIS, NATIVE, ')', '}':
+		return true
+	}
+	return false
+}
+
+// lexStartsExpr returns true if an expression in a block can start with
+// tok.
+func lexStartsExpr(tok int) bool {
+	switch tok {
+	case OBJECTID, INTEGER, STRING, TRUE, FALSE, NULL, THIS, SUPER, NEW, VAR, IF, WHILE, '{', '(', '!':
+		return true
+	}
+	return false
+}
+
+// lexStartsFeature returns true if a feature can start with tok, or the
+// body of the class can end with it.
+func lexStartsFeature(tok int) bool {
+	switch tok {
+	case VAR, DEF, OVERRIDE, '{', '}':
+		return true
+	}
+	return false
+}
+
+// lexBeforeExpr returns true if tok can be followed by an expression that
+// is not in a block.
+func lexBeforeExpr(tok int) bool {
+	switch tok {
+	case '=', '(', ',', ')', '+', '-', '*', '/', '<', '!', LE, EQ, ELSE, ARROW:
+		return true
+	}
+	return false
+}
+
+func (l *lex) Report(code, s string) {
+	end := token.NoPos
+	if offset := l.End(); offset > l.offset {
+		end = l.file.Pos(offset)
+	}
+
+	l.diagnostics = append(l.diagnostics, &Diagnostic{
+		Severity: SeverityError,
+		Code:     code,
+		Pos:      l.Pos(),
+		End:      end,
+		Message:  s,
+	})
 	l.haveError = true
 }
 
 func (l *lex) Pos() token.Pos {
 	return l.file.Pos(l.offset)
 }
+
+// End returns the offset just after the last byte the lexer has read, which
+// is the end of the current token.
+func (l *lex) End() int {
+	offset, err := l.r.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return l.offset
+	}
+	return int(offset)
+}
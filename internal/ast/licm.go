@@ -0,0 +1,433 @@
+package ast
+
+import "go/token"
+
+// semLoop is what the optimizer knows about a while loop while it optimizes
+// the loop's condition and body. Expressions whose value is the same on
+// every iteration are moved to variables declared before the loop, and
+// multiplications by a variable that the loop only ever increases or
+// decreases by the same amount are replaced by variables that are updated
+// with an addition each time it changes.
+type semLoop struct {
+	// assigned is the set of variables and attributes assigned in the
+	// loop.
+	assigned map[Object]bool
+	// declared is the set of variables declared in the loop.
+	declared map[Object]bool
+	// steps is the amounts added to each variable assigned only by
+	// expressions of the form `i = i + c` and `i = i - c`. A variable
+	// that is assigned in any other way has no entry.
+	steps map[Object][]Expr
+	// other is the set of variables assigned in some other way.
+	other map[Object]bool
+	// calls is true if the loop calls a method that might assign an
+	// attribute.
+	calls bool
+	// products is the multiplications in the loop, in the order they
+	// appear in the source code.
+	products []*MultiplyExpr
+
+	// hoisted is the variables declared before the loop, in order.
+	hoisted []*VarExpr
+	// pos is the position of each hoisted expression, for remarks.
+	pos map[*VarExpr]token.Pos
+	// reduced is the strength reduction of each multiplication that was
+	// strength reduced.
+	reduced map[*MultiplyExpr]*semReduction
+	// reductions is the strength reductions of each induction variable.
+	reductions map[Object][]*semReduction
+}
+
+// semReduction is a variable that holds the value of i * k, where i is an
+// induction variable and k is invariant.
+type semReduction struct {
+	v *VarExpr
+	i Expr
+	k Expr
+}
+
+// Loop scans the condition and body of e for what they assign and plans
+// the strength reductions that can be done in them. It must be called
+// before the loop's assignments are forgotten, since the initial values of
+// the strength reduced variables are computed from the values known before
+// the loop.
+func (ctx *semCtx) Loop(e *WhileExpr) *semLoop {
+	loop := &semLoop{
+		assigned: make(map[Object]bool),
+		declared: make(map[Object]bool),
+		steps:    make(map[Object][]Expr),
+		other:    make(map[Object]bool),
+
+		pos:        make(map[*VarExpr]token.Pos),
+		reduced:    make(map[*MultiplyExpr]*semReduction),
+		reductions: make(map[Object][]*semReduction),
+	}
+	e.semantAssigned(ctx, loop)
+
+	// the variables of outer loops' strength reductions are assigned
+	// wherever their induction variables are.
+	for _, outer := range ctx.loops {
+		for i, rs := range outer.reductions {
+			if loop.assigned[i] {
+				for _, r := range rs {
+					loop.assigned[r.v] = true
+				}
+			}
+		}
+	}
+
+	if !ctx.opt.OptLICM {
+		return loop
+	}
+
+	type key struct {
+		i Object
+		k Object
+		n int32
+	}
+	reductions := make(map[key]*semReduction)
+
+products:
+	for _, p := range loop.products {
+		for _, outer := range ctx.loops {
+			if _, ok := outer.reduced[p]; ok {
+				continue products
+			}
+		}
+
+		i, k := p.Left, p.Right
+		if !loop.Induction(i) {
+			i, k = k, i
+		}
+		if !loop.Induction(i) || !loop.Invariant(k) {
+			continue
+		}
+
+		id := key{i: i.(*NameExpr).Name.Object}
+		if n, ok := k.(*IntExpr); ok {
+			id.n = n.Lit.Int
+		} else {
+			id.k = k.(*NameExpr).Name.Object
+		}
+
+		if r, ok := reductions[id]; ok {
+			loop.reduced[p] = r
+			continue
+		}
+
+		r := &semReduction{
+			v: loop.Declare("reduced", p.Int, ctx.Product(i, k, p.Int)),
+			i: i,
+			k: k,
+		}
+		reductions[id] = r
+		loop.reduced[p] = r
+		loop.reductions[id.i] = append(loop.reductions[id.i], r)
+		loop.assigned[r.v] = true
+	}
+
+	return loop
+}
+
+// Assign records an assignment in the loop.
+func (loop *semLoop) Assign(e *AssignExpr) {
+	v := e.Name.Object
+	loop.assigned[v] = true
+	if step, _, ok := semantStep(e); ok && !loop.other[v] {
+		loop.steps[v] = append(loop.steps[v], step)
+	} else {
+		loop.other[v] = true
+		delete(loop.steps, v)
+	}
+	if _, ok := v.(*AttributeObject); ok {
+		// the attribute might belong to this.
+		loop.calls = true
+	}
+}
+
+// Call records a method call in the loop. Native methods don't run any Cool
+// code, unless they switch to another coroutine.
+func (loop *semLoop) Call(ctx *semCtx, m *Method, dynamic bool) {
+	if _, ok := m.Body.(*NativeExpr); ok && !dynamic && !ctx.opt.Coroutine {
+		return
+	}
+	loop.calls = true
+}
+
+// semantStep returns c and true if e has the form `i = i - c`, or c and false
+// if e has the form `i = i + c` or `i = c + i`.
+func semantStep(e *AssignExpr) (step Expr, negative, ok bool) {
+	isVar := func(x Expr) bool {
+		n, ok := x.(*NameExpr)
+		return ok && n.Name.Object == e.Name.Object
+	}
+
+	switch x := e.Expr.(type) {
+	case *AddExpr:
+		if isVar(x.Left) {
+			return x.Right, false, true
+		}
+		if isVar(x.Right) {
+			return x.Left, false, true
+		}
+	case *SubtractExpr:
+		if isVar(x.Left) {
+			return x.Right, true, true
+		}
+	}
+	return nil, false, false
+}
+
+// Invariant returns true if e is a literal or a variable that has the same
+// value on every iteration of the loop, and that is in scope before it.
+func (loop *semLoop) Invariant(e Expr) bool {
+	switch e := e.(type) {
+	case *IntExpr, *BoolExpr, *StringExpr:
+		return true
+	case *NameExpr:
+		switch o := e.Name.Object.(type) {
+		case *Formal:
+			return true
+		case *VarExpr, *MatchExpr:
+			return !loop.assigned[o] && !loop.declared[o]
+		case *Attribute:
+			return !loop.assigned[o] && !loop.calls
+		}
+	}
+	return false
+}
+
+// Induction returns true if e is a variable declared outside the loop that
+// the loop only changes by adding or subtracting an invariant amount.
+func (loop *semLoop) Induction(e Expr) bool {
+	n, ok := e.(*NameExpr)
+	if !ok {
+		return false
+	}
+	v, ok := n.Name.Object.(*VarExpr)
+	if !ok || loop.declared[v] || len(loop.steps[v]) == 0 {
+		return false
+	}
+	for _, step := range loop.steps[v] {
+		if !loop.Invariant(step) {
+			return false
+		}
+	}
+	return true
+}
+
+// KnownInt returns the value of e if it is an integer literal or a variable
+// whose value is a known integer.
+func (ctx *semCtx) KnownInt(e Expr) (int32, bool) {
+	if n, ok := e.(*NameExpr); ok {
+		e = ctx.values[n.Name.Object]
+	}
+	if i, ok := e.(*IntExpr); ok {
+		return i.Lit.Int, true
+	}
+	return 0, false
+}
+
+// Product returns an expression for a * b, using the values of a and b if
+// they are known.
+func (ctx *semCtx) Product(a, b Expr, typ *Ident) Expr {
+	x, xok := ctx.KnownInt(a)
+	y, yok := ctx.KnownInt(b)
+	switch {
+	case xok && yok:
+		return ctx.IntLit(x * y)
+	case xok && x == 0, yok && y == 0:
+		return ctx.IntLit(0)
+	case xok && x == 1:
+		return b
+	case yok && y == 1:
+		return a
+	}
+
+	if xok {
+		a = ctx.IntLit(x)
+	}
+	if yok {
+		b = ctx.IntLit(y)
+	}
+	return &MultiplyExpr{
+		Left:  a,
+		Right: b,
+		Int:   typ,
+	}
+}
+
+// IntLit returns a generated integer literal.
+func (ctx *semCtx) IntLit(n int32) Expr {
+	return &IntExpr{
+		Lit: &IntLit{
+			Int:   n,
+			Class: ctx.intClass,
+		},
+	}
+}
+
+// Declare adds a variable before the loop with the given initial value.
+func (loop *semLoop) Declare(name string, typ *Ident, init Expr) *VarExpr {
+	v := &VarExpr{
+		Name: &Ident{
+			Name: "'" + name,
+		},
+		Type: typ,
+		Init: init,
+	}
+	v.Name.Object = v
+	loop.hoisted = append(loop.hoisted, v)
+	return v
+}
+
+// Wrap returns expr inside the declarations of the loop's variables.
+func (loop *semLoop) Wrap(ctx *semCtx, expr Expr) Expr {
+	for _, v := range loop.hoisted {
+		if pos, ok := loop.pos[v]; ok {
+			ctx.Remark("hoisted", pos, token.NoPos, "moved "+remarkExpr(v.Init)+" out of the loop: its value is the same on every iteration")
+		}
+	}
+
+	for i := len(loop.hoisted) - 1; i >= 0; i-- {
+		loop.hoisted[i].Body = expr
+		expr = loop.hoisted[i]
+	}
+	return expr
+}
+
+// LoopVar returns true if e is a variable declared for a loop. Copies of
+// these variables aren't propagated, since they are assigned without an
+// AssignExpr being optimized and can be merged into other variables.
+func (ctx *semCtx) LoopVar(e Expr) bool {
+	n, ok := e.(*NameExpr)
+	if !ok {
+		return false
+	}
+	v, ok := n.Name.Object.(*VarExpr)
+	if !ok {
+		return false
+	}
+	for _, loop := range ctx.loops {
+		for _, h := range loop.hoisted {
+			if h == v {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// InvariantLoop returns the innermost loop if each of operands has the same
+// value on every iteration of it, or nil if it doesn't or -opt-licm is off.
+func (ctx *semCtx) InvariantLoop(operands ...Expr) *semLoop {
+	if !ctx.opt.OptLICM || len(ctx.loops) == 0 {
+		return nil
+	}
+
+	loop := ctx.loops[len(ctx.loops)-1]
+	for _, e := range operands {
+		if !loop.Invariant(e) {
+			return nil
+		}
+	}
+	return loop
+}
+
+// Hoist moves e to a variable declared before the loop, and returns a read
+// of the variable.
+func (loop *semLoop) Hoist(pos token.Pos, typ *Ident, e Expr) Expr {
+	v := loop.Declare("hoisted", typ, e)
+	loop.pos[v] = pos
+	return semantRead(v, pos)
+}
+
+// Unhoist returns the expression that was hoisted if e reads a variable
+// hoisted by Hoist, and removes the variable. It is used when e is an
+// operand of an expression that is also being hoisted, so that only one
+// variable is needed for both.
+func (loop *semLoop) Unhoist(e Expr) Expr {
+	n, ok := e.(*NameExpr)
+	if !ok {
+		return e
+	}
+	v, ok := n.Name.Object.(*VarExpr)
+	if !ok {
+		return e
+	}
+	if _, ok = loop.pos[v]; !ok {
+		return e
+	}
+
+	for i, h := range loop.hoisted {
+		if h == v {
+			loop.hoisted = append(loop.hoisted[:i], loop.hoisted[i+1:]...)
+			break
+		}
+	}
+	delete(loop.pos, v)
+	return v.Init
+}
+
+// Reduced returns a read of the variable that holds the value of e if e
+// was strength reduced.
+func (ctx *semCtx) Reduced(e *MultiplyExpr) (Expr, bool) {
+	for _, loop := range ctx.loops {
+		if r, ok := loop.reduced[e]; ok {
+			ctx.Remark("strength-reduced", e.Pos, token.NoPos, "replaced "+remarkExpr(e)+" with a variable that is updated by an addition each time "+remarkExpr(r.i)+" changes")
+			return semantRead(r.v, e.Pos), true
+		}
+	}
+	return nil, false
+}
+
+// Step returns the assignments that keep the strength reduced variables of
+// e's target up to date, to be done after e.
+func (ctx *semCtx) Step(e *AssignExpr) []Expr {
+	var updates []Expr
+	for _, loop := range ctx.loops {
+		rs := loop.reductions[e.Name.Object]
+		if len(rs) == 0 {
+			continue
+		}
+
+		step, negative, _ := semantStep(e)
+		for _, r := range rs {
+			// i * k changes by step * k when i changes by step.
+			delta := ctx.Product(step, r.k, r.v.Type)
+			if _, ok := delta.(*MultiplyExpr); ok {
+				delta = semantRead(loop.Declare("step", r.v.Type, delta), token.NoPos)
+			}
+
+			update := &BinaryOperator{
+				Left:  semantRead(r.v, token.NoPos),
+				Right: delta,
+				Int:   r.v.Type,
+			}
+			var expr Expr = (*AddExpr)(update)
+			if negative {
+				expr = (*SubtractExpr)(update)
+			}
+			updates = append(updates, &AssignExpr{
+				Name: &Ident{
+					Name:   r.v.Name.Name,
+					Object: r.v,
+				},
+				Expr: expr,
+				Unit: e.Unit,
+			})
+		}
+	}
+	return updates
+}
+
+// semantRead returns an expression that reads v.
+func semantRead(v *VarExpr, pos token.Pos) Expr {
+	return &NameExpr{
+		Name: &Ident{
+			Name:   v.Name.Name,
+			Pos:    pos,
+			Object: v,
+		},
+	}
+}
@@ -0,0 +1,203 @@
+package ast
+
+import (
+	"go/token"
+	"sort"
+	"strings"
+)
+
+// Idents returns the identifiers in the source code of f in the order they
+// appear. After Semant, each one refers to the class, method, or object it
+// names, including the identifiers in declarations.
+func (p *Program) Idents(f *token.File) []*Ident {
+	return p.idents[f]
+}
+
+// IdentAt returns the identifier in f that contains pos, or nil if there is
+// none. A position immediately after an identifier counts as part of it.
+func (p *Program) IdentAt(f *token.File, pos token.Pos) *Ident {
+	ids := p.idents[f]
+	i := sort.Search(len(ids), func(i int) bool {
+		return ids[i].End >= pos
+	})
+	if i < len(ids) && ids[i].Pos <= pos {
+		return ids[i]
+	}
+	return nil
+}
+
+// exprSpan is the source code covered by an expression, from the position of
+// its first byte to the position immediately after its last byte.
+type exprSpan struct {
+	Expr     Expr
+	Pos, End token.Pos
+}
+
+// ExprAt returns the smallest expression in f whose source code contains pos,
+// and the positions of its first byte and the byte after its last. If the
+// expression is in parentheses or braces, they are included. It returns a nil
+// Expr if pos is not in any expression.
+func (p *Program) ExprAt(f *token.File, pos token.Pos) (e Expr, start, end token.Pos) {
+	for _, span := range p.exprs[f] {
+		if span.Pos > pos || span.End <= pos {
+			continue
+		}
+		if e == nil || span.End-span.Pos < end-start {
+			e, start, end = span.Expr, span.Pos, span.End
+		}
+	}
+	return
+}
+
+// StaticType returns the static type of e found by Semant, or nil if e was
+// not checked or an error left its type unknown.
+func (p *Program) StaticType(e Expr) *Class {
+	if t := p.types[e]; t != errorClass {
+		return t
+	}
+	return nil
+}
+
+// Receiver returns the static type of the receiver of a method call, given
+// the identifier of the method's name, or nil if id is not the name of
+// a method call. It is set even if the method does not exist.
+func (p *Program) Receiver(id *Ident) *Class {
+	return p.receivers[id]
+}
+
+// Definition returns the identifier in the declaration of the class, method,
+// or object that id refers to, or nil if id does not refer to anything.
+func (p *Program) Definition(id *Ident) *Ident {
+	switch {
+	case id.Class != nil:
+		if id.Class == errorClass {
+			return nil
+		}
+		return id.Class.Type
+	case id.Method != nil:
+		return id.Method.Name
+	case id.Object != nil:
+		return objectDefinition(id.Object, id.Pos)
+	}
+	return nil
+}
+
+func objectDefinition(o Object, pos token.Pos) *Ident {
+	switch o := o.(type) {
+	case *Formal:
+		return o.Name
+	case *Attribute:
+		return o.Name
+	case *AttributeObject:
+		return o.Attribute.Name
+	case *VarExpr:
+		return o.Name
+	case *MatchExpr:
+		// every case of a match shares the same object, so the case
+		// that declares pos is the last one before it.
+		var name *Ident
+		for _, c := range o.Cases {
+			if c.Name.Pos <= pos && c.Type.Class != nullClass {
+				name = c.Name
+			}
+		}
+		return name
+	}
+	return nil
+}
+
+// References returns every identifier in the program that refers to the same
+// class, method, or object as id, including the declaration, in the order the
+// files were parsed. Generated identifiers that have no position are left out.
+func (p *Program) References(id *Ident) []*Ident {
+	def := p.Definition(id)
+	if def == nil {
+		return nil
+	}
+
+	var files []*token.File
+	for f := range p.idents {
+		files = append(files, f)
+	}
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].Base() < files[j].Base()
+	})
+
+	var refs []*Ident
+	for _, f := range files {
+		for _, other := range p.idents[f] {
+			if p.Definition(other) == def {
+				refs = append(refs, other)
+			}
+		}
+	}
+	return refs
+}
+
+// Describe returns a line of Cool code that declares the class, method, or
+// object id refers to, such as `def length() : Int`, or an empty string if
+// id does not refer to anything.
+func (p *Program) Describe(id *Ident) string {
+	switch {
+	case id.Class != nil:
+		if id.Class == errorClass {
+			return ""
+		}
+		return describeClass(id.Class)
+	case id.Method != nil:
+		return describeMethod(id.Method)
+	case id.Object != nil:
+		def := objectDefinition(id.Object, id.Pos)
+		if def == nil {
+			return ""
+		}
+		switch o := id.Object.(type) {
+		case *Formal:
+			return def.Name + " : " + o.Type.Name
+		case *Attribute:
+			return "var " + o.Parent.Type.Name + "." + def.Name + " : " + o.Type.Name
+		case *AttributeObject:
+			return "var " + o.Attribute.Parent.Type.Name + "." + def.Name + " : " + o.Attribute.Type.Name
+		case *VarExpr:
+			return "var " + def.Name + " : " + o.Type.Name
+		case *MatchExpr:
+			for _, c := range o.Cases {
+				if c.Name == def {
+					return "case " + def.Name + " : " + c.Type.Name
+				}
+			}
+		}
+	}
+	return ""
+}
+
+func describeClass(c *Class) string {
+	s := "class " + c.Type.Name
+	if len(c.Formals) != 0 {
+		args := make([]string, len(c.Formals))
+		for i, f := range c.Formals {
+			args[i] = "var " + strings.TrimPrefix(f.Name.Name, "'") + " : " + f.Type.Name
+		}
+		s += "(" + strings.Join(args, ", ") + ")"
+	}
+	if e := c.Extends.Type; e.Class != nil && e.Class != nativeClass && e.Name != "native" {
+		s += " extends " + e.Name
+	}
+	return s
+}
+
+func describeMethod(m *Method) string {
+	args := make([]string, len(m.Args))
+	for i, a := range m.Args {
+		args[i] = a.Name.Name + " : " + a.Type.Name
+	}
+
+	s := "def "
+	if m.Override {
+		s = "override def "
+	}
+	if m.Parent != nil {
+		s += m.Parent.Type.Name + "."
+	}
+	return s + m.Name.Name + "(" + strings.Join(args, ", ") + ") : " + m.Type.Name
+}
@@ -4,9 +4,30 @@ import "io"
 
 type Options struct {
 	Errors io.Writer
+	// Diagnostics is the format of error messages written to Errors:
+	// "text" (the default), "pretty", or "json".
+	Diagnostics string
+	// Color enables terminal colors in the "pretty" format.
+	Color bool
+	// Report, if it is not nil, is called with each diagnostic instead of
+	// writing it to Errors.
+	Report func(*Diagnostic)
+	// Dump, if it is not nil, is called by Semant with "checked" after
+	// type checking and with "optimized" after optimization, so the
+	// program can be inspected between passes.
+	Dump func(stage string)
+	// Warnings turns warnings on or off by name. Warnings that are not in
+	// the map use their default setting.
+	Warnings map[string]bool
+	// WarningErrors makes warnings into errors by name. The empty name
+	// applies to every warning that is not in the map.
+	WarningErrors map[string]bool
 
 	Benchmark int
 	Coroutine bool
+	// Library allows a program without a Main class, for tools such as
+	// the documentation generator. A library cannot be compiled.
+	Library bool
 
 	OptInt      bool
 	OptJump     bool
@@ -14,4 +35,25 @@ type Options struct {
 	OptDispatch bool
 	OptFold     bool
 	OptInline   bool
+	// OptDead leaves out the code for methods that are never called and
+	// the method tables of classes that are never instantiated.
+	OptDead bool
+	// OptLICM moves expressions that have the same value on every
+	// iteration of a loop to before the loop, and replaces multiplications
+	// by a loop counter with additions.
+	OptLICM bool
+	// OptEscape keeps the attributes of objects that never leave the
+	// method that creates them in stack slots, and builds Ints passed to
+	// methods that only read them in the stack frame.
+	OptEscape bool
+	// OptRegs keeps local variables, arguments, and temporaries in
+	// registers instead of stack slots where it can.
+	OptRegs bool
+	// OptPeephole removes redundant instructions from the generated code
+	// for each method, using the rules in genPeepholeRules.
+	OptPeephole bool
+	// OptReport adds a remark to the diagnostics for each method call and
+	// constant expression the optimizer looks at, saying what it did and
+	// why.
+	OptReport bool
 }
@@ -0,0 +1,218 @@
+package ast
+
+import "strings"
+
+// genPeepholeRule replaces a short sequence of instructions with a shorter
+// one that does the same thing.
+type genPeepholeRule struct {
+	name string
+	// apply looks at the instructions starting at code[0]. If the rule
+	// matches, it returns the number of instructions it matched and what
+	// to replace them with.
+	apply func(code []genInstr) (n int, replace []genInstr)
+}
+
+// genPeepholeRules is the rules that genPeephole applies. A rule never
+// matches an instruction after a label unless it says so, because another
+// jump to the label could make the instructions before it not run.
+var genPeepholeRules = []genPeepholeRule{
+	{"pop-push", peepPopPush},
+	{"push-pop", peepPushPop},
+	{"jump-next", peepJumpNext},
+	{"jump-over", peepJumpOver},
+	{"test-test", peepTestTest},
+	{"move-self", peepMoveSelf},
+	{"move-again", peepMoveAgain},
+	{"move-through", peepMoveThrough},
+}
+
+// genPeephole applies genPeepholeRules to the code for a method until none
+// of them match.
+func genPeephole(code []genInstr) []genInstr {
+	for changed := true; changed; {
+		changed = false
+		out := make([]genInstr, 0, len(code))
+		for i := 0; i < len(code); {
+			n, replace := 0, []genInstr(nil)
+			for _, r := range genPeepholeRules {
+				if n, replace = r.apply(code[i:]); n != 0 {
+					break
+				}
+			}
+			if n == 0 {
+				out = append(out, code[i])
+				i++
+				continue
+			}
+			out = append(out, replace...)
+			i += n
+			changed = true
+		}
+		code = out
+	}
+	return code
+}
+
+// peepInstrs returns the first n instructions of code if there are that
+// many and none of them are labels.
+func peepInstrs(code []genInstr, n int) []genInstr {
+	if len(code) < n {
+		return nil
+	}
+	for _, in := range code[:n] {
+		if in.Label != "" {
+			return nil
+		}
+	}
+	return code[:n]
+}
+
+func peepIs(in genInstr, ops ...string) bool {
+	for _, op := range ops {
+		if in.Op == op {
+			return true
+		}
+	}
+	return false
+}
+
+func peepReg(arg string) bool {
+	return strings.HasPrefix(arg, "%")
+}
+
+// pop R; push R leaves R and the stack as they were after the pop, so the
+// value only needs to be copied.
+func peepPopPush(code []genInstr) (int, []genInstr) {
+	in := peepInstrs(code, 2)
+	if in == nil || !peepIs(in[0], "pop", "popl") || !peepIs(in[1], "push", "pushl") {
+		return 0, nil
+	}
+	r := in[0].Args[0]
+	if r != in[1].Args[0] || !peepReg(r) || r == "%esp" {
+		return 0, nil
+	}
+	return 2, []genInstr{{Op: "movl", Args: []string{"(%esp)", r}}}
+}
+
+// push X; pop Y is a move from X to Y, or nothing if they are the same.
+func peepPushPop(code []genInstr) (int, []genInstr) {
+	in := peepInstrs(code, 2)
+	if in == nil || !peepIs(in[0], "push", "pushl") || !peepIs(in[1], "pop", "popl") {
+		return 0, nil
+	}
+	x, y := in[0].Args[0], in[1].Args[0]
+	if strings.Contains(x, "%esp") || strings.Contains(y, "%esp") {
+		return 0, nil
+	}
+	if x == y {
+		return 2, nil
+	}
+	if !peepReg(x) && !peepReg(y) {
+		return 0, nil
+	}
+	return 2, []genInstr{{Op: "movl", Args: []string{x, y}}}
+}
+
+var peepInverse = map[string]string{
+	"je":  "jne",
+	"jne": "je",
+	"jz":  "jnz",
+	"jnz": "jz",
+	"jl":  "jge",
+	"jge": "jl",
+	"jle": "jg",
+	"jg":  "jle",
+}
+
+// peepTarget returns true if a jump to target from just before code would
+// go to one of the labels at the start of code.
+func peepTarget(code []genInstr, target string) bool {
+	for _, in := range code {
+		if in.Label == "" {
+			return false
+		}
+		if target == in.Label || target == in.Label+"f" {
+			return true
+		}
+	}
+	return false
+}
+
+// A jump to the label after it does nothing.
+func peepJumpNext(code []genInstr) (int, []genInstr) {
+	in := peepInstrs(code, 1)
+	if in == nil || (in[0].Op != "jmp" && peepInverse[in[0].Op] == "") {
+		return 0, nil
+	}
+	if !peepTarget(code[1:], in[0].Args[0]) {
+		return 0, nil
+	}
+	return 1, nil
+}
+
+// jcc L1; jmp L0; L1: only jumps to L0 if the condition is false.
+func peepJumpOver(code []genInstr) (int, []genInstr) {
+	in := peepInstrs(code, 2)
+	if in == nil || peepInverse[in[0].Op] == "" || in[1].Op != "jmp" {
+		return 0, nil
+	}
+	if !peepTarget(code[2:], in[0].Args[0]) {
+		return 0, nil
+	}
+	return 2, []genInstr{{Op: peepInverse[in[0].Op], Args: in[1].Args}}
+}
+
+// Testing the same register twice in a row sets the flags the same way.
+func peepTestTest(code []genInstr) (int, []genInstr) {
+	in := peepInstrs(code, 2)
+	if in == nil || in[0].Op != "test" || in[1].Op != "test" {
+		return 0, nil
+	}
+	if in[0].Args[0] != in[1].Args[0] || in[0].Args[1] != in[1].Args[1] {
+		return 0, nil
+	}
+	return 2, in[:1]
+}
+
+// movl R, R does nothing.
+func peepMoveSelf(code []genInstr) (int, []genInstr) {
+	in := peepInstrs(code, 1)
+	if in == nil || in[0].Op != "movl" || in[0].Args[0] != in[0].Args[1] {
+		return 0, nil
+	}
+	return 1, nil
+}
+
+// After movl A, B, B already holds the value of A, so moving A to B again
+// or B back to A does nothing unless one of them is addressed by the other.
+func peepMoveAgain(code []genInstr) (int, []genInstr) {
+	in := peepInstrs(code, 2)
+	if in == nil || in[0].Op != "movl" || in[1].Op != "movl" {
+		return 0, nil
+	}
+	a, b := in[0].Args[0], in[0].Args[1]
+	if strings.Contains(a, b) || strings.Contains(b, a) {
+		return 0, nil
+	}
+	if (in[1].Args[0] == a && in[1].Args[1] == b) || (in[1].Args[0] == b && in[1].Args[1] == a) {
+		return 2, in[:1]
+	}
+	return 0, nil
+}
+
+// movl A, %eax; movl %eax, B; movl C, %eax only uses %eax to get the value
+// of A to B, which can be done directly if one of them is a register.
+func peepMoveThrough(code []genInstr) (int, []genInstr) {
+	in := peepInstrs(code, 3)
+	if in == nil || in[0].Op != "movl" || in[1].Op != "movl" || in[2].Op != "movl" {
+		return 0, nil
+	}
+	a, b, c := in[0].Args[0], in[1].Args[1], in[2].Args[0]
+	if in[0].Args[1] != "%eax" || in[1].Args[0] != "%eax" || in[2].Args[1] != "%eax" {
+		return 0, nil
+	}
+	if strings.Contains(b, "%eax") || strings.Contains(c, "%eax") || (!peepReg(a) && !peepReg(b)) {
+		return 0, nil
+	}
+	return 3, []genInstr{{Op: "movl", Args: []string{a, b}}, in[2]}
+}
@@ -0,0 +1,695 @@
+package ast
+
+import (
+	"bytes"
+	"go/token"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Precedence levels for printing expressions, from the loosest to the
+// tightest. They match the precedence declarations in syntax.y.
+const (
+	printAssign = iota + 1
+	printIf
+	printMatch
+	printCompare
+	printEqual
+	printAdd
+	printMultiply
+	printUnary
+	printCall
+)
+
+type printCtx struct {
+	opt Options
+	w   *bytes.Buffer
+
+	indent int
+	// prefix is written after the first prefixDepth levels of
+	// indentation on each line, to print code inside a line comment.
+	prefix      string
+	prefixDepth int
+
+	// names is the name each variable is printed with, and scope is the
+	// number of variables in scope with each printed name, so variables
+	// added by inlining don't collide with the variables around them.
+	names map[Object]string
+	scope map[string]int
+}
+
+// PrintSource writes the classes in the program that are not built in as
+// Cool source code. It can be used after any stage of compilation to see
+// what the compiler is working with.
+//
+// After Semant, the generated constructor of each class is printed as
+// a comment, since Cool has no syntax for it. Names that the compiler
+// generates and that are not valid identifiers, such as the `this` of an
+// inlined method, are given a trailing underscore. Comments mark the method
+// calls that will use static dispatch and the method each inlined body was
+// copied from. The optimized program can do things that Cool has no syntax
+// for: allocate an object without running its constructor, and read or
+// write the attributes of an object other than this. An inlined body that
+// does either is written as the call it replaced, so the output can be
+// compiled again.
+func (p *Program) PrintSource(opt Options, fset *token.FileSet, w io.Writer) error {
+	ctx := &printCtx{
+		opt: opt,
+		w:   new(bytes.Buffer),
+
+		names: make(map[Object]string),
+		scope: make(map[string]int),
+	}
+
+	first := true
+	for _, c := range p.Classes {
+		if c.Type.Pos == token.NoPos || p.builtin[fset.File(c.Type.Pos)] {
+			continue
+		}
+
+		if !first {
+			ctx.w.WriteString("\n")
+		}
+		first = false
+
+		c.print(ctx)
+	}
+
+	_, err := w.Write(ctx.w.Bytes())
+	return err
+}
+
+func (ctx *printCtx) WriteString(s string) {
+	ctx.w.WriteString(s)
+}
+
+func (ctx *printCtx) Newline() {
+	ctx.w.WriteByte('\n')
+	for i := 0; i < ctx.indent; i++ {
+		if i == ctx.prefixDepth {
+			ctx.w.WriteString(ctx.prefix)
+		}
+		ctx.w.WriteByte('\t')
+	}
+	if ctx.indent == ctx.prefixDepth {
+		ctx.w.WriteString(ctx.prefix)
+	}
+}
+
+// printName adds an underscore to names that cannot be used in Cool source
+// code.
+func printName(name string) string {
+	if strings.HasPrefix(name, "'") {
+		return name[1:] + "_"
+	}
+	if name == "this" {
+		return name + "_"
+	}
+	return name
+}
+
+// Name writes the name of a variable.
+func (ctx *printCtx) Name(id *Ident) {
+	if name, ok := ctx.names[id.Object]; ok && id.Object != nil {
+		ctx.WriteString(name)
+		return
+	}
+	ctx.WriteString(printName(id.Name))
+}
+
+// Declare gives the variable declared by id a name that no other variable
+// in scope has, and returns the function that ends its scope.
+func (ctx *printCtx) Declare(id *Ident) func() {
+	base := printName(id.Name)
+	name := base
+	for i := 2; ctx.scope[name] != 0; i++ {
+		name = base + strconv.Itoa(i)
+	}
+	if id.Object != nil {
+		ctx.names[id.Object] = name
+	}
+	ctx.scope[name]++
+	return func() {
+		ctx.scope[name]--
+	}
+}
+
+// Variable writes the name of the variable id refers to. An attribute of
+// the this of an inlined method is marked with a comment saying which
+// variable holds it. Cool has no syntax for the attribute of any other
+// object, so it is written as `object.name`, which doesn't parse. The
+// inlined bodies that use one are written as calls instead, by InlinedCall.
+func (ctx *printCtx) Variable(id *Ident) {
+	a, ok := id.Object.(*AttributeObject)
+	if !ok {
+		ctx.Name(id)
+		return
+	}
+
+	v, ok := a.Object.(*VarExpr)
+	if !ok {
+		ctx.WriteString("?." + id.Name)
+		return
+	}
+	if printIsThis(v) {
+		ctx.Name(id)
+		ctx.WriteString("/*of ")
+		ctx.Name(v.Name)
+		ctx.WriteString("*/")
+		return
+	}
+	ctx.Name(v.Name)
+	ctx.WriteString("." + id.Name)
+}
+
+// printIsThis returns true if v holds this, so the attributes it refers to
+// can be written without saying which object they belong to.
+func printIsThis(v *VarExpr) bool {
+	switch init := v.Init.(type) {
+	case *ThisExpr:
+		return true
+	case *NameExpr:
+		if u, ok := init.Name.Object.(*VarExpr); ok {
+			return printIsThis(u)
+		}
+	}
+	return false
+}
+
+// Expr writes e, with parentheses around it if its precedence is lower
+// than prec.
+func (ctx *printCtx) Expr(e Expr, prec int) {
+	e.print(ctx, prec)
+}
+
+// Paren writes an opening parenthesis if the expression being printed has
+// lower precedence than its context, and returns the function that closes
+// it.
+func (ctx *printCtx) Paren(own, prec int) func() {
+	if own >= prec {
+		return func() {}
+	}
+	ctx.WriteString("(")
+	return func() {
+		ctx.WriteString(")")
+	}
+}
+
+// Block writes e as a block expression, with each statement on its own
+// line.
+func (ctx *printCtx) Block(e Expr) {
+	ctx.WriteString("{")
+	ctx.indent++
+	ctx.Newline()
+	ctx.Statements(e)
+	ctx.indent--
+	ctx.Newline()
+	ctx.WriteString("}")
+}
+
+// Statements writes the contents of a block. Local variables are only
+// written as declarations when the rest of the block is their scope.
+func (ctx *printCtx) Statements(e Expr) {
+	switch e := e.(type) {
+	case *ChainExpr:
+		ctx.preStatements(e.Pre)
+		ctx.Statements(e.Expr)
+	case *VarExpr:
+		if e.Inlined != nil && ctx.InlinedCall(e) {
+			return
+		}
+		if e.Inlined != nil {
+			ctx.WriteString("/*inlined from " + e.Inlined.Parent.Type.Name + "." + e.Inlined.Name.Name)
+			if e.NullCheck {
+				ctx.WriteString(", null checked")
+			}
+			if e.Downcast {
+				ctx.WriteString(", downcast")
+			}
+			ctx.WriteString("*/ ")
+		}
+		ctx.WriteString("var ")
+		end := ctx.Declare(e.Name)
+		ctx.Name(e.Name)
+		ctx.WriteString(" : " + e.Type.Name + " = ")
+		if e.Downcast {
+			// Cool has no cast without a check, so write the cast
+			// as a match that does the same thing.
+			cast := &MatchExpr{
+				Left: e.Init,
+			}
+			name := &Ident{
+				Name:   e.Name.Name,
+				Object: cast,
+			}
+			cast.Cases = []*Case{
+				{
+					Name: name,
+					Type: e.Type,
+					Body: &NameExpr{
+						Name: name,
+					},
+				},
+			}
+			ctx.Expr(cast, printAssign)
+		} else {
+			ctx.Expr(e.Init, printAssign)
+		}
+		ctx.WriteString(";")
+		ctx.Newline()
+		ctx.Statements(e.Body)
+		end()
+	default:
+		ctx.Expr(e, printAssign)
+	}
+}
+
+// InlinedCall writes the call that was inlined to make v instead of the
+// inlined body if the body can't be written in Cool: if it allocates an
+// object without running its constructor, or uses the attributes of an
+// object other than this. It returns false if the body can be written.
+func (ctx *printCtx) InlinedCall(v *VarExpr) bool {
+	_, alloc := v.Init.(*AllocExpr)
+	if !alloc && (printIsThis(v) || !printUsesAttributes(v.Body, v)) {
+		return false
+	}
+	return ctx.Call(v)
+}
+
+// Call writes the call that was inlined to make v. It returns false if the
+// arguments of the call can't be found.
+func (ctx *printCtx) Call(v *VarExpr) bool {
+	_, alloc := v.Init.(*AllocExpr)
+	if len(v.Args) != len(v.Inlined.Args) {
+		return false
+	}
+
+	// the arguments are the variables declared right after this, unless
+	// the optimizer removed them.
+	args := append([]Expr(nil), v.Args...)
+	body := v.Body
+	for i, a := range v.Inlined.Args {
+		if arg, ok := body.(*VarExpr); ok && arg.Name.Name == a.Name.Name {
+			args[i] = arg.Init
+			body = arg.Body
+		}
+	}
+
+	ctx.WriteString("/*inlined from " + v.Inlined.Parent.Type.Name + "." + v.Inlined.Name.Name + "*/ ")
+	if alloc {
+		ctx.WriteString("new " + v.Inlined.Name.Name)
+	} else {
+		ctx.Expr(v.Init, printCall)
+		ctx.WriteString("." + v.Inlined.Name.Name)
+	}
+	ctx.Args(args)
+	return true
+}
+
+// printUsesAttributes returns true if e reads or writes an attribute of the
+// object in v.
+func printUsesAttributes(e Expr, v *VarExpr) bool {
+	attributeOf := func(id *Ident) bool {
+		a, ok := id.Object.(*AttributeObject)
+		return ok && a.Object == v
+	}
+	uses := func(es ...Expr) bool {
+		for _, e := range es {
+			if printUsesAttributes(e, v) {
+				return true
+			}
+		}
+		return false
+	}
+
+	switch e := e.(type) {
+	case *NotExpr:
+		return uses(e.Expr)
+	case *NegativeExpr:
+		return uses(e.Expr)
+	case *IfExpr:
+		return uses(e.Cond, e.Then, e.Else)
+	case *WhileExpr:
+		return uses(e.Cond, e.Body)
+	case *LessOrEqualExpr:
+		return uses(e.Left, e.Right)
+	case *LessThanExpr:
+		return uses(e.Left, e.Right)
+	case *MultiplyExpr:
+		return uses(e.Left, e.Right)
+	case *DivideExpr:
+		return uses(e.Left, e.Right)
+	case *AddExpr:
+		return uses(e.Left, e.Right)
+	case *SubtractExpr:
+		return uses(e.Left, e.Right)
+	case *MatchExpr:
+		for _, c := range e.Cases {
+			if uses(c.Body) {
+				return true
+			}
+		}
+		return uses(e.Left)
+	case *DynamicCallExpr:
+		return uses(e.Recv) || uses(e.Args...)
+	case *SuperCallExpr:
+		return uses(e.Args...)
+	case *StaticCallExpr:
+		return uses(e.Recv) || uses(e.Args...)
+	case *AssignExpr:
+		return attributeOf(e.Name) || uses(e.Expr)
+	case *VarExpr:
+		return uses(e.Init, e.Body)
+	case *ChainExpr:
+		return uses(e.Pre, e.Expr)
+	case *NameExpr:
+		return attributeOf(e.Name)
+	}
+	return false
+}
+
+func (ctx *printCtx) preStatements(e Expr) {
+	if c, ok := e.(*ChainExpr); ok {
+		ctx.preStatements(c.Pre)
+		ctx.preStatements(c.Expr)
+		return
+	}
+
+	ctx.Expr(e, printAssign)
+	ctx.WriteString(";")
+	ctx.Newline()
+}
+
+func (ctx *printCtx) Args(args []Expr) {
+	ctx.WriteString("(")
+	for i, a := range args {
+		if i != 0 {
+			ctx.WriteString(", ")
+		}
+		ctx.Expr(a, printAssign)
+	}
+	ctx.WriteString(")")
+}
+
+func (c *Class) print(ctx *printCtx) {
+	ctx.WriteString("class " + c.Type.Name + "(")
+	for i, f := range c.Formals {
+		if i != 0 {
+			ctx.WriteString(", ")
+		}
+		ctx.WriteString("var " + strings.TrimPrefix(f.Name.Name, "'") + " : " + f.Type.Name)
+	}
+	ctx.WriteString(")")
+	if c.Extends.Type.Pos != token.NoPos {
+		ctx.WriteString(" extends " + c.Extends.Type.Name)
+		ctx.Args(c.Extends.Args)
+	}
+	ctx.WriteString(" {")
+	ctx.indent++
+
+	// attributes of the class and its parents are in scope everywhere
+	// in the class.
+	for p := c; p != nil && p != nativeClass; p = p.Extends.Type.Class {
+		for _, f := range p.Features {
+			if a, ok := f.(*Attribute); ok {
+				defer ctx.Declare(a.Name)()
+			}
+		}
+	}
+
+	features := c.Features
+	if c.Methods != nil {
+		// the first features are generated from the formals.
+		features = features[len(c.Formals):]
+	}
+	for _, f := range features {
+		ctx.Newline()
+		switch f := f.(type) {
+		case *Init:
+			ctx.Block(f.Expr)
+		case *Attribute:
+			ctx.WriteString("var " + f.Name.Name)
+			if _, ok := f.Init.(*NativeExpr); ok {
+				ctx.WriteString(" = native")
+			} else {
+				ctx.WriteString(" : " + f.Type.Name + " = ")
+				ctx.Expr(f.Init, printAssign)
+			}
+		case *Method:
+			if f.Name.Name == c.Type.Name {
+				f.printConstructor(ctx)
+				continue
+			}
+			f.print(ctx)
+		}
+		ctx.WriteString(";")
+	}
+
+	ctx.indent--
+	ctx.Newline()
+	ctx.WriteString("}\n")
+}
+
+func (m *Method) print(ctx *printCtx) {
+	if m.Override {
+		ctx.WriteString("override ")
+	}
+	ctx.WriteString("def " + m.Name.Name + "(")
+	for i, a := range m.Args {
+		if i != 0 {
+			ctx.WriteString(", ")
+		}
+		defer ctx.Declare(a.Name)()
+		ctx.Name(a.Name)
+		ctx.WriteString(" : " + a.Type.Name)
+	}
+	ctx.WriteString(") : " + m.Type.Name + " = ")
+	ctx.Expr(m.Body, printAssign)
+}
+
+// printConstructor writes the generated constructor as a comment.
+func (m *Method) printConstructor(ctx *printCtx) {
+	ctx.prefix, ctx.prefixDepth = "// ", ctx.indent
+	ctx.WriteString(ctx.prefix + "constructor:")
+	ctx.Newline()
+	m.print(ctx)
+	ctx.WriteString(";")
+	ctx.prefix, ctx.prefixDepth = "", 0
+}
+
+func (e *NotExpr) print(ctx *printCtx, prec int) {
+	defer ctx.Paren(printUnary, prec)()
+	ctx.WriteString("!")
+	ctx.Expr(e.Expr, printUnary)
+}
+
+func (e *NegativeExpr) print(ctx *printCtx, prec int) {
+	defer ctx.Paren(printUnary, prec)()
+	ctx.WriteString("-")
+	ctx.Expr(e.Expr, printUnary)
+}
+
+func (e *IfExpr) print(ctx *printCtx, prec int) {
+	defer ctx.Paren(printIf, prec)()
+	ctx.WriteString("if (")
+	ctx.Expr(e.Cond, printAssign)
+	ctx.WriteString(") ")
+	ctx.Expr(e.Then, printIf)
+	ctx.WriteString(" else ")
+	ctx.Expr(e.Else, printIf)
+}
+
+func (e *WhileExpr) print(ctx *printCtx, prec int) {
+	defer ctx.Paren(printIf, prec)()
+	ctx.WriteString("while (")
+	ctx.Expr(e.Cond, printAssign)
+	ctx.WriteString(") ")
+	ctx.Expr(e.Body, printIf)
+}
+
+func (e *BinaryOperator) print(ctx *printCtx, prec, own int, op string) {
+	defer ctx.Paren(own, prec)()
+	ctx.Expr(e.Left, own)
+	ctx.WriteString(" " + op + " ")
+	ctx.Expr(e.Right, own+1)
+}
+
+func (e *LessOrEqualExpr) print(ctx *printCtx, prec int) {
+	(*BinaryOperator)(e).print(ctx, prec, printCompare, "<=")
+}
+
+func (e *LessThanExpr) print(ctx *printCtx, prec int) {
+	(*BinaryOperator)(e).print(ctx, prec, printCompare, "<")
+}
+
+func (e *MultiplyExpr) print(ctx *printCtx, prec int) {
+	(*BinaryOperator)(e).print(ctx, prec, printMultiply, "*")
+}
+
+func (e *DivideExpr) print(ctx *printCtx, prec int) {
+	(*BinaryOperator)(e).print(ctx, prec, printMultiply, "/")
+}
+
+func (e *AddExpr) print(ctx *printCtx, prec int) {
+	(*BinaryOperator)(e).print(ctx, prec, printAdd, "+")
+}
+
+func (e *SubtractExpr) print(ctx *printCtx, prec int) {
+	(*BinaryOperator)(e).print(ctx, prec, printAdd, "-")
+}
+
+func (e *MatchExpr) print(ctx *printCtx, prec int) {
+	defer ctx.Paren(printMatch, prec)()
+	// the inlined body can have a narrower type than the call, which
+	// would make some of the cases unreachable.
+	if v, ok := e.Left.(*VarExpr); !ok || v.Inlined == nil || !ctx.Call(v) {
+		ctx.Expr(e.Left, printMatch)
+	}
+	ctx.WriteString(" match {")
+	ctx.indent++
+	for _, c := range e.Cases {
+		ctx.Newline()
+		end := func() {}
+		if c.Type.Name == "Null" && c.Name.Name == "null" {
+			ctx.WriteString("case null => ")
+		} else {
+			ctx.WriteString("case ")
+			end = ctx.Declare(c.Name)
+			ctx.Name(c.Name)
+			ctx.WriteString(" : " + c.Type.Name + " => ")
+		}
+		ctx.indent++
+		ctx.Statements(c.Body)
+		ctx.indent--
+		end()
+	}
+	ctx.indent--
+	ctx.Newline()
+	ctx.WriteString("}")
+}
+
+func (e *DynamicCallExpr) print(ctx *printCtx, prec int) {
+	if e.Name.Name == "equals" && e.Name.End == token.NoPos && e.Name.Pos != token.NoPos && len(e.Args) == 1 {
+		// x == y
+		defer ctx.Paren(printEqual, prec)()
+		ctx.Expr(e.Recv, printEqual)
+		ctx.WriteString(" == ")
+		ctx.Expr(e.Args[0], printEqual+1)
+		return
+	}
+
+	defer ctx.Paren(printCall, prec)()
+	if this, ok := e.Recv.(*ThisExpr); !ok || this.Pos != e.Name.Pos {
+		ctx.Expr(e.Recv, printCall)
+		ctx.WriteString(".")
+	}
+	if e.Name.Method != nil && !e.HasOverride && ctx.opt.OptDispatch {
+		ctx.WriteString("/*static*/")
+	}
+	ctx.WriteString(e.Name.Name)
+	ctx.Args(e.Args)
+}
+
+func (e *SuperCallExpr) print(ctx *printCtx, prec int) {
+	ctx.WriteString("super." + e.Name.Name)
+	ctx.Args(e.Args)
+}
+
+func (e *StaticCallExpr) print(ctx *printCtx, prec int) {
+	if alloc, ok := e.Recv.(*AllocExpr); ok && alloc.Type.Name == e.Name.Name {
+		ctx.WriteString("new " + alloc.Type.Name)
+		ctx.Args(e.Args)
+		return
+	}
+
+	defer ctx.Paren(printCall, prec)()
+	ctx.Expr(e.Recv, printCall)
+	ctx.WriteString("./*static*/" + e.Name.Name)
+	ctx.Args(e.Args)
+}
+
+func (e *AllocExpr) print(ctx *printCtx, prec int) {
+	// Cool has no syntax for an object whose constructor hasn't run, so
+	// this is written as new without arguments, which doesn't parse.
+	// InlinedCall writes the constructors that allocate one as calls.
+	ctx.WriteString("new " + e.Type.Name)
+}
+
+func (e *AssignExpr) print(ctx *printCtx, prec int) {
+	defer ctx.Paren(printAssign, prec)()
+	ctx.Variable(e.Name)
+	ctx.WriteString(" = ")
+	ctx.Expr(e.Expr, printAssign)
+}
+
+func (e *VarExpr) print(ctx *printCtx, prec int) {
+	if e.Inlined != nil && ctx.InlinedCall(e) {
+		return
+	}
+	ctx.Block(e)
+}
+
+func (e *ChainExpr) print(ctx *printCtx, prec int) {
+	ctx.Block(e)
+}
+
+func (e *ThisExpr) print(ctx *printCtx, prec int) {
+	ctx.WriteString("this")
+}
+
+func (e *NullExpr) print(ctx *printCtx, prec int) {
+	ctx.WriteString("null")
+}
+
+func (e *UnitExpr) print(ctx *printCtx, prec int) {
+	ctx.WriteString("()")
+}
+
+func (e *NameExpr) print(ctx *printCtx, prec int) {
+	ctx.Variable(e.Name)
+}
+
+var printStringEscapes = strings.NewReplacer(
+	"\x00", `\0`,
+	"\b", `\b`,
+	"\t", `\t`,
+	"\n", `\n`,
+	"\r", `\r`,
+	"\f", `\f`,
+	`"`, `\"`,
+	`\`, `\\`,
+)
+
+func (e *StringExpr) print(ctx *printCtx, prec int) {
+	ctx.WriteString(`"` + printStringEscapes.Replace(e.Lit.Str) + `"`)
+}
+
+func (e *BoolExpr) print(ctx *printCtx, prec int) {
+	ctx.WriteString(strconv.FormatBool(e.Lit.Bool))
+}
+
+func (e *IntExpr) print(ctx *printCtx, prec int) {
+	n := e.Lit.Int
+	if n >= 0 {
+		ctx.WriteString(strconv.Itoa(int(n)))
+		return
+	}
+
+	if n == -1<<31 {
+		// 2147483648 is too big to be an integer literal.
+		defer ctx.Paren(printAdd, prec)()
+		ctx.WriteString("-2147483647 - 1")
+		return
+	}
+	defer ctx.Paren(printUnary, prec)()
+	ctx.WriteString(strconv.Itoa(int(n)))
+}
+
+func (e *NativeExpr) print(ctx *printCtx, prec int) {
+	ctx.WriteString("native")
+}
+
+func (e *BadExpr) print(ctx *printCtx, prec int) {
+	ctx.WriteString("/*error*/()")
+}
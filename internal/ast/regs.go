@@ -0,0 +1,242 @@
+package ast
+
+import (
+	"sort"
+	"strconv"
+)
+
+// genRegisters are the registers that values can be kept in. %eax is not one
+// of them because every instruction passes values through it. The runtime
+// doesn't preserve any of them, so they are saved around each call.
+var genRegisters = [...]string{"%ebx", "%esi", "%edi", "%ecx", "%edx"}
+
+// genInterval is a temporary that has a home slot in the stack frame and
+// might be kept in a register instead.
+type genInterval struct {
+	// home is the offset of the slot from %ebp. The value is kept there
+	// if it has no register, and while a call would change the register.
+	home int
+	// words is the number of words in the home slot.
+	words int
+	// start is the position at which the value is first stored, and end
+	// is the position of the last time it is used, counting loops that
+	// use it as lasting until the end of the loop. Instruction i of the
+	// method reads its operands at position 2i, and stores its result at
+	// 2i+1.
+	start, end int
+	// weight is the number of times the value is used, with each loop
+	// making the uses inside it count 8 times as much.
+	weight int
+	// readOnly is true if the value is an argument, so the home slot is
+	// always up to date.
+	readOnly bool
+	// avoid is a register the value can't be kept in.
+	avoid string
+	// reg is the register the value is kept in, or "" for its home slot.
+	reg string
+}
+
+// Operand returns the assembly operand for the value.
+func (iv *genInterval) Operand() string {
+	if iv.reg != "" {
+		return iv.reg
+	}
+	return strconv.Itoa(iv.home) + "(%ebp)"
+}
+
+// genRegs allocates registers and home slots for the temporaries of one
+// method from the positions of the instructions that use them.
+type genRegs struct {
+	// intervals is every temporary in the method that isn't kept in
+	// %eax, in the order they are first stored.
+	intervals []*genInterval
+	// calls is the position of each call in the method, and the weight
+	// of saving a register around it.
+	calls []genCall
+	// slots is the number of words in the stack frame for the home
+	// slots.
+	slots int
+}
+
+type genCall struct {
+	pos, weight int
+}
+
+// genFactor is the weight of a use at the given loop depth.
+func genFactor(depth int) int {
+	f := 1
+	for i := 0; i < depth && i < 6; i++ {
+		f *= 8
+	}
+	return f
+}
+
+// Use records a use of iv at pos.
+func (iv *genInterval) Use(pos, depth int) {
+	if pos < iv.start {
+		iv.start = pos
+	}
+	if pos > iv.end {
+		iv.end = pos
+	}
+	iv.weight += genFactor(depth)
+}
+
+// Loop extends the values that are stored before the loop starting at
+// position start and used inside it to last until the loop ends at
+// position end, because they are needed again on the next iteration.
+func (r *genRegs) Loop(start, end int) bool {
+	changed := false
+	for _, iv := range r.intervals {
+		if iv.start < start && iv.end >= start && iv.end < end {
+			iv.end = end
+			changed = true
+		}
+	}
+	return changed
+}
+
+// Crosses returns true if a call happens while iv holds a value.
+func (r *genRegs) Crosses(iv *genInterval) bool {
+	for _, c := range r.calls {
+		if iv.start < c.pos && c.pos < iv.end {
+			return true
+		}
+	}
+	return false
+}
+
+// Busy returns true if reg holds a value during the instruction whose
+// operands are read at pos.
+func (r *genRegs) Busy(reg string, pos int) bool {
+	for _, iv := range r.intervals {
+		if iv.reg == reg && iv.start <= pos+1 && pos <= iv.end {
+			return true
+		}
+	}
+	return false
+}
+
+// Call returns the values that are kept in a register and used again after
+// a call at pos, so they must be saved around it.
+func (r *genRegs) Call(pos int) []*genInterval {
+	var live []*genInterval
+	for _, iv := range r.intervals {
+		if iv.reg != "" && iv.start < pos && pos < iv.end {
+			live = append(live, iv)
+		}
+	}
+	return live
+}
+
+// Allocate chooses registers for the values by linear scan: the values are
+// visited in the order they are first stored, and each one gets a register
+// that isn't held by a value that is still in use. When there is none, the
+// value that is used the least keeps its home slot instead.
+func (r *genRegs) Allocate() {
+	r.sort()
+
+	for _, iv := range r.intervals {
+		// a value that is saved and reloaded around a call more often
+		// than it is used is better off in its home slot.
+		for _, c := range r.calls {
+			if iv.start < c.pos && c.pos < iv.end {
+				if iv.readOnly {
+					iv.weight -= c.weight
+				} else {
+					iv.weight -= 2 * c.weight
+				}
+			}
+		}
+	}
+
+	var active []*genInterval
+	for _, iv := range r.intervals {
+		if iv.weight <= 0 || iv.words != 1 {
+			continue
+		}
+
+		n := 0
+		for _, a := range active {
+			if a.end >= iv.start && a.reg != "" {
+				active[n] = a
+				n++
+			}
+		}
+		active = active[:n]
+
+		for _, reg := range genRegisters {
+			if reg == iv.avoid {
+				continue
+			}
+			free := true
+			for _, a := range active {
+				if a.reg == reg {
+					free = false
+					break
+				}
+			}
+			if free {
+				iv.reg = reg
+				break
+			}
+		}
+
+		if iv.reg == "" {
+			var victim *genInterval
+			for _, a := range active {
+				if a.reg != iv.avoid && (victim == nil || a.weight < victim.weight) {
+					victim = a
+				}
+			}
+			if victim == nil || victim.weight >= iv.weight {
+				continue
+			}
+			iv.reg, victim.reg = victim.reg, ""
+		}
+
+		active = append(active, iv)
+	}
+}
+
+// Homes gives a home slot to each value that needs one: the ones without a
+// register, and the ones that are saved around a call. Values that are never
+// in use at the same time share a slot.
+func (r *genRegs) Homes() {
+	r.sort()
+
+	// used is the end of the last value in each word of the frame.
+	var used []int
+	for _, iv := range r.intervals {
+		if iv.readOnly || (iv.reg != "" && !r.Crosses(iv)) {
+			continue
+		}
+		base := 0
+		for ; base < len(used); base++ {
+			free := true
+			for i := base; i < base+iv.words && i < len(used); i++ {
+				if used[i] >= iv.start {
+					free = false
+					break
+				}
+			}
+			if free {
+				break
+			}
+		}
+		for len(used) < base+iv.words {
+			used = append(used, -1)
+		}
+		for i := base; i < base+iv.words; i++ {
+			used[i] = iv.end
+		}
+		iv.home = -(base + iv.words) * 4
+	}
+	r.slots = len(used)
+}
+
+func (r *genRegs) sort() {
+	sort.SliceStable(r.intervals, func(i, j int) bool {
+		return r.intervals[i].start < r.intervals[j].start
+	})
+}
@@ -0,0 +1,148 @@
+package ast
+
+import (
+	"bytes"
+	"go/token"
+	"strconv"
+	"strings"
+)
+
+// Remark records an explanation of a decision made by the optimizer if
+// opt.OptReport is set and pos is in code written by the user. The codes
+// are:
+//
+//   - "devirtualized" and "virtual" for method calls that were or were not
+//     converted to static dispatch.
+//   - "inlined" and "not-inlined" for method calls that were or were not
+//     replaced with the body of the method.
+//   - "folded" for constant expressions that were computed at compile time.
+//   - "propagated" for reads of local variables whose value is known at
+//     compile time.
+//   - "dead-branch" for branches of if and while expressions that can
+//     never run.
+//   - "hoisted" for expressions that were moved out of a loop because they
+//     have the same value on every iteration.
+//   - "strength-reduced" for multiplications by a loop counter that were
+//     replaced with additions.
+//   - "null-check-removed" for method calls whose receiver is never null.
+//   - "scalar-replaced" for new expressions whose attributes were kept in
+//     stack slots instead of an object.
+//   - "stack-allocated" for Int arguments that were built in the caller's
+//     stack frame instead of on the heap.
+//   - "dead-class" and "dead-method" for classes that are never
+//     instantiated and methods that are never called, which have no code.
+//
+// Nothing is recorded while the body of a method is being optimized to be
+// inlined, as it is reported when the method itself is optimized.
+func (ctx *semCtx) Remark(code string, pos, end token.Pos, message string) {
+	if !ctx.opt.OptReport || len(ctx.inlining) != 0 || !pos.IsValid() || ctx.program.builtin[ctx.fset.File(pos)] {
+		return
+	}
+
+	ctx.diagnostics = append(ctx.diagnostics, &Diagnostic{
+		Severity: SeverityRemark,
+		Code:     code,
+		Pos:      pos,
+		End:      end,
+		Message:  message,
+	})
+}
+
+// RemarkFold records that before was computed at compile time.
+func (ctx *semCtx) RemarkFold(pos token.Pos, before Expr, after int32) {
+	if !ctx.opt.OptReport {
+		return
+	}
+
+	ctx.Remark("folded", pos, token.NoPos, "folded "+remarkExpr(before)+" to "+strconv.Itoa(int(after)))
+}
+
+// RemarkFoldBool records that the condition before was computed at compile
+// time.
+func (ctx *semCtx) RemarkFoldBool(pos token.Pos, before Expr, after bool) {
+	if !ctx.opt.OptReport {
+		return
+	}
+
+	ctx.Remark("folded", pos, token.NoPos, "folded "+remarkExpr(before)+" to "+strconv.FormatBool(after))
+}
+
+// remarkExpr returns e as Cool source code, for remarks.
+func remarkExpr(e Expr) string {
+	// the zero Options leaves out the /*static*/ comments.
+	p := &printCtx{
+		w: new(bytes.Buffer),
+
+		names: make(map[Object]string),
+		scope: make(map[string]int),
+	}
+	e.print(p, printAssign)
+
+	return p.w.String()
+}
+
+// remarkMethod returns the name of the method called by name, for remarks.
+func remarkMethod(name *Ident) string {
+	return remarkMethodName(name.Method)
+}
+
+func remarkMethodName(m *Method) string {
+	if class := m.Parent.Type.Name; class != m.Name.Name {
+		return class + "." + m.Name.Name
+	}
+	return "constructor " + m.Name.Name
+}
+
+// remarkDead records the classes that get no method table and the methods
+// that get no code because -opt-dead found that they can't be used.
+func (ctx *semCtx) remarkDead() {
+	if !ctx.opt.OptReport {
+		return
+	}
+
+	for _, c := range ctx.program.Classes {
+		if !ctx.program.live[c] {
+			ctx.Remark("dead-class", c.Type.Pos, c.Type.End, "left out the method table of "+c.Type.Name+": it is never instantiated")
+		}
+		for _, f := range c.Features {
+			if m, ok := f.(*Method); ok && !ctx.program.reached[m] {
+				ctx.Remark("dead-method", m.Name.Pos, m.Name.End, "left out the code for "+remarkMethodName(m)+": it is never called")
+			}
+		}
+	}
+}
+
+// remarkDispatch records whether the call to name can use static dispatch,
+// and if it can't, which subclasses of the receiver's type override the
+// method.
+func (ctx *semCtx) remarkDispatch(name *Ident, hasOverride bool) {
+	if !ctx.opt.OptReport {
+		return
+	}
+
+	method := remarkMethod(name)
+	if !hasOverride {
+		if ctx.opt.OptDispatch {
+			ctx.Remark("devirtualized", name.Pos, name.End, "call to "+method+" uses static dispatch: no subclass overrides it")
+		} else {
+			ctx.Remark("virtual", name.Pos, name.End, "call to "+method+" uses dynamic dispatch: -opt-dispatch is off")
+		}
+		return
+	}
+
+	var overrides []string
+	if recv := ctx.program.receivers[name]; recv != nil {
+		for i, m := range recv.Methods {
+			if m != name.Method {
+				continue
+			}
+			for _, sub := range ctx.program.Ordered[recv.Order:recv.MaxOrder] {
+				if sub.Methods[i].Parent == sub {
+					overrides = append(overrides, sub.Type.Name)
+				}
+			}
+		}
+	}
+
+	ctx.Remark("virtual", name.Pos, name.End, "call to "+method+" uses dynamic dispatch: overridden in "+strings.Join(overrides, ", "))
+}
@@ -0,0 +1,291 @@
+package ast
+
+// rtaCtx is the state of rapid type analysis, which finds the classes a
+// program can instantiate by following method calls from Program.Main. A
+// dynamic call only reaches the methods of classes that have been found
+// to be instantiated, so the set of classes grows with the set of methods
+// until neither changes.
+type rtaCtx struct {
+	program *Program
+
+	// builtin is the set of classes in the standard library.
+	builtin map[*Class]bool
+	// live is the set of classes the program can instantiate.
+	live map[*Class]bool
+	// reached is the set of methods the program can call.
+	reached map[*Method]bool
+	// calls is the set of method table offsets called dynamically on
+	// each static receiver type.
+	calls map[*Class]map[int]bool
+	// queue is the methods that have been reached but not yet walked.
+	queue []*Method
+}
+
+// rapidTypeAnalysis returns the set of classes that can be instantiated by
+// the program starting from Program.Main, and the set of methods that can be
+// called.
+func (ctx *semCtx) rapidTypeAnalysis() (live map[*Class]bool, reached map[*Method]bool) {
+	p := ctx.program
+	rta := &rtaCtx{
+		program: p,
+
+		builtin: make(map[*Class]bool),
+		live:    make(map[*Class]bool),
+		reached: make(map[*Method]bool),
+		calls:   make(map[*Class]map[int]bool),
+	}
+
+	for _, c := range p.Classes {
+		if p.builtin[ctx.fset.File(c.Type.Pos)] {
+			rta.builtin[c] = true
+		}
+	}
+
+	// the runtime creates objects without an AllocExpr for literals,
+	// for classes with native attributes, and as the return values of
+	// native methods.
+	rta.Alloc(ctx.unitClass)
+	for _, c := range p.Classes {
+		if !rta.builtin[c] {
+			continue
+		}
+
+		for _, f := range c.Features {
+			switch f := f.(type) {
+			case *Attribute:
+				if _, ok := f.Init.(*NativeExpr); ok {
+					rta.Alloc(c)
+				}
+			case *Method:
+				if _, ok := f.Body.(*NativeExpr); ok && f.Type.Class != nothingClass {
+					rta.Alloc(f.Type.Class)
+				}
+			}
+		}
+	}
+
+	// the scheduler in libcoolsched.a calls Runnable.run through the
+	// method table.
+	if runnable, ok := p.classMap["Runnable"]; ok && rta.builtin[runnable] {
+		for _, m := range runnable.Methods {
+			if m.Name.Name == "run" {
+				rta.Call(runnable, m.Order)
+			}
+		}
+	}
+
+	p.Main.rta(rta)
+
+	for len(rta.queue) != 0 {
+		m := rta.queue[0]
+		rta.queue = rta.queue[1:]
+		m.Body.rta(rta)
+	}
+
+	return rta.live, rta.reached
+}
+
+// Reach records that m can be called.
+func (ctx *rtaCtx) Reach(m *Method) {
+	if ctx.reached[m] {
+		return
+	}
+
+	ctx.reached[m] = true
+	ctx.queue = append(ctx.queue, m)
+}
+
+// Alloc records that c can be instantiated, and reaches the methods of c
+// that have already been called dynamically.
+func (ctx *rtaCtx) Alloc(c *Class) {
+	if ctx.live[c] {
+		return
+	}
+
+	ctx.live[c] = true
+
+	for p := c; p != nativeClass; p = p.Extends.Type.Class {
+		for order := range ctx.calls[p] {
+			ctx.Reach(c.Methods[order])
+		}
+	}
+}
+
+// Call records that the method at order in the method table of recv can be
+// called dynamically, and reaches it in each instantiated subclass.
+func (ctx *rtaCtx) Call(recv *Class, order int) {
+	if ctx.calls[recv][order] {
+		return
+	}
+
+	if ctx.calls[recv] == nil {
+		ctx.calls[recv] = make(map[int]bool)
+	}
+	ctx.calls[recv][order] = true
+
+	for _, c := range ctx.program.Ordered[recv.Order-1 : recv.MaxOrder] {
+		if ctx.live[c] {
+			ctx.Reach(c.Methods[order])
+		}
+	}
+}
+
+func (e *NotExpr) rta(ctx *rtaCtx) {
+	e.Expr.rta(ctx)
+}
+
+func (e *NegativeExpr) rta(ctx *rtaCtx) {
+	e.Expr.rta(ctx)
+}
+
+func (e *IfExpr) rta(ctx *rtaCtx) {
+	e.Cond.rta(ctx)
+	e.Then.rta(ctx)
+	e.Else.rta(ctx)
+}
+
+func (e *WhileExpr) rta(ctx *rtaCtx) {
+	e.Cond.rta(ctx)
+	e.Body.rta(ctx)
+}
+
+func (e *LessOrEqualExpr) rta(ctx *rtaCtx) {
+	e.Left.rta(ctx)
+	e.Right.rta(ctx)
+}
+
+func (e *LessThanExpr) rta(ctx *rtaCtx) {
+	e.Left.rta(ctx)
+	e.Right.rta(ctx)
+}
+
+func (e *MultiplyExpr) rta(ctx *rtaCtx) {
+	e.Left.rta(ctx)
+	e.Right.rta(ctx)
+}
+
+func (e *DivideExpr) rta(ctx *rtaCtx) {
+	e.Left.rta(ctx)
+	e.Right.rta(ctx)
+}
+
+func (e *AddExpr) rta(ctx *rtaCtx) {
+	e.Left.rta(ctx)
+	e.Right.rta(ctx)
+}
+
+func (e *SubtractExpr) rta(ctx *rtaCtx) {
+	e.Left.rta(ctx)
+	e.Right.rta(ctx)
+}
+
+func (e *MatchExpr) rta(ctx *rtaCtx) {
+	e.Left.rta(ctx)
+	for _, c := range e.Cases {
+		c.Body.rta(ctx)
+	}
+}
+
+func (e *DynamicCallExpr) rta(ctx *rtaCtx) {
+	e.Recv.rta(ctx)
+	for _, a := range e.Args {
+		a.rta(ctx)
+	}
+
+	if !e.HasOverride {
+		// every class that can receive the call uses the same method.
+		ctx.Reach(e.Name.Method)
+		return
+	}
+
+	recv := ctx.program.receivers[e.Name]
+	if recv == nil {
+		// a generated call; the class that declares the method is
+		// an ancestor of the receiver's type.
+		recv = e.Name.Method.Parent
+	}
+	ctx.Call(recv, e.Name.Method.Order)
+}
+
+func (e *SuperCallExpr) rta(ctx *rtaCtx) {
+	for _, a := range e.Args {
+		a.rta(ctx)
+	}
+	ctx.Reach(e.Name.Method)
+}
+
+func (e *StaticCallExpr) rta(ctx *rtaCtx) {
+	e.Recv.rta(ctx)
+	for _, a := range e.Args {
+		a.rta(ctx)
+	}
+	ctx.Reach(e.Name.Method)
+}
+
+func (e *AllocExpr) rta(ctx *rtaCtx) {
+	ctx.Alloc(e.Type.Class)
+}
+
+func (e *AssignExpr) rta(ctx *rtaCtx) {
+	e.Expr.rta(ctx)
+}
+
+func (e *VarExpr) rta(ctx *rtaCtx) {
+	e.Init.rta(ctx)
+	e.Body.rta(ctx)
+}
+
+func (e *ChainExpr) rta(ctx *rtaCtx) {
+	e.Pre.rta(ctx)
+	e.Expr.rta(ctx)
+}
+
+func (e *ThisExpr) rta(ctx *rtaCtx) {
+}
+
+func (e *NullExpr) rta(ctx *rtaCtx) {
+}
+
+func (e *UnitExpr) rta(ctx *rtaCtx) {
+}
+
+func (e *NameExpr) rta(ctx *rtaCtx) {
+}
+
+func (e *StringExpr) rta(ctx *rtaCtx) {
+}
+
+func (e *BoolExpr) rta(ctx *rtaCtx) {
+}
+
+func (e *IntExpr) rta(ctx *rtaCtx) {
+}
+
+func (e *NativeExpr) rta(ctx *rtaCtx) {
+}
+
+func (e *BadExpr) rta(ctx *rtaCtx) {
+}
+
+// rapidDispatch returns the only method a dynamic call to name can reach in
+// a class that rapid type analysis found to be instantiated, or nil if
+// there is more than one or the analysis wasn't done.
+func (ctx *semCtx) rapidDispatch(name *Ident) *Method {
+	recv := ctx.program.receivers[name]
+	if ctx.live == nil || recv == nil {
+		return nil
+	}
+
+	var target *Method
+	for _, c := range ctx.program.Ordered[recv.Order-1 : recv.MaxOrder] {
+		if !ctx.live[c] {
+			continue
+		}
+		if m := c.Methods[name.Method.Order]; target == nil {
+			target = m
+		} else if m != target {
+			return nil
+		}
+	}
+	return target
+}
@@ -1,8 +1,9 @@
 package ast
 
 import (
-	"fmt"
 	"go/token"
+	"strconv"
+	"strings"
 )
 
 var errorIdent = &Ident{
@@ -56,20 +57,62 @@ type semCtx struct {
 	fset       *token.FileSet
 	haveErrors bool
 
+	diagnostics []*Diagnostic
+
+	// reads is the number of times each object is read by a NameExpr.
+	reads map[Object]int
+	// nullable is the set of local variables that might be assigned null.
+	nullable map[Object]bool
+	// nullMatches are the matches on each local variable that have no
+	// case for null. They are reported when the variable's scope ends if
+	// the variable turned out to be nullable.
+	nullMatches map[Object][]*MatchExpr
+
 	anyClass     *Class
 	unitClass    *Class
 	mainClass    *Class
 	intClass     *Class
 	booleanClass *Class
 
-	inInline bool
+	// method is the method whose body is being optimized, if any.
+	method *Method
+	// inlining is the methods whose bodies are being optimized to be
+	// inlined into method, innermost last.
+	inlining []*Method
+
+	// live is the set of classes found by rapid type analysis to be
+	// instantiated by the program, or nil if -opt-dispatch is off.
+	live map[*Class]bool
+
+	// values is the value of each local variable that is known at the
+	// point being optimized: a literal, or another variable that holds
+	// the same value.
+	values map[Object]Expr
+	// loops is the while loops around the expression being optimized,
+	// innermost last.
+	loops []*semLoop
 
 	opt Options
 }
 
-func (ctx *semCtx) Report(pos token.Pos, message string) {
-	fmt.Fprintf(ctx.opt.Errors, "%v: %s\n", ctx.fset.Position(pos), message)
+// Report records an error. The returned Diagnostic can be used to attach
+// notes to the error before it is written at the end of Semant.
+func (ctx *semCtx) Report(code string, pos, end token.Pos, message string) *Diagnostic {
+	d := &Diagnostic{
+		Severity: SeverityError,
+		Code:     code,
+		Pos:      pos,
+		End:      end,
+		Message:  message,
+	}
+	ctx.diagnostics = append(ctx.diagnostics, d)
 	ctx.haveErrors = true
+	return d
+}
+
+// ReportIdent records an error about id.
+func (ctx *semCtx) ReportIdent(code string, id *Ident, message string) *Diagnostic {
+	return ctx.Report(code, id.Pos, id.End, message)
 }
 
 func (ctx *semCtx) LookupClass(id *Ident) {
@@ -78,14 +121,23 @@ func (ctx *semCtx) LookupClass(id *Ident) {
 		return
 	}
 
-	ctx.Report(id.Pos, "use of undeclared class "+id.Name)
+	var candidates []*Ident
+	for _, c := range ctx.program.Classes {
+		candidates = append(candidates, c.Type)
+	}
+	candidates = append(candidates, nothingClass.Type, nullClass.Type)
+
+	ctx.ReportIdent("undeclared-class", id, "use of undeclared class "+id.Name).
+		Suggest(id, candidates)
 	id.Class = errorClass
 }
 
 func (ctx *semCtx) FindRequiredClasses() {
 	ctx.anyClass = ctx.FindRequiredClass("Any")
 	ctx.unitClass = ctx.FindRequiredClass("Unit")
-	ctx.mainClass = ctx.FindRequiredClass("Main")
+	if !ctx.opt.Library {
+		ctx.mainClass = ctx.FindRequiredClass("Main")
+	}
 	ctx.intClass = ctx.FindRequiredClass("Int")
 	ctx.booleanClass = ctx.FindRequiredClass("Boolean")
 }
@@ -95,7 +147,7 @@ func (ctx *semCtx) FindRequiredClass(name string) *Class {
 		return c
 	}
 
-	ctx.Report(token.NoPos, "missing required class: "+name)
+	ctx.Report("missing-class", token.NoPos, token.NoPos, "missing required class: "+name)
 	return nil
 }
 
@@ -121,6 +173,14 @@ func (ctx *semCtx) Less(t1, t2 *Class) bool {
 	return false
 }
 
+// Check checks e and returns its static type. The type is also recorded so
+// that Program.StaticType can find it.
+func (ctx *semCtx) Check(e Expr, ids semantIdentifiers) *Class {
+	t := e.semantIdentifiers(ctx, ids)
+	ctx.program.types[e] = t
+	return t
+}
+
 func (ctx *semCtx) Lub(ts ...*Class) *Class {
 	t1 := nothingClass
 
@@ -158,17 +218,35 @@ func (ctx *semCtx) AssertLess(t1 *Class, id *Ident) {
 	t2 := id.Class
 
 	if !ctx.Less(t1, t2) {
-		ctx.Report(id.Pos, "type "+t1.Type.Name+" does not conform to type "+t2.Type.Name)
+		ctx.ReportIdent("type-mismatch", id, "type "+t1.Type.Name+" does not conform to type "+t2.Type.Name)
 	}
 }
 
+// Limits on inlining. A method is only inlined if the cost of its optimized
+// body is at most maxInlineCost, and calls in the body of an inlined method
+// are only inlined up to maxInlineDepth methods deep. Under -coroutine, each
+// variable an inlined call adds to the caller's stack frame costs
+// coroutineVarCost more, as runtime.morestack can't grow the stack of a
+// coroutine.
+const (
+	maxInlineCost    = 40
+	maxInlineDepth   = 3
+	coroutineVarCost = 10
+)
+
+// semantInline returns the body of the method called by name, with this and
+// the arguments replaced by local variables, if the call can be inlined. The
+// caller must know that the call is statically bound. If recv might be null,
+// the variable for this checks it.
 func semantInline(ctx *semCtx, recv Expr, name *Ident, args []Expr) (Expr, bool) {
-	if ctx.inInline {
-		return nil, false
-	}
+	m := name.Method
 
-	if ctx.opt.OptFold && name.Method.Name.Name == "length" && len(args) == 0 {
+	if ctx.opt.OptFold && m.Name.Name == "length" && len(args) == 0 {
 		if str, ok := recv.(*StringExpr); ok {
+			ctx.RemarkFold(name.Pos, &DynamicCallExpr{
+				Recv: str,
+				Name: name,
+			}, int32(len(str.Lit.Str)))
 			return &IntExpr{
 				Lit: &IntLit{
 					Pos:   str.Lit.Pos,
@@ -179,75 +257,241 @@ func semantInline(ctx *semCtx, recv Expr, name *Ident, args []Expr) (Expr, bool)
 		}
 	}
 
-	if !ctx.opt.OptInline {
+	if ctx.opt.OptFold && m.Name.Name == "equals" && m.Parent == ctx.intClass {
+		if li, ok := recv.(*IntExpr); ok {
+			if ri, ok := args[0].(*IntExpr); ok {
+				folded := li.Lit.Int == ri.Lit.Int
+				ctx.RemarkFoldBool(name.Pos, &DynamicCallExpr{
+					Recv: li,
+					Name: name,
+					Args: []Expr{ri},
+				}, folded)
+				return &BoolExpr{
+					Lit: &BoolLit{
+						Pos:   li.Lit.Pos,
+						Bool:  folded,
+						Class: ctx.booleanClass,
+					},
+				}, true
+			}
+		}
+	}
+
+	notInlined := func(reason string) (Expr, bool) {
+		ctx.Remark("not-inlined", name.Pos, name.End, "did not inline call to "+remarkMethod(name)+": "+reason)
 		return nil, false
 	}
 
-	wrap := func(expr Expr) (Expr, bool) {
-		for i := len(args) - 1; i >= 0; i-- {
-			a := name.Method.Args[i]
-			var v VarExpr
-			v = VarExpr{
-				Name: &Ident{
-					Pos:    a.Name.Pos,
-					Name:   a.Name.Name,
-					Object: &v,
-				},
-				Type: a.Type,
-				Init: args[i],
-				Body: expr.semantReplaceObject(ctx, a, &v),
-			}
-			expr = &v
+	if !ctx.opt.OptInline {
+		return notInlined("-opt-inline is off")
+	}
+
+	if _, ok := m.Body.(*NativeExpr); ok {
+		return notInlined("it is implemented natively")
+	}
+
+	if m == ctx.method {
+		return notInlined("it is recursive")
+	}
+	for _, outer := range ctx.inlining {
+		if m == outer {
+			return notInlined("it is recursive")
+		}
+	}
+
+	if len(ctx.inlining) >= maxInlineDepth {
+		return notInlined("calls are already inlined " + strconv.Itoa(maxInlineDepth) + " deep")
+	}
+
+	ctx.inlining = append(ctx.inlining, m)
+	// the inlined body's variables are declared inside any loop around
+	// the call, so its expressions can't be moved out of that loop.
+	values, loops := ctx.values, ctx.loops
+	ctx.values, ctx.loops = make(map[Object]Expr), nil
+	// every argument is computed before the body runs, so a formal can't
+	// be replaced by a variable that a later argument assigns.
+	later := make(map[Object]bool)
+	for i := len(args) - 1; i >= 0; i-- {
+		if n, ok := args[i].(*NameExpr); !ok || !later[n.Name.Object] {
+			ctx.Assign(m.Args[i], args[i])
+		}
+		for v := range ctx.Assigned(args[i]) {
+			later[v] = true
 		}
-		var this VarExpr
-		this = VarExpr{
+	}
+	expr := m.Body.semantOpt(ctx)
+	ctx.values, ctx.loops = values, loops
+	ctx.inlining = ctx.inlining[:len(ctx.inlining)-1]
+
+	cost, what := expr.semantCost(ctx), "its body costs "
+	if ctx.opt.Coroutine {
+		// the variables for this and the arguments.
+		cost += (1 + len(args)) * coroutineVarCost
+		what = "its body and the variables it adds to the stack frame cost "
+	}
+	if cost > maxInlineCost {
+		return notInlined(what + strconv.Itoa(cost) + ", more than the limit of " + strconv.Itoa(maxInlineCost))
+	}
+
+	ctx.Remark("inlined", name.Pos, name.End, "inlined call to "+remarkMethod(name))
+
+	// only the body is rewritten; the receiver and arguments belong to
+	// the caller.
+	this := &VarExpr{
+		Name: &Ident{
+			Pos:  name.Pos,
+			Name: "this",
+		},
+		Type: m.Parent.Type,
+		Init: recv,
+
+		Inlined:   m,
+		Args:      args,
+		NullCheck: !recv.semantGuaranteedNonNull(ctx),
+	}
+	if c := ctx.program.receivers[name]; c != nil && !ctx.Less(c, m.Parent) {
+		this.Downcast = true
+	}
+	this.Name.Object = this
+	expr = expr.semantReplaceObject(ctx, (*AttributeObject)(nil), this)
+
+	vars := make([]*VarExpr, len(args))
+	for i, a := range m.Args {
+		vars[i] = &VarExpr{
 			Name: &Ident{
-				Pos:    name.Pos,
-				Name:   "this",
-				Object: &this,
+				Pos:  a.Name.Pos,
+				Name: a.Name.Name,
 			},
-			Type: name.Method.Parent.Type,
-			Init: recv,
-			Body: expr.semantReplaceObject(ctx, (*AttributeObject)(nil), &this),
+			Type: a.Type,
+			Init: args[i],
 		}
-		return &this, true
+		vars[i].Name.Object = vars[i]
+		expr = expr.semantReplaceObject(ctx, a, vars[i])
 	}
 
-	ctx.inInline = true
-	body := name.Method.Body.semantOpt(ctx)
-	ctx.inInline = false
-
-	if l, ok := body.(*StringExpr); ok {
-		return wrap(l)
+	for i := len(vars) - 1; i >= 0; i-- {
+		vars[i].Body = expr
+		expr = vars[i]
 	}
+	this.Body = expr
+	return this, true
+}
 
-	if l, ok := body.(*IntExpr); ok {
-		return wrap(l)
+// semantPropagates returns true if reads of v can be replaced with value
+// after value is assigned to v. The value must be a literal or a parameter
+// or local variable, and must have the same type as v.
+func semantPropagates(v Object, value Expr) bool {
+	var t *Class
+	switch target := v.(type) {
+	case *VarExpr:
+		if target.Downcast {
+			return false
+		}
+		t = target.Type.Class
+	case *Formal:
+		t = target.Type.Class
+	default:
+		return false
 	}
 
-	if l, ok := body.(*BoolExpr); ok {
-		return wrap(l)
+	switch value := value.(type) {
+	case *IntExpr:
+		return value.Lit.Class == t
+	case *BoolExpr:
+		return value.Lit.Class == t
+	case *StringExpr:
+		return value.Lit.Class == t
+	case *NameExpr:
+		switch source := value.Name.Object.(type) {
+		case *Formal:
+			return source.Type.Class == t
+		case *VarExpr:
+			// assigning to source forgets the copy.
+			return source != v && !source.Downcast && source.Type.Class == t
+		}
 	}
+	return false
+}
 
-	if l, ok := body.(*UnitExpr); ok {
-		return wrap(l)
+// semantPure returns true if e has no side effects and can't fail, so it
+// doesn't need to be evaluated if its value is unused.
+func semantPure(e Expr) bool {
+	switch e.(type) {
+	case *IntExpr, *BoolExpr, *StringExpr, *NameExpr, *ThisExpr, *NullExpr, *UnitExpr:
+		return true
 	}
+	return false
+}
 
-	if l, ok := body.(*NullExpr); ok {
-		return wrap(l)
+// semantSameValue returns true if a and b are values from ctx.values that
+// are the same.
+func semantSameValue(a, b Expr) bool {
+	switch a := a.(type) {
+	case *IntExpr:
+		b, ok := b.(*IntExpr)
+		return ok && a.Lit.Int == b.Lit.Int
+	case *BoolExpr:
+		b, ok := b.(*BoolExpr)
+		return ok && a.Lit.Bool == b.Lit.Bool
+	case *StringExpr:
+		b, ok := b.(*StringExpr)
+		return ok && a.Lit.Str == b.Lit.Str
+	case *NameExpr:
+		b, ok := b.(*NameExpr)
+		return ok && a.Name.Object == b.Name.Object
 	}
+	return false
+}
 
-	if n, ok := body.(*NameExpr); ok {
-		return wrap(n)
+// Assign records that value was assigned to v.
+func (ctx *semCtx) Assign(v Object, value Expr) {
+	ctx.Forget(map[Object]bool{v: true})
+	if ctx.opt.OptFold && semantPropagates(v, value) && !ctx.LoopVar(value) {
+		ctx.values[v] = value
 	}
+}
 
-	if a, ok := body.(*AssignExpr); ok {
-		if _, ok := a.Expr.(*NameExpr); ok {
-			return wrap(a)
+// Forget removes the values of the assigned variables, and of the variables
+// that are copies of them.
+func (ctx *semCtx) Forget(assigned map[Object]bool) {
+	for v, value := range ctx.values {
+		if assigned[v] {
+			delete(ctx.values, v)
+		} else if n, ok := value.(*NameExpr); ok && assigned[n.Name.Object] {
+			delete(ctx.values, v)
 		}
 	}
+}
 
-	return nil, false
+// Assigned returns the variables and attributes that e assigns.
+func (ctx *semCtx) Assigned(e Expr) map[Object]bool {
+	loop := &semLoop{
+		assigned: make(map[Object]bool),
+		declared: make(map[Object]bool),
+		steps:    make(map[Object][]Expr),
+		other:    make(map[Object]bool),
+	}
+	e.semantAssigned(ctx, loop)
+	return loop.assigned
+}
+
+// SaveValues returns a copy of the known values, for optimizing a branch.
+func (ctx *semCtx) SaveValues() map[Object]Expr {
+	values := make(map[Object]Expr, len(ctx.values))
+	for v, value := range ctx.values {
+		values[v] = value
+	}
+	return values
+}
+
+// MergeValues keeps the known values that are the same in other, for the
+// point after two branches join.
+func (ctx *semCtx) MergeValues(other map[Object]Expr) {
+	for v, value := range ctx.values {
+		if o, ok := other[v]; !ok || !semantSameValue(value, o) {
+			delete(ctx.values, v)
+		}
+	}
 }
 
 func (p *Program) Semant(opt Options, fset *token.FileSet) bool {
@@ -255,14 +499,24 @@ func (p *Program) Semant(opt Options, fset *token.FileSet) bool {
 		program: p,
 		fset:    fset,
 		opt:     opt,
+
+		reads:       make(map[Object]int),
+		nullable:    make(map[Object]bool),
+		nullMatches: make(map[Object][]*MatchExpr),
 	}
+	p.receivers = make(map[*Ident]*Class)
+	p.types = make(map[Expr]*Class)
+	defer func() {
+		writeDiagnostics(opt, fset.Position, p.sources, ctx.WarningsIfNoErrors())
+	}()
+
 	p.classMap = map[string]*Class{
 		"Nothing": nothingClass,
 		"Null":    nullClass,
 	}
 
 	var mainRun *Method
-	if opt.Coroutine {
+	if opt.Coroutine && !opt.Library {
 		mainRun = &Method{
 			Override: true,
 			Name: &Ident{
@@ -313,8 +567,8 @@ func (p *Program) Semant(opt Options, fset *token.FileSet) bool {
 
 	for _, c := range p.Classes {
 		if o, ok := p.classMap[c.Type.Name]; ok {
-			ctx.Report(c.Type.Pos, "duplicate declaration of class "+c.Type.Name)
-			ctx.Report(o.Type.Pos, "(previous declaration was here)")
+			ctx.ReportIdent("duplicate-class", c.Type, "duplicate declaration of class "+c.Type.Name).
+				NoteIdent(o.Type, "previous declaration was here")
 		} else {
 			p.classMap[c.Type.Name] = c
 		}
@@ -369,7 +623,7 @@ func (p *Program) Semant(opt Options, fset *token.FileSet) bool {
 
 	for _, c := range p.Classes {
 		if _, ok := children[c]; ok {
-			ctx.Report(c.Type.Pos, "class heirarchy loop: "+c.Type.Name)
+			ctx.ReportIdent("class-loop", c.Type, "class heirarchy loop: "+c.Type.Name)
 		}
 	}
 
@@ -389,6 +643,15 @@ func (p *Program) Semant(opt Options, fset *token.FileSet) bool {
 		c.semantIdentifiers(ctx)
 	}
 
+	for _, c := range p.Classes {
+		c.semantUnusedAttributes(ctx)
+	}
+
+	if opt.Library {
+		// there's no entry point to check.
+		return ctx.haveErrors
+	}
+
 	p.Main = &StaticCallExpr{
 		Recv: &AllocExpr{
 			Type: &Ident{
@@ -547,15 +810,41 @@ func (p *Program) Semant(opt Options, fset *token.FileSet) bool {
 		return ctx.haveErrors
 	}
 
+	if opt.Dump != nil {
+		opt.Dump("checked")
+	}
+
+	if opt.OptDispatch {
+		ctx.live, _ = ctx.rapidTypeAnalysis()
+	}
+
 	for _, c := range p.Classes {
 		for _, f := range c.Features {
 			if m, ok := f.(*Method); ok {
+				ctx.method = m
+				ctx.values = make(map[Object]Expr)
 				m.Body = m.Body.semantOpt(ctx)
 			}
 		}
 	}
+	ctx.method = nil
+	ctx.values = make(map[Object]Expr)
 	p.Main = p.Main.semantOpt(ctx)
 
+	if opt.OptDead {
+		// inlining can leave methods that are never called.
+		p.live, p.reached = ctx.rapidTypeAnalysis()
+		ctx.remarkDead()
+	}
+
+	if opt.OptEscape {
+		p.borrowed = ctx.escapeAnalysis()
+	}
+
+	if opt.Dump != nil && !ctx.haveErrors {
+		opt.Dump("optimized")
+	}
+
 	return ctx.haveErrors
 }
 
@@ -586,6 +875,7 @@ func (c *Class) semantMakeConstructor(ctx *semCtx) {
 			Init: &NameExpr{
 				Name: &Ident{
 					Pos:  f.Name.Pos,
+					End:  f.Name.End,
 					Name: "'" + f.Name.Name,
 				},
 			},
@@ -594,6 +884,7 @@ func (c *Class) semantMakeConstructor(ctx *semCtx) {
 		}
 		f.Name = &Ident{
 			Pos:  f.Name.Pos,
+			End:  f.Name.End,
 			Name: "'" + f.Name.Name,
 		}
 	}
@@ -690,16 +981,16 @@ func (c *Class) semantMethods(ctx *semCtx) {
 	c.Methods = make([]*Method, len(parent.Methods))
 	copy(c.Methods, parent.Methods)
 
-	used := make(map[string]token.Pos)
+	used := make(map[string]*Ident)
 
 	for _, f := range c.Features {
 		if m, ok := f.(*Method); ok {
 			if o, ok := used[m.Name.Name]; ok {
-				ctx.Report(m.Name.Pos, "duplicate declaration of "+m.Name.Name)
-				ctx.Report(o, "(previous declaration was here)")
+				ctx.ReportIdent("duplicate-method", m.Name, "duplicate declaration of "+m.Name.Name).
+					NoteIdent(o, "previous declaration was here")
 				continue
 			}
-			used[m.Name.Name] = m.Name.Pos
+			used[m.Name.Name] = m.Name
 
 			if m.Name.Name == c.Type.Name {
 				// don't put the constructor in the method table
@@ -721,12 +1012,12 @@ func (c *Class) semantMethods(ctx *semCtx) {
 					m.Order = len(c.Methods)
 					c.Methods = append(c.Methods, m)
 				} else {
-					ctx.Report(m.Name.Pos, "missing 'override' on method "+c.Type.Name+"."+m.Name.Name)
-					ctx.Report(override.Name.Pos, "(previous declaration was here)")
+					ctx.ReportIdent("missing-override", m.Name, "missing 'override' on method "+c.Type.Name+"."+m.Name.Name).
+						NoteIdent(override.Name, "previous declaration was here")
 				}
 			} else {
 				if override == nil {
-					ctx.Report(m.Name.Pos, "missing parent for 'override' method "+c.Type.Name+"."+m.Name.Name)
+					ctx.ReportIdent("override-without-parent", m.Name, "missing parent for 'override' method "+c.Type.Name+"."+m.Name.Name)
 
 					// add the method anyway. we won't generate
 					// code, so this isn't a problem.
@@ -737,20 +1028,20 @@ func (c *Class) semantMethods(ctx *semCtx) {
 					c.Methods[override.Order] = m
 
 					if len(m.Args) != len(override.Args) {
-						ctx.Report(m.Name.Pos, "invalid override: method "+c.Type.Name+"."+m.Name.Name+" has the wrong number of arguments")
-						ctx.Report(override.Name.Pos, "(parent declaration is here)")
+						ctx.ReportIdent("override-argument-count", m.Name, "invalid override: method "+c.Type.Name+"."+m.Name.Name+" has the wrong number of arguments").
+							NoteIdent(override.Name, "parent declaration is here")
 					} else {
 						for i, a := range m.Args {
 							if t1, t2 := a.Type, override.Args[i].Type; t1.Class != t2.Class {
-								ctx.Report(t1.Pos, "invalid override: method "+c.Type.Name+"."+m.Name.Name+" has an incorrect argument type")
-								ctx.Report(t2.Pos, "(parent declaration is here)")
+								ctx.ReportIdent("override-argument-type", t1, "invalid override: method "+c.Type.Name+"."+m.Name.Name+" has an incorrect argument type").
+									NoteIdent(t2, "parent declaration is here")
 							}
 						}
 					}
 
 					if !ctx.Less(m.Type.Class, override.Type.Class) {
-						ctx.Report(m.Type.Pos, "invalid override: method "+c.Type.Name+"."+m.Name.Name+" has incompatible return type "+m.Type.Name)
-						ctx.Report(override.Type.Pos, "(parent return type is "+override.Type.Name+")")
+						ctx.ReportIdent("override-return-type", m.Type, "invalid override: method "+c.Type.Name+"."+m.Name.Name+" has incompatible return type "+m.Type.Name).
+							NoteIdent(override.Type, "parent return type is "+override.Type.Name)
 					}
 
 					for p := c.Extends.Type.Class; p != nativeClass; p = p.Extends.Type.Class {
@@ -785,6 +1076,23 @@ func (ids semantIdentifiers) Lookup(name string) *semantIdentifier {
 	return nil
 }
 
+// Names returns the names of the identifiers, innermost scope first.
+func (ids semantIdentifiers) Names() []*Ident {
+	names := make([]*Ident, len(ids))
+	for i, id := range ids {
+		names[len(ids)-1-i] = id.Name
+	}
+	return names
+}
+
+func methodNames(methods []*Method) []*Ident {
+	names := make([]*Ident, len(methods))
+	for i, m := range methods {
+		names[i] = m.Name
+	}
+	return names
+}
+
 func (c *Class) semantInheritedIdentifiers(report func(string)) semantIdentifiers {
 	if c == nativeClass {
 		return nil
@@ -808,28 +1116,28 @@ func (c *Class) semantInheritedIdentifiers(report func(string)) semantIdentifier
 
 func (c *Class) semantIdentifiers(ctx *semCtx) {
 	ids := c.Extends.Type.Class.semantInheritedIdentifiers(func(s string) {
-		ctx.Report(c.Extends.Type.Pos, s)
+		ctx.ReportIdent("cannot-extend", c.Extends.Type, s)
 	})
-	used := make(map[string]token.Pos)
+	used := make(map[string]*Ident)
 	for _, id := range ids {
-		used[id.Name.Name] = id.Name.Pos
+		used[id.Name.Name] = id.Name
 	}
 	for _, f := range c.Features {
 		if a, ok := f.(*Attribute); ok {
 			a.Name.Object = a
 			if a.Type.Class == nothingClass {
-				ctx.Report(a.Type.Pos, "cannot declare attribute of type Nothing")
+				ctx.ReportIdent("nothing-attribute", a.Type, "cannot declare attribute of type Nothing")
 			}
-			if pos, ok := used[a.Name.Name]; ok {
-				ctx.Report(a.Name.Pos, "duplicate declaration of "+a.Name.Name)
-				ctx.Report(pos, "(previous declaration was here)")
+			if o, ok := used[a.Name.Name]; ok {
+				ctx.ReportIdent("duplicate-attribute", a.Name, "duplicate declaration of "+a.Name.Name).
+					NoteIdent(o, "previous declaration was here")
 			} else {
 				ids = append(ids, &semantIdentifier{
 					Name:   a.Name,
 					Type:   a.Type,
 					Object: a,
 				})
-				used[a.Name.Name] = a.Name.Pos
+				used[a.Name.Name] = a.Name
 			}
 		}
 	}
@@ -841,6 +1149,18 @@ func (c *Class) semantIdentifiers(ctx *semCtx) {
 	}
 }
 
+func (c *Class) semantUnusedAttributes(ctx *semCtx) {
+	for _, f := range c.Features {
+		if a, ok := f.(*Attribute); ok && ctx.reads[a] == 0 {
+			if _, ok := a.Init.(*NativeExpr); ok {
+				continue
+			}
+
+			ctx.WarnIdent("unused-attribute", a.Name, "attribute "+a.Name.Name+" of class "+c.Type.Name+" is never read")
+		}
+	}
+}
+
 func (e *Extends) semantTypes(ctx *semCtx, c *Class) {
 	ctx.LookupClass(e.Type)
 	for _, a := range e.Args {
@@ -930,22 +1250,35 @@ func (f *Method) semantTypes(ctx *semCtx, c *Class) {
 }
 
 func (f *Method) semantIdentifiers(ctx *semCtx, ids semantIdentifiers) {
-	used := make(map[string]token.Pos)
+	f.Name.Method = f
+	used := make(map[string]*Ident)
 	for _, a := range f.Args {
+		a.Name.Object = a
 		if o, ok := used[a.Name.Name]; ok {
-			ctx.Report(a.Name.Pos, "duplicate declaration of "+a.Name.Name)
-			ctx.Report(o, "(previous declaration was here)")
+			ctx.ReportIdent("duplicate-argument", a.Name, "duplicate declaration of "+a.Name.Name).
+				NoteIdent(o, "previous declaration was here")
 		} else {
 			ids = append(ids, &semantIdentifier{
 				Name:   a.Name,
 				Type:   a.Type,
 				Object: a,
 			})
-			used[a.Name.Name] = a.Name.Pos
+			used[a.Name.Name] = a.Name
 		}
 	}
 
-	ctx.AssertLess(f.Body.semantIdentifiers(ctx, ids), f.Type)
+	ctx.AssertLess(ctx.Check(f.Body, ids), f.Type)
+
+	// parameters of methods that override or are overridden are part of
+	// an interface, so they may be needed by another implementation.
+	if _, ok := f.Body.(*NativeExpr); ok || f.Override || f.Name.Name == f.Parent.Type.Name || f.Parent.HasOverride[f.Order] {
+		return
+	}
+	for _, a := range f.Args {
+		if ctx.reads[a] == 0 {
+			ctx.WarnIdent("unused-param", a.Name, "parameter "+a.Name.Name+" of method "+f.Parent.Type.Name+"."+f.Name.Name+" is never read")
+		}
+	}
 }
 
 func (a *Formal) semantTypes(ctx *semCtx, c *Class) {
@@ -958,7 +1291,7 @@ func (e *NotExpr) semantTypes(ctx *semCtx, c *Class) {
 }
 
 func (e *NotExpr) semantIdentifiers(ctx *semCtx, ids semantIdentifiers) *Class {
-	ctx.AssertLess(e.Expr.semantIdentifiers(ctx, ids), e.Boolean)
+	ctx.AssertLess(ctx.Check(e.Expr, ids), e.Boolean)
 	return e.Boolean.Class
 }
 
@@ -966,8 +1299,28 @@ func (e *NotExpr) semantGuaranteedNonNull(ctx *semCtx) bool {
 	return true
 }
 
+func (e *NotExpr) semantCost(ctx *semCtx) int {
+	return 1 + e.Expr.semantCost(ctx)
+}
+
+func (e *NotExpr) semantAssigned(ctx *semCtx, loop *semLoop) {
+	e.Expr.semantAssigned(ctx, loop)
+}
+
 func (e *NotExpr) semantOpt(ctx *semCtx) Expr {
 	expr := e.Expr.semantOpt(ctx)
+	if b, ok := expr.(*BoolExpr); ok && ctx.opt.OptFold {
+		ctx.RemarkFoldBool(b.Lit.Pos, &NotExpr{
+			Expr: b,
+		}, !b.Lit.Bool)
+		return &BoolExpr{
+			Lit: &BoolLit{
+				Pos:   b.Lit.Pos,
+				Bool:  !b.Lit.Bool,
+				Class: b.Lit.Class,
+			},
+		}
+	}
 	if expr != e.Expr {
 		return &NotExpr{
 			Expr:    expr,
@@ -994,7 +1347,7 @@ func (e *NegativeExpr) semantTypes(ctx *semCtx, c *Class) {
 }
 
 func (e *NegativeExpr) semantIdentifiers(ctx *semCtx, ids semantIdentifiers) *Class {
-	ctx.AssertLess(e.Expr.semantIdentifiers(ctx, ids), e.Int)
+	ctx.AssertLess(ctx.Check(e.Expr, ids), e.Int)
 	return e.Int.Class
 }
 
@@ -1002,9 +1355,23 @@ func (e *NegativeExpr) semantGuaranteedNonNull(ctx *semCtx) bool {
 	return true
 }
 
+func (e *NegativeExpr) semantCost(ctx *semCtx) int {
+	return 1 + e.Expr.semantCost(ctx)
+}
+
+func (e *NegativeExpr) semantAssigned(ctx *semCtx, loop *semLoop) {
+	e.Expr.semantAssigned(ctx, loop)
+}
+
 func (e *NegativeExpr) semantOpt(ctx *semCtx) Expr {
 	expr := e.Expr.semantOpt(ctx)
 	if i, ok := expr.(*IntExpr); ok && ctx.opt.OptFold {
+		if expr != e.Expr {
+			// a negative literal is not worth mentioning.
+			ctx.RemarkFold(i.Lit.Pos, &NegativeExpr{
+				Expr: i,
+			}, -i.Lit.Int)
+		}
 		return &IntExpr{
 			Lit: &IntLit{
 				Pos:   i.Lit.Pos,
@@ -1041,18 +1408,39 @@ func (e *IfExpr) semantTypes(ctx *semCtx, c *Class) {
 }
 
 func (e *IfExpr) semantIdentifiers(ctx *semCtx, ids semantIdentifiers) *Class {
-	ctx.AssertLess(e.Cond.semantIdentifiers(ctx, ids), e.Boolean)
-	return ctx.Lub(e.Then.semantIdentifiers(ctx, ids), e.Else.semantIdentifiers(ctx, ids))
+	ctx.AssertLess(ctx.Check(e.Cond, ids), e.Boolean)
+	return ctx.Lub(ctx.Check(e.Then, ids), ctx.Check(e.Else, ids))
 }
 
 func (e *IfExpr) semantGuaranteedNonNull(ctx *semCtx) bool {
 	return e.Then.semantGuaranteedNonNull(ctx) && e.Else.semantGuaranteedNonNull(ctx)
 }
 
+func (e *IfExpr) semantCost(ctx *semCtx) int {
+	return 1 + e.Cond.semantCost(ctx) + e.Then.semantCost(ctx) + e.Else.semantCost(ctx)
+}
+
+func (e *IfExpr) semantAssigned(ctx *semCtx, loop *semLoop) {
+	e.Cond.semantAssigned(ctx, loop)
+	e.Then.semantAssigned(ctx, loop)
+	e.Else.semantAssigned(ctx, loop)
+}
+
 func (e *IfExpr) semantOpt(ctx *semCtx) Expr {
 	cond := e.Cond.semantOpt(ctx)
+	if b, ok := cond.(*BoolExpr); ok && ctx.opt.OptFold {
+		if b.Lit.Bool {
+			ctx.Remark("dead-branch", b.Lit.Pos, token.NoPos, "removed the else branch: the condition is always true")
+			return e.Then.semantOpt(ctx)
+		}
+		ctx.Remark("dead-branch", b.Lit.Pos, token.NoPos, "removed the then branch: the condition is always false")
+		return e.Else.semantOpt(ctx)
+	}
+	values := ctx.SaveValues()
 	then := e.Then.semantOpt(ctx)
+	values, ctx.values = ctx.values, values
 	els := e.Else.semantOpt(ctx)
+	ctx.MergeValues(values)
 	if cond != e.Cond || then != e.Then || els != e.Else {
 		return &IfExpr{
 			Cond:    cond,
@@ -1087,8 +1475,8 @@ func (e *WhileExpr) semantTypes(ctx *semCtx, c *Class) {
 }
 
 func (e *WhileExpr) semantIdentifiers(ctx *semCtx, ids semantIdentifiers) *Class {
-	ctx.AssertLess(e.Cond.semantIdentifiers(ctx, ids), e.Boolean)
-	e.Body.semantIdentifiers(ctx, ids)
+	ctx.AssertLess(ctx.Check(e.Cond, ids), e.Boolean)
+	ctx.Check(e.Body, ids)
 	return e.Unit.Class
 }
 
@@ -1096,18 +1484,45 @@ func (e *WhileExpr) semantGuaranteedNonNull(ctx *semCtx) bool {
 	return true
 }
 
+func (e *WhileExpr) semantCost(ctx *semCtx) int {
+	return 1 + e.Cond.semantCost(ctx) + e.Body.semantCost(ctx)
+}
+
+func (e *WhileExpr) semantAssigned(ctx *semCtx, loop *semLoop) {
+	e.Cond.semantAssigned(ctx, loop)
+	e.Body.semantAssigned(ctx, loop)
+}
+
 func (e *WhileExpr) semantOpt(ctx *semCtx) Expr {
+	loop := ctx.Loop(e)
+	// the condition runs again after the body, so anything the loop
+	// assigns is unknown in both.
+	ctx.Forget(loop.assigned)
+
+	ctx.loops = append(ctx.loops, loop)
 	cond := e.Cond.semantOpt(ctx)
+	if b, ok := cond.(*BoolExpr); ok && ctx.opt.OptFold && !b.Lit.Bool {
+		ctx.loops = ctx.loops[:len(ctx.loops)-1]
+		ctx.Remark("dead-branch", b.Lit.Pos, token.NoPos, "removed the loop body: the condition is always false")
+		return &UnitExpr{
+			Class: e.Unit.Class,
+		}
+	}
+	values := ctx.SaveValues()
 	body := e.Body.semantOpt(ctx)
+	ctx.values = values
+	ctx.loops = ctx.loops[:len(ctx.loops)-1]
+
+	var expr Expr = e
 	if cond != e.Cond || body != e.Body {
-		return &WhileExpr{
+		expr = &WhileExpr{
 			Cond:    cond,
 			Body:    body,
 			Boolean: e.Boolean,
 			Unit:    e.Unit,
 		}
 	}
-	return e
+	return loop.Wrap(ctx, expr)
 }
 
 func (e *WhileExpr) semantReplaceObject(ctx *semCtx, from, to Object) Expr {
@@ -1132,8 +1547,8 @@ func (e *LessOrEqualExpr) semantTypes(ctx *semCtx, c *Class) {
 }
 
 func (e *LessOrEqualExpr) semantIdentifiers(ctx *semCtx, ids semantIdentifiers) *Class {
-	ctx.AssertLess(e.Left.semantIdentifiers(ctx, ids), e.Int)
-	ctx.AssertLess(e.Right.semantIdentifiers(ctx, ids), e.Int)
+	ctx.AssertLess(ctx.Check(e.Left, ids), e.Int)
+	ctx.AssertLess(ctx.Check(e.Right, ids), e.Int)
 	return e.Boolean.Class
 }
 
@@ -1141,9 +1556,43 @@ func (e *LessOrEqualExpr) semantGuaranteedNonNull(ctx *semCtx) bool {
 	return true
 }
 
+func (e *LessOrEqualExpr) semantCost(ctx *semCtx) int {
+	return 1 + e.Left.semantCost(ctx) + e.Right.semantCost(ctx)
+}
+
+func (e *LessOrEqualExpr) semantAssigned(ctx *semCtx, loop *semLoop) {
+	e.Left.semantAssigned(ctx, loop)
+	e.Right.semantAssigned(ctx, loop)
+}
+
 func (e *LessOrEqualExpr) semantOpt(ctx *semCtx) Expr {
 	left := e.Left.semantOpt(ctx)
 	right := e.Right.semantOpt(ctx)
+	if li, ok := left.(*IntExpr); ok && ctx.opt.OptFold {
+		if ri, ok := right.(*IntExpr); ok {
+			folded := li.Lit.Int <= ri.Lit.Int
+			ctx.RemarkFoldBool(e.Pos, &LessOrEqualExpr{
+				Left:  li,
+				Right: ri,
+			}, folded)
+			return &BoolExpr{
+				Lit: &BoolLit{
+					Pos:   e.Pos,
+					Bool:  folded,
+					Class: e.Boolean.Class,
+				},
+			}
+		}
+	}
+	if loop := ctx.InvariantLoop(left, right); loop != nil {
+		return loop.Hoist(e.Pos, e.Boolean, &LessOrEqualExpr{
+			Left:    loop.Unhoist(left),
+			Right:   loop.Unhoist(right),
+			Pos:     e.Pos,
+			Boolean: e.Boolean,
+			Int:     e.Int,
+		})
+	}
 	if left != e.Left || right != e.Right {
 		return &LessOrEqualExpr{
 			Left:    left,
@@ -1179,8 +1628,8 @@ func (e *LessThanExpr) semantTypes(ctx *semCtx, c *Class) {
 }
 
 func (e *LessThanExpr) semantIdentifiers(ctx *semCtx, ids semantIdentifiers) *Class {
-	ctx.AssertLess(e.Left.semantIdentifiers(ctx, ids), e.Int)
-	ctx.AssertLess(e.Right.semantIdentifiers(ctx, ids), e.Int)
+	ctx.AssertLess(ctx.Check(e.Left, ids), e.Int)
+	ctx.AssertLess(ctx.Check(e.Right, ids), e.Int)
 	return e.Boolean.Class
 }
 
@@ -1188,9 +1637,43 @@ func (e *LessThanExpr) semantGuaranteedNonNull(ctx *semCtx) bool {
 	return true
 }
 
+func (e *LessThanExpr) semantCost(ctx *semCtx) int {
+	return 1 + e.Left.semantCost(ctx) + e.Right.semantCost(ctx)
+}
+
+func (e *LessThanExpr) semantAssigned(ctx *semCtx, loop *semLoop) {
+	e.Left.semantAssigned(ctx, loop)
+	e.Right.semantAssigned(ctx, loop)
+}
+
 func (e *LessThanExpr) semantOpt(ctx *semCtx) Expr {
 	left := e.Left.semantOpt(ctx)
 	right := e.Right.semantOpt(ctx)
+	if li, ok := left.(*IntExpr); ok && ctx.opt.OptFold {
+		if ri, ok := right.(*IntExpr); ok {
+			folded := li.Lit.Int < ri.Lit.Int
+			ctx.RemarkFoldBool(e.Pos, &LessThanExpr{
+				Left:  li,
+				Right: ri,
+			}, folded)
+			return &BoolExpr{
+				Lit: &BoolLit{
+					Pos:   e.Pos,
+					Bool:  folded,
+					Class: e.Boolean.Class,
+				},
+			}
+		}
+	}
+	if loop := ctx.InvariantLoop(left, right); loop != nil {
+		return loop.Hoist(e.Pos, e.Boolean, &LessThanExpr{
+			Left:    loop.Unhoist(left),
+			Right:   loop.Unhoist(right),
+			Pos:     e.Pos,
+			Boolean: e.Boolean,
+			Int:     e.Int,
+		})
+	}
 	if left != e.Left || right != e.Right {
 		return &LessThanExpr{
 			Left:    left,
@@ -1225,8 +1708,8 @@ func (e *MultiplyExpr) semantTypes(ctx *semCtx, c *Class) {
 }
 
 func (e *MultiplyExpr) semantIdentifiers(ctx *semCtx, ids semantIdentifiers) *Class {
-	ctx.AssertLess(e.Left.semantIdentifiers(ctx, ids), e.Int)
-	ctx.AssertLess(e.Right.semantIdentifiers(ctx, ids), e.Int)
+	ctx.AssertLess(ctx.Check(e.Left, ids), e.Int)
+	ctx.AssertLess(ctx.Check(e.Right, ids), e.Int)
 	return e.Int.Class
 }
 
@@ -1234,20 +1717,47 @@ func (e *MultiplyExpr) semantGuaranteedNonNull(ctx *semCtx) bool {
 	return true
 }
 
+func (e *MultiplyExpr) semantCost(ctx *semCtx) int {
+	return 1 + e.Left.semantCost(ctx) + e.Right.semantCost(ctx)
+}
+
+func (e *MultiplyExpr) semantAssigned(ctx *semCtx, loop *semLoop) {
+	loop.products = append(loop.products, e)
+	e.Left.semantAssigned(ctx, loop)
+	e.Right.semantAssigned(ctx, loop)
+}
+
 func (e *MultiplyExpr) semantOpt(ctx *semCtx) Expr {
+	if reduced, ok := ctx.Reduced(e); ok {
+		return reduced
+	}
+
 	left := e.Left.semantOpt(ctx)
 	right := e.Right.semantOpt(ctx)
 	if li, ok := left.(*IntExpr); ok && ctx.opt.OptFold {
 		if ri, ok := right.(*IntExpr); ok {
+			folded := li.Lit.Int * ri.Lit.Int
+			ctx.RemarkFold(e.Pos, &MultiplyExpr{
+				Left:  li,
+				Right: ri,
+			}, folded)
 			return &IntExpr{
 				Lit: &IntLit{
 					Pos:   e.Pos,
-					Int:   li.Lit.Int * ri.Lit.Int,
+					Int:   folded,
 					Class: li.Lit.Class,
 				},
 			}
 		}
 	}
+	if loop := ctx.InvariantLoop(left, right); loop != nil {
+		return loop.Hoist(e.Pos, e.Int, &MultiplyExpr{
+			Left:  loop.Unhoist(left),
+			Right: loop.Unhoist(right),
+			Pos:   e.Pos,
+			Int:   e.Int,
+		})
+	}
 	if left != e.Left || right != e.Right {
 		return &MultiplyExpr{
 			Left:  left,
@@ -1280,8 +1790,8 @@ func (e *DivideExpr) semantTypes(ctx *semCtx, c *Class) {
 }
 
 func (e *DivideExpr) semantIdentifiers(ctx *semCtx, ids semantIdentifiers) *Class {
-	ctx.AssertLess(e.Left.semantIdentifiers(ctx, ids), e.Int)
-	ctx.AssertLess(e.Right.semantIdentifiers(ctx, ids), e.Int)
+	ctx.AssertLess(ctx.Check(e.Left, ids), e.Int)
+	ctx.AssertLess(ctx.Check(e.Right, ids), e.Int)
 	return e.Int.Class
 }
 
@@ -1289,20 +1799,46 @@ func (e *DivideExpr) semantGuaranteedNonNull(ctx *semCtx) bool {
 	return true
 }
 
+func (e *DivideExpr) semantCost(ctx *semCtx) int {
+	return 1 + e.Left.semantCost(ctx) + e.Right.semantCost(ctx)
+}
+
+func (e *DivideExpr) semantAssigned(ctx *semCtx, loop *semLoop) {
+	e.Left.semantAssigned(ctx, loop)
+	e.Right.semantAssigned(ctx, loop)
+}
+
 func (e *DivideExpr) semantOpt(ctx *semCtx) Expr {
 	left := e.Left.semantOpt(ctx)
 	right := e.Right.semantOpt(ctx)
 	if li, ok := left.(*IntExpr); ok && ctx.opt.OptFold {
 		if ri, ok := right.(*IntExpr); ok && ri.Lit.Int != 0 {
+			folded := li.Lit.Int / ri.Lit.Int
+			ctx.RemarkFold(e.Pos, &DivideExpr{
+				Left:  li,
+				Right: ri,
+			}, folded)
 			return &IntExpr{
 				Lit: &IntLit{
 					Pos:   e.Pos,
-					Int:   li.Lit.Int / ri.Lit.Int,
+					Int:   folded,
 					Class: li.Lit.Class,
 				},
 			}
 		}
 	}
+	// x / 0 and -2147483648 / -1 stop the program, so they can't be
+	// moved to before a loop that might not run them.
+	if ri, ok := right.(*IntExpr); ok && ri.Lit.Int != 0 && ri.Lit.Int != -1 {
+		if loop := ctx.InvariantLoop(left, right); loop != nil {
+			return loop.Hoist(e.Pos, e.Int, &DivideExpr{
+				Left:  loop.Unhoist(left),
+				Right: right,
+				Pos:   e.Pos,
+				Int:   e.Int,
+			})
+		}
+	}
 	if left != e.Left || right != e.Right {
 		return &DivideExpr{
 			Left:  left,
@@ -1335,8 +1871,8 @@ func (e *AddExpr) semantTypes(ctx *semCtx, c *Class) {
 }
 
 func (e *AddExpr) semantIdentifiers(ctx *semCtx, ids semantIdentifiers) *Class {
-	ctx.AssertLess(e.Left.semantIdentifiers(ctx, ids), e.Int)
-	ctx.AssertLess(e.Right.semantIdentifiers(ctx, ids), e.Int)
+	ctx.AssertLess(ctx.Check(e.Left, ids), e.Int)
+	ctx.AssertLess(ctx.Check(e.Right, ids), e.Int)
 	return e.Int.Class
 }
 
@@ -1344,20 +1880,42 @@ func (e *AddExpr) semantGuaranteedNonNull(ctx *semCtx) bool {
 	return true
 }
 
+func (e *AddExpr) semantCost(ctx *semCtx) int {
+	return 1 + e.Left.semantCost(ctx) + e.Right.semantCost(ctx)
+}
+
+func (e *AddExpr) semantAssigned(ctx *semCtx, loop *semLoop) {
+	e.Left.semantAssigned(ctx, loop)
+	e.Right.semantAssigned(ctx, loop)
+}
+
 func (e *AddExpr) semantOpt(ctx *semCtx) Expr {
 	left := e.Left.semantOpt(ctx)
 	right := e.Right.semantOpt(ctx)
 	if li, ok := left.(*IntExpr); ok && ctx.opt.OptFold {
 		if ri, ok := right.(*IntExpr); ok {
+			folded := li.Lit.Int + ri.Lit.Int
+			ctx.RemarkFold(e.Pos, &AddExpr{
+				Left:  li,
+				Right: ri,
+			}, folded)
 			return &IntExpr{
 				Lit: &IntLit{
 					Pos:   e.Pos,
-					Int:   li.Lit.Int + ri.Lit.Int,
+					Int:   folded,
 					Class: li.Lit.Class,
 				},
 			}
 		}
 	}
+	if loop := ctx.InvariantLoop(left, right); loop != nil {
+		return loop.Hoist(e.Pos, e.Int, &AddExpr{
+			Left:  loop.Unhoist(left),
+			Right: loop.Unhoist(right),
+			Pos:   e.Pos,
+			Int:   e.Int,
+		})
+	}
 	if left != e.Left || right != e.Right {
 		return &AddExpr{
 			Left:  left,
@@ -1390,8 +1948,8 @@ func (e *SubtractExpr) semantTypes(ctx *semCtx, c *Class) {
 }
 
 func (e *SubtractExpr) semantIdentifiers(ctx *semCtx, ids semantIdentifiers) *Class {
-	ctx.AssertLess(e.Left.semantIdentifiers(ctx, ids), e.Int)
-	ctx.AssertLess(e.Right.semantIdentifiers(ctx, ids), e.Int)
+	ctx.AssertLess(ctx.Check(e.Left, ids), e.Int)
+	ctx.AssertLess(ctx.Check(e.Right, ids), e.Int)
 	return e.Int.Class
 }
 
@@ -1399,20 +1957,42 @@ func (e *SubtractExpr) semantGuaranteedNonNull(ctx *semCtx) bool {
 	return true
 }
 
+func (e *SubtractExpr) semantCost(ctx *semCtx) int {
+	return 1 + e.Left.semantCost(ctx) + e.Right.semantCost(ctx)
+}
+
+func (e *SubtractExpr) semantAssigned(ctx *semCtx, loop *semLoop) {
+	e.Left.semantAssigned(ctx, loop)
+	e.Right.semantAssigned(ctx, loop)
+}
+
 func (e *SubtractExpr) semantOpt(ctx *semCtx) Expr {
 	left := e.Left.semantOpt(ctx)
 	right := e.Right.semantOpt(ctx)
 	if li, ok := left.(*IntExpr); ok && ctx.opt.OptFold {
 		if ri, ok := right.(*IntExpr); ok {
+			folded := li.Lit.Int - ri.Lit.Int
+			ctx.RemarkFold(e.Pos, &SubtractExpr{
+				Left:  li,
+				Right: ri,
+			}, folded)
 			return &IntExpr{
 				Lit: &IntLit{
 					Pos:   e.Pos,
-					Int:   li.Lit.Int - ri.Lit.Int,
+					Int:   folded,
 					Class: li.Lit.Class,
 				},
 			}
 		}
 	}
+	if loop := ctx.InvariantLoop(left, right); loop != nil {
+		return loop.Hoist(e.Pos, e.Int, &SubtractExpr{
+			Left:  loop.Unhoist(left),
+			Right: loop.Unhoist(right),
+			Pos:   e.Pos,
+			Int:   e.Int,
+		})
+	}
 	if left != e.Left || right != e.Right {
 		return &SubtractExpr{
 			Left:  left,
@@ -1446,7 +2026,7 @@ func (e *MatchExpr) semantTypes(ctx *semCtx, c *Class) {
 }
 
 func (e *MatchExpr) semantIdentifiers(ctx *semCtx, ids semantIdentifiers) *Class {
-	left := e.Left.semantIdentifiers(ctx, ids)
+	left := ctx.Check(e.Left, ids)
 
 	possible := make(map[int]bool)
 	if left == nothingClass {
@@ -1469,14 +2049,97 @@ func (e *MatchExpr) semantIdentifiers(ctx *semCtx, ids semantIdentifiers) *Class
 		}
 	}
 
+	// only left and its children can actually reach the match.
+	reachable := make(map[int]bool)
+	for i := range possible {
+		if i == 0 || (i >= left.Order && i <= left.MaxOrder) {
+			reachable[i] = true
+		}
+	}
+
 	var ts []*Class
 	for _, c := range e.Cases {
 		ts = append(ts, c.semantIdentifiers(ctx, ids, e, possible))
 	}
 
+	e.semantExhaustive(ctx, left, reachable, possible)
+
 	return ctx.Lub(ts...)
 }
 
+// semantExhaustive warns if any type in reachable is still in uncovered after
+// every case has been checked. A class is listed instead of its children when
+// none of them are covered.
+//
+// Values of type Any come from containers like ArrayAny and Channel, and a
+// match on one is a cast to the type the program knows it put there, so they
+// are not checked. A missing case for null is reported separately as
+// match-null, because most values can't be proven non-null.
+func (e *MatchExpr) semantExhaustive(ctx *semCtx, left *Class, reachable, uncovered map[int]bool) {
+	if left == ctx.anyClass {
+		return
+	}
+	if left == nothingClass || e.Left.semantGuaranteedNonNull(ctx) {
+		delete(reachable, 0)
+	}
+	if reachable[0] && uncovered[0] {
+		e.semantNullMatch(ctx)
+	}
+
+	var missing []string
+	skip := 0
+	for _, c := range ctx.program.Ordered {
+		if c.Order <= skip || !reachable[c.Order] || !uncovered[c.Order] {
+			continue
+		}
+		missing = append(missing, c.Type.Name)
+
+		all := true
+		for i := c.Order; i <= c.MaxOrder; i++ {
+			if !uncovered[i] {
+				all = false
+				break
+			}
+		}
+		if all {
+			// a case for c would cover all of its children too.
+			skip = c.MaxOrder
+		}
+	}
+	if len(missing) == 0 {
+		return
+	}
+
+	var list string
+	switch len(missing) {
+	case 1:
+		list = missing[0]
+	case 2:
+		list = missing[0] + " or " + missing[1]
+	default:
+		list = strings.Join(missing[:len(missing)-1], ", ") + ", or " + missing[len(missing)-1]
+	}
+
+	ctx.Warn("incomplete-match", e.Pos, e.Pos+token.Pos(len("match")), "match has no case for "+list)
+}
+
+// semantNullMatch warns that e has no case for null. A match on a local
+// variable waits until the end of the variable's scope, when every assignment
+// to it has been seen.
+func (e *MatchExpr) semantNullMatch(ctx *semCtx) {
+	if name, ok := e.Left.(*NameExpr); ok {
+		if v, ok := name.Name.Object.(*VarExpr); ok {
+			ctx.nullMatches[v] = append(ctx.nullMatches[v], e)
+			return
+		}
+	}
+	e.semantWarnNull(ctx)
+}
+
+func (e *MatchExpr) semantWarnNull(ctx *semCtx) {
+	ctx.Warn("match-null", e.Pos, e.Pos+token.Pos(len("match")), "match has no case for null")
+}
+
 func (e *MatchExpr) semantGuaranteedNonNull(ctx *semCtx) bool {
 	for _, c := range e.Cases {
 		if !c.Body.semantGuaranteedNonNull(ctx) {
@@ -1486,12 +2149,38 @@ func (e *MatchExpr) semantGuaranteedNonNull(ctx *semCtx) bool {
 	return true
 }
 
+func (e *MatchExpr) semantCost(ctx *semCtx) int {
+	cost := 1 + e.Left.semantCost(ctx)
+	for _, c := range e.Cases {
+		cost += 1 + c.Body.semantCost(ctx)
+	}
+	return cost
+}
+
+func (e *MatchExpr) semantAssigned(ctx *semCtx, loop *semLoop) {
+	e.Left.semantAssigned(ctx, loop)
+	loop.declared[e] = true
+	for _, c := range e.Cases {
+		c.Body.semantAssigned(ctx, loop)
+	}
+}
+
 func (e *MatchExpr) semantOpt(ctx *semCtx) Expr {
 	left := e.Left.semantOpt(ctx)
 	cases := make([]*Case, len(e.Cases))
 	anyCase := false
+	// each case starts from the values known after left, and only the
+	// values all of the cases agree on are known after the match.
+	before := ctx.values
+	var after map[Object]Expr
 	for i, c := range e.Cases {
+		ctx.values = before
+		ctx.values = ctx.SaveValues()
 		body := c.Body.semantOpt(ctx)
+		if after != nil {
+			ctx.MergeValues(after)
+		}
+		after = ctx.values
 		if body != c.Body {
 			cases[i] = &Case{
 				Name: c.Name,
@@ -1503,6 +2192,9 @@ func (e *MatchExpr) semantOpt(ctx *semCtx) Expr {
 			cases[i] = c
 		}
 	}
+	if after != nil {
+		ctx.values = after
+	}
 	if left != e.Left || anyCase {
 		var m MatchExpr
 		for i, c := range cases {
@@ -1568,18 +2260,19 @@ func (e *DynamicCallExpr) semantTypes(ctx *semCtx, c *Class) {
 }
 
 func (e *DynamicCallExpr) semantIdentifiers(ctx *semCtx, ids semantIdentifiers) *Class {
-	left := e.Recv.semantIdentifiers(ctx, ids)
+	left := ctx.Check(e.Recv, ids)
+	ctx.program.receivers[e.Name] = left
 
 	for i, m := range left.Methods {
 		if m.Name.Name == e.Name.Name {
 			e.Name.Method = m
 
 			if len(m.Args) != len(e.Args) {
-				ctx.Report(e.Name.Pos, "wrong number of method arguments")
-				ctx.Report(m.Name.Pos, "(method is declared here)")
+				ctx.ReportIdent("argument-count", e.Name, "wrong number of method arguments").
+					NoteIdent(m.Name, "method is declared here")
 			} else {
 				for i, a := range e.Args {
-					ctx.AssertLess(a.semantIdentifiers(ctx, ids), m.Args[i].Type)
+					ctx.AssertLess(ctx.Check(a, ids), m.Args[i].Type)
 				}
 			}
 
@@ -1590,7 +2283,8 @@ func (e *DynamicCallExpr) semantIdentifiers(ctx *semCtx, ids semantIdentifiers)
 		}
 	}
 
-	ctx.Report(e.Name.Pos, "undeclared method "+left.Type.Name+"."+e.Name.Name)
+	ctx.ReportIdent("undeclared-method", e.Name, "undeclared method "+left.Type.Name+"."+e.Name.Name).
+		Suggest(e.Name, methodNames(left.Methods))
 	return nothingClass
 }
 
@@ -1601,6 +2295,22 @@ func (e *DynamicCallExpr) semantGuaranteedNonNull(ctx *semCtx) bool {
 	return false
 }
 
+func (e *DynamicCallExpr) semantCost(ctx *semCtx) int {
+	cost := 3 + e.Recv.semantCost(ctx)
+	for _, a := range e.Args {
+		cost += a.semantCost(ctx)
+	}
+	return cost
+}
+
+func (e *DynamicCallExpr) semantAssigned(ctx *semCtx, loop *semLoop) {
+	e.Recv.semantAssigned(ctx, loop)
+	for _, a := range e.Args {
+		a.semantAssigned(ctx, loop)
+	}
+	loop.Call(ctx, e.Name.Method, e.HasOverride)
+}
+
 func (e *DynamicCallExpr) semantOpt(ctx *semCtx) Expr {
 	recv := e.Recv.semantOpt(ctx)
 	args := make([]Expr, len(e.Args))
@@ -1620,10 +2330,43 @@ func (e *DynamicCallExpr) semantOpt(ctx *semCtx) Expr {
 			HasOverride: e.HasOverride,
 		}
 	}
-	if e.RecvNotNull && !e.HasOverride {
+	if e.HasOverride {
+		if m := ctx.rapidDispatch(e.Name); m != nil {
+			if m == e.Name.Method {
+				ctx.Remark("devirtualized", e.Name.Pos, e.Name.End, "call to "+remarkMethod(e.Name)+" uses static dispatch: no class that overrides it is instantiated")
+			} else {
+				ctx.Remark("devirtualized", e.Name.Pos, e.Name.End, "call to "+remarkMethod(e.Name)+" uses static dispatch to "+m.Parent.Type.Name+"."+m.Name.Name+": no other class that overrides it is instantiated")
+			}
+
+			name := &Ident{
+				Name:   e.Name.Name,
+				Pos:    e.Name.Pos,
+				End:    e.Name.End,
+				Method: m,
+			}
+			ctx.program.receivers[name] = ctx.program.receivers[e.Name]
+			e = &DynamicCallExpr{
+				Recv:        e.Recv,
+				Name:        name,
+				Args:        e.Args,
+				RecvNotNull: e.RecvNotNull,
+				HasOverride: false,
+			}
+		} else {
+			ctx.remarkDispatch(e.Name, true)
+		}
+	} else {
+		ctx.remarkDispatch(e.Name, false)
+	}
+	if !e.HasOverride {
 		if inl, ok := semantInline(ctx, e.Recv, e.Name, e.Args); ok {
 			return inl
 		}
+	} else {
+		ctx.Remark("not-inlined", e.Name.Pos, e.Name.End, "did not inline call to "+remarkMethod(e.Name)+": it uses dynamic dispatch")
+	}
+	if e.RecvNotNull {
+		ctx.Remark("null-check-removed", e.Name.Pos, e.Name.End, "removed null check before call to "+remarkMethod(e.Name)+": the receiver is never null")
 	}
 	return e
 }
@@ -1639,10 +2382,12 @@ func (e *DynamicCallExpr) semantReplaceObject(ctx *semCtx, from, to Object) Expr
 		}
 	}
 	if recv != e.Recv || anyArg {
+		// replacing a variable doesn't change whether it can be null.
 		return &DynamicCallExpr{
 			Recv:        recv,
 			Name:        e.Name,
 			Args:        args,
+			RecvNotNull: e.RecvNotNull,
 			HasOverride: e.HasOverride,
 		}
 	}
@@ -1662,16 +2407,17 @@ func (e *SuperCallExpr) semantTypes(ctx *semCtx, c *Class) {
 }
 
 func (e *SuperCallExpr) semantIdentifiers(ctx *semCtx, ids semantIdentifiers) *Class {
+	ctx.program.receivers[e.Name] = e.Class
 	for _, m := range e.Class.Methods {
 		if m.Name.Name == e.Name.Name {
 			e.Name.Method = m
 
 			if len(m.Args) != len(e.Args) {
-				ctx.Report(e.Name.Pos, "wrong number of method arguments")
-				ctx.Report(m.Name.Pos, "(method is declared here)")
+				ctx.ReportIdent("argument-count", e.Name, "wrong number of method arguments").
+					NoteIdent(m.Name, "method is declared here")
 			} else {
 				for i, a := range e.Args {
-					ctx.AssertLess(a.semantIdentifiers(ctx, ids), m.Args[i].Type)
+					ctx.AssertLess(ctx.Check(a, ids), m.Args[i].Type)
 				}
 			}
 
@@ -1679,7 +2425,8 @@ func (e *SuperCallExpr) semantIdentifiers(ctx *semCtx, ids semantIdentifiers) *C
 		}
 	}
 
-	ctx.Report(e.Name.Pos, "undeclared method "+e.Class.Type.Name+"."+e.Name.Name)
+	ctx.ReportIdent("undeclared-method", e.Name, "undeclared method "+e.Class.Type.Name+"."+e.Name.Name).
+		Suggest(e.Name, methodNames(e.Class.Methods))
 	return nothingClass
 }
 
@@ -1690,6 +2437,21 @@ func (e *SuperCallExpr) semantGuaranteedNonNull(ctx *semCtx) bool {
 	return false
 }
 
+func (e *SuperCallExpr) semantCost(ctx *semCtx) int {
+	cost := 2
+	for _, a := range e.Args {
+		cost += a.semantCost(ctx)
+	}
+	return cost
+}
+
+func (e *SuperCallExpr) semantAssigned(ctx *semCtx, loop *semLoop) {
+	for _, a := range e.Args {
+		a.semantAssigned(ctx, loop)
+	}
+	loop.Call(ctx, e.Name.Method, false)
+}
+
 func (e *SuperCallExpr) semantOpt(ctx *semCtx) Expr {
 	args := make([]Expr, len(e.Args))
 	anyArg := false
@@ -1748,18 +2510,18 @@ func (e *StaticCallExpr) semantTypes(ctx *semCtx, c *Class) {
 }
 
 func (e *StaticCallExpr) semantIdentifiers(ctx *semCtx, ids semantIdentifiers) *Class {
-	left := e.Recv.semantIdentifiers(ctx, ids)
+	left := ctx.Check(e.Recv, ids)
 
 	for _, f := range left.Features {
 		if m, ok := f.(*Method); ok && m.Name.Name == e.Name.Name {
 			e.Name.Method = m
 
 			if len(m.Args) != len(e.Args) {
-				ctx.Report(e.Name.Pos, "wrong number of method arguments")
-				ctx.Report(m.Name.Pos, "(method is declared here)")
+				ctx.ReportIdent("argument-count", e.Name, "wrong number of method arguments").
+					NoteIdent(m.Name, "method is declared here")
 			} else {
 				for i, a := range e.Args {
-					ctx.AssertLess(a.semantIdentifiers(ctx, ids), m.Args[i].Type)
+					ctx.AssertLess(ctx.Check(a, ids), m.Args[i].Type)
 				}
 			}
 
@@ -1767,7 +2529,14 @@ func (e *StaticCallExpr) semantIdentifiers(ctx *semCtx, ids semantIdentifiers) *
 		}
 	}
 
-	ctx.Report(e.Name.Pos, "undeclared method "+left.Type.Name+"."+e.Name.Name)
+	var methods []*Method
+	for _, f := range left.Features {
+		if m, ok := f.(*Method); ok {
+			methods = append(methods, m)
+		}
+	}
+	ctx.ReportIdent("undeclared-method", e.Name, "undeclared method "+left.Type.Name+"."+e.Name.Name).
+		Suggest(e.Name, methodNames(methods))
 	return nothingClass
 }
 
@@ -1781,6 +2550,22 @@ func (e *StaticCallExpr) semantGuaranteedNonNull(ctx *semCtx) bool {
 	return false
 }
 
+func (e *StaticCallExpr) semantCost(ctx *semCtx) int {
+	cost := 2 + e.Recv.semantCost(ctx)
+	for _, a := range e.Args {
+		cost += a.semantCost(ctx)
+	}
+	return cost
+}
+
+func (e *StaticCallExpr) semantAssigned(ctx *semCtx, loop *semLoop) {
+	e.Recv.semantAssigned(ctx, loop)
+	for _, a := range e.Args {
+		a.semantAssigned(ctx, loop)
+	}
+	loop.Call(ctx, e.Name.Method, false)
+}
+
 func (e *StaticCallExpr) semantOpt(ctx *semCtx) Expr {
 	recv := e.Recv.semantOpt(ctx)
 	args := make([]Expr, len(e.Args))
@@ -1836,6 +2621,13 @@ func (e *AllocExpr) semantGuaranteedNonNull(ctx *semCtx) bool {
 	return true
 }
 
+func (e *AllocExpr) semantCost(ctx *semCtx) int {
+	return 2
+}
+
+func (e *AllocExpr) semantAssigned(ctx *semCtx, loop *semLoop) {
+}
+
 func (e *AllocExpr) semantOpt(ctx *semCtx) Expr {
 	return e
 }
@@ -1851,12 +2643,17 @@ func (e *AssignExpr) semantTypes(ctx *semCtx, c *Class) {
 
 func (e *AssignExpr) semantIdentifiers(ctx *semCtx, ids semantIdentifiers) *Class {
 	if o := ids.Lookup(e.Name.Name); o == nil {
-		ctx.Report(e.Name.Pos, "undeclared identifier "+e.Name.Name)
+		ctx.ReportIdent("undeclared-identifier", e.Name, "undeclared identifier "+e.Name.Name).
+			Suggest(e.Name, ids.Names())
 	} else {
 		e.Name.Object = o.Object
-		ctx.AssertLess(e.Expr.semantIdentifiers(ctx, ids), o.Type)
+		t := ctx.Check(e.Expr, ids)
+		ctx.AssertLess(t, o.Type)
+		if t != nothingClass && !e.Expr.semantGuaranteedNonNull(ctx) {
+			ctx.nullable[o.Object] = true
+		}
 		if !o.Object.CanAssign() {
-			ctx.Report(e.Name.Pos, "cannot assign to "+e.Name.Name)
+			ctx.ReportIdent("cannot-assign", e.Name, "cannot assign to "+e.Name.Name)
 		}
 	}
 	return e.Unit.Class
@@ -1866,16 +2663,33 @@ func (e *AssignExpr) semantGuaranteedNonNull(ctx *semCtx) bool {
 	return true
 }
 
+func (e *AssignExpr) semantCost(ctx *semCtx) int {
+	return 1 + e.Expr.semantCost(ctx)
+}
+
+func (e *AssignExpr) semantAssigned(ctx *semCtx, loop *semLoop) {
+	loop.Assign(e)
+	e.Expr.semantAssigned(ctx, loop)
+}
+
 func (e *AssignExpr) semantOpt(ctx *semCtx) Expr {
 	expr := e.Expr.semantOpt(ctx)
+	ctx.Assign(e.Name.Object, expr)
+	var result Expr = e
 	if expr != e.Expr {
-		return &AssignExpr{
+		result = &AssignExpr{
 			Name: e.Name,
-			Expr: e.Expr,
+			Expr: expr,
 			Unit: e.Unit,
 		}
 	}
-	return e
+	for _, update := range ctx.Step(e) {
+		result = &ChainExpr{
+			Pre:  result,
+			Expr: update,
+		}
+	}
+	return result
 }
 
 func (e *AssignExpr) semantReplaceObject(ctx *semCtx, from, to Object) Expr {
@@ -1899,58 +2713,150 @@ func (e *VarExpr) semantTypes(ctx *semCtx, c *Class) {
 
 func (e *VarExpr) semantIdentifiers(ctx *semCtx, ids semantIdentifiers) *Class {
 	if o := ids.Lookup(e.Name.Name); o != nil {
-		ctx.Report(e.Name.Pos, "duplicate declaration of "+e.Name.Name)
-		ctx.Report(o.Name.Pos, "(previous declaration was here)")
+		ctx.ReportIdent("duplicate-variable", e.Name, "duplicate declaration of "+e.Name.Name).
+			NoteIdent(o.Name, "previous declaration was here")
 	} else {
 		e.Name.Object = e
-		ctx.AssertLess(e.Init.semantIdentifiers(ctx, ids), e.Type)
+		init := ctx.Check(e.Init, ids)
+		ctx.AssertLess(init, e.Type)
+		if init != nothingClass && !e.Init.semantGuaranteedNonNull(ctx) {
+			ctx.nullable[e] = true
+		}
 		ids = append(ids, &semantIdentifier{
 			Name:   e.Name,
 			Type:   e.Type,
 			Object: e,
 		})
 	}
-	return e.Body.semantIdentifiers(ctx, ids)
+	t := ctx.Check(e.Body, ids)
+	if e.Name.Object == e && ctx.reads[e] == 0 {
+		ctx.WarnIdent("unused-var", e.Name, "local variable "+e.Name.Name+" is never read")
+	}
+	if ctx.nullable[e] {
+		for _, m := range ctx.nullMatches[e] {
+			m.semantWarnNull(ctx)
+		}
+	}
+	delete(ctx.nullMatches, e)
+	return t
 }
 
 func (e *VarExpr) semantGuaranteedNonNull(ctx *semCtx) bool {
 	return e.Body.semantGuaranteedNonNull(ctx)
 }
 
+func (e *VarExpr) semantCost(ctx *semCtx) int {
+	cost := 1 + e.Init.semantCost(ctx) + e.Body.semantCost(ctx)
+	if ctx.opt.Coroutine {
+		cost += coroutineVarCost
+	}
+	return cost
+}
+
+func (e *VarExpr) semantAssigned(ctx *semCtx, loop *semLoop) {
+	loop.declared[e] = true
+	e.Init.semantAssigned(ctx, loop)
+	e.Body.semantAssigned(ctx, loop)
+}
+
 func (e *VarExpr) semantOpt(ctx *semCtx) Expr {
 	init := e.Init.semantOpt(ctx)
+	args := e.semantArgs(ctx)
+	ctx.Assign(e, init)
 	body := e.Body.semantOpt(ctx)
-	unused := body == body.semantReplaceObject(ctx, e, nil)
+	// the variable is out of scope, so copies of it are too.
+	ctx.Forget(map[Object]bool{e: true})
+	// the null check of an inlined receiver must stay even if the
+	// inlined body doesn't use this.
+	unused := body == body.semantReplaceObject(ctx, e, nil) && !e.NullCheck
+	if unused && ctx.opt.OptFold && semantPure(init) {
+		return body
+	}
 	if unused {
 		return &ChainExpr{
 			Pre:  init,
 			Expr: body,
 		}
 	}
-	if init != e.Init || body != e.Body {
+	if init != e.Init || body != e.Body || !semantSameExprs(args, e.Args) {
 		var v VarExpr
 		v = VarExpr{
 			Name: e.Name.semantReplaceObject(ctx, e, &v),
 			Type: e.Type,
 			Init: init,
 			Body: body.semantReplaceObject(ctx, e, &v),
+
+			Inlined:   e.Inlined,
+			Args:      args,
+			NullCheck: e.NullCheck,
+			Downcast:  e.Downcast,
 		}
 		return &v
 	}
 	return e
 }
 
+// semantArgs returns the arguments of an inlined call with the values of
+// the variables they read, since the optimizer can remove those variables.
+// The arguments are not optimized again, because they are only kept to print
+// the call.
+func (e *VarExpr) semantArgs(ctx *semCtx) []Expr {
+	var args []Expr
+	for i, a := range e.Args {
+		n, ok := a.(*NameExpr)
+		if !ok {
+			continue
+		}
+		value, ok := ctx.values[n.Name.Object]
+		if !ok {
+			continue
+		}
+		if args == nil {
+			args = append([]Expr(nil), e.Args...)
+		}
+		args[i] = value
+	}
+	if args == nil {
+		return e.Args
+	}
+	return args
+}
+
+func semantSameExprs(a, b []Expr) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
 func (e *VarExpr) semantReplaceObject(ctx *semCtx, from, to Object) Expr {
 	name := e.Name.semantReplaceObject(ctx, from, to)
 	init := e.Init.semantReplaceObject(ctx, from, to)
 	body := e.Body.semantReplaceObject(ctx, from, to)
-	if name != e.Name || init != e.Init || body != e.Body {
+	var args []Expr
+	if e.Args != nil {
+		args = make([]Expr, len(e.Args))
+		for i, a := range e.Args {
+			args[i] = a.semantReplaceObject(ctx, from, to)
+		}
+	}
+	if name != e.Name || init != e.Init || body != e.Body || !semantSameExprs(args, e.Args) {
 		var v VarExpr
 		v = VarExpr{
 			Name: name.semantReplaceObject(ctx, e, &v),
 			Type: e.Type,
 			Init: init,
 			Body: body.semantReplaceObject(ctx, e, &v),
+
+			Inlined:   e.Inlined,
+			Args:      args,
+			NullCheck: e.NullCheck,
+			Downcast:  e.Downcast,
 		}
 		return &v
 	}
@@ -1963,14 +2869,23 @@ func (e *ChainExpr) semantTypes(ctx *semCtx, c *Class) {
 }
 
 func (e *ChainExpr) semantIdentifiers(ctx *semCtx, ids semantIdentifiers) *Class {
-	e.Pre.semantIdentifiers(ctx, ids)
-	return e.Expr.semantIdentifiers(ctx, ids)
+	ctx.Check(e.Pre, ids)
+	return ctx.Check(e.Expr, ids)
 }
 
 func (e *ChainExpr) semantGuaranteedNonNull(ctx *semCtx) bool {
 	return e.Expr.semantGuaranteedNonNull(ctx)
 }
 
+func (e *ChainExpr) semantCost(ctx *semCtx) int {
+	return e.Pre.semantCost(ctx) + e.Expr.semantCost(ctx)
+}
+
+func (e *ChainExpr) semantAssigned(ctx *semCtx, loop *semLoop) {
+	e.Pre.semantAssigned(ctx, loop)
+	e.Expr.semantAssigned(ctx, loop)
+}
+
 func (e *ChainExpr) semantOpt(ctx *semCtx) Expr {
 	pre := e.Pre.semantOpt(ctx)
 	expr := e.Expr.semantOpt(ctx)
@@ -2007,6 +2922,13 @@ func (e *ThisExpr) semantGuaranteedNonNull(ctx *semCtx) bool {
 	return true
 }
 
+func (e *ThisExpr) semantCost(ctx *semCtx) int {
+	return 1
+}
+
+func (e *ThisExpr) semantAssigned(ctx *semCtx, loop *semLoop) {
+}
+
 func (e *ThisExpr) semantOpt(ctx *semCtx) Expr {
 	return e
 }
@@ -2035,6 +2957,13 @@ func (e *NullExpr) semantGuaranteedNonNull(ctx *semCtx) bool {
 	return false
 }
 
+func (e *NullExpr) semantCost(ctx *semCtx) int {
+	return 1
+}
+
+func (e *NullExpr) semantAssigned(ctx *semCtx, loop *semLoop) {
+}
+
 func (e *NullExpr) semantOpt(ctx *semCtx) Expr {
 	return e
 }
@@ -2060,6 +2989,13 @@ func (e *UnitExpr) semantGuaranteedNonNull(ctx *semCtx) bool {
 	return true
 }
 
+func (e *UnitExpr) semantCost(ctx *semCtx) int {
+	return 1
+}
+
+func (e *UnitExpr) semantAssigned(ctx *semCtx, loop *semLoop) {
+}
+
 func (e *UnitExpr) semantOpt(ctx *semCtx) Expr {
 	return e
 }
@@ -2074,9 +3010,11 @@ func (e *NameExpr) semantTypes(ctx *semCtx, c *Class) {
 func (e *NameExpr) semantIdentifiers(ctx *semCtx, ids semantIdentifiers) *Class {
 	if o := ids.Lookup(e.Name.Name); o != nil {
 		e.Name.Object = o.Object
+		ctx.reads[o.Object]++
 		return o.Type.Class
 	}
-	ctx.Report(e.Name.Pos, "undeclared identifier "+e.Name.Name)
+	ctx.ReportIdent("undeclared-identifier", e.Name, "undeclared identifier "+e.Name.Name).
+		Suggest(e.Name, ids.Names())
 	return nothingClass
 }
 
@@ -2084,8 +3022,62 @@ func (e *NameExpr) semantGuaranteedNonNull(ctx *semCtx) bool {
 	return e.Name.Object.NonNull(ctx)
 }
 
+func (e *NameExpr) semantCost(ctx *semCtx) int {
+	return 1
+}
+
+func (e *NameExpr) semantAssigned(ctx *semCtx, loop *semLoop) {
+}
+
 func (e *NameExpr) semantOpt(ctx *semCtx) Expr {
-	return e
+	value, ok := ctx.values[e.Name.Object]
+	if !ok {
+		if a, ok := e.Name.Object.(*Attribute); ok {
+			if loop := ctx.InvariantLoop(e); loop != nil {
+				return loop.Hoist(e.Name.Pos, a.Type, e)
+			}
+		}
+		return e
+	}
+
+	ctx.Remark("propagated", e.Name.Pos, e.Name.End, "replaced "+remarkExpr(e)+" with "+remarkExpr(value))
+
+	switch value := value.(type) {
+	case *IntExpr:
+		return &IntExpr{
+			Lit: &IntLit{
+				Pos:   e.Name.Pos,
+				Int:   value.Lit.Int,
+				Class: value.Lit.Class,
+			},
+		}
+	case *BoolExpr:
+		return &BoolExpr{
+			Lit: &BoolLit{
+				Pos:   e.Name.Pos,
+				Bool:  value.Lit.Bool,
+				Class: value.Lit.Class,
+			},
+		}
+	case *StringExpr:
+		return &StringExpr{
+			Lit: &StringLit{
+				Pos:   e.Name.Pos,
+				Str:   value.Lit.Str,
+				Class: value.Lit.Class,
+			},
+		}
+	case *NameExpr:
+		return &NameExpr{
+			Name: &Ident{
+				Name:   value.Name.Name,
+				Pos:    e.Name.Pos,
+				End:    e.Name.End,
+				Object: value.Name.Object,
+			},
+		}
+	}
+	panic("unreachable")
 }
 
 func (e *NameExpr) semantReplaceObject(ctx *semCtx, from, to Object) Expr {
@@ -2110,6 +3102,13 @@ func (e *StringExpr) semantGuaranteedNonNull(ctx *semCtx) bool {
 	return true
 }
 
+func (e *StringExpr) semantCost(ctx *semCtx) int {
+	return 1
+}
+
+func (e *StringExpr) semantAssigned(ctx *semCtx, loop *semLoop) {
+}
+
 func (e *StringExpr) semantOpt(ctx *semCtx) Expr {
 	return e
 }
@@ -2130,6 +3129,13 @@ func (e *BoolExpr) semantGuaranteedNonNull(ctx *semCtx) bool {
 	return true
 }
 
+func (e *BoolExpr) semantCost(ctx *semCtx) int {
+	return 1
+}
+
+func (e *BoolExpr) semantAssigned(ctx *semCtx, loop *semLoop) {
+}
+
 func (e *BoolExpr) semantOpt(ctx *semCtx) Expr {
 	return e
 }
@@ -2150,6 +3156,13 @@ func (e *IntExpr) semantGuaranteedNonNull(ctx *semCtx) bool {
 	return true
 }
 
+func (e *IntExpr) semantCost(ctx *semCtx) int {
+	return 1
+}
+
+func (e *IntExpr) semantAssigned(ctx *semCtx, loop *semLoop) {
+}
+
 func (e *IntExpr) semantOpt(ctx *semCtx) Expr {
 	return e
 }
@@ -2173,6 +3186,14 @@ func (e *NativeExpr) semantGuaranteedNonNull(ctx *semCtx) bool {
 	panic("NativeExpr.semantGuaranteedNonNull should never be called")
 }
 
+func (e *NativeExpr) semantCost(ctx *semCtx) int {
+	panic("NativeExpr.semantCost should never be called")
+}
+
+func (e *NativeExpr) semantAssigned(ctx *semCtx, loop *semLoop) {
+	panic("NativeExpr.semantAssigned should never be called")
+}
+
 func (e *NativeExpr) semantOpt(ctx *semCtx) Expr {
 	return e
 }
@@ -2181,6 +3202,32 @@ func (e *NativeExpr) semantReplaceObject(ctx *semCtx, from, to Object) Expr {
 	panic("NativeExpr.semantReplaceObject should never be called")
 }
 
+func (e *BadExpr) semantTypes(ctx *semCtx, c *Class) {
+}
+
+func (e *BadExpr) semantIdentifiers(ctx *semCtx, ids semantIdentifiers) *Class {
+	return nothingClass
+}
+
+func (e *BadExpr) semantGuaranteedNonNull(ctx *semCtx) bool {
+	return true
+}
+
+func (e *BadExpr) semantCost(ctx *semCtx) int {
+	return 1
+}
+
+func (e *BadExpr) semantAssigned(ctx *semCtx, loop *semLoop) {
+}
+
+func (e *BadExpr) semantOpt(ctx *semCtx) Expr {
+	return e
+}
+
+func (e *BadExpr) semantReplaceObject(ctx *semCtx, from, to Object) Expr {
+	return e
+}
+
 func (l *IntLit) semantTypes(ctx *semCtx, c *Class) {
 	i := Ident{
 		Pos:  l.Pos,
@@ -2237,16 +3284,19 @@ func (a *Case) semantIdentifiers(ctx *semCtx, ids semantIdentifiers, m *MatchExp
 	}
 
 	if !any {
-		ctx.Report(a.Type.Pos, "unreachable case for type "+a.Type.Name)
+		ctx.ReportIdent("unreachable-case", a.Type, "unreachable case for type "+a.Type.Name)
 	}
 
+	if left != nullClass {
+		a.Name.Object = m
+	}
 	ids = append(ids, &semantIdentifier{
 		Name:   a.Name,
 		Type:   a.Type,
 		Object: m,
 	})
 
-	return a.Body.semantIdentifiers(ctx, ids)
+	return ctx.Check(a.Body, ids)
 }
 
 func (i *Ident) semantReplaceObject(ctx *semCtx, from, to Object) *Ident {
@@ -2280,10 +3330,6 @@ func (i *Ident) semantReplaceObject(ctx *semCtx, from, to Object) *Ident {
 				},
 			}
 		}
-
-		if _, ok := i.Object.(*AttributeObject); ok {
-			panic("INTERNAL COMPILER ERROR: attempt to inline a method twice")
-		}
 	}
 
 	return i
@@ -0,0 +1,95 @@
+package ast
+
+// Suggest adds a note to d with the candidate that id was most likely meant
+// to be, if there is one.
+func (d *Diagnostic) Suggest(id *Ident, candidates []*Ident) {
+	s := suggest(id.Name, candidates)
+	if s == nil {
+		return
+	}
+
+	if s.Pos.IsValid() {
+		d.NoteIdent(s, "did you mean "+s.Name+"?")
+	} else {
+		d.NoteIdent(id, "did you mean "+s.Name+"?")
+	}
+}
+
+// suggest returns the candidate with the name most similar to name, or nil if
+// none of them are close enough to be a likely typo. Earlier candidates win
+// ties.
+func suggest(name string, candidates []*Ident) *Ident {
+	best, bestDistance := (*Ident)(nil), (len(name)+2)/3+1
+
+	for _, c := range candidates {
+		if c.Name == name || !validName(c.Name) {
+			continue
+		}
+
+		if d := editDistance(name, c.Name); d < bestDistance {
+			best, bestDistance = c, d
+		}
+	}
+
+	return best
+}
+
+// validName returns false for names generated by the compiler, which can't be
+// written in Cool code.
+func validName(name string) bool {
+	if name == "" {
+		return false
+	}
+	c := name[0]
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+// editDistance returns the number of single-byte insertions, deletions,
+// substitutions, and swaps of adjacent bytes needed to turn a into b. Changing
+// the case of a letter only counts as half of a change, but the result is
+// rounded up.
+func editDistance(a, b string) int {
+	// d[i][j] is twice the distance between a[:i] and b[:j].
+	d := make([][]int, len(a)+1)
+	for i := range d {
+		d[i] = make([]int, len(b)+1)
+		d[i][0] = i * 2
+	}
+	for j := range d[0] {
+		d[0][j] = j * 2
+	}
+
+	for i := 1; i <= len(a); i++ {
+		for j := 1; j <= len(b); j++ {
+			cost := 0
+			if a[i-1] != b[j-1] {
+				cost = 2
+				if lower(a[i-1]) == lower(b[j-1]) {
+					cost = 1
+				}
+			}
+
+			d[i][j] = d[i-1][j-1] + cost
+			if n := d[i-1][j] + 2; n < d[i][j] {
+				d[i][j] = n
+			}
+			if n := d[i][j-1] + 2; n < d[i][j] {
+				d[i][j] = n
+			}
+			if i > 1 && j > 1 && a[i-1] == b[j-2] && a[i-2] == b[j-1] {
+				if n := d[i-2][j-2] + 2; n < d[i][j] {
+					d[i][j] = n
+				}
+			}
+		}
+	}
+
+	return (d[len(a)][len(b)] + 1) / 2
+}
+
+func lower(c byte) byte {
+	if c >= 'A' && c <= 'Z' {
+		return c + 'a' - 'A'
+	}
+	return c
+}
@@ -1,9 +1,12 @@
 %{
-//go:generate go tool yacc -l syntax.y
+//go:generate goyacc -l syntax.y
 
 package ast
 
-import "go/token"
+import (
+	"go/token"
+	"strconv"
+)
 
 func init() {
 	yyErrorVerbose = true
@@ -12,9 +15,10 @@ func init() {
 
 %union {
 	pos token.Pos
+	// end is the position after the last byte of a token.
+	end token.Pos
 
 	cl  *Class
-	cls []*Class
 	ft  Feature
 	fts []Feature
 	fm  *Formal
@@ -31,7 +35,6 @@ func init() {
 	str *StringLit
 }
 
-%type<cls> classes
 %type<cl>  class
 %type<fts> feature_list
 %type<ft>  feature var method
@@ -44,7 +47,7 @@ func init() {
 %type<ca>  case
 %type<bin> boolean
 
-%token<pos> CLASS EXTENDS NATIVE VAR DEF OVERRIDE SUPER NEW ELSE NULL THIS ARROW CASE TRUE FALSE ILLEGAL '('
+%token<pos> CLASS EXTENDS NATIVE VAR DEF OVERRIDE SUPER NEW ELSE NULL THIS ARROW CASE TRUE FALSE ILLEGAL '(' '{'
 %token<id>  TYPEID OBJECTID
 %token<int> INTEGER
 %token<str> STRING
@@ -59,24 +62,37 @@ func init() {
 %left<pos> '!'
 %left<pos> '.'
 
+/* messages for syntax errors that only happen in one parser state. each one
+ * is the shortest sequence of tokens that leads to the error, followed by the
+ * unexpected token. %s is replaced with the source code of the unexpected
+ * token. errors that can happen anywhere in an expression, like a missing
+ * ';', are explained by lex.Error instead. */
+%error CLASS TYPEID '{': "missing parameter list after class name, found %s"
+%error CLASS TYPEID EXTENDS: "missing parameter list after class name, found %s"
+%error CLASS TYPEID '(' ')' '{' DEF OBJECTID ':': "missing parameter list after method name, found %s"
+%error CLASS TYPEID '(' ')' '{' DEF OBJECTID '(' ')' '=': "missing return type after method parameters, found %s"
+%error CLASS TYPEID '(' ')' '{' DEF OBJECTID '(' ')' ':' TYPEID '{': "missing '=' before method body, found %s"
+%error CLASS TYPEID '(' ')' '{' DEF OBJECTID '(' ')' ':' TYPEID '=' '{' VAR OBJECTID '=': "missing type in variable declaration, found %s"
+
 %%
 
 program
 : classes
-	{
-		p := yylex.(*lex).program
-		p.Classes = append(p.Classes, $1...)
-	}
 ;
 
+/* classes are added to the program as soon as they are parsed so that they
+ * are kept even if a later syntax error can't be recovered from. */
 classes
 : /* empty */
+| classes class
 	{
-		$$ = nil
+		p := yylex.(*lex).program
+		p.Classes = append(p.Classes, $2)
 	}
-| classes class
+| classes error class
 	{
-		$$ = append($1, $2)
+		p := yylex.(*lex).program
+		p.Classes = append(p.Classes, $3)
 	}
 ;
 
@@ -87,9 +103,26 @@ class
 			Type:     $2,
 			Formals:  $4,
 			Extends:  $6,
+			Doc:      yylex.(*lex).doc($1),
 			Features: $8,
 		}
 	}
+| CLASS TYPEID error '{' feature_list '}'
+	{
+		$$ = &Class{
+			Type:     $2,
+			Formals:  nil,
+			Extends:  &Extends{
+				Type: &Ident{
+					Name: "Any",
+					Pos:  token.NoPos,
+				},
+				Args: nil,
+			},
+			Doc:      yylex.(*lex).doc($1),
+			Features: $5,
+		}
+	}
 ;
 
 extends
@@ -131,6 +164,20 @@ feature_list
 	{
 		$$ = append($1, $2)
 	}
+| feature_list feature error
+	{
+		// lex.Error has reported the missing ';', and the feature
+		// itself is complete.
+		$$ = append($1, $2)
+	}
+| feature_list error ';'
+	{
+		$$ = $1
+	}
+| feature_list error feature ';'
+	{
+		$$ = append($1, $3)
+	}
 ;
 
 feature
@@ -152,6 +199,9 @@ feature
 	{
 		$$ = $2
 		$$.(*Method).Override = true
+		if doc := yylex.(*lex).doc($1); doc != "" {
+			$$.(*Method).Doc = doc
+		}
 	}
 ;
 
@@ -162,6 +212,7 @@ var
 			Name: $2,
 			Type: $4,
 			Init: $6,
+			Doc:  yylex.(*lex).doc($1),
 		}
 	}
 | VAR OBJECTID '=' NATIVE
@@ -175,6 +226,7 @@ var
 			Init: &NativeExpr{
 				Pos: $4,
 			},
+			Doc: yylex.(*lex).doc($1),
 		}
 	}
 ;
@@ -187,6 +239,7 @@ method
 			Args: $4,
 			Type: $7,
 			Body: $9,
+			Doc:  yylex.(*lex).doc($1),
 		}
 	}
 | DEF OBJECTID '(' formals ')' ':' TYPEID '=' NATIVE
@@ -198,6 +251,7 @@ method
 			Body: &NativeExpr{
 				Pos: $9,
 			},
+			Doc: yylex.(*lex).doc($1),
 		}
 	}
 ;
@@ -242,6 +296,16 @@ block_nonempty
 	{
 		$$ = $1
 	}
+| error
+	{
+		$$ = &BadExpr{
+			Pos: yylex.(*lex).Pos(),
+		}
+	}
+| error ';' block_nonempty
+	{
+		$$ = $3
+	}
 | VAR OBJECTID ':' TYPEID '=' expr ';' block_nonempty
 	{
 		$$ = &VarExpr{
@@ -250,6 +314,7 @@ block_nonempty
 			Init: $6,
 			Body: $8,
 		}
+		yylex.(*lex).span($$, $1, yylex.(*lex).exprEnd($8))
 	}
 | expr ';' block_nonempty
 	{
@@ -257,6 +322,7 @@ block_nonempty
 			Pre:  $1,
 			Expr: $3,
 		}
+		yylex.(*lex).span($$, yylex.(*lex).exprPos($1), yylex.(*lex).exprEnd($3))
 	}
 ;
 
@@ -337,6 +403,7 @@ expr
 				Pos:  $2,
 			},
 		}
+		yylex.(*lex).span($$, $1.Pos, yylex.(*lex).exprEnd($3))
 	}
 | '!' expr %prec '!'
 	{
@@ -348,6 +415,7 @@ expr
 				Pos:  $1,
 			},
 		}
+		yylex.(*lex).span($$, $1, yylex.(*lex).exprEnd($2))
 	}
 | '-' expr %prec '!'
 	{
@@ -359,6 +427,7 @@ expr
 				Pos:  $1,
 			},
 		}
+		yylex.(*lex).span($$, $1, yylex.(*lex).exprEnd($2))
 	}
 | IF '(' expr ')' expr ELSE expr %prec IF
 	{
@@ -372,6 +441,7 @@ expr
 				Pos:  $1,
 			},
 		}
+		yylex.(*lex).span($$, $1, yylex.(*lex).exprEnd($7))
 	}
 | WHILE '(' expr ')' expr %prec WHILE
 	{
@@ -388,6 +458,7 @@ expr
 				Pos:  $1,
 			},
 		}
+		yylex.(*lex).span($$, $1, yylex.(*lex).exprEnd($5))
 	}
 | expr LE expr %prec LE
 	{
@@ -405,6 +476,7 @@ expr
 				Pos:  $2,
 			},
 		}
+		yylex.(*lex).span($$, yylex.(*lex).exprPos($1), yylex.(*lex).exprEnd($3))
 	}
 | expr '<' expr %prec '<'
 	{
@@ -422,6 +494,7 @@ expr
 				Pos:  $2,
 			},
 		}
+		yylex.(*lex).span($$, yylex.(*lex).exprPos($1), yylex.(*lex).exprEnd($3))
 	}
 | expr EQ expr %prec EQ
 	{
@@ -435,6 +508,7 @@ expr
 				$3,
 			},
 		}
+		yylex.(*lex).span($$, yylex.(*lex).exprPos($1), yylex.(*lex).exprEnd($3))
 	}
 | expr '*' expr %prec '*'
 	{
@@ -448,6 +522,7 @@ expr
 				Pos:  $2,
 			},
 		}
+		yylex.(*lex).span($$, yylex.(*lex).exprPos($1), yylex.(*lex).exprEnd($3))
 	}
 | expr '/' expr %prec '/'
 	{
@@ -461,6 +536,7 @@ expr
 				Pos:  $2,
 			},
 		}
+		yylex.(*lex).span($$, yylex.(*lex).exprPos($1), yylex.(*lex).exprEnd($3))
 	}
 | expr '+' expr %prec '+'
 	{
@@ -474,6 +550,7 @@ expr
 				Pos:  $2,
 			},
 		}
+		yylex.(*lex).span($$, yylex.(*lex).exprPos($1), yylex.(*lex).exprEnd($3))
 	}
 | expr '-' expr %prec '-'
 	{
@@ -487,6 +564,7 @@ expr
 				Pos:  $2,
 			},
 		}
+		yylex.(*lex).span($$, yylex.(*lex).exprPos($1), yylex.(*lex).exprEnd($3))
 	}
 | expr MATCH '{' cases '}' %prec MATCH
 	{
@@ -495,6 +573,7 @@ expr
 			Left:  $1,
 			Cases: $4,
 		}
+		yylex.(*lex).span($$, yylex.(*lex).exprPos($1), $<end>5)
 	}
 | expr '.' OBJECTID '(' actuals ')' %prec '.'
 	{
@@ -503,6 +582,7 @@ expr
 			Name: $3,
 			Args: $5,
 		}
+		yylex.(*lex).span($$, yylex.(*lex).exprPos($1), $<end>6)
 	}
 ;
 
@@ -516,6 +596,7 @@ primary
 			Name: $1,
 			Args: $3,
 		}
+		yylex.(*lex).span($$, $1.Pos, $<end>4)
 	}
 | SUPER '.' OBJECTID '(' actuals ')'
 	{
@@ -524,6 +605,7 @@ primary
 			Name: $3,
 			Args: $5,
 		}
+		yylex.(*lex).span($$, $1, $<end>6)
 	}
 | NEW TYPEID '(' actuals ')'
 	{
@@ -536,60 +618,71 @@ primary
 			Name: &Ident{
 				Name: $2.Name,
 				Pos:  $2.Pos,
+				End:  $2.End,
 			},
 			Args: $4,
 			
 		}
+		yylex.(*lex).span($$, $1, $<end>5)
 	}
 | '{' block '}'
 	{
 		$$ = $2
+		yylex.(*lex).span($$, $1, $<end>3)
 	}
 | '(' expr ')'
 	{
 		$$ = $2
+		yylex.(*lex).span($$, $1, $<end>3)
 	}
 | NULL
 	{
 		$$ = &NullExpr{
 			Pos: $1,
 		}
+		yylex.(*lex).span($$, $1, $<end>1)
 	}
 | '(' ')'
 	{
 		$$ = &UnitExpr{
 			Pos: $1,
 		}
+		yylex.(*lex).span($$, $1, $<end>2)
 	}
 | OBJECTID
 	{
 		$$ = &NameExpr{
 			Name: $1,
 		}
+		yylex.(*lex).span($$, $1.Pos, $1.End)
 	}
 | INTEGER
 	{
 		$$ = &IntExpr{
 			Lit: $1,
 		}
+		yylex.(*lex).span($$, $1.Pos, $<end>1)
 	}
 | STRING
 	{
 		$$ = &StringExpr{
 			Lit: $1,
 		}
+		yylex.(*lex).span($$, $1.Pos, $<end>1)
 	}
 | boolean
 	{
 		$$ = &BoolExpr{
 			Lit: $1,
 		}
+		yylex.(*lex).span($$, $1.Pos, $1.Pos + token.Pos(len(strconv.FormatBool($1.Bool))))
 	}
 | THIS
 	{
 		$$ = &ThisExpr{
 			Pos: $1,
 		}
+		yylex.(*lex).span($$, $1, $<end>1)
 	}
 ;
 
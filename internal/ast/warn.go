@@ -0,0 +1,135 @@
+package ast
+
+import "go/token"
+
+// Warning is a kind of warning that can be turned on or off.
+type Warning struct {
+	// Name is used in the -W<name> and -Wno-<name> flags, and as the code
+	// of the warning's diagnostics.
+	Name string
+	// Doc is a short description of the problem the warning finds.
+	Doc string
+	// Default is true if the warning is on when no flags are given.
+	Default bool
+}
+
+// Warnings is every kind of warning the compiler can report.
+var Warnings = []*Warning{
+	{
+		Name:    "unused-var",
+		Doc:     "local variables that are never read",
+		Default: true,
+	},
+	{
+		Name:    "unused-param",
+		Doc:     "method parameters that are never read",
+		Default: true,
+	},
+	{
+		Name:    "unused-attribute",
+		Doc:     "attributes that are never read by their class or its subclasses",
+		Default: true,
+	},
+	{
+		Name:    "incomplete-match",
+		Doc:     "match expressions with no case for some possible types",
+		Default: true,
+	},
+	{
+		Name: "match-null",
+		Doc:  "match expressions with no case for null on a value that might be null",
+	},
+}
+
+func lookupWarning(name string) *Warning {
+	for _, w := range Warnings {
+		if w.Name == name {
+			return w
+		}
+	}
+	return nil
+}
+
+// WarningEnabled returns true if the warning with the given name should be
+// reported.
+func (opt Options) WarningEnabled(name string) bool {
+	if on, ok := opt.Warnings[name]; ok {
+		return on
+	}
+	if w := lookupWarning(name); w != nil {
+		return w.Default
+	}
+	return false
+}
+
+// WarningIsError returns true if the warning with the given name should be
+// reported as an error.
+func (opt Options) WarningIsError(name string) bool {
+	if isError, ok := opt.WarningErrors[name]; ok {
+		return isError
+	}
+	return opt.WarningErrors[""]
+}
+
+// MarkBuiltin records that f is part of the standard library. Warnings are
+// not reported for code in builtin files.
+func (p *Program) MarkBuiltin(f *token.File) {
+	if p.builtin == nil {
+		p.builtin = make(map[*token.File]bool)
+	}
+	p.builtin[f] = true
+}
+
+// Warn reports a warning if it is enabled and pos is in code written by the
+// user. The returned Diagnostic can be used to attach notes even if the
+// warning is not reported.
+func (ctx *semCtx) Warn(name string, pos, end token.Pos, message string) *Diagnostic {
+	d := &Diagnostic{
+		Severity: SeverityWarning,
+		Code:     name,
+		Pos:      pos,
+		End:      end,
+		Message:  message,
+	}
+
+	if !pos.IsValid() || ctx.program.builtin[ctx.fset.File(pos)] || !ctx.opt.WarningEnabled(name) {
+		return d
+	}
+
+	if ctx.opt.WarningIsError(name) {
+		d.Severity = SeverityError
+		ctx.haveErrors = true
+	}
+
+	ctx.diagnostics = append(ctx.diagnostics, d)
+	return d
+}
+
+// WarnIdent reports a warning about id.
+func (ctx *semCtx) WarnIdent(name string, id *Ident, message string) *Diagnostic {
+	return ctx.Warn(name, id.Pos, id.End, message)
+}
+
+// WarningsIfNoErrors returns the diagnostics without any warnings if there
+// were errors. An error can leave parts of the program unchecked, so warnings
+// such as unused variables are not reliable.
+func (ctx *semCtx) WarningsIfNoErrors() []*Diagnostic {
+	errors := ctx.program.haveSyntaxErrors
+	for _, d := range ctx.diagnostics {
+		if d.Severity == SeverityError && lookupWarning(d.Code) == nil {
+			errors = true
+			break
+		}
+	}
+	if !errors {
+		return ctx.diagnostics
+	}
+
+	var ds []*Diagnostic
+	for _, d := range ctx.diagnostics {
+		if lookupWarning(d.Code) == nil {
+			ds = append(ds, d)
+		}
+	}
+	return ds
+}
@@ -1,10 +1,14 @@
-//go:generate go tool yacc -l syntax.y
+// Code generated by goyacc -l syntax.y. DO NOT EDIT.
+//go:generate goyacc -l syntax.y
 
 package ast
 
 import __yyfmt__ "fmt"
 
-import "go/token"
+import (
+	"go/token"
+	"strconv"
+)
 
 func init() {
 	yyErrorVerbose = true
@@ -13,9 +17,10 @@ func init() {
 type yySymType struct {
 	yys int
 	pos token.Pos
+	// end is the position after the last byte of a token.
+	end token.Pos
 
 	cl  *Class
-	cls []*Class
 	ft  Feature
 	fts []Feature
 	fm  *Formal
@@ -79,6 +84,7 @@ var yyToknames = [...]string{
 	"FALSE",
 	"ILLEGAL",
 	"'('",
+	"'{'",
 	"TYPEID",
 	"OBJECTID",
 	"INTEGER",
@@ -97,169 +103,192 @@ var yyToknames = [...]string{
 	"'!'",
 	"'.'",
 	"')'",
-	"'{'",
 	"'}'",
 	"';'",
 	"':'",
 	"','",
 }
+
 var yyStatenames = [...]string{}
 
 const yyEofCode = 1
 const yyErrCode = 2
-const yyMaxDepth = 200
+const yyInitialStackSize = 16
 
-var yyExca = [...]int{
+var yyExca = [...]int16{
 	-1, 1,
 	1, -1,
 	-2, 0,
+	-1, 2,
+	1, 1,
+	-2, 0,
+	-1, 27,
+	40, 27,
+	-2, 0,
+	-1, 54,
+	40, 27,
+	-2, 0,
+	-1, 155,
+	16, 27,
+	40, 27,
+	-2, 0,
+	-1, 166,
+	16, 27,
+	40, 27,
+	-2, 0,
 }
 
-const yyNprod = 70
 const yyPrivate = 57344
 
-var yyTokenNames []string
-var yyStates []string
-
-const yyLast = 276
-
-var yyAct = [...]int{
-
-	56, 13, 55, 54, 115, 33, 42, 43, 126, 46,
-	50, 87, 62, 51, 52, 12, 45, 140, 37, 47,
-	48, 138, 40, 41, 108, 35, 18, 105, 86, 39,
-	53, 83, 38, 116, 82, 44, 97, 19, 144, 74,
-	75, 142, 135, 31, 32, 30, 81, 125, 80, 70,
-	63, 64, 65, 68, 69, 66, 67, 127, 71, 151,
-	118, 61, 150, 89, 90, 91, 92, 93, 94, 95,
-	96, 11, 71, 99, 35, 27, 25, 101, 102, 100,
-	66, 67, 57, 71, 78, 42, 43, 107, 46, 50,
-	113, 73, 51, 52, 136, 45, 72, 37, 47, 48,
-	147, 40, 41, 124, 130, 35, 14, 103, 39, 29,
-	122, 38, 98, 129, 44, 85, 60, 59, 35, 128,
-	132, 133, 35, 131, 21, 137, 146, 134, 139, 70,
-	63, 64, 65, 68, 69, 66, 67, 145, 71, 20,
-	58, 123, 84, 155, 149, 148, 109, 42, 43, 79,
-	46, 50, 154, 153, 51, 52, 156, 45, 121, 37,
-	47, 48, 22, 40, 41, 5, 117, 104, 88, 77,
-	39, 42, 43, 38, 46, 50, 44, 76, 51, 52,
-	24, 45, 6, 37, 47, 48, 116, 40, 41, 65,
-	68, 69, 66, 67, 39, 71, 152, 38, 141, 32,
-	44, 70, 63, 64, 65, 68, 69, 66, 67, 10,
-	71, 120, 70, 63, 64, 65, 68, 69, 66, 67,
-	143, 71, 119, 70, 63, 64, 65, 68, 69, 66,
-	67, 9, 71, 106, 110, 16, 70, 63, 64, 65,
-	68, 69, 66, 67, 17, 71, 70, 63, 64, 65,
-	68, 69, 66, 67, 4, 71, 68, 69, 66, 67,
-	1, 71, 49, 114, 34, 36, 15, 112, 111, 8,
-	7, 28, 26, 23, 3, 2,
+const yyLast = 300
+
+var yyAct = [...]uint8{
+	43, 17, 41, 139, 128, 126, 97, 16, 42, 52,
+	53, 154, 56, 60, 152, 111, 61, 62, 23, 55,
+	54, 94, 47, 57, 58, 80, 50, 51, 73, 76,
+	77, 74, 75, 49, 79, 68, 48, 93, 92, 78,
+	71, 72, 73, 76, 77, 74, 75, 26, 79, 84,
+	85, 163, 31, 32, 30, 38, 91, 90, 78, 71,
+	72, 73, 76, 77, 74, 75, 27, 79, 99, 159,
+	70, 129, 101, 102, 103, 104, 105, 106, 107, 100,
+	118, 69, 156, 112, 99, 96, 150, 114, 115, 110,
+	113, 44, 165, 138, 37, 141, 45, 124, 132, 52,
+	53, 125, 56, 60, 15, 79, 61, 62, 88, 55,
+	54, 19, 47, 57, 58, 29, 50, 51, 99, 74,
+	75, 146, 79, 49, 136, 137, 48, 140, 18, 116,
+	144, 99, 142, 83, 147, 148, 99, 145, 151, 82,
+	143, 153, 149, 109, 81, 66, 63, 157, 78, 71,
+	72, 73, 76, 77, 74, 75, 169, 79, 162, 164,
+	52, 53, 65, 56, 60, 64, 168, 61, 62, 170,
+	55, 54, 167, 47, 57, 58, 161, 50, 51, 160,
+	131, 25, 52, 53, 49, 56, 60, 48, 120, 61,
+	62, 108, 55, 54, 33, 47, 57, 58, 89, 50,
+	51, 36, 76, 77, 74, 75, 49, 79, 40, 48,
+	78, 71, 72, 73, 76, 77, 74, 75, 7, 79,
+	134, 78, 71, 72, 73, 76, 77, 74, 75, 158,
+	79, 133, 78, 71, 72, 73, 76, 77, 74, 75,
+	35, 79, 119, 31, 32, 30, 78, 71, 72, 73,
+	76, 77, 74, 75, 26, 79, 34, 27, 14, 31,
+	32, 30, 9, 135, 130, 117, 95, 87, 86, 67,
+	12, 129, 166, 27, 155, 32, 13, 39, 121, 21,
+	8, 4, 5, 5, 3, 2, 1, 22, 59, 6,
+	127, 98, 24, 46, 20, 123, 122, 11, 10, 28,
 }
-var yyPact = [...]int{
-
-	-1000, -1000, 250, -1000, 144, 162, 202, 33, -28, -1000,
-	84, 230, 202, -1000, -16, -2, 118, -1000, 141, -1000,
-	160, -1000, -1000, 36, 161, -1000, -11, 75, -1000, -1000,
-	191, 95, 94, 23, -31, 218, -1000, 71, 161, 161,
-	157, 149, 47, 128, 75, -4, -1000, -1000, -1000, -1000,
-	-1000, -1000, -1000, -1000, -9, -1000, 101, 93, -1000, -14,
-	148, -1000, 161, 161, 161, 161, 161, 161, 161, 161,
-	-3, 90, 161, 161, 35, 35, 161, 161, 85, 147,
-	-13, 195, -1000, -1000, 75, -18, 125, 228, 84, 218,
-	158, 158, 224, 35, 35, 46, 46, 170, 146, 218,
-	22, 184, 173, 138, 161, -1000, -1000, -1000, 120, 78,
-	-1000, 9, -35, -1000, 17, -1000, 91, 161, -1000, 161,
-	161, 161, 4, 69, 161, -21, 84, -1000, -1000, -25,
-	183, 3, 208, 218, 0, -1000, 161, 218, 105, -1000,
-	79, 75, -1000, 161, -1000, 21, 34, 181, -1000, 218,
-	75, 137, 75, -1000, 218, -1000, -1000,
+
+var yyPact = [...]int16{
+	-1000, -1000, 279, -1000, 278, 196, -1000, 260, 269, 237,
+	65, -36, -1000, 105, -1000, 274, 269, -1000, -24, 252,
+	173, 234, -1000, 179, -1000, 53, 236, 89, -1000, -1000,
+	267, 142, 139, -1000, 249, -1000, -1000, -1000, -1000, -1000,
+	-6, 41, -1000, 29, -16, 121, -1000, 113, 172, 172,
+	248, 247, 70, 176, 89, -1, -1000, -1000, -1000, -1000,
+	-1000, -1000, -1000, -1000, -5, 246, 45, 172, -1000, -1000,
+	89, 172, 172, 172, 172, 172, 172, 172, 170, 120,
+	89, -27, 172, 172, 67, 67, 172, 172, 106, 245,
+	40, 203, -1000, 166, 272, 105, -1000, 62, -38, 119,
+	-1000, -4, -4, 169, 67, 67, 84, 84, 255, 244,
+	-1000, 158, 119, 59, 192, 181, 243, 172, -1000, -1000,
+	99, -1000, 54, -40, -1000, -1000, 172, 55, -1000, 117,
+	172, 95, -1000, 172, 172, 172, 47, 172, -28, 105,
+	119, -1000, -1000, -31, 259, 43, 172, 217, 119, 30,
+	-1000, 119, 157, -1000, 154, 89, -1000, 10, 172, -1000,
+	66, 257, -1000, 89, 119, 150, 89, -1000, 119, -1000,
+	-1000,
 }
-var yyPgo = [...]int{
 
-	0, 275, 274, 273, 272, 271, 109, 270, 269, 268,
-	267, 231, 1, 266, 3, 2, 0, 265, 5, 264,
-	263, 4, 262, 260,
+var yyPgo = [...]int16{
+	0, 284, 111, 181, 299, 115, 298, 297, 296, 295,
+	270, 1, 294, 2, 8, 0, 293, 6, 291, 290,
+	4, 288, 286, 285,
 }
-var yyR1 = [...]int{
 
-	0, 23, 1, 1, 2, 13, 13, 13, 3, 3,
-	4, 4, 4, 4, 5, 5, 6, 6, 18, 18,
-	19, 19, 14, 14, 15, 15, 15, 7, 7, 8,
-	8, 11, 9, 9, 10, 10, 12, 16, 16, 16,
+var yyR1 = [...]int8{
+	0, 22, 23, 23, 23, 1, 1, 12, 12, 12,
+	2, 2, 2, 2, 2, 3, 3, 3, 3, 4,
+	4, 5, 5, 17, 17, 18, 18, 13, 13, 14,
+	14, 14, 14, 14, 6, 6, 7, 7, 10, 8,
+	8, 9, 9, 11, 15, 15, 15, 15, 15, 15,
+	15, 15, 15, 15, 15, 15, 15, 15, 15, 16,
 	16, 16, 16, 16, 16, 16, 16, 16, 16, 16,
-	16, 16, 17, 17, 17, 17, 17, 17, 17, 17,
-	17, 17, 17, 17, 22, 22, 20, 20, 21, 21,
+	16, 21, 21, 19, 19, 20, 20,
 }
-var yyR2 = [...]int{
-
-	0, 1, 0, 2, 9, 0, 5, 2, 0, 3,
-	3, 1, 1, 2, 6, 4, 9, 9, 0, 1,
-	1, 3, 0, 1, 1, 8, 3, 0, 1, 1,
-	3, 2, 0, 1, 1, 3, 3, 1, 3, 2,
-	2, 7, 5, 3, 3, 3, 3, 3, 3, 3,
-	5, 6, 4, 6, 5, 3, 3, 1, 2, 1,
-	1, 1, 1, 1, 1, 1, 1, 2, 6, 4,
+
+var yyR2 = [...]int8{
+	0, 1, 0, 2, 3, 9, 6, 0, 5, 2,
+	0, 3, 3, 3, 4, 3, 1, 1, 2, 6,
+	4, 9, 9, 0, 1, 1, 3, 0, 1, 1,
+	1, 3, 8, 3, 0, 1, 1, 3, 2, 0,
+	1, 1, 3, 3, 1, 3, 2, 2, 7, 5,
+	3, 3, 3, 3, 3, 3, 3, 5, 6, 4,
+	6, 5, 3, 3, 1, 2, 1, 1, 1, 1,
+	1, 1, 1, 1, 2, 6, 4,
 }
-var yyChk = [...]int{
-
-	-1000, -23, -1, -2, 4, 21, 20, -7, -8, -11,
-	7, 38, 43, -12, 22, -13, 5, -11, 42, 39,
-	21, 6, 21, -3, 20, 40, -4, 39, -5, -6,
-	9, 7, 8, -18, -19, -16, -17, 22, 36, 33,
-	26, 27, 10, 11, 39, 20, 13, 23, 24, -22,
-	14, 17, 18, 41, -14, -15, -16, 7, -6, 22,
-	22, 38, 43, 29, 30, 31, 34, 35, 32, 33,
-	28, 37, 25, 20, -16, -16, 20, 20, 37, 21,
-	-14, -16, 38, 40, 41, 22, 42, 25, 20, -16,
-	-16, -16, -16, -16, -16, -16, -16, 39, 22, -16,
-	-18, -16, -16, 22, 20, 40, 38, -15, 42, 21,
-	6, -9, -10, -12, -20, -21, 16, 20, 38, 38,
-	38, 20, -18, 21, 25, 38, 43, 40, -21, 22,
-	13, -18, -16, -16, -18, 38, 25, -16, 42, -12,
-	42, 15, 38, 12, 38, -16, 21, 21, -14, -16,
-	41, 25, 15, -15, -16, 6, -14,
+
+var yyChk = [...]int16{
+	-1000, -22, -23, -1, 2, 4, -1, 22, 20, 2,
+	-6, -7, -10, 7, 21, 39, 43, -11, 23, -2,
+	-12, 5, -10, 42, 40, -3, 2, 21, -4, -5,
+	9, 7, 8, 21, 22, 6, 22, 41, 2, 41,
+	-3, -13, -14, -15, 2, 7, -16, 23, 37, 34,
+	27, 28, 10, 11, 21, 20, 13, 24, 25, -21,
+	14, 17, 18, -5, 23, 23, -2, 20, 41, 40,
+	41, 30, 31, 32, 35, 36, 33, 34, 29, 38,
+	41, 23, 26, 20, -15, -15, 20, 20, 38, 22,
+	-13, -15, 39, 42, 26, 20, 40, -17, -18, -15,
+	-14, -15, -15, -15, -15, -15, -15, -15, 21, 23,
+	-14, 42, -15, -17, -15, -15, 23, 20, 40, 39,
+	22, 6, -8, -9, -11, 39, 43, -19, -20, 16,
+	20, 22, 39, 39, 39, 20, -17, 26, 39, 43,
+	-15, 40, -20, 23, 13, -17, 26, -15, -15, -17,
+	39, -15, 42, -11, 42, 15, 39, -15, 12, 39,
+	22, 22, -13, 41, -15, 26, 15, -14, -15, 6,
+	-13,
 }
-var yyDef = [...]int{
-
-	2, -2, 1, 3, 0, 0, 27, 0, 28, 29,
-	0, 5, 0, 31, 0, 0, 0, 30, 0, 8,
-	0, 7, 36, 0, 18, 4, 0, 22, 11, 12,
-	0, 0, 0, 0, 19, 20, 37, 59, 0, 0,
-	0, 0, 0, 0, 22, 0, 57, 60, 61, 62,
-	63, 64, 65, 9, 0, 23, 24, 0, 13, 0,
-	0, 6, 0, 0, 0, 0, 0, 0, 0, 0,
-	0, 0, 0, 18, 39, 40, 0, 0, 0, 0,
-	0, 0, 58, 10, 0, 0, 0, 0, 32, 21,
-	43, 44, 45, 46, 47, 48, 49, 0, 0, 38,
-	0, 0, 0, 0, 18, 55, 56, 26, 0, 0,
-	15, 0, 33, 34, 0, 66, 0, 18, 52, 0,
-	0, 18, 0, 0, 0, 0, 0, 50, 67, 0,
-	0, 0, 0, 42, 0, 54, 0, 14, 0, 35,
-	0, 22, 51, 0, 53, 0, 0, 0, 69, 41,
-	0, 0, 22, 25, 16, 17, 68,
+
+var yyDef = [...]int8{
+	2, -2, -2, 3, 0, 0, 4, 0, 34, 0,
+	0, 35, 36, 0, 10, 7, 0, 38, 0, 0,
+	0, 0, 37, 0, 6, 0, 0, -2, 16, 17,
+	0, 0, 0, 10, 0, 9, 43, 11, 12, 13,
+	0, 0, 28, 29, 30, 0, 44, 66, 0, 0,
+	0, 0, 0, 0, -2, 0, 64, 67, 68, 69,
+	70, 71, 72, 18, 0, 0, 0, 23, 14, 15,
+	0, 0, 0, 0, 0, 0, 0, 0, 0, 0,
+	0, 0, 0, 23, 46, 47, 0, 0, 0, 0,
+	0, 0, 65, 0, 0, 39, 5, 0, 24, 25,
+	33, 50, 51, 52, 53, 54, 55, 56, 0, 0,
+	31, 0, 45, 0, 0, 0, 0, 23, 62, 63,
+	0, 20, 0, 40, 41, 8, 0, 0, 73, 0,
+	23, 0, 59, 0, 0, 23, 0, 0, 0, 0,
+	26, 57, 74, 0, 0, 0, 0, 0, 49, 0,
+	61, 19, 0, 42, 0, -2, 58, 0, 0, 60,
+	0, 0, 76, 0, 48, 0, -2, 32, 21, 22,
+	75,
 }
-var yyTok1 = [...]int{
 
+var yyTok1 = [...]int8{
 	1, 3, 3, 3, 3, 3, 3, 3, 3, 3,
 	3, 3, 3, 3, 3, 3, 3, 3, 3, 3,
 	3, 3, 3, 3, 3, 3, 3, 3, 3, 3,
-	3, 3, 3, 36, 3, 3, 3, 3, 3, 3,
-	20, 38, 34, 32, 43, 33, 37, 35, 3, 3,
+	3, 3, 3, 37, 3, 3, 3, 3, 3, 3,
+	20, 39, 35, 33, 43, 34, 38, 36, 3, 3,
 	3, 3, 3, 3, 3, 3, 3, 3, 42, 41,
-	30, 25, 3, 3, 3, 3, 3, 3, 3, 3,
+	31, 26, 3, 3, 3, 3, 3, 3, 3, 3,
 	3, 3, 3, 3, 3, 3, 3, 3, 3, 3,
 	3, 3, 3, 3, 3, 3, 3, 3, 3, 3,
 	3, 3, 3, 3, 3, 3, 3, 3, 3, 3,
 	3, 3, 3, 3, 3, 3, 3, 3, 3, 3,
 	3, 3, 3, 3, 3, 3, 3, 3, 3, 3,
-	3, 3, 3, 39, 3, 40,
+	3, 3, 3, 21, 3, 40,
 }
-var yyTok2 = [...]int{
 
+var yyTok2 = [...]int8{
 	2, 3, 4, 5, 6, 7, 8, 9, 10, 11,
-	12, 13, 14, 15, 16, 17, 18, 19, 21, 22,
-	23, 24, 26, 27, 28, 29, 31,
+	12, 13, 14, 15, 16, 17, 18, 19, 22, 23,
+	24, 25, 27, 28, 29, 30, 32,
 }
-var yyTok3 = [...]int{
+
+var yyTok3 = [...]int8{
 	0,
 }
 
@@ -267,7 +296,14 @@ var yyErrorMessages = [...]struct {
 	state int
 	token int
 	msg   string
-}{}
+}{
+	{7, 21, "missing parameter list after class name, found %s"},
+	{7, 5, "missing parameter list after class name, found %s"},
+	{65, 42, "missing parameter list after method name, found %s"},
+	{138, 26, "missing return type after method parameters, found %s"},
+	{160, 21, "missing '=' before method body, found %s"},
+	{81, 26, "missing type in variable declaration, found %s"},
+}
 
 /*	parser for yacc output	*/
 
@@ -287,18 +323,17 @@ type yyParser interface {
 }
 
 type yyParserImpl struct {
-	lookahead func() int
+	lval  yySymType
+	stack [yyInitialStackSize]yySymType
+	char  int
 }
 
 func (p *yyParserImpl) Lookahead() int {
-	return p.lookahead()
+	return p.char
 }
 
 func yyNewParser() yyParser {
-	p := &yyParserImpl{
-		lookahead: func() int { return -1 },
-	}
-	return p
+	return &yyParserImpl{}
 }
 
 const yyFlag = -1000
@@ -340,9 +375,9 @@ func yyErrorMessage(state, lookAhead int) string {
 	expected := make([]int, 0, 4)
 
 	// Look for shiftable tokens.
-	base := yyPact[state]
+	base := int(yyPact[state])
 	for tok := TOKSTART; tok-1 < len(yyToknames); tok++ {
-		if n := base + tok; n >= 0 && n < yyLast && yyChk[yyAct[n]] == tok {
+		if n := base + tok; n >= 0 && n < yyLast && int(yyChk[int(yyAct[n])]) == tok {
 			if len(expected) == cap(expected) {
 				return res
 			}
@@ -352,13 +387,13 @@ func yyErrorMessage(state, lookAhead int) string {
 
 	if yyDef[state] == -2 {
 		i := 0
-		for yyExca[i] != -1 || yyExca[i+1] != state {
+		for yyExca[i] != -1 || int(yyExca[i+1]) != state {
 			i += 2
 		}
 
 		// Look for tokens that we accept or reduce.
 		for i += 2; yyExca[i] >= 0; i += 2 {
-			tok := yyExca[i]
+			tok := int(yyExca[i])
 			if tok < TOKSTART || yyExca[i+1] == 0 {
 				continue
 			}
@@ -389,30 +424,30 @@ func yylex1(lex yyLexer, lval *yySymType) (char, token int) {
 	token = 0
 	char = lex.Lex(lval)
 	if char <= 0 {
-		token = yyTok1[0]
+		token = int(yyTok1[0])
 		goto out
 	}
 	if char < len(yyTok1) {
-		token = yyTok1[char]
+		token = int(yyTok1[char])
 		goto out
 	}
 	if char >= yyPrivate {
 		if char < yyPrivate+len(yyTok2) {
-			token = yyTok2[char-yyPrivate]
+			token = int(yyTok2[char-yyPrivate])
 			goto out
 		}
 	}
 	for i := 0; i < len(yyTok3); i += 2 {
-		token = yyTok3[i+0]
+		token = int(yyTok3[i+0])
 		if token == char {
-			token = yyTok3[i+1]
+			token = int(yyTok3[i+1])
 			goto out
 		}
 	}
 
 out:
 	if token == 0 {
-		token = yyTok2[1] /* unknown char */
+		token = int(yyTok2[1]) /* unknown char */
 	}
 	if yyDebug >= 3 {
 		__yyfmt__.Printf("lex %s(%d)\n", yyTokname(token), uint(char))
@@ -426,22 +461,20 @@ func yyParse(yylex yyLexer) int {
 
 func (yyrcvr *yyParserImpl) Parse(yylex yyLexer) int {
 	var yyn int
-	var yylval yySymType
 	var yyVAL yySymType
 	var yyDollar []yySymType
 	_ = yyDollar // silence set and not used
-	yyS := make([]yySymType, yyMaxDepth)
+	yyS := yyrcvr.stack[:]
 
 	Nerrs := 0   /* number of errors */
 	Errflag := 0 /* error recovery flag */
 	yystate := 0
-	yychar := -1
-	yytoken := -1 // yychar translated into internal numbering
-	yyrcvr.lookahead = func() int { return yychar }
+	yyrcvr.char = -1
+	yytoken := -1 // yyrcvr.char translated into internal numbering
 	defer func() {
 		// Make sure we report no lookahead when not parsing.
 		yystate = -1
-		yychar = -1
+		yyrcvr.char = -1
 		yytoken = -1
 	}()
 	yyp := -1
@@ -469,22 +502,22 @@ yystack:
 	yyS[yyp].yys = yystate
 
 yynewstate:
-	yyn = yyPact[yystate]
+	yyn = int(yyPact[yystate])
 	if yyn <= yyFlag {
 		goto yydefault /* simple state */
 	}
-	if yychar < 0 {
-		yychar, yytoken = yylex1(yylex, &yylval)
+	if yyrcvr.char < 0 {
+		yyrcvr.char, yytoken = yylex1(yylex, &yyrcvr.lval)
 	}
 	yyn += yytoken
 	if yyn < 0 || yyn >= yyLast {
 		goto yydefault
 	}
-	yyn = yyAct[yyn]
-	if yyChk[yyn] == yytoken { /* valid shift */
-		yychar = -1
+	yyn = int(yyAct[yyn])
+	if int(yyChk[yyn]) == yytoken { /* valid shift */
+		yyrcvr.char = -1
 		yytoken = -1
-		yyVAL = yylval
+		yyVAL = yyrcvr.lval
 		yystate = yyn
 		if Errflag > 0 {
 			Errflag--
@@ -494,27 +527,27 @@ yynewstate:
 
 yydefault:
 	/* default state action */
-	yyn = yyDef[yystate]
+	yyn = int(yyDef[yystate])
 	if yyn == -2 {
-		if yychar < 0 {
-			yychar, yytoken = yylex1(yylex, &yylval)
+		if yyrcvr.char < 0 {
+			yyrcvr.char, yytoken = yylex1(yylex, &yyrcvr.lval)
 		}
 
 		/* look through exception table */
 		xi := 0
 		for {
-			if yyExca[xi+0] == -1 && yyExca[xi+1] == yystate {
+			if yyExca[xi+0] == -1 && int(yyExca[xi+1]) == yystate {
 				break
 			}
 			xi += 2
 		}
 		for xi += 2; ; xi += 2 {
-			yyn = yyExca[xi+0]
+			yyn = int(yyExca[xi+0])
 			if yyn < 0 || yyn == yytoken {
 				break
 			}
 		}
-		yyn = yyExca[xi+1]
+		yyn = int(yyExca[xi+1])
 		if yyn < 0 {
 			goto ret0
 		}
@@ -536,10 +569,10 @@ yydefault:
 
 			/* find a state where "error" is a legal shift action */
 			for yyp >= 0 {
-				yyn = yyPact[yyS[yyp].yys] + yyErrCode
+				yyn = int(yyPact[yyS[yyp].yys]) + yyErrCode
 				if yyn >= 0 && yyn < yyLast {
-					yystate = yyAct[yyn] /* simulate a shift of "error" */
-					if yyChk[yystate] == yyErrCode {
+					yystate = int(yyAct[yyn]) /* simulate a shift of "error" */
+					if int(yyChk[yystate]) == yyErrCode {
 						goto yystack
 					}
 				}
@@ -560,7 +593,7 @@ yydefault:
 			if yytoken == yyEofCode {
 				goto ret1
 			}
-			yychar = -1
+			yyrcvr.char = -1
 			yytoken = -1
 			goto yynewstate /* try again in the same state */
 		}
@@ -575,7 +608,7 @@ yydefault:
 	yypt := yyp
 	_ = yypt // guard against "declared and not used"
 
-	yyp -= yyR2[yyn]
+	yyp -= int(yyR2[yyn])
 	// yyp is now the index of $0. Perform the default action. Iff the
 	// reduced production is ε, $1 is possibly out of range.
 	if yyp+1 >= len(yyS) {
@@ -586,48 +619,62 @@ yydefault:
 	yyVAL = yyS[yyp+1]
 
 	/* consult goto table to find next state */
-	yyn = yyR1[yyn]
-	yyg := yyPgo[yyn]
+	yyn = int(yyR1[yyn])
+	yyg := int(yyPgo[yyn])
 	yyj := yyg + yyS[yyp].yys + 1
 
 	if yyj >= yyLast {
-		yystate = yyAct[yyg]
+		yystate = int(yyAct[yyg])
 	} else {
-		yystate = yyAct[yyj]
-		if yyChk[yystate] != -yyn {
-			yystate = yyAct[yyg]
+		yystate = int(yyAct[yyj])
+		if int(yyChk[yystate]) != -yyn {
+			yystate = int(yyAct[yyg])
 		}
 	}
 	// dummy call; replaced with literal code
 	switch yynt {
 
-	case 1:
-		yyDollar = yyS[yypt-1 : yypt+1]
-		{
-			p := yylex.(*lex).program
-			p.Classes = append(p.Classes, yyDollar[1].cls...)
-		}
-	case 2:
-		yyDollar = yyS[yypt-0 : yypt+1]
-		{
-			yyVAL.cls = nil
-		}
 	case 3:
 		yyDollar = yyS[yypt-2 : yypt+1]
 		{
-			yyVAL.cls = append(yyDollar[1].cls, yyDollar[2].cl)
+			p := yylex.(*lex).program
+			p.Classes = append(p.Classes, yyDollar[2].cl)
 		}
 	case 4:
+		yyDollar = yyS[yypt-3 : yypt+1]
+		{
+			p := yylex.(*lex).program
+			p.Classes = append(p.Classes, yyDollar[3].cl)
+		}
+	case 5:
 		yyDollar = yyS[yypt-9 : yypt+1]
 		{
 			yyVAL.cl = &Class{
 				Type:     yyDollar[2].id,
 				Formals:  yyDollar[4].fms,
 				Extends:  yyDollar[6].ext,
+				Doc:      yylex.(*lex).doc(yyDollar[1].pos),
 				Features: yyDollar[8].fts,
 			}
 		}
-	case 5:
+	case 6:
+		yyDollar = yyS[yypt-6 : yypt+1]
+		{
+			yyVAL.cl = &Class{
+				Type:    yyDollar[2].id,
+				Formals: nil,
+				Extends: &Extends{
+					Type: &Ident{
+						Name: "Any",
+						Pos:  token.NoPos,
+					},
+					Args: nil,
+				},
+				Doc:      yylex.(*lex).doc(yyDollar[1].pos),
+				Features: yyDollar[5].fts,
+			}
+		}
+	case 7:
 		yyDollar = yyS[yypt-0 : yypt+1]
 		{
 			yyVAL.ext = &Extends{
@@ -638,7 +685,7 @@ yydefault:
 				Args: nil,
 			}
 		}
-	case 6:
+	case 8:
 		yyDollar = yyS[yypt-5 : yypt+1]
 		{
 			yyVAL.ext = &Extends{
@@ -646,7 +693,7 @@ yydefault:
 				Args: yyDollar[4].act,
 			}
 		}
-	case 7:
+	case 9:
 		yyDollar = yyS[yypt-2 : yypt+1]
 		{
 			yyVAL.ext = &Extends{
@@ -657,49 +704,70 @@ yydefault:
 				Args: nil,
 			}
 		}
-	case 8:
+	case 10:
 		yyDollar = yyS[yypt-0 : yypt+1]
 		{
 			yyVAL.fts = nil
 		}
-	case 9:
+	case 11:
 		yyDollar = yyS[yypt-3 : yypt+1]
 		{
 			yyVAL.fts = append(yyDollar[1].fts, yyDollar[2].ft)
 		}
-	case 10:
+	case 12:
+		yyDollar = yyS[yypt-3 : yypt+1]
+		{
+			// lex.Error has reported the missing ';', and the feature
+			// itself is complete.
+			yyVAL.fts = append(yyDollar[1].fts, yyDollar[2].ft)
+		}
+	case 13:
+		yyDollar = yyS[yypt-3 : yypt+1]
+		{
+			yyVAL.fts = yyDollar[1].fts
+		}
+	case 14:
+		yyDollar = yyS[yypt-4 : yypt+1]
+		{
+			yyVAL.fts = append(yyDollar[1].fts, yyDollar[3].ft)
+		}
+	case 15:
 		yyDollar = yyS[yypt-3 : yypt+1]
 		{
 			yyVAL.ft = &Init{
 				Expr: yyDollar[2].exp,
 			}
 		}
-	case 11:
+	case 16:
 		yyDollar = yyS[yypt-1 : yypt+1]
 		{
 			yyVAL.ft = yyDollar[1].ft
 		}
-	case 12:
+	case 17:
 		yyDollar = yyS[yypt-1 : yypt+1]
 		{
 			yyVAL.ft = yyDollar[1].ft
 		}
-	case 13:
+	case 18:
 		yyDollar = yyS[yypt-2 : yypt+1]
 		{
 			yyVAL.ft = yyDollar[2].ft
 			yyVAL.ft.(*Method).Override = true
+			if doc := yylex.(*lex).doc(yyDollar[1].pos); doc != "" {
+				yyVAL.ft.(*Method).Doc = doc
+			}
 		}
-	case 14:
+	case 19:
 		yyDollar = yyS[yypt-6 : yypt+1]
 		{
 			yyVAL.ft = &Attribute{
 				Name: yyDollar[2].id,
 				Type: yyDollar[4].id,
 				Init: yyDollar[6].exp,
+				Doc:  yylex.(*lex).doc(yyDollar[1].pos),
 			}
 		}
-	case 15:
+	case 20:
 		yyDollar = yyS[yypt-4 : yypt+1]
 		{
 			yyVAL.ft = &Attribute{
@@ -711,9 +779,10 @@ yydefault:
 				Init: &NativeExpr{
 					Pos: yyDollar[4].pos,
 				},
+				Doc: yylex.(*lex).doc(yyDollar[1].pos),
 			}
 		}
-	case 16:
+	case 21:
 		yyDollar = yyS[yypt-9 : yypt+1]
 		{
 			yyVAL.ft = &Method{
@@ -721,9 +790,10 @@ yydefault:
 				Args: yyDollar[4].fms,
 				Type: yyDollar[7].id,
 				Body: yyDollar[9].exp,
+				Doc:  yylex.(*lex).doc(yyDollar[1].pos),
 			}
 		}
-	case 17:
+	case 22:
 		yyDollar = yyS[yypt-9 : yypt+1]
 		{
 			yyVAL.ft = &Method{
@@ -733,46 +803,59 @@ yydefault:
 				Body: &NativeExpr{
 					Pos: yyDollar[9].pos,
 				},
+				Doc: yylex.(*lex).doc(yyDollar[1].pos),
 			}
 		}
-	case 18:
+	case 23:
 		yyDollar = yyS[yypt-0 : yypt+1]
 		{
 			yyVAL.act = nil
 		}
-	case 19:
+	case 24:
 		yyDollar = yyS[yypt-1 : yypt+1]
 		{
 			yyVAL.act = yyDollar[1].act
 		}
-	case 20:
+	case 25:
 		yyDollar = yyS[yypt-1 : yypt+1]
 		{
 			yyVAL.act = []Expr{yyDollar[1].exp}
 		}
-	case 21:
+	case 26:
 		yyDollar = yyS[yypt-3 : yypt+1]
 		{
 			yyVAL.act = append(yyDollar[1].act, yyDollar[3].exp)
 		}
-	case 22:
+	case 27:
 		yyDollar = yyS[yypt-0 : yypt+1]
 		{
 			yyVAL.exp = &UnitExpr{
 				Pos: yylex.(*lex).Pos(),
 			}
 		}
-	case 23:
+	case 28:
 		yyDollar = yyS[yypt-1 : yypt+1]
 		{
 			yyVAL.exp = yyDollar[1].exp
 		}
-	case 24:
+	case 29:
 		yyDollar = yyS[yypt-1 : yypt+1]
 		{
 			yyVAL.exp = yyDollar[1].exp
 		}
-	case 25:
+	case 30:
+		yyDollar = yyS[yypt-1 : yypt+1]
+		{
+			yyVAL.exp = &BadExpr{
+				Pos: yylex.(*lex).Pos(),
+			}
+		}
+	case 31:
+		yyDollar = yyS[yypt-3 : yypt+1]
+		{
+			yyVAL.exp = yyDollar[3].exp
+		}
+	case 32:
 		yyDollar = yyS[yypt-8 : yypt+1]
 		{
 			yyVAL.exp = &VarExpr{
@@ -781,61 +864,63 @@ yydefault:
 				Init: yyDollar[6].exp,
 				Body: yyDollar[8].exp,
 			}
+			yylex.(*lex).span(yyVAL.exp, yyDollar[1].pos, yylex.(*lex).exprEnd(yyDollar[8].exp))
 		}
-	case 26:
+	case 33:
 		yyDollar = yyS[yypt-3 : yypt+1]
 		{
 			yyVAL.exp = &ChainExpr{
 				Pre:  yyDollar[1].exp,
 				Expr: yyDollar[3].exp,
 			}
+			yylex.(*lex).span(yyVAL.exp, yylex.(*lex).exprPos(yyDollar[1].exp), yylex.(*lex).exprEnd(yyDollar[3].exp))
 		}
-	case 27:
+	case 34:
 		yyDollar = yyS[yypt-0 : yypt+1]
 		{
 			yyVAL.fms = nil
 		}
-	case 28:
+	case 35:
 		yyDollar = yyS[yypt-1 : yypt+1]
 		{
 			yyVAL.fms = yyDollar[1].fms
 		}
-	case 29:
+	case 36:
 		yyDollar = yyS[yypt-1 : yypt+1]
 		{
 			yyVAL.fms = []*Formal{yyDollar[1].fm}
 		}
-	case 30:
+	case 37:
 		yyDollar = yyS[yypt-3 : yypt+1]
 		{
 			yyVAL.fms = append(yyDollar[1].fms, yyDollar[3].fm)
 		}
-	case 31:
+	case 38:
 		yyDollar = yyS[yypt-2 : yypt+1]
 		{
 			yyVAL.fm = yyDollar[2].fm
 		}
-	case 32:
+	case 39:
 		yyDollar = yyS[yypt-0 : yypt+1]
 		{
 			yyVAL.fms = nil
 		}
-	case 33:
+	case 40:
 		yyDollar = yyS[yypt-1 : yypt+1]
 		{
 			yyVAL.fms = yyDollar[1].fms
 		}
-	case 34:
+	case 41:
 		yyDollar = yyS[yypt-1 : yypt+1]
 		{
 			yyVAL.fms = []*Formal{yyDollar[1].fm}
 		}
-	case 35:
+	case 42:
 		yyDollar = yyS[yypt-3 : yypt+1]
 		{
 			yyVAL.fms = append(yyDollar[1].fms, yyDollar[3].fm)
 		}
-	case 36:
+	case 43:
 		yyDollar = yyS[yypt-3 : yypt+1]
 		{
 			yyVAL.fm = &Formal{
@@ -843,12 +928,12 @@ yydefault:
 				Type: yyDollar[3].id,
 			}
 		}
-	case 37:
+	case 44:
 		yyDollar = yyS[yypt-1 : yypt+1]
 		{
 			yyVAL.exp = yyDollar[1].exp
 		}
-	case 38:
+	case 45:
 		yyDollar = yyS[yypt-3 : yypt+1]
 		{
 			yyVAL.exp = &AssignExpr{
@@ -860,8 +945,9 @@ yydefault:
 					Pos:  yyDollar[2].pos,
 				},
 			}
+			yylex.(*lex).span(yyVAL.exp, yyDollar[1].id.Pos, yylex.(*lex).exprEnd(yyDollar[3].exp))
 		}
-	case 39:
+	case 46:
 		yyDollar = yyS[yypt-2 : yypt+1]
 		{
 			yyVAL.exp = &NotExpr{
@@ -872,8 +958,9 @@ yydefault:
 					Pos:  yyDollar[1].pos,
 				},
 			}
+			yylex.(*lex).span(yyVAL.exp, yyDollar[1].pos, yylex.(*lex).exprEnd(yyDollar[2].exp))
 		}
-	case 40:
+	case 47:
 		yyDollar = yyS[yypt-2 : yypt+1]
 		{
 			yyVAL.exp = &NegativeExpr{
@@ -884,8 +971,9 @@ yydefault:
 					Pos:  yyDollar[1].pos,
 				},
 			}
+			yylex.(*lex).span(yyVAL.exp, yyDollar[1].pos, yylex.(*lex).exprEnd(yyDollar[2].exp))
 		}
-	case 41:
+	case 48:
 		yyDollar = yyS[yypt-7 : yypt+1]
 		{
 			yyVAL.exp = &IfExpr{
@@ -898,8 +986,9 @@ yydefault:
 					Pos:  yyDollar[1].pos,
 				},
 			}
+			yylex.(*lex).span(yyVAL.exp, yyDollar[1].pos, yylex.(*lex).exprEnd(yyDollar[7].exp))
 		}
-	case 42:
+	case 49:
 		yyDollar = yyS[yypt-5 : yypt+1]
 		{
 			yyVAL.exp = &WhileExpr{
@@ -915,8 +1004,9 @@ yydefault:
 					Pos:  yyDollar[1].pos,
 				},
 			}
+			yylex.(*lex).span(yyVAL.exp, yyDollar[1].pos, yylex.(*lex).exprEnd(yyDollar[5].exp))
 		}
-	case 43:
+	case 50:
 		yyDollar = yyS[yypt-3 : yypt+1]
 		{
 			yyVAL.exp = &LessOrEqualExpr{
@@ -933,8 +1023,9 @@ yydefault:
 					Pos:  yyDollar[2].pos,
 				},
 			}
+			yylex.(*lex).span(yyVAL.exp, yylex.(*lex).exprPos(yyDollar[1].exp), yylex.(*lex).exprEnd(yyDollar[3].exp))
 		}
-	case 44:
+	case 51:
 		yyDollar = yyS[yypt-3 : yypt+1]
 		{
 			yyVAL.exp = &LessThanExpr{
@@ -951,8 +1042,9 @@ yydefault:
 					Pos:  yyDollar[2].pos,
 				},
 			}
+			yylex.(*lex).span(yyVAL.exp, yylex.(*lex).exprPos(yyDollar[1].exp), yylex.(*lex).exprEnd(yyDollar[3].exp))
 		}
-	case 45:
+	case 52:
 		yyDollar = yyS[yypt-3 : yypt+1]
 		{
 			yyVAL.exp = &DynamicCallExpr{
@@ -965,8 +1057,9 @@ yydefault:
 					yyDollar[3].exp,
 				},
 			}
+			yylex.(*lex).span(yyVAL.exp, yylex.(*lex).exprPos(yyDollar[1].exp), yylex.(*lex).exprEnd(yyDollar[3].exp))
 		}
-	case 46:
+	case 53:
 		yyDollar = yyS[yypt-3 : yypt+1]
 		{
 			yyVAL.exp = &MultiplyExpr{
@@ -979,8 +1072,9 @@ yydefault:
 					Pos:  yyDollar[2].pos,
 				},
 			}
+			yylex.(*lex).span(yyVAL.exp, yylex.(*lex).exprPos(yyDollar[1].exp), yylex.(*lex).exprEnd(yyDollar[3].exp))
 		}
-	case 47:
+	case 54:
 		yyDollar = yyS[yypt-3 : yypt+1]
 		{
 			yyVAL.exp = &DivideExpr{
@@ -993,8 +1087,9 @@ yydefault:
 					Pos:  yyDollar[2].pos,
 				},
 			}
+			yylex.(*lex).span(yyVAL.exp, yylex.(*lex).exprPos(yyDollar[1].exp), yylex.(*lex).exprEnd(yyDollar[3].exp))
 		}
-	case 48:
+	case 55:
 		yyDollar = yyS[yypt-3 : yypt+1]
 		{
 			yyVAL.exp = &AddExpr{
@@ -1007,8 +1102,9 @@ yydefault:
 					Pos:  yyDollar[2].pos,
 				},
 			}
+			yylex.(*lex).span(yyVAL.exp, yylex.(*lex).exprPos(yyDollar[1].exp), yylex.(*lex).exprEnd(yyDollar[3].exp))
 		}
-	case 49:
+	case 56:
 		yyDollar = yyS[yypt-3 : yypt+1]
 		{
 			yyVAL.exp = &SubtractExpr{
@@ -1021,8 +1117,9 @@ yydefault:
 					Pos:  yyDollar[2].pos,
 				},
 			}
+			yylex.(*lex).span(yyVAL.exp, yylex.(*lex).exprPos(yyDollar[1].exp), yylex.(*lex).exprEnd(yyDollar[3].exp))
 		}
-	case 50:
+	case 57:
 		yyDollar = yyS[yypt-5 : yypt+1]
 		{
 			yyVAL.exp = &MatchExpr{
@@ -1030,8 +1127,9 @@ yydefault:
 				Left:  yyDollar[1].exp,
 				Cases: yyDollar[4].cas,
 			}
+			yylex.(*lex).span(yyVAL.exp, yylex.(*lex).exprPos(yyDollar[1].exp), yyDollar[5].end)
 		}
-	case 51:
+	case 58:
 		yyDollar = yyS[yypt-6 : yypt+1]
 		{
 			yyVAL.exp = &DynamicCallExpr{
@@ -1039,8 +1137,9 @@ yydefault:
 				Name: yyDollar[3].id,
 				Args: yyDollar[5].act,
 			}
+			yylex.(*lex).span(yyVAL.exp, yylex.(*lex).exprPos(yyDollar[1].exp), yyDollar[6].end)
 		}
-	case 52:
+	case 59:
 		yyDollar = yyS[yypt-4 : yypt+1]
 		{
 			yyVAL.exp = &DynamicCallExpr{
@@ -1050,8 +1149,9 @@ yydefault:
 				Name: yyDollar[1].id,
 				Args: yyDollar[3].act,
 			}
+			yylex.(*lex).span(yyVAL.exp, yyDollar[1].id.Pos, yyDollar[4].end)
 		}
-	case 53:
+	case 60:
 		yyDollar = yyS[yypt-6 : yypt+1]
 		{
 			yyVAL.exp = &SuperCallExpr{
@@ -1059,8 +1159,9 @@ yydefault:
 				Name: yyDollar[3].id,
 				Args: yyDollar[5].act,
 			}
+			yylex.(*lex).span(yyVAL.exp, yyDollar[1].pos, yyDollar[6].end)
 		}
-	case 54:
+	case 61:
 		yyDollar = yyS[yypt-5 : yypt+1]
 		{
 			yyVAL.exp = &StaticCallExpr{
@@ -1072,70 +1173,81 @@ yydefault:
 				Name: &Ident{
 					Name: yyDollar[2].id.Name,
 					Pos:  yyDollar[2].id.Pos,
+					End:  yyDollar[2].id.End,
 				},
 				Args: yyDollar[4].act,
 			}
+			yylex.(*lex).span(yyVAL.exp, yyDollar[1].pos, yyDollar[5].end)
 		}
-	case 55:
+	case 62:
 		yyDollar = yyS[yypt-3 : yypt+1]
 		{
 			yyVAL.exp = yyDollar[2].exp
+			yylex.(*lex).span(yyVAL.exp, yyDollar[1].pos, yyDollar[3].end)
 		}
-	case 56:
+	case 63:
 		yyDollar = yyS[yypt-3 : yypt+1]
 		{
 			yyVAL.exp = yyDollar[2].exp
+			yylex.(*lex).span(yyVAL.exp, yyDollar[1].pos, yyDollar[3].end)
 		}
-	case 57:
+	case 64:
 		yyDollar = yyS[yypt-1 : yypt+1]
 		{
 			yyVAL.exp = &NullExpr{
 				Pos: yyDollar[1].pos,
 			}
+			yylex.(*lex).span(yyVAL.exp, yyDollar[1].pos, yyDollar[1].end)
 		}
-	case 58:
+	case 65:
 		yyDollar = yyS[yypt-2 : yypt+1]
 		{
 			yyVAL.exp = &UnitExpr{
 				Pos: yyDollar[1].pos,
 			}
+			yylex.(*lex).span(yyVAL.exp, yyDollar[1].pos, yyDollar[2].end)
 		}
-	case 59:
+	case 66:
 		yyDollar = yyS[yypt-1 : yypt+1]
 		{
 			yyVAL.exp = &NameExpr{
 				Name: yyDollar[1].id,
 			}
+			yylex.(*lex).span(yyVAL.exp, yyDollar[1].id.Pos, yyDollar[1].id.End)
 		}
-	case 60:
+	case 67:
 		yyDollar = yyS[yypt-1 : yypt+1]
 		{
 			yyVAL.exp = &IntExpr{
 				Lit: yyDollar[1].int,
 			}
+			yylex.(*lex).span(yyVAL.exp, yyDollar[1].int.Pos, yyDollar[1].end)
 		}
-	case 61:
+	case 68:
 		yyDollar = yyS[yypt-1 : yypt+1]
 		{
 			yyVAL.exp = &StringExpr{
 				Lit: yyDollar[1].str,
 			}
+			yylex.(*lex).span(yyVAL.exp, yyDollar[1].str.Pos, yyDollar[1].end)
 		}
-	case 62:
+	case 69:
 		yyDollar = yyS[yypt-1 : yypt+1]
 		{
 			yyVAL.exp = &BoolExpr{
 				Lit: yyDollar[1].bin,
 			}
+			yylex.(*lex).span(yyVAL.exp, yyDollar[1].bin.Pos, yyDollar[1].bin.Pos+token.Pos(len(strconv.FormatBool(yyDollar[1].bin.Bool))))
 		}
-	case 63:
+	case 70:
 		yyDollar = yyS[yypt-1 : yypt+1]
 		{
 			yyVAL.exp = &ThisExpr{
 				Pos: yyDollar[1].pos,
 			}
+			yylex.(*lex).span(yyVAL.exp, yyDollar[1].pos, yyDollar[1].end)
 		}
-	case 64:
+	case 71:
 		yyDollar = yyS[yypt-1 : yypt+1]
 		{
 			yyVAL.bin = &BoolLit{
@@ -1143,7 +1255,7 @@ yydefault:
 				Bool: true,
 			}
 		}
-	case 65:
+	case 72:
 		yyDollar = yyS[yypt-1 : yypt+1]
 		{
 			yyVAL.bin = &BoolLit{
@@ -1151,17 +1263,17 @@ yydefault:
 				Bool: false,
 			}
 		}
-	case 66:
+	case 73:
 		yyDollar = yyS[yypt-1 : yypt+1]
 		{
 			yyVAL.cas = []*Case{yyDollar[1].ca}
 		}
-	case 67:
+	case 74:
 		yyDollar = yyS[yypt-2 : yypt+1]
 		{
 			yyVAL.cas = append(yyDollar[1].cas, yyDollar[2].ca)
 		}
-	case 68:
+	case 75:
 		yyDollar = yyS[yypt-6 : yypt+1]
 		{
 			yyVAL.ca = &Case{
@@ -1170,7 +1282,7 @@ yydefault:
 				Body: yyDollar[6].exp,
 			}
 		}
-	case 69:
+	case 76:
 		yyDollar = yyS[yypt-4 : yypt+1]
 		{
 			yyVAL.ca = &Case{
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestIRPasses(t *testing.T) {
+	good, err := filepath.Glob(filepath.Join("testdata", "good????.cool"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// each combination of the passes over the intermediate representation
+	// gives the same output.
+	for i := 0; i < 8; i++ {
+		args := []string{
+			"-run",
+			"-opt-int=" + strconv.FormatBool(i&1 != 0),
+			"-opt-jump=" + strconv.FormatBool(i&2 != 0),
+			"-opt-unused=" + strconv.FormatBool(i&4 != 0),
+		}
+		for _, name := range good {
+			testRun(t, strings.TrimSuffix(filepath.Base(name), ".cool"), args...)
+		}
+	}
+}
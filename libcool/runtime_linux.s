@@ -323,6 +323,39 @@ runtime.bounds_panic:
 
 .data
 
+.align 2
+removed_method_panic_before:
+	.ascii "Call to a method that was removed as unreachable\n"
+.set removed_method_panic_before_length, .-removed_method_panic_before
+
+.text
+
+.globl runtime.removed_method_panic
+.type runtime.removed_method_panic, @function
+runtime.removed_method_panic:
+	.cfi_startproc
+	push %ebp
+	.cfi_def_cfa_offset 8
+	.cfi_offset ebp, -8
+	movl %esp, %ebp
+	.cfi_def_cfa_register ebp
+	subl $0, %esp
+
+	movl $4, %eax
+	movl $1, %ebx
+	leal removed_method_panic_before, %ecx
+	movl $removed_method_panic_before_length, %edx
+	int $0x80
+
+	movl $1, %eax
+	movl $1, %ebx
+	int $0x80
+
+	.cfi_endproc
+	.size runtime.removed_method_panic, .-runtime.removed_method_panic
+
+.data
+
 .align 2
 deadlock_panic_before:
 	.ascii "Deadlock\n"
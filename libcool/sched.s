@@ -97,7 +97,7 @@ runtime.morestack:
 	leal 12(%ebp), %esi
 	leal -4(%ebx), %edi
 	std
-	rep movsd
+	rep movsl
 	movl %edi, %ebx
 
 	// inject runtime.lessstack between our parent and its parent.
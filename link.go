@@ -0,0 +1,87 @@
+package main
+
+import (
+	"embed"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/BenLubar/coolc/internal/ast"
+)
+
+// libcool is the source code of the Cool runtime. It is assembled and linked
+// with the generated code when coolc is asked for an executable.
+//
+//go:embed libcool/*.s
+var libcool embed.FS
+
+// libcoolSources returns the runtime files that make up libcool.a or, when
+// coroutines are enabled, libcoolsched.a. Keep this in sync with
+// libcool/Makefile.
+func libcoolSources(coroutine bool) []string {
+	if coroutine {
+		return []string{"basic.s", "sched.s", "runtime_linux.s", "gc.s"}
+	}
+	return []string{"basic.s", "nosched.s", "runtime_linux.s", "gc.s"}
+}
+
+// link assembles asm along with the runtime and links the result into an
+// executable named output. The intermediate files are kept in a temporary
+// directory that is removed before link returns.
+func link(opt ast.Options, asm []byte, output string) error {
+	output, err := filepath.Abs(output)
+	if err != nil {
+		return err
+	}
+
+	dir, err := ioutil.TempDir("", "coolc")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	sources := libcoolSources(opt.Coroutine)
+
+	// basic_defs.s is never assembled on its own, but every other file
+	// includes it.
+	for _, name := range append(sources, "basic_defs.s") {
+		b, err := libcool.ReadFile(path.Join("libcool", name))
+		if err != nil {
+			return err
+		}
+		if err = ioutil.WriteFile(filepath.Join(dir, name), b, 0644); err != nil {
+			return err
+		}
+	}
+
+	sources = append(sources, "program.s")
+	if err = ioutil.WriteFile(filepath.Join(dir, "program.s"), asm, 0644); err != nil {
+		return err
+	}
+
+	objects := make([]string, len(sources))
+	for i, name := range sources {
+		objects[i] = strings.TrimSuffix(name, ".s") + ".o"
+
+		if err = runTool(dir, "as", "-32", "-g", "--fatal-warnings", "-o", objects[i], name); err != nil {
+			return err
+		}
+	}
+
+	return runTool(dir, "ld", append([]string{"-melf_i386", "-o", output, "--start-group"}, objects...)...)
+}
+
+func runTool(dir, name string, args ...string) error {
+	cmd := exec.Command(name, args...)
+	cmd.Dir = dir
+
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("%s: %v\n%s", name, err, output)
+	}
+
+	return nil
+}
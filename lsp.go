@@ -0,0 +1,707 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"go/token"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+
+	"github.com/BenLubar/coolc/internal/ast"
+)
+
+// completionName replaces the partial method name when the language server
+// checks a document to find the receiver of a method call being completed.
+const completionName = "coolc_complete"
+
+// lsp runs a language server that speaks the Language Server Protocol on in
+// and out until the client asks it to exit. Every open document is checked
+// together as one program each time one of them changes.
+func lsp(args []string, in io.Reader, out, errors io.Writer) int {
+	flagSet := flag.NewFlagSet("coolc lsp", flag.ContinueOnError)
+	flagSet.SetOutput(errors)
+	flagSet.Usage = func() {
+		fmt.Fprintln(errors, "Usage: coolc lsp [ -coroutine ]")
+		flagSet.PrintDefaults()
+	}
+	coroutine := flagSet.Bool("coroutine", false, "check programs with coroutine support")
+
+	if err := flagSet.Parse(args[1:]); err != nil {
+		return 1
+	}
+	if flagSet.NArg() != 0 {
+		flagSet.Usage()
+		return 1
+	}
+
+	s := &lspServer{
+		in:        bufio.NewReader(in),
+		out:       out,
+		errors:    errors,
+		coroutine: *coroutine,
+		docs:      make(map[string][]byte),
+	}
+
+	return s.serve()
+}
+
+type lspServer struct {
+	in        *bufio.Reader
+	out       io.Writer
+	errors    io.Writer
+	coroutine bool
+
+	// docs is the text of each open document by URI, and order is the
+	// order they were opened in.
+	docs  map[string][]byte
+	order []string
+
+	// builtin is the URI of the read-only copy of each builtin file.
+	builtin map[string]string
+
+	shutdown bool
+}
+
+type lspRequest struct {
+	ID     *json.RawMessage `json:"id"`
+	Method string           `json:"method"`
+	Params json.RawMessage  `json:"params"`
+}
+
+type lspResponse struct {
+	JSONRPC string           `json:"jsonrpc"`
+	ID      *json.RawMessage `json:"id"`
+	Result  interface{}      `json:"result"`
+}
+
+type lspErrorResponse struct {
+	JSONRPC string           `json:"jsonrpc"`
+	ID      *json.RawMessage `json:"id"`
+	Error   lspError         `json:"error"`
+}
+
+type lspError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+type lspNotification struct {
+	JSONRPC string      `json:"jsonrpc"`
+	Method  string      `json:"method"`
+	Params  interface{} `json:"params"`
+}
+
+type lspPosition struct {
+	Line      int `json:"line"`
+	Character int `json:"character"`
+}
+
+type lspRange struct {
+	Start lspPosition `json:"start"`
+	End   lspPosition `json:"end"`
+}
+
+type lspLocation struct {
+	URI   string   `json:"uri"`
+	Range lspRange `json:"range"`
+}
+
+type lspTextDocumentPosition struct {
+	TextDocument struct {
+		URI string `json:"uri"`
+	} `json:"textDocument"`
+	Position lspPosition `json:"position"`
+}
+
+type lspDiagnostic struct {
+	Range              lspRange                `json:"range"`
+	Severity           int                     `json:"severity"`
+	Code               string                  `json:"code,omitempty"`
+	Source             string                  `json:"source"`
+	Message            string                  `json:"message"`
+	RelatedInformation []lspRelatedInformation `json:"relatedInformation,omitempty"`
+}
+
+type lspRelatedInformation struct {
+	Location lspLocation `json:"location"`
+	Message  string      `json:"message"`
+}
+
+type lspCompletionItem struct {
+	Label  string `json:"label"`
+	Kind   int    `json:"kind"`
+	Detail string `json:"detail"`
+}
+
+// serve handles messages until the client sends exit. The return value is
+// the exit status the protocol asks for: 0 if shutdown was requested first.
+func (s *lspServer) serve() int {
+	for {
+		body, err := s.read()
+		if err != nil {
+			if err != io.EOF {
+				fmt.Fprintf(s.errors, "coolc lsp: %v\n", err)
+			}
+			return 1
+		}
+
+		var req lspRequest
+		if err := json.Unmarshal(body, &req); err != nil {
+			fmt.Fprintf(s.errors, "coolc lsp: %v\n", err)
+			continue
+		}
+
+		if req.Method == "exit" {
+			if s.shutdown {
+				return 0
+			}
+			return 1
+		}
+
+		result, rpcErr := s.handle(req.Method, req.Params)
+		if req.ID == nil {
+			continue
+		}
+		if rpcErr != nil {
+			s.write(lspErrorResponse{JSONRPC: "2.0", ID: req.ID, Error: *rpcErr})
+		} else {
+			s.write(lspResponse{JSONRPC: "2.0", ID: req.ID, Result: result})
+		}
+	}
+}
+
+// read reads the body of one message.
+func (s *lspServer) read() ([]byte, error) {
+	length := -1
+	for {
+		line, err := s.in.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		line = strings.TrimRight(line, "\r\n")
+		if line == "" {
+			break
+		}
+		if i := strings.IndexByte(line, ':'); i != -1 && strings.EqualFold(line[:i], "Content-Length") {
+			length, err = strconv.Atoi(strings.TrimSpace(line[i+1:]))
+			if err != nil {
+				return nil, fmt.Errorf("invalid header %q", line)
+			}
+		}
+	}
+	if length < 0 {
+		return nil, fmt.Errorf("message without Content-Length")
+	}
+
+	body := make([]byte, length)
+	_, err := io.ReadFull(s.in, body)
+	return body, err
+}
+
+func (s *lspServer) write(v interface{}) {
+	body, err := json.Marshal(v)
+	if err != nil {
+		panic(err)
+	}
+	fmt.Fprintf(s.out, "Content-Length: %d\r\n\r\n%s", len(body), body)
+}
+
+func (s *lspServer) handle(method string, params json.RawMessage) (interface{}, *lspError) {
+	switch method {
+	case "initialize":
+		return map[string]interface{}{
+			"capabilities": map[string]interface{}{
+				// send the full text of documents when they change.
+				"textDocumentSync":   1,
+				"hoverProvider":      true,
+				"definitionProvider": true,
+				"referencesProvider": true,
+				"completionProvider": map[string]interface{}{
+					"triggerCharacters": []string{"."},
+				},
+			},
+			"serverInfo": map[string]interface{}{
+				"name": "coolc",
+			},
+		}, nil
+
+	case "shutdown":
+		s.shutdown = true
+		return nil, nil
+
+	case "textDocument/didOpen":
+		var p struct {
+			TextDocument struct {
+				URI  string `json:"uri"`
+				Text string `json:"text"`
+			} `json:"textDocument"`
+		}
+		if err := json.Unmarshal(params, &p); err != nil {
+			return nil, &lspError{Code: -32602, Message: err.Error()}
+		}
+		if _, ok := s.docs[p.TextDocument.URI]; !ok {
+			s.order = append(s.order, p.TextDocument.URI)
+		}
+		s.docs[p.TextDocument.URI] = []byte(p.TextDocument.Text)
+		s.publish(nil)
+		return nil, nil
+
+	case "textDocument/didChange":
+		var p struct {
+			TextDocument struct {
+				URI string `json:"uri"`
+			} `json:"textDocument"`
+			ContentChanges []struct {
+				Text string `json:"text"`
+			} `json:"contentChanges"`
+		}
+		if err := json.Unmarshal(params, &p); err != nil {
+			return nil, &lspError{Code: -32602, Message: err.Error()}
+		}
+		if _, ok := s.docs[p.TextDocument.URI]; !ok || len(p.ContentChanges) == 0 {
+			return nil, nil
+		}
+		s.docs[p.TextDocument.URI] = []byte(p.ContentChanges[len(p.ContentChanges)-1].Text)
+		s.publish(nil)
+		return nil, nil
+
+	case "textDocument/didClose":
+		var p struct {
+			TextDocument struct {
+				URI string `json:"uri"`
+			} `json:"textDocument"`
+		}
+		if err := json.Unmarshal(params, &p); err != nil {
+			return nil, &lspError{Code: -32602, Message: err.Error()}
+		}
+		if _, ok := s.docs[p.TextDocument.URI]; !ok {
+			return nil, nil
+		}
+		delete(s.docs, p.TextDocument.URI)
+		for i, uri := range s.order {
+			if uri == p.TextDocument.URI {
+				s.order = append(s.order[:i], s.order[i+1:]...)
+				break
+			}
+		}
+		s.publish([]string{p.TextDocument.URI})
+		return nil, nil
+
+	case "textDocument/definition":
+		c, id, err := s.identAt(params)
+		if err != nil || id == nil {
+			return nil, err
+		}
+		def := c.prog.Definition(id)
+		if def == nil || !def.Pos.IsValid() {
+			return nil, nil
+		}
+		return c.location(def.Pos, def.End), nil
+
+	case "textDocument/references":
+		c, id, err := s.identAt(params)
+		if err != nil || id == nil {
+			return nil, err
+		}
+		var p struct {
+			Context struct {
+				IncludeDeclaration bool `json:"includeDeclaration"`
+			} `json:"context"`
+		}
+		if err := json.Unmarshal(params, &p); err != nil {
+			return nil, &lspError{Code: -32602, Message: err.Error()}
+		}
+		def := c.prog.Definition(id)
+		locations := []lspLocation{}
+		for _, ref := range c.prog.References(id) {
+			if ref == def && !p.Context.IncludeDeclaration {
+				continue
+			}
+			locations = append(locations, c.location(ref.Pos, ref.End))
+		}
+		return locations, nil
+
+	case "textDocument/hover":
+		return s.hover(params)
+
+	case "textDocument/completion":
+		return s.complete(params)
+	}
+
+	if strings.HasPrefix(method, "$/") {
+		return nil, nil
+	}
+	return nil, &lspError{Code: -32601, Message: "method not found: " + method}
+}
+
+// lspCheck is the result of checking every open document as one program.
+type lspCheck struct {
+	s     *lspServer
+	fset  *token.FileSet
+	prog  *ast.Program
+	files map[string]*token.File
+	text  map[string][]byte
+	diags []*ast.Diagnostic
+}
+
+// check parses and type checks the open documents, with the text of the
+// document uri replaced by text if uri is not empty.
+func (s *lspServer) check(uri string, text []byte) (c *lspCheck) {
+	c = &lspCheck{
+		s:     s,
+		fset:  token.NewFileSet(),
+		prog:  new(ast.Program),
+		files: make(map[string]*token.File),
+		text:  make(map[string][]byte),
+	}
+
+	opt := ast.Options{
+		Errors:    ioutil.Discard,
+		Coroutine: s.coroutine,
+		Report: func(d *ast.Diagnostic) {
+			c.diags = append(c.diags, d)
+		},
+	}
+
+	defer func() {
+		// a program that is being edited can be broken in ways the
+		// command line compiler never sees, so don't let that stop
+		// the server.
+		if r := recover(); r != nil {
+			fmt.Fprintf(s.errors, "coolc lsp: panic while checking: %v\n", r)
+		}
+	}()
+
+	add := func(name string, b []byte) {
+		f := c.fset.AddFile(name, -1, len(b))
+		f.SetLinesForContent(b)
+		c.files[name] = f
+		c.text[name] = b
+		c.prog.Parse(f, opt, bytes.NewReader(b))
+	}
+
+	add("basic.cool", basicCool)
+	if s.coroutine {
+		add("coroutine.cool", coroutineCool)
+	}
+	for _, name := range s.order {
+		if name == uri {
+			add(name, text)
+		} else {
+			add(name, s.docs[name])
+		}
+	}
+
+	c.prog.Semant(opt, c.fset)
+
+	return c
+}
+
+// publish checks the open documents and sends their diagnostics to the
+// client. closed documents get an empty list to clear their diagnostics.
+func (s *lspServer) publish(closed []string) {
+	c := s.check("", nil)
+
+	byURI := make(map[string][]lspDiagnostic)
+	for _, uri := range s.order {
+		byURI[uri] = []lspDiagnostic{}
+	}
+	for _, uri := range closed {
+		byURI[uri] = []lspDiagnostic{}
+	}
+
+	for _, d := range c.diags {
+		ld := lspDiagnostic{
+			Severity: 1,
+			Code:     d.Code,
+			Source:   "coolc",
+			Message:  d.Message,
+		}
+		if d.Severity == ast.SeverityWarning {
+			ld.Severity = 2
+		}
+		for _, n := range d.Notes {
+			if n.Pos.IsValid() {
+				ld.RelatedInformation = append(ld.RelatedInformation, lspRelatedInformation{
+					Location: c.location(n.Pos, n.End),
+					Message:  n.Message,
+				})
+			}
+		}
+
+		if !d.Pos.IsValid() {
+			// problems with the program as a whole, like a missing
+			// Main class, go at the start of every document.
+			for _, uri := range s.order {
+				byURI[uri] = append(byURI[uri], ld)
+			}
+			continue
+		}
+
+		loc := c.location(d.Pos, d.End)
+		if _, ok := s.docs[loc.URI]; !ok {
+			continue
+		}
+		ld.Range = loc.Range
+		byURI[loc.URI] = append(byURI[loc.URI], ld)
+	}
+
+	for _, uri := range append(append([]string(nil), s.order...), closed...) {
+		s.write(lspNotification{
+			JSONRPC: "2.0",
+			Method:  "textDocument/publishDiagnostics",
+			Params: map[string]interface{}{
+				"uri":         uri,
+				"diagnostics": byURI[uri],
+			},
+		})
+	}
+}
+
+// identAt checks the open documents and finds the identifier at the position
+// given in params.
+func (s *lspServer) identAt(params json.RawMessage) (*lspCheck, *ast.Ident, *lspError) {
+	var p lspTextDocumentPosition
+	if err := json.Unmarshal(params, &p); err != nil {
+		return nil, nil, &lspError{Code: -32602, Message: err.Error()}
+	}
+
+	c := s.check("", nil)
+	uri := s.fileName(p.TextDocument.URI)
+	f, ok := c.files[uri]
+	if !ok {
+		return c, nil, nil
+	}
+
+	offset := lspOffset(c.text[uri], p.Position)
+	return c, c.prog.IdentAt(f, f.Pos(offset)), nil
+}
+
+// hover describes the declaration of the identifier at the position given
+// in params. Anywhere else in an expression, such as on an operator, a
+// literal, or the parentheses around an expression, it shows the static type
+// of the smallest expression there.
+func (s *lspServer) hover(params json.RawMessage) (interface{}, *lspError) {
+	var p lspTextDocumentPosition
+	if err := json.Unmarshal(params, &p); err != nil {
+		return nil, &lspError{Code: -32602, Message: err.Error()}
+	}
+
+	c := s.check("", nil)
+	uri := s.fileName(p.TextDocument.URI)
+	f, ok := c.files[uri]
+	if !ok {
+		return nil, nil
+	}
+	pos := f.Pos(lspOffset(c.text[uri], p.Position))
+
+	var desc string
+	var start, end token.Pos
+	if id := c.prog.IdentAt(f, pos); id != nil {
+		desc, start, end = c.prog.Describe(id), id.Pos, id.End
+	}
+	if desc == "" {
+		e, eStart, eEnd := c.prog.ExprAt(f, pos)
+		if e == nil {
+			return nil, nil
+		}
+		t := c.prog.StaticType(e)
+		if t == nil {
+			return nil, nil
+		}
+		desc, start, end = t.Type.Name, eStart, eEnd
+	}
+
+	return map[string]interface{}{
+		"contents": map[string]interface{}{
+			"kind":  "markdown",
+			"value": "```cool\n" + desc + "\n```",
+		},
+		"range": c.location(start, end).Range,
+	}, nil
+}
+
+// complete lists the methods that can be called at the position given in
+// params if it is just after a '.'. The partial method name is replaced with
+// a call to a method that does not exist so that the type checker records
+// the type of the receiver even though the document is incomplete.
+func (s *lspServer) complete(params json.RawMessage) (interface{}, *lspError) {
+	var p lspTextDocumentPosition
+	if err := json.Unmarshal(params, &p); err != nil {
+		return nil, &lspError{Code: -32602, Message: err.Error()}
+	}
+
+	uri := p.TextDocument.URI
+	text, ok := s.docs[uri]
+	if !ok {
+		return nil, nil
+	}
+
+	isIdent := func(b byte) bool {
+		return b == '_' || (b >= 'a' && b <= 'z') || (b >= 'A' && b <= 'Z') || (b >= '0' && b <= '9')
+	}
+
+	offset := lspOffset(text, p.Position)
+	start, end := offset, offset
+	for start > 0 && isIdent(text[start-1]) {
+		start--
+	}
+	for end < len(text) && isIdent(text[end]) {
+		end++
+	}
+	if start == 0 || text[start-1] != '.' {
+		return []lspCompletionItem{}, nil
+	}
+
+	call := completionName
+	if rest := bytes.TrimLeft(text[end:], " \t\r\n"); len(rest) == 0 || rest[0] != '(' {
+		call += "()"
+	}
+
+	edited := make([]byte, 0, len(text)+len(call))
+	edited = append(edited, text[:start]...)
+	edited = append(edited, call...)
+	edited = append(edited, text[end:]...)
+
+	c := s.check(uri, edited)
+	f, ok := c.files[uri]
+	if !ok {
+		return []lspCompletionItem{}, nil
+	}
+
+	id := c.prog.IdentAt(f, f.Pos(start))
+	if id == nil || id.Name != completionName {
+		return []lspCompletionItem{}, nil
+	}
+	recv := c.prog.Receiver(id)
+	if recv == nil {
+		return []lspCompletionItem{}, nil
+	}
+
+	items := []lspCompletionItem{}
+	for _, m := range recv.Methods {
+		// constructors can't be called with a '.'.
+		if m.Parent != nil && m.Name.Name == m.Parent.Type.Name {
+			continue
+		}
+		items = append(items, lspCompletionItem{
+			Label:  m.Name.Name,
+			Kind:   2, // Method
+			Detail: c.prog.Describe(m.Name),
+		})
+	}
+	return items, nil
+}
+
+// fileName returns the name of the file in the program for a URI.
+func (s *lspServer) fileName(uri string) string {
+	for name, builtinURI := range s.builtin {
+		if uri == builtinURI {
+			return name
+		}
+	}
+	return uri
+}
+
+// uri returns the URI of a file in the program. The builtin files are written
+// to a read-only file in a temporary directory the first time they are needed
+// so that clients can show them.
+func (s *lspServer) uri(name string) string {
+	var src []byte
+	switch name {
+	case "basic.cool":
+		src = basicCool
+	case "coroutine.cool":
+		src = coroutineCool
+	default:
+		return name
+	}
+
+	if uri, ok := s.builtin[name]; ok {
+		return uri
+	}
+
+	dir := filepath.Join(os.TempDir(), "coolc-lsp")
+	path := filepath.Join(dir, name)
+	if old, err := ioutil.ReadFile(path); err != nil || !bytes.Equal(old, src) {
+		_ = os.MkdirAll(dir, 0755)
+		_ = os.Remove(path)
+		if err := ioutil.WriteFile(path, src, 0444); err != nil {
+			fmt.Fprintf(s.errors, "coolc lsp: %v\n", err)
+		}
+	}
+
+	uri := (&url.URL{Scheme: "file", Path: filepath.ToSlash(path)}).String()
+	if s.builtin == nil {
+		s.builtin = make(map[string]string)
+	}
+	s.builtin[name] = uri
+	return uri
+}
+
+// location converts a range of positions in the program to a location in
+// a document.
+func (c *lspCheck) location(pos, end token.Pos) lspLocation {
+	f := c.fset.File(pos)
+	text := c.text[f.Name()]
+	if !end.IsValid() || c.fset.File(end) != f || end < pos {
+		end = pos
+	}
+
+	return lspLocation{
+		URI: c.s.uri(f.Name()),
+		Range: lspRange{
+			Start: lspPositionAt(text, f.Offset(pos)),
+			End:   lspPositionAt(text, f.Offset(end)),
+		},
+	}
+}
+
+// lspPositionAt converts a byte offset to a line and a character, which the
+// protocol counts in UTF-16 code units.
+func lspPositionAt(text []byte, offset int) lspPosition {
+	if offset > len(text) {
+		offset = len(text)
+	}
+
+	start := bytes.LastIndexByte(text[:offset], '\n') + 1
+	character := 0
+	for _, r := range string(text[start:offset]) {
+		character += len(utf16.Encode([]rune{r}))
+	}
+
+	return lspPosition{
+		Line:      bytes.Count(text[:start], []byte{'\n'}),
+		Character: character,
+	}
+}
+
+// lspOffset converts a protocol position to a byte offset in text.
+func lspOffset(text []byte, p lspPosition) int {
+	offset := 0
+	for line := 0; line < p.Line; line++ {
+		i := bytes.IndexByte(text[offset:], '\n')
+		if i == -1 {
+			return len(text)
+		}
+		offset += i + 1
+	}
+
+	for character := 0; character < p.Character && offset < len(text) && text[offset] != '\n'; {
+		r, size := utf8.DecodeRune(text[offset:])
+		character += len(utf16.Encode([]rune{r}))
+		offset += size
+	}
+
+	return offset
+}
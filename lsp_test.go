@@ -0,0 +1,203 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+const lspTestURI = "file:///tmp/lsptest.cool"
+
+const lspTestBad = `class Main() extends IO() {
+	var count : Int = "zero";
+	{ out("hi") };
+}
+`
+
+const lspTestGood = `class Main() extends IO() {
+	var count : Int = 0;
+
+	def greet(name : String) : IO = out(name);
+
+	{
+		this.greet("hi");
+		count = (count + 1) * 2;
+		out_any(count)
+	};
+}
+`
+
+const lspTestIncomplete = `class Main() extends IO() {
+	{
+		"hello".
+	};
+}
+`
+
+type lspTestMessage struct {
+	ID     *int            `json:"id"`
+	Method string          `json:"method"`
+	Params json.RawMessage `json:"params"`
+	Result json.RawMessage `json:"result"`
+}
+
+func TestLSP(t *testing.T) {
+	var in bytes.Buffer
+	send := func(id int, method string, params interface{}) {
+		msg := map[string]interface{}{
+			"jsonrpc": "2.0",
+			"method":  method,
+			"params":  params,
+		}
+		if id != 0 {
+			msg["id"] = id
+		}
+		b, err := json.Marshal(msg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		fmt.Fprintf(&in, "Content-Length: %d\r\n\r\n%s", len(b), b)
+	}
+	position := func(line, character int) map[string]interface{} {
+		return map[string]interface{}{
+			"textDocument": map[string]interface{}{"uri": lspTestURI},
+			"position":     map[string]interface{}{"line": line, "character": character},
+			"context":      map[string]interface{}{"includeDeclaration": true},
+		}
+	}
+
+	send(1, "initialize", map[string]interface{}{})
+	send(0, "initialized", map[string]interface{}{})
+	send(0, "textDocument/didOpen", map[string]interface{}{
+		"textDocument": map[string]interface{}{
+			"uri":        lspTestURI,
+			"languageId": "cool",
+			"version":    1,
+			"text":       lspTestBad,
+		},
+	})
+	send(0, "textDocument/didChange", map[string]interface{}{
+		"textDocument":   map[string]interface{}{"uri": lspTestURI, "version": 2},
+		"contentChanges": []interface{}{map[string]interface{}{"text": lspTestGood}},
+	})
+	send(2, "textDocument/definition", position(7, 11)) // count
+	send(3, "textDocument/references", position(3, 7))  // greet
+	send(4, "textDocument/hover", position(3, 38))      // name
+	send(5, "textDocument/definition", position(3, 34)) // out
+	send(6, "textDocument/completion", position(6, 7))  // this.
+	send(9, "textDocument/hover", position(7, 10))      // (
+	send(10, "textDocument/hover", position(7, 17))     // +
+	send(11, "textDocument/hover", position(7, 24))     // 2
+	send(12, "textDocument/hover", position(6, 6))      // this.greet
+	send(0, "textDocument/didChange", map[string]interface{}{
+		"textDocument":   map[string]interface{}{"uri": lspTestURI, "version": 3},
+		"contentChanges": []interface{}{map[string]interface{}{"text": lspTestIncomplete}},
+	})
+	send(7, "textDocument/completion", position(2, 10)) // "hello".
+	send(8, "shutdown", nil)
+	send(0, "exit", nil)
+
+	var out, errors bytes.Buffer
+	if exit := lsp([]string{"lsp"}, &in, &out, &errors); exit != 0 {
+		t.Errorf("exit status was unexpected: %v", exit)
+	}
+	if errors.Len() != 0 {
+		t.Errorf("unexpected output on standard error:\n%s", errors.Bytes())
+	}
+
+	var diagnostics []json.RawMessage
+	results := make(map[int]json.RawMessage)
+	r := bufio.NewReader(&out)
+	for {
+		line, err := r.ReadString('\n')
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		length, err := strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(line, "Content-Length:")))
+		if err != nil {
+			t.Fatalf("unexpected header %q", line)
+		}
+		if _, err = r.ReadString('\n'); err != nil {
+			t.Fatal(err)
+		}
+		body := make([]byte, length)
+		if _, err = io.ReadFull(r, body); err != nil {
+			t.Fatal(err)
+		}
+
+		var msg lspTestMessage
+		if err = json.Unmarshal(body, &msg); err != nil {
+			t.Fatal(err)
+		}
+		if msg.Method == "textDocument/publishDiagnostics" {
+			diagnostics = append(diagnostics, msg.Params)
+		} else if msg.ID != nil {
+			results[*msg.ID] = msg.Result
+		}
+	}
+
+	check := func(name string, actual json.RawMessage, expected string) {
+		if !strings.Contains(string(actual), expected) {
+			t.Errorf("%s: expected %s in:\n%s", name, expected, actual)
+		}
+	}
+
+	if len(diagnostics) != 3 {
+		t.Fatalf("expected 3 sets of diagnostics, got %d", len(diagnostics))
+	}
+	check("diagnostics after open", diagnostics[0], `"code":"type-mismatch"`)
+	check("diagnostics after open", diagnostics[0], `"range":{"start":{"line":1,"character":13},"end":{"line":1,"character":16}}`)
+	check("diagnostics after change", diagnostics[1], `"diagnostics":[]`)
+
+	check("definition", results[2], `"uri":"`+lspTestURI+`","range":{"start":{"line":1,"character":5},"end":{"line":1,"character":10}}`)
+	check("references", results[3], `"range":{"start":{"line":3,"character":5}`)
+	check("references", results[3], `"range":{"start":{"line":6,"character":7}`)
+	check("hover", results[4], "name : String")
+	check("hover parentheses", results[9], `"value":"`+"```cool\\nInt\\n```"+`"`)
+	check("hover parentheses", results[9], `"range":{"start":{"line":7,"character":10},"end":{"line":7,"character":21}}`)
+	check("hover operator", results[10], `"range":{"start":{"line":7,"character":11},"end":{"line":7,"character":20}}`)
+	check("hover literal", results[11], `"range":{"start":{"line":7,"character":24},"end":{"line":7,"character":25}}`)
+	check("hover call", results[12], `"value":"`+"```cool\\nIO\\n```"+`"`)
+	check("hover call", results[12], `"range":{"start":{"line":6,"character":2},"end":{"line":6,"character":18}}`)
+	check("builtin definition", results[5], `coolc-lsp/basic.cool"`)
+	check("completion", results[6], `"label":"greet","kind":2,"detail":"def Main.greet(name : String) : IO"`)
+	check("completion", results[6], `"label":"out_any"`)
+	check("completion", results[6], `"label":"toString"`)
+	check("diagnostics after incomplete change", diagnostics[2], `"code":"syntax"`)
+	check("incomplete completion", results[7], `"label":"substring"`)
+}
+
+func TestLSPBuiltinReadOnly(t *testing.T) {
+	s := &lspServer{errors: ioutil.Discard}
+	uri := s.uri("basic.cool")
+	if !strings.HasPrefix(uri, "file://") {
+		t.Fatalf("unexpected URI for basic.cool: %q", uri)
+	}
+	if name := s.fileName(uri); name != "basic.cool" {
+		t.Errorf("expected %q to map back to basic.cool, not %q", uri, name)
+	}
+
+	u, err := url.Parse(uri)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fi, err := os.Stat(filepath.FromSlash(u.Path))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Mode().Perm()&0222 != 0 {
+		t.Errorf("expected basic.cool to be read-only, but its mode is %v", fi.Mode())
+	}
+}
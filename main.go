@@ -8,6 +8,7 @@ import (
 	"io"
 	"io/ioutil"
 	"os"
+	"strconv"
 	"strings"
 
 	"github.com/BenLubar/coolc/internal/ast"
@@ -18,6 +19,16 @@ func main() {
 }
 
 func compiler(args []string, errors io.Writer) int {
+	if len(args) > 1 && args[1] == "lsp" {
+		return lsp(args[1:], os.Stdin, os.Stdout, errors)
+	}
+	if len(args) > 1 && args[1] == "fmt" {
+		return format(args[1:], os.Stdin, os.Stdout, errors)
+	}
+	if len(args) > 1 && args[1] == "doc" {
+		return documentation(args[1:], os.Stdout, errors)
+	}
+
 	var opt ast.Options
 
 	opt.Errors = errors
@@ -25,11 +36,30 @@ func compiler(args []string, errors io.Writer) int {
 	flagSet := flag.NewFlagSet("coolc", flag.ContinueOnError)
 	flagSet.SetOutput(errors)
 	flagSet.Usage = func() {
-		fmt.Fprintln(opt.Errors, "Usage:", args[0], "[ -o fileout ] file1.cool file2.cool ... filen.cool")
+		fmt.Fprintln(opt.Errors, "Usage:", args[0], "[ -o fileout ] [ -exe ] file1.cool file2.cool ... filen.cool")
+		fmt.Fprintln(opt.Errors, "      ", args[0], "-run file1.cool file2.cool ... filen.cool [ -- arg1 arg2 ... argn ]")
+		fmt.Fprintln(opt.Errors, "      ", args[0], "-interp file1.cool file2.cool ... filen.cool")
+		fmt.Fprintln(opt.Errors, "      ", args[0], "fmt [ -w ] [ -l ] file1.cool file2.cool ... filen.cool")
+		fmt.Fprintln(opt.Errors, "      ", args[0], "doc [ -format html|markdown ] [ -o fileout ] file1.cool file2.cool ... filen.cool")
+		fmt.Fprintln(opt.Errors, "      ", args[0], "lsp [ -coroutine ]")
 		flagSet.PrintDefaults()
 	}
 
 	flagOutput := flagSet.String("o", "", "output filename")
+	flagExe := flagSet.Bool("exe", false, "assemble and link the program with the runtime to produce an executable")
+	flagRun := flagSet.Bool("run", false, "build the program in a temporary directory, run it, and exit with its exit status")
+	flagInterp := flagSet.Bool("interp", false, "run the program with an interpreter instead of generating code, and exit with its exit status")
+	flagDumpAST := flagSet.String("dump-ast", "", "write the program as JSON instead of generating code, after the given stage: parsed, checked, or optimized")
+	flagPrint := flagSet.String("print", "", "write the program as Cool source code instead of generating code, after the given stage: parsed, checked, or optimized")
+	flagSet.StringVar(&opt.Diagnostics, "diagnostics", "text", "format of error messages: text, pretty (with source code), or json")
+	flagColor := flagSet.String("color", "auto", "use colors in pretty error messages: auto, always, or never")
+	opt.Warnings = make(map[string]bool)
+	opt.WarningErrors = make(map[string]bool)
+	for _, w := range ast.Warnings {
+		flagSet.Var(warningFlag{opt.Warnings, w.Name, true}, "W"+w.Name, "warn about "+w.Doc)
+		flagSet.Var(warningFlag{opt.Warnings, w.Name, false}, "Wno-"+w.Name, "don't warn about "+w.Doc)
+	}
+	flagSet.Var(werrorFlag(opt.WarningErrors), "Werror", "treat warnings as errors; -Werror=name only affects one warning")
 	flagSet.IntVar(&opt.Benchmark, "benchmark", 1, "repeat the program this many times")
 	flagSet.BoolVar(&opt.Coroutine, "coroutine", false, "enable coroutine support")
 	flagSet.BoolVar(&opt.OptInt, "opt-int", true, "optimization: use raw integers")
@@ -38,19 +68,90 @@ func compiler(args []string, errors io.Writer) int {
 	flagSet.BoolVar(&opt.OptDispatch, "opt-dispatch", true, "optimization: convert dynamic dispatch to a known method to static dispatch")
 	flagSet.BoolVar(&opt.OptFold, "opt-fold", true, "optimization: precompute the values of constant arithmetic expressions")
 	flagSet.BoolVar(&opt.OptInline, "opt-inline", true, "optimization: inline methods that are sufficiently simple")
+	flagSet.BoolVar(&opt.OptDead, "opt-dead", true, "optimization: leave out methods that are never called and classes that are never instantiated")
+	flagSet.BoolVar(&opt.OptLICM, "opt-licm", true, "optimization: move loop-invariant expressions out of loops and replace multiplications by loop counters with additions")
+	flagSet.BoolVar(&opt.OptEscape, "opt-escape", true, "optimization: keep objects that never leave the method that creates them in the stack frame")
+	flagSet.BoolVar(&opt.OptRegs, "opt-regs", true, "optimization: keep local variables and temporaries in registers")
+	flagSet.BoolVar(&opt.OptPeephole, "opt-peephole", true, "optimization: remove redundant instructions from the generated code")
+	flagSet.BoolVar(&opt.OptReport, "opt-report", false, "report which method calls were devirtualized or inlined, which constants were folded, and which null checks were removed")
 
 	if err := flagSet.Parse(args[1:]); err != nil {
 		flagSet.Usage()
 		return 1
 	}
 
-	if flagSet.NArg() == 0 {
+	switch opt.Diagnostics {
+	case "text", "pretty", "json":
+	default:
+		fmt.Fprintf(opt.Errors, "invalid value %q for flag -diagnostics\n", opt.Diagnostics)
+		flagSet.Usage()
+		return 1
+	}
+
+	// -dump-ast and -print write the program as it is after the given
+	// stage instead of generating code.
+	var inspect, inspectStage string
+	switch {
+	case *flagDumpAST != "" && *flagPrint != "":
+		fmt.Fprintln(opt.Errors, "cannot use -dump-ast and -print together")
+		flagSet.Usage()
+		return 1
+	case *flagDumpAST != "":
+		inspect, inspectStage = "dump-ast", *flagDumpAST
+	case *flagPrint != "":
+		inspect, inspectStage = "print", *flagPrint
+	}
+
+	switch inspectStage {
+	case "", "parsed", "checked", "optimized":
+	default:
+		fmt.Fprintf(opt.Errors, "invalid value %q for flag -%s\n", inspectStage, inspect)
+		flagSet.Usage()
+		return 1
+	}
+
+	switch *flagColor {
+	case "auto":
+		opt.Color = isTerminal(errors) && os.Getenv("NO_COLOR") == ""
+	case "always":
+		opt.Color = true
+	case "never":
+		opt.Color = false
+	default:
+		fmt.Fprintf(opt.Errors, "invalid value %q for flag -color\n", *flagColor)
+		flagSet.Usage()
+		return 1
+	}
+
+	sources := flagSet.Args()
+	var programArgs []string
+
+	if *flagRun {
+		for i, arg := range sources {
+			if arg == "--" {
+				sources, programArgs = sources[:i], sources[i+1:]
+				break
+			}
+		}
+	}
+
+	if len(sources) == 0 {
 		flagSet.Usage()
 		return 1
 	}
 
+	// -dump-ast and -print write to standard output unless -o is given.
+	inspectOutput := *flagOutput
+
 	if *flagOutput == "" {
-		*flagOutput = strings.TrimSuffix(flagSet.Arg(0), ".cool") + ".s"
+		if *flagExe {
+			*flagOutput = strings.TrimSuffix(sources[0], ".cool")
+			if *flagOutput == sources[0] {
+				*flagOutput += ".out"
+			}
+		} else {
+			*flagOutput = strings.TrimSuffix(sources[0], ".cool") + ".s"
+		}
 	}
 
 	if opt.Benchmark < 1 {
@@ -59,12 +160,13 @@ func compiler(args []string, errors io.Writer) int {
 
 	fset := token.NewFileSet()
 
-	var haveErrors bool
+	var haveErrors, haveReadErrors bool
 	var prog ast.Program
 
 	{
 		f := fset.AddFile("basic.cool", -1, len(basicCool))
 		f.SetLinesForContent(basicCool)
+		prog.MarkBuiltin(f)
 
 		haveErrors = prog.Parse(f, opt, bytes.NewReader(basicCool))
 	}
@@ -72,15 +174,16 @@ func compiler(args []string, errors io.Writer) int {
 	if opt.Coroutine {
 		f := fset.AddFile("coroutine.cool", -1, len(coroutineCool))
 		f.SetLinesForContent(coroutineCool)
+		prog.MarkBuiltin(f)
 
 		haveErrors = prog.Parse(f, opt, bytes.NewReader(coroutineCool))
 	}
 
-	for _, name := range flagSet.Args() {
+	for _, name := range sources {
 		b, err := ioutil.ReadFile(name)
 		if err != nil {
-			fmt.Fprintf(opt.Errors, "%s: %v", name, err)
-			haveErrors = true
+			ast.ReportError(opt, "read", fmt.Sprintf("%s: %v", name, err))
+			haveReadErrors = true
 			continue
 		}
 
@@ -90,26 +193,169 @@ func compiler(args []string, errors io.Writer) int {
 		haveErrors = prog.Parse(f, opt, bytes.NewReader(b)) || haveErrors
 	}
 
-	if haveErrors {
+	if haveReadErrors {
 		return 2
 	}
 
-	if prog.Semant(opt, fset) {
+	if inspect != "" {
+		dump := func(stage string) {
+			if stage == inspectStage && !writeInspect(opt, &prog, fset, inspect, stage, inspectOutput) {
+				haveErrors = true
+			}
+		}
+
+		if inspectStage == "parsed" {
+			if haveErrors {
+				return 2
+			}
+			dump("parsed")
+			if haveErrors {
+				return 2
+			}
+			return 0
+		}
+
+		opt.Dump = dump
+	}
+
+	// the parser keeps the classes it could parse, so type check them
+	// even if there were syntax errors to report as many errors as
+	// possible at once.
+	if prog.Semant(opt, fset) || haveErrors {
 		return 2
 	}
 
+	if inspect != "" {
+		return 0
+	}
+
+	if *flagInterp {
+		return prog.Interp(opt, os.Stdin, os.Stdout)
+	}
+
+	if *flagExe || *flagRun {
+		var buf bytes.Buffer
+
+		err := prog.CodeGen(opt, fset, &buf)
+		if err != nil {
+			ast.ReportError(opt, "codegen", fmt.Sprintf("error during code generation: %v", err))
+			return 2
+		}
+
+		if *flagRun {
+			return run(opt, buf.Bytes(), programArgs)
+		}
+
+		err = link(opt, buf.Bytes(), *flagOutput)
+		if err != nil {
+			ast.ReportError(opt, "link", fmt.Sprintf("error during linking: %v", err))
+			return 2
+		}
+
+		return 0
+	}
+
 	f, err := os.Create(*flagOutput)
 	if err != nil {
-		fmt.Fprintf(opt.Errors, "%s: %v\n", *flagOutput, err)
+		ast.ReportError(opt, "write", fmt.Sprintf("%s: %v", *flagOutput, err))
 		return 2
 	}
 	defer f.Close()
 
 	err = prog.CodeGen(opt, fset, f)
 	if err != nil {
-		fmt.Fprintf(opt.Errors, "error during code generation: %v\n", err)
+		ast.ReportError(opt, "codegen", fmt.Sprintf("error during code generation: %v", err))
 		return 2
 	}
 
 	return 0
 }
+
+// writeInspect writes the program for -dump-ast or -print to the named file,
+// or to standard output if name is empty. It returns false if there was an
+// error.
+func writeInspect(opt ast.Options, prog *ast.Program, fset *token.FileSet, inspect, stage, name string) bool {
+	var w io.Writer = os.Stdout
+	if name != "" {
+		f, err := os.Create(name)
+		if err != nil {
+			ast.ReportError(opt, "write", fmt.Sprintf("%s: %v", name, err))
+			return false
+		}
+		defer f.Close()
+
+		w = f
+	}
+
+	var err error
+	if inspect == "dump-ast" {
+		err = prog.DumpAST(w, fset, stage)
+	} else {
+		err = prog.PrintSource(opt, fset, w)
+	}
+	if err != nil {
+		ast.ReportError(opt, "write", fmt.Sprintf("error writing program: %v", err))
+		return false
+	}
+
+	return true
+}
+
+// isTerminal returns true if w is a terminal that can display colors.
+func isTerminal(w io.Writer) bool {
+	f, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+
+	fi, err := f.Stat()
+	if err != nil {
+		return false
+	}
+
+	return fi.Mode()&os.ModeCharDevice != 0 && os.Getenv("TERM") != "dumb"
+}
+
+// warningFlag is a boolean flag that turns a warning on (-Wname) or off
+// (-Wno-name).
+type warningFlag struct {
+	warnings map[string]bool
+	name     string
+	on       bool
+}
+
+func (f warningFlag) IsBoolFlag() bool { return true }
+func (f warningFlag) String() string   { return "" }
+
+func (f warningFlag) Set(s string) error {
+	b, err := strconv.ParseBool(s)
+	if err != nil {
+		return err
+	}
+
+	f.warnings[f.name] = b == f.on
+	return nil
+}
+
+// werrorFlag is -Werror, which makes every warning an error, or
+// -Werror=name, which makes one warning an error.
+type werrorFlag map[string]bool
+
+func (f werrorFlag) IsBoolFlag() bool { return true }
+func (f werrorFlag) String() string   { return "" }
+
+func (f werrorFlag) Set(s string) error {
+	if b, err := strconv.ParseBool(s); err == nil {
+		f[""] = b
+		return nil
+	}
+
+	for _, w := range ast.Warnings {
+		if w.Name == s {
+			f[s] = true
+			return nil
+		}
+	}
+
+	return fmt.Errorf("unknown warning %q", s)
+}
@@ -10,30 +10,330 @@ func TestBad0000(t *testing.T) {
 	testBad(t, "bad0000")
 }
 
+func TestBad0000JSON(t *testing.T) {
+	testBadJSON(t, "bad0000")
+}
+
+func TestBad0000Pretty(t *testing.T) {
+	testBadPretty(t, "bad0000")
+}
+
 func TestBad0001(t *testing.T) {
 	testBad(t, "bad0001")
 }
 
+func TestBad0001JSON(t *testing.T) {
+	testBadJSON(t, "bad0001")
+}
+
+func TestBad0001Pretty(t *testing.T) {
+	testBadPretty(t, "bad0001")
+}
+
 func TestBad0002(t *testing.T) {
 	testBad(t, "bad0002")
 }
 
+func TestBad0002JSON(t *testing.T) {
+	testBadJSON(t, "bad0002")
+}
+
+func TestBad0002Pretty(t *testing.T) {
+	testBadPretty(t, "bad0002")
+}
+
 func TestBad0003(t *testing.T) {
 	testBad(t, "bad0003")
 }
 
+func TestBad0003JSON(t *testing.T) {
+	testBadJSON(t, "bad0003")
+}
+
+func TestBad0003Pretty(t *testing.T) {
+	testBadPretty(t, "bad0003")
+}
+
 func TestBad0004(t *testing.T) {
 	testBad(t, "bad0004")
 }
 
+func TestBad0004JSON(t *testing.T) {
+	testBadJSON(t, "bad0004")
+}
+
+func TestBad0004Pretty(t *testing.T) {
+	testBadPretty(t, "bad0004")
+}
+
 func TestBad0005(t *testing.T) {
 	testBad(t, "bad0005")
 }
 
+func TestBad0005JSON(t *testing.T) {
+	testBadJSON(t, "bad0005")
+}
+
+func TestBad0005Pretty(t *testing.T) {
+	testBadPretty(t, "bad0005")
+}
+
 func TestBad0006(t *testing.T) {
 	testBad(t, "bad0006")
 }
 
+func TestBad0006JSON(t *testing.T) {
+	testBadJSON(t, "bad0006")
+}
+
+func TestBad0006Pretty(t *testing.T) {
+	testBadPretty(t, "bad0006")
+}
+
+func TestBad0007(t *testing.T) {
+	testBad(t, "bad0007")
+}
+
+func TestBad0007JSON(t *testing.T) {
+	testBadJSON(t, "bad0007")
+}
+
+func TestBad0007Pretty(t *testing.T) {
+	testBadPretty(t, "bad0007")
+}
+
+func TestBad0008(t *testing.T) {
+	testBad(t, "bad0008")
+}
+
+func TestBad0008JSON(t *testing.T) {
+	testBadJSON(t, "bad0008")
+}
+
+func TestBad0008Pretty(t *testing.T) {
+	testBadPretty(t, "bad0008")
+}
+
+func TestBad0009(t *testing.T) {
+	testBad(t, "bad0009")
+}
+
+func TestBad0009JSON(t *testing.T) {
+	testBadJSON(t, "bad0009")
+}
+
+func TestBad0009Pretty(t *testing.T) {
+	testBadPretty(t, "bad0009")
+}
+
+func TestBad0010(t *testing.T) {
+	testBad(t, "bad0010")
+}
+
+func TestBad0010JSON(t *testing.T) {
+	testBadJSON(t, "bad0010")
+}
+
+func TestBad0010Pretty(t *testing.T) {
+	testBadPretty(t, "bad0010")
+}
+
+func TestBad0011(t *testing.T) {
+	testBad(t, "bad0011")
+}
+
+func TestBad0011JSON(t *testing.T) {
+	testBadJSON(t, "bad0011")
+}
+
+func TestBad0011Pretty(t *testing.T) {
+	testBadPretty(t, "bad0011")
+}
+
+func TestBad0012(t *testing.T) {
+	testBad(t, "bad0012")
+}
+
+func TestBad0012JSON(t *testing.T) {
+	testBadJSON(t, "bad0012")
+}
+
+func TestBad0012Pretty(t *testing.T) {
+	testBadPretty(t, "bad0012")
+}
+
+func TestBad0013(t *testing.T) {
+	testBad(t, "bad0013")
+}
+
+func TestBad0013JSON(t *testing.T) {
+	testBadJSON(t, "bad0013")
+}
+
+func TestBad0013Pretty(t *testing.T) {
+	testBadPretty(t, "bad0013")
+}
+
+func TestBad0014(t *testing.T) {
+	testBad(t, "bad0014")
+}
+
+func TestBad0014JSON(t *testing.T) {
+	testBadJSON(t, "bad0014")
+}
+
+func TestBad0014Pretty(t *testing.T) {
+	testBadPretty(t, "bad0014")
+}
+
+func TestBad0015(t *testing.T) {
+	testBad(t, "bad0015")
+}
+
+func TestBad0015JSON(t *testing.T) {
+	testBadJSON(t, "bad0015")
+}
+
+func TestBad0015Pretty(t *testing.T) {
+	testBadPretty(t, "bad0015")
+}
+
+func TestWarn0000(t *testing.T) {
+	testWarn(t, "warn0000")
+}
+
+func TestWarn0000Error(t *testing.T) {
+	testWarnError(t, "warn0000")
+}
+
+func TestWarn0001(t *testing.T) {
+	testWarn(t, "warn0001")
+}
+
+func TestWarn0001Error(t *testing.T) {
+	testWarnError(t, "warn0001")
+}
+
+func TestFmt0000(t *testing.T) {
+	testFmt(t, "fmt0000")
+}
+
+func TestOpt0000(t *testing.T) {
+	testOpt(t, "opt0000")
+}
+
+func TestOpt0000Report(t *testing.T) {
+	testOptReport(t, "opt0000")
+}
+
+func TestOpt0000Print(t *testing.T) {
+	testOptPrint(t, "opt0000")
+}
+
+func TestOpt0001(t *testing.T) {
+	testOpt(t, "opt0001")
+}
+
+func TestOpt0001Report(t *testing.T) {
+	testOptReport(t, "opt0001")
+}
+
+func TestOpt0001Print(t *testing.T) {
+	testOptPrint(t, "opt0001")
+}
+
+func TestOpt0002(t *testing.T) {
+	testOpt(t, "opt0002")
+}
+
+func TestOpt0002Report(t *testing.T) {
+	testOptReport(t, "opt0002")
+}
+
+func TestOpt0002Print(t *testing.T) {
+	testOptPrint(t, "opt0002")
+}
+
+func TestOpt0003(t *testing.T) {
+	testOpt(t, "opt0003")
+}
+
+func TestOpt0003Report(t *testing.T) {
+	testOptReport(t, "opt0003")
+}
+
+func TestOpt0003Print(t *testing.T) {
+	testOptPrint(t, "opt0003")
+}
+
+func TestOpt0004(t *testing.T) {
+	testOpt(t, "opt0004")
+}
+
+func TestOpt0004Report(t *testing.T) {
+	testOptReport(t, "opt0004")
+}
+
+func TestOpt0004Print(t *testing.T) {
+	testOptPrint(t, "opt0004")
+}
+
+func TestOpt0005(t *testing.T) {
+	testOpt(t, "opt0005")
+}
+
+func TestOpt0005Report(t *testing.T) {
+	testOptReport(t, "opt0005")
+}
+
+func TestOpt0005Print(t *testing.T) {
+	testOptPrint(t, "opt0005")
+}
+
+func TestOpt0006(t *testing.T) {
+	testOpt(t, "opt0006")
+}
+
+func TestOpt0006Report(t *testing.T) {
+	testOptReport(t, "opt0006")
+}
+
+func TestOpt0006Print(t *testing.T) {
+	testOptPrint(t, "opt0006")
+}
+
+func TestOpt0007(t *testing.T) {
+	testOpt(t, "opt0007")
+}
+
+func TestOpt0007Report(t *testing.T) {
+	testOptReport(t, "opt0007")
+}
+
+func TestOpt0007Print(t *testing.T) {
+	testOptPrint(t, "opt0007")
+}
+
+func TestOpt0008(t *testing.T) {
+	testOpt(t, "opt0008")
+}
+
+func TestOpt0008Report(t *testing.T) {
+	testOptReport(t, "opt0008")
+}
+
+func TestOpt0008Print(t *testing.T) {
+	testOptPrint(t, "opt0008")
+}
+
+func TestDump0000Parsed(t *testing.T) {
+	testDump(t, "dump0000", "parsed")
+}
+
+func TestDump0000Checked(t *testing.T) {
+	testDump(t, "dump0000", "checked")
+}
+
 func TestGood0000(t *testing.T) {
 	testGood(t, "good0000", "libcool.a")
 }
@@ -46,6 +346,18 @@ func TestGood0000Co(t *testing.T) {
 func BenchmarkGood0000Co(b *testing.B) {
 	benchmarkGood(b, "good0000", "libcoolsched.a", "-coroutine")
 }
+func TestGood0000Exe(t *testing.T) {
+	testExe(t, "good0000")
+}
+func TestGood0000Run(t *testing.T) {
+	testRun(t, "good0000", "-run")
+}
+func TestGood0000Interp(t *testing.T) {
+	testRun(t, "good0000", "-interp")
+}
+func TestGood0000CoInterp(t *testing.T) {
+	testRun(t, "good0000", "-interp", "-coroutine")
+}
 
 func TestGood0001(t *testing.T) {
 	testGood(t, "good0001", "libcool.a")
@@ -59,6 +371,18 @@ func TestGood0001Co(t *testing.T) {
 func BenchmarkGood0001Co(b *testing.B) {
 	benchmarkGood(b, "good0001", "libcoolsched.a", "-coroutine")
 }
+func TestGood0001Exe(t *testing.T) {
+	testExe(t, "good0001")
+}
+func TestGood0001Run(t *testing.T) {
+	testRun(t, "good0001", "-run")
+}
+func TestGood0001Interp(t *testing.T) {
+	testRun(t, "good0001", "-interp")
+}
+func TestGood0001CoInterp(t *testing.T) {
+	testRun(t, "good0001", "-interp", "-coroutine")
+}
 
 func TestGood0002(t *testing.T) {
 	testGood(t, "good0002", "libcool.a")
@@ -72,6 +396,18 @@ func TestGood0002Co(t *testing.T) {
 func BenchmarkGood0002Co(b *testing.B) {
 	benchmarkGood(b, "good0002", "libcoolsched.a", "-coroutine")
 }
+func TestGood0002Exe(t *testing.T) {
+	testExe(t, "good0002")
+}
+func TestGood0002Run(t *testing.T) {
+	testRun(t, "good0002", "-run")
+}
+func TestGood0002Interp(t *testing.T) {
+	testRun(t, "good0002", "-interp")
+}
+func TestGood0002CoInterp(t *testing.T) {
+	testRun(t, "good0002", "-interp", "-coroutine")
+}
 
 func TestGood0003(t *testing.T) {
 	testGood(t, "good0003", "libcool.a")
@@ -85,6 +421,68 @@ func TestGood0003Co(t *testing.T) {
 func BenchmarkGood0003Co(b *testing.B) {
 	benchmarkGood(b, "good0003", "libcoolsched.a", "-coroutine")
 }
+func TestGood0003Exe(t *testing.T) {
+	testExe(t, "good0003")
+}
+func TestGood0003Run(t *testing.T) {
+	testRun(t, "good0003", "-run")
+}
+func TestGood0003Interp(t *testing.T) {
+	testRun(t, "good0003", "-interp")
+}
+func TestGood0003CoInterp(t *testing.T) {
+	testRun(t, "good0003", "-interp", "-coroutine")
+}
+
+func TestGood0004(t *testing.T) {
+	testGood(t, "good0004", "libcool.a")
+}
+func BenchmarkGood0004(b *testing.B) {
+	benchmarkGood(b, "good0004", "libcool.a")
+}
+func TestGood0004Co(t *testing.T) {
+	testGood(t, "good0004", "libcoolsched.a", "-coroutine")
+}
+func BenchmarkGood0004Co(b *testing.B) {
+	benchmarkGood(b, "good0004", "libcoolsched.a", "-coroutine")
+}
+func TestGood0004Exe(t *testing.T) {
+	testExe(t, "good0004")
+}
+func TestGood0004Run(t *testing.T) {
+	testRun(t, "good0004", "-run")
+}
+func TestGood0004Interp(t *testing.T) {
+	testRun(t, "good0004", "-interp")
+}
+func TestGood0004CoInterp(t *testing.T) {
+	testRun(t, "good0004", "-interp", "-coroutine")
+}
+
+func TestGood0005(t *testing.T) {
+	testGood(t, "good0005", "libcool.a")
+}
+func BenchmarkGood0005(b *testing.B) {
+	benchmarkGood(b, "good0005", "libcool.a")
+}
+func TestGood0005Co(t *testing.T) {
+	testGood(t, "good0005", "libcoolsched.a", "-coroutine")
+}
+func BenchmarkGood0005Co(b *testing.B) {
+	benchmarkGood(b, "good0005", "libcoolsched.a", "-coroutine")
+}
+func TestGood0005Exe(t *testing.T) {
+	testExe(t, "good0005")
+}
+func TestGood0005Run(t *testing.T) {
+	testRun(t, "good0005", "-run")
+}
+func TestGood0005Interp(t *testing.T) {
+	testRun(t, "good0005", "-interp")
+}
+func TestGood0005CoInterp(t *testing.T) {
+	testRun(t, "good0005", "-interp", "-coroutine")
+}
 
 func TestCoroutine0000Co(t *testing.T) {
 	testGood(t, "coroutine0000", "libcoolsched.a", "-coroutine")
@@ -92,6 +490,12 @@ func TestCoroutine0000Co(t *testing.T) {
 func BenchmarkCoroutine0000Co(b *testing.B) {
 	benchmarkGood(b, "coroutine0000", "libcoolsched.a", "-coroutine")
 }
+func TestCoroutine0000CoExe(t *testing.T) {
+	testExe(t, "coroutine0000", "-coroutine")
+}
+func TestCoroutine0000CoInterp(t *testing.T) {
+	testRun(t, "coroutine0000", "-interp", "-coroutine")
+}
 
 func TestCoroutine0001Co(t *testing.T) {
 	testGood(t, "coroutine0001", "libcoolsched.a", "-coroutine")
@@ -99,6 +503,12 @@ func TestCoroutine0001Co(t *testing.T) {
 func BenchmarkCoroutine0001Co(b *testing.B) {
 	benchmarkGood(b, "coroutine0001", "libcoolsched.a", "-coroutine")
 }
+func TestCoroutine0001CoExe(t *testing.T) {
+	testExe(t, "coroutine0001", "-coroutine")
+}
+func TestCoroutine0001CoInterp(t *testing.T) {
+	testRun(t, "coroutine0001", "-interp", "-coroutine")
+}
 
 func TestCoroutine0002Co(t *testing.T) {
 	testGood(t, "coroutine0002", "libcoolsched.a", "-coroutine")
@@ -106,3 +516,9 @@ func TestCoroutine0002Co(t *testing.T) {
 func BenchmarkCoroutine0002Co(b *testing.B) {
 	benchmarkGood(b, "coroutine0002", "libcoolsched.a", "-coroutine")
 }
+func TestCoroutine0002CoExe(t *testing.T) {
+	testExe(t, "coroutine0002", "-coroutine")
+}
+func TestCoroutine0002CoInterp(t *testing.T) {
+	testRun(t, "coroutine0002", "-interp", "-coroutine")
+}
@@ -37,7 +37,75 @@ func main() {
 func TestBad%[1]s(t *testing.T) {
 	testBad(t, %[2]q)
 }
+
+func TestBad%[1]sJSON(t *testing.T) {
+	testBadJSON(t, %[2]q)
+}
+
+func TestBad%[1]sPretty(t *testing.T) {
+	testBadPretty(t, %[2]q)
+}
 `, name[len("bad"):][:4], name[:len("bad")+4])
+	}
+	warn, err := filepath.Glob("warn????.cool")
+	if err != nil {
+		panic(err)
+	}
+	for _, name := range warn {
+		fmt.Fprintf(f, `
+func TestWarn%[1]s(t *testing.T) {
+	testWarn(t, %[2]q)
+}
+
+func TestWarn%[1]sError(t *testing.T) {
+	testWarnError(t, %[2]q)
+}
+`, name[len("warn"):][:4], name[:len("warn")+4])
+	}
+	fmtTests, err := filepath.Glob("fmt????.cool")
+	if err != nil {
+		panic(err)
+	}
+	for _, name := range fmtTests {
+		fmt.Fprintf(f, `
+func TestFmt%[1]s(t *testing.T) {
+	testFmt(t, %[2]q)
+}
+`, name[len("fmt"):][:4], name[:len("fmt")+4])
+	}
+	opt, err := filepath.Glob("opt????.cool")
+	if err != nil {
+		panic(err)
+	}
+	for _, name := range opt {
+		fmt.Fprintf(f, `
+func TestOpt%[1]s(t *testing.T) {
+	testOpt(t, %[2]q)
+}
+
+func TestOpt%[1]sReport(t *testing.T) {
+	testOptReport(t, %[2]q)
+}
+
+func TestOpt%[1]sPrint(t *testing.T) {
+	testOptPrint(t, %[2]q)
+}
+`, name[len("opt"):][:4], name[:len("opt")+4])
+	}
+	dump, err := filepath.Glob("dump????.cool")
+	if err != nil {
+		panic(err)
+	}
+	for _, name := range dump {
+		fmt.Fprintf(f, `
+func TestDump%[1]sParsed(t *testing.T) {
+	testDump(t, %[2]q, "parsed")
+}
+
+func TestDump%[1]sChecked(t *testing.T) {
+	testDump(t, %[2]q, "checked")
+}
+`, name[len("dump"):][:4], name[:len("dump")+4])
 	}
 	good, err := filepath.Glob("good????.cool")
 	if err != nil {
@@ -57,6 +125,18 @@ func TestGood%[1]sCo(t *testing.T) {
 func BenchmarkGood%[1]sCo(b *testing.B) {
 	benchmarkGood(b, %[2]q, "libcoolsched.a", "-coroutine")
 }
+func TestGood%[1]sExe(t *testing.T) {
+	testExe(t, %[2]q)
+}
+func TestGood%[1]sRun(t *testing.T) {
+	testRun(t, %[2]q, "-run")
+}
+func TestGood%[1]sInterp(t *testing.T) {
+	testRun(t, %[2]q, "-interp")
+}
+func TestGood%[1]sCoInterp(t *testing.T) {
+	testRun(t, %[2]q, "-interp", "-coroutine")
+}
 `, name[len("good"):][:4], name[:len("good")+4])
 	}
 	coroutine, err := filepath.Glob("coroutine????.cool")
@@ -71,6 +151,12 @@ func TestCoroutine%[1]sCo(t *testing.T) {
 func BenchmarkCoroutine%[1]sCo(b *testing.B) {
 	benchmarkGood(b, %[2]q, "libcoolsched.a", "-coroutine")
 }
+func TestCoroutine%[1]sCoExe(t *testing.T) {
+	testExe(t, %[2]q, "-coroutine")
+}
+func TestCoroutine%[1]sCoInterp(t *testing.T) {
+	testRun(t, %[2]q, "-interp", "-coroutine")
+}
 `, name[len("coroutine"):][:4], name[:len("coroutine")+4])
 	}
 }
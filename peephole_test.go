@@ -0,0 +1,73 @@
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestPeephole(t *testing.T) {
+	programs, err := filepath.Glob(filepath.Join("testdata", "opt????.cool"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range programs {
+		b, err := ioutil.ReadFile(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, args := range [][]string{
+			nil,
+			{"-opt-regs=false"},
+			{"-opt-int=false"},
+			{"-opt-jump=false"},
+			{"-coroutine"},
+		} {
+			asm := compileSource(t, string(b), args...)
+			for _, m := range peepholeMatches(asm) {
+				t.Errorf("%s %v: redundant instructions left in generated code:\n%s", name, args, m)
+			}
+		}
+	}
+
+	// opt0008 is written to give the rules something to remove.
+	b, err := ioutil.ReadFile(filepath.Join("testdata", "opt0008.cool"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	plain := compileSource(t, string(b), "-opt-peephole=false")
+	if len(peepholeMatches(plain)) == 0 {
+		t.Errorf("expected redundant instructions with -opt-peephole=false")
+	}
+}
+
+// peepholeMatches returns the pairs of lines in asm that -opt-peephole
+// should have removed.
+func peepholeMatches(asm string) []string {
+	var matches []string
+	lines := strings.Split(asm, "\n")
+	for i := 1; i < len(lines); i++ {
+		a, b := strings.TrimSpace(lines[i-1]), strings.TrimSpace(lines[i])
+		pa, pb := strings.Fields(a), strings.Fields(b)
+		if len(pa) != 2 || len(pb) < 1 {
+			continue
+		}
+		switch {
+		case (pa[0] == "pop" || pa[0] == "popl") && (pb[0] == "push" || pb[0] == "pushl") && len(pb) == 2 && pa[1] == pb[1]:
+		case pa[0] == "jmp" && b == strings.TrimSuffix(pa[1], "f")+":":
+		case pa[0] == "test" && a == b:
+		default:
+			continue
+		}
+		matches = append(matches, a+"\n"+b)
+	}
+	for _, l := range lines {
+		if f := strings.Fields(l); len(f) == 3 && f[0] == "movl" && f[1] == f[2]+"," {
+			matches = append(matches, strings.TrimSpace(l))
+		}
+	}
+	return matches
+}
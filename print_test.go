@@ -0,0 +1,116 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const printTestSource = `class Main() extends IO() {
+	var x : Int = 1;
+
+	def get() : Int = x;
+
+	{
+		out_any(get())
+	};
+}
+`
+
+func runPrint(t *testing.T, stage string) string {
+	dir, err := ioutil.TempDir("", "coolc-print")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	source := filepath.Join(dir, "main.cool")
+	if err = ioutil.WriteFile(source, []byte(printTestSource), 0644); err != nil {
+		t.Fatal(err)
+	}
+	output := filepath.Join(dir, "printed.cool")
+
+	out, exit := runCompiler([]string{"coolc", "-print=" + stage, "-o", output, source})
+	if exit != 0 {
+		t.Fatalf("exit status was unexpected: %v\n%s", exit, out)
+	}
+
+	b, err := ioutil.ReadFile(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the printed program must be valid Cool.
+	out, exit = runCompiler([]string{"coolc", "-print=checked", "-o", filepath.Join(dir, "reprinted.cool"), output})
+	if exit != 0 {
+		t.Errorf("printed program does not compile: %v\n%s\n%s", exit, out, b)
+	}
+
+	return string(b)
+}
+
+func TestPrintParsed(t *testing.T) {
+	out := runPrint(t, "parsed")
+
+	for _, expected := range []string{
+		"class Main() extends IO() {\n\tvar x : Int = 1;\n",
+		"\tdef get() : Int = x;\n",
+		"\t{\n\t\tout_any(get())\n\t};\n}\n",
+	} {
+		if !strings.Contains(out, expected) {
+			t.Errorf("expected %q in output:\n%s", expected, out)
+		}
+	}
+	if strings.Contains(out, "constructor") {
+		t.Errorf("constructors should not exist before type checking:\n%s", out)
+	}
+}
+
+func TestPrintOptimized(t *testing.T) {
+	out := runPrint(t, "optimized")
+
+	for _, expected := range []string{
+		"\t\t/*static*/out_any(/*static*/get())\n",
+		"\t// constructor:\n\t// def Main() : Main = {\n\t// \t{\n\t// \t\t/*inlined from IO.IO*/ var this_ : IO = this;\n",
+		// the inlined variables are renamed so they don't hide each other.
+		"/*inlined from IO.out_any*/ var this_ : IO = this;\n",
+		"/*inlined from Main.get*/ var this_2 : Main = this;\n",
+		"\tx/*of this_2*/\n",
+		"\t// };\n}\n",
+	} {
+		if !strings.Contains(out, expected) {
+			t.Errorf("expected %q in output:\n%s", expected, out)
+		}
+	}
+}
+
+func TestPrintOptimizedConstructor(t *testing.T) {
+	dir, err := ioutil.TempDir("", "coolc-print")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	output := filepath.Join(dir, "printed.cool")
+	if out, exit := runCompiler([]string{"coolc", "-print=optimized", "-o", output, filepath.Join("testdata", "good0000.cool")}); exit != 0 {
+		t.Fatalf("exit status was unexpected: %v\n%s", exit, out)
+	}
+
+	b, err := ioutil.ReadFile(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the inlined constructor of Sieve sets the attributes of the new
+	// object, which Cool has no syntax for, so the call is printed.
+	expected := "var s : Sieve = /*inlined from Sieve.Sieve*/ new Sieve(2);\n"
+	if !strings.Contains(string(b), expected) {
+		t.Errorf("expected %q in output:\n%s", expected, b)
+	}
+
+	if out, exit := runCompiler([]string{"coolc", "-o", os.DevNull, output}); exit != 0 {
+		t.Errorf("printed program does not compile: %v\n%s\n%s", exit, out, b)
+	}
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const reportTestSource = `class Shape() {
+	def area() : Int = 0;
+	def sides() : Int = 3 + 1;
+	def count(n : Int) : Int = if (n <= 0) 0 else 1 + count(n - 1);
+}
+
+class Square(var side : Int) extends Shape() {
+	override def area() : Int = side * side;
+}
+
+class Main() extends IO() {
+	var s : Shape = new Square(2);
+	{
+		out_any(s.area());
+		out_any(new Shape().sides());
+		out_any(s.sides());
+		out_any(s.count(2))
+	};
+}
+`
+
+func TestOptReport(t *testing.T) {
+	dir, err := ioutil.TempDir("", "coolc-report")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	source := filepath.Join(dir, "main.cool")
+	if err = ioutil.WriteFile(source, []byte(reportTestSource), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	out, exit := runCompiler([]string{"coolc", "-opt-report", "-o", os.DevNull, source})
+	if exit != 0 {
+		t.Fatalf("exit status was unexpected: %v\n%s", exit, out)
+	}
+
+	for _, expected := range []string{
+		"main.cool:3:24: remark: folded 3 + 1 to 4\n",
+		"main.cool:4:52: remark: did not inline call to Shape.count: it is recursive\n",
+		"main.cool:4:52: remark: removed null check before call to Shape.count: the receiver is never null\n",
+		"main.cool:14:13: remark: call to Shape.area uses dynamic dispatch: overridden in Square\n",
+		"main.cool:14:13: remark: did not inline call to Shape.area: it uses dynamic dispatch\n",
+		"main.cool:14:3: remark: call to IO.out_any uses static dispatch: no subclass overrides it\n",
+		"main.cool:15:15: remark: inlined call to constructor Shape\n",
+		"main.cool:15:23: remark: inlined call to Shape.sides\n",
+		// the receiver might be null, so the inlined body checks it.
+		"main.cool:16:13: remark: inlined call to Shape.sides\n",
+		"main.cool:17:13: remark: inlined call to Shape.count\n",
+	} {
+		if !strings.Contains(string(out), expected) {
+			t.Errorf("expected %q in output:\n%s", expected, out)
+		}
+	}
+
+	if strings.Contains(string(out), "basic.cool") {
+		t.Errorf("the basic classes should not be reported:\n%s", out)
+	}
+
+	out, exit = runCompiler([]string{"coolc", "-o", os.DevNull, source})
+	if exit != 0 || len(out) != 0 {
+		t.Errorf("expected no output without -opt-report, not %v:\n%s", exit, out)
+	}
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"syscall"
+
+	"github.com/BenLubar/coolc/internal/ast"
+)
+
+// run links asm into an executable in a temporary directory and runs it with
+// the given arguments, connected to coolc's standard input and output. The
+// program's standard error goes to opt.Errors. The return value is the exit
+// status of the program, or 2 if it could not be started.
+func run(opt ast.Options, asm []byte, args []string) int {
+	dir, err := ioutil.TempDir("", "coolc")
+	if err != nil {
+		ast.ReportError(opt, "run", err.Error())
+		return 2
+	}
+	defer os.RemoveAll(dir)
+
+	exe := filepath.Join(dir, "program")
+
+	err = link(opt, asm, exe)
+	if err != nil {
+		ast.ReportError(opt, "link", fmt.Sprintf("error during linking: %v", err))
+		return 2
+	}
+
+	cmd := exec.Command(exe, args...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = opt.Errors
+
+	err = cmd.Run()
+	if exit, ok := err.(*exec.ExitError); ok {
+		// follow the shell's convention for programs killed by a
+		// signal so that the caller can tell them apart.
+		if status, ok := exit.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+			return 128 + int(status.Signal())
+		}
+		return exit.ExitCode()
+	}
+	if err != nil {
+		ast.ReportError(opt, "run", fmt.Sprintf("error running program: %v", err))
+		return 2
+	}
+
+	return 0
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+const matchNullTestSource = `class Box(var v : Int) {
+	def get() : Int = v;
+}
+
+class Main() extends IO() {
+	def make() : Box = new Box(2);
+
+	def fresh() : Int = {
+		var b : Box = new Box(1);
+		b match { case x : Box => x.get() }
+	};
+
+	def reassigned() : Int = {
+		var b : Box = new Box(1);
+		b = make();
+		b match { case x : Box => x.get() }
+	};
+
+	def called() : Int =
+		make() match { case x : Box => x.get() };
+
+	{
+		out_any(fresh() + reassigned() + called())
+	};
+}
+`
+
+func TestWarnMatchNull(t *testing.T) {
+	// match-null is off by default.
+	if out, exit := runSource(t, matchNullTestSource, "-o", os.DevNull); exit != 0 || out != "" {
+		t.Errorf("unexpected output with default warnings (exit status %v):\n%s", exit, out)
+	}
+
+	out, exit := runSource(t, matchNullTestSource, "-o", os.DevNull, "-Wmatch-null")
+	if exit != 0 {
+		t.Errorf("exit status was unexpected: %v", exit)
+	}
+	// b in fresh is only ever assigned a new Box, so its match needs no
+	// case for null.
+	expected := []string{
+		"main.cool:16:5: warning: match has no case for null [-Wmatch-null]\n",
+		"main.cool:20:10: warning: match has no case for null [-Wmatch-null]\n",
+	}
+	for _, line := range expected {
+		if !strings.Contains(out, line) {
+			t.Errorf("missing warning: %q", line)
+		}
+	}
+	if n := strings.Count(out, "\n"); n != len(expected) {
+		t.Errorf("expected %d warnings, but the output was:\n%s", len(expected), out)
+	}
+}